@@ -3,10 +3,12 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"strings"
 
-	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/mcapix"
 	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
 	"github.com/spf13/cobra"
 )
 
@@ -15,71 +17,212 @@ var checkCmd = &cobra.Command{
 	Use:   "check",
 	Short: "Checks the given spreadsheet(s) for errors and reports the errors. No ETL is performed.",
 	Long: `The check command validates the given spreadsheets and reports any errors. It will not perform
-any ETL operations on the spreadsheets.`,
+any ETL operations on the spreadsheets. It also shows the type each column is loaded as, and a profile
+of the values in each attribute column that marks columns with blank, non-numeric or outlying values.
+Validator programs given with --validator check the worksheets against rules of your own. Use
+--format json for a single JSON document of the errors and warnings, with the worksheet, row and column
+of each, for programs that check spreadsheets.`,
+	Example: `  mcetl check -f heat-treatment.xlsx --has-parent
+  mcetl check -f heat-treatment.xlsx --has-parent -p <project-id> -k <apikey>
+  mcetl check -f heat-treatment.xlsx --has-parent --format json > check.json`,
 	Run: cliCmdCheck,
 }
 
 func init() {
 	rootCmd.AddCommand(checkCmd)
-	checkCmd.Flags().StringP("files", "f", "", "Path to the excel spreadsheet")
-	checkCmd.Flags().IntP("header-row", "r", 0, "Row to start reading from")
-	checkCmd.Flags().BoolP("has-parent", "t", false, "2nd column is the parent column")
+	addLoaderFlags(checkCmd)
+	addOutputFlag(checkCmd)
+	checkCmd.Flags().String("format", "text", `How to report what is found, "text" or "json" (a single JSON document of the errors and warnings)`)
 	checkCmd.Flags().StringP("project-id", "p", "", "Project to create experiment in")
 	checkCmd.Flags().StringP("mcurl", "u", "http://localhost:5016/api", "URL for the API service")
 	checkCmd.Flags().StringP("apikey", "k", "", "apikey to pass in REST API calls")
+	checkCmd.Flags().Bool("apikey-in-query", false, "Send the apikey as a query parameter rather than in the Authorization header, for older servers that reject the header")
+	checkCmd.Flags().String("file-index", "", "Cache file for the project file list (.json or .json.gz), downloaded if it doesn't exist")
+	checkCmd.Flags().Bool("refresh-file-index", false, "Download the project file list again even if the file index cache exists")
+	checkCmd.Flags().StringArray("validator", nil, `Program that checks the worksheets with rules of your own, a path or a JSON array of the program and its arguments such as '["python3", "rules.py"]'. Can be repeated, see mcetl examples`)
+	checkCmd.Flags().Duration("validator-timeout", spreadsheet.DefaultValidatorTimeout, "How long a validator can run before it is stopped")
 }
 
 func cliCmdCheck(cmd *cobra.Command, args []string) {
-	files, err := cmd.Flags().GetString("files")
-	if err != nil {
-		fmt.Print("error", err)
-		os.Exit(1)
+	if err := startOutput(cmd); err != nil {
+		exitCommand(1)
 	}
+	defer finishOutput(0)
 
-	headerRow, err := cmd.Flags().GetInt("header-row")
+	report, err := startCheckReport(cmd)
 	if err != nil {
-		fmt.Print("error", err)
-		os.Exit(1)
+		exitCommand(1)
 	}
 
-	hasParent, err := cmd.Flags().GetBool("has-parent")
+	loader, err := loaderFromFlags(cmd)
 	if err != nil {
-		fmt.Println("error", err)
-		os.Exit(1)
+		exitCommand(1)
 	}
 
-	loader := spreadsheet.NewLoader(hasParent, headerRow, strings.Split(files, ","))
+	// An older workbook can have an attribute name that ends in a type, see declared_types.go
+	loader.WarnDeclaredTypes = true
 
 	worksheets, err := loader.Load()
 	if err != nil {
-		fmt.Println("Loading spreadsheet failed")
-		if merr, ok := err.(*multierror.Error); ok {
-			for _, e := range merr.Errors {
-				fmt.Println(" ", e)
-			}
-		}
-		os.Exit(1)
+		printLoadErrors(cmd, loader, "Loading spreadsheet failed", err)
+		exitCommand(1)
 	}
+	report.setWorksheets(worksheets)
 
-	client, err := createAPIClient(cmd)
-	if err != nil {
-		// No API Client params were set
-		return
+	showColumnSummary(worksheets)
+	showColumnProfiles(worksheets)
+
+	// check has no normalize-units flag, so process attributes are compared as written
+	options := processor.WorkflowOptions{HasParent: loader.HasParent, SamplesSheet: loader.SamplesSheet}
+	if options.SkipOrphanSamples, err = cmd.Flags().GetBool("skip-orphan-samples"); err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	if err := checkDuplicateSamples(cmd, options, worksheets); err != nil {
+		exitCommand(1)
+	}
+
+	checkOrphanSamples(options, worksheets)
+
+	if err := runValidators(cmd, worksheets); err != nil {
+		exitCommand(1)
 	}
 
 	var projectID string
 	if projectID, err = cmd.Flags().GetString("project-id"); err != nil {
-		fmt.Println("error", err)
-		os.Exit(1)
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	// A cached file index lets the files be validated without any server calls
+	if loader.FileIndex, err = cachedFileIndex(cmd, projectID); err != nil {
+		exitCommand(1)
+	}
+
+	var client *mcapix.Client
+	if loader.FileIndex == nil {
+		if client, err = createAPIClient(cmd); err != nil {
+			// No API Client params were set
+			return
+		}
+
+		if loader.FileIndex, err = downloadFileIndex(cmd, client, projectID); err != nil {
+			exitCommand(1)
+		}
 	}
 
-	if client != nil && projectID != "" {
+	if loader.FileIndex != nil || (client != nil && projectID != "") {
 		if err := loader.ValidateFilesExistInProject(worksheets, projectID, client); err != nil {
-			if merr, ok := err.(*multierror.Error); ok {
-				for _, e := range merr.Errors {
-					fmt.Println(" ", e)
-				}
-			}
+			printLoadErrors(cmd, loader, "Files not found in project:", err)
 		}
 	}
 }
+
+// cachedFileIndex loads the file index cache given in the file-index flag. It returns nil if no cache
+// was given, it doesn't exist yet, a refresh was requested, or it is for a different project.
+func cachedFileIndex(cmd *cobra.Command, projectID string) (*spreadsheet.FileIndex, error) {
+	var (
+		cachePath string
+		refresh   bool
+		err       error
+	)
+
+	if cachePath, err = cmd.Flags().GetString("file-index"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if refresh, err = cmd.Flags().GetBool("refresh-file-index"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if cachePath == "" || refresh {
+		return nil, nil
+	}
+
+	if _, err := os.Stat(cachePath); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	index, err := spreadsheet.LoadFileIndex(cachePath)
+	if err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if projectID != "" && index.ProjectID != projectID {
+		fmt.Printf("File index %s is for project %s, downloading file index for project %s\n", cachePath, index.ProjectID, projectID)
+		return nil, nil
+	}
+
+	fmt.Printf("Using file index %s downloaded %s\n", cachePath, index.Downloaded.Format("2006-01-02 15:04"))
+	return index, nil
+}
+
+// downloadFileIndex downloads the project file index when a file-index cache was given and saves it to the
+// cache. It returns nil when no cache was given, in which case each file is checked with a separate call.
+func downloadFileIndex(cmd *cobra.Command, client *mcapix.Client, projectID string) (*spreadsheet.FileIndex, error) {
+	cachePath, err := cmd.Flags().GetString("file-index")
+	if err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if cachePath == "" || projectID == "" {
+		return nil, nil
+	}
+
+	index, err := spreadsheet.DownloadFileIndex(projectID, client)
+	if err != nil {
+		console.Error("Unable to download project file index:", err)
+		return nil, err
+	}
+
+	if err := index.Save(cachePath); err != nil {
+		console.Error("Unable to save file index:", err)
+		return nil, err
+	}
+
+	fmt.Printf("Saved file index with %d files to %s\n", len(index.Paths), cachePath)
+	return index, nil
+}
+
+// runValidators runs the validator programs given with the validator flag against the worksheets and
+// reports the errors they find.
+func runValidators(cmd *cobra.Command, worksheets []*model.Worksheet) error {
+	flags, err := cmd.Flags().GetStringArray("validator")
+	if err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if len(flags) == 0 {
+		return nil
+	}
+
+	timeout, err := cmd.Flags().GetDuration("validator-timeout")
+	if err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	var validators [][]string
+	for _, flag := range flags {
+		argv, err := spreadsheet.ParseValidator(flag)
+		if err != nil {
+			console.Error("error", err)
+			return err
+		}
+		validators = append(validators, argv)
+	}
+
+	if err := spreadsheet.RunValidators(validators, timeout, worksheets); err != nil {
+		printErrors(cmd, "Validators found errors:", err)
+		return err
+	}
+
+	fmt.Printf("%d validator(s) found no errors\n", len(validators))
+	return nil
+}
@@ -0,0 +1,180 @@
+package cmd
+
+/*
+ * check_report prints what check finds as a single JSON document when --format json is given, for web
+ * portals and other programs that check spreadsheets before they are submitted:
+ *
+ *   {"valid": false, "errors": 1, "warnings": 1, "findings": [
+ *     {"severity": "error", "worksheet": "SEM", "file": "campaign.xlsx", "row": 4, "column": "C",
+ *      "message": "worksheet SEM row 4 column C has unit 'mm' but the header Size(um) gives the unit 'um'"},
+ *     {"severity": "warning", "worksheet": "Cast", "message": "Worksheet Cast has no data rows, it won't be loaded ..."}]}
+ *
+ * The output is gathered like --output json's (see output.go), with each error and warning becoming a finding
+ * and the other messages left out. The worksheet, row and column are those the error or warning was printed
+ * with. When it wasn't printed with them they are taken from the message if it gives them, so they are left
+ * out of the findings whose messages don't. valid is true when check found no errors.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/spf13/cobra"
+)
+
+// checkFinding is an error or warning found by check.
+type checkFinding struct {
+	Severity  string `json:"severity"`
+	Worksheet string `json:"worksheet,omitempty"`
+	File      string `json:"file,omitempty"`
+	Row       int    `json:"row,omitempty"`
+	Column    string `json:"column,omitempty"`
+	Message   string `json:"message"`
+}
+
+// checkReport is what check found, see above.
+type checkReport struct {
+	Valid    bool            `json:"valid"`
+	Errors   int             `json:"errors"`
+	Warnings int             `json:"warnings"`
+	Findings []*checkFinding `json:"findings"`
+
+	// worksheets are the loaded worksheets, whose names are looked for in the messages. They are set
+	// while the messages are being gathered, so mu guards them.
+	mu         sync.Mutex
+	worksheets []*model.Worksheet
+}
+
+var (
+	findingRowRE    = regexp.MustCompile(`\brow (\d+)\b`)
+	findingColumnRE = regexp.MustCompile(`\bcolumn ([A-Z]+|\d+)\b`)
+	findingSheetRE  = regexp.MustCompile(`(?i)\b(?:work)?sheet (.+?) (?:row|column) `)
+	findingSourceRE = regexp.MustCompile(`(?i)\bworksheet (.+?) \(([^()]+)\)`)
+)
+
+// startCheckReport starts gathering the output into a report if --format json was given.
+func startCheckReport(cmd *cobra.Command) (*checkReport, error) {
+	format, err := cmd.Flags().GetString("format")
+	switch {
+	case err != nil:
+		console.Error("error", err)
+		return nil, err
+	case format == "text":
+		return nil, nil
+	case format != "json":
+		err := fmt.Errorf(`invalid --format '%s', must be "text" or "json"`, format)
+		console.Error("error", err)
+		return nil, err
+	case output != nil:
+		err := fmt.Errorf("use either --format json or --output json, not both")
+		console.Error("error", err)
+		return nil, err
+	}
+
+	report := &checkReport{Findings: []*checkFinding{}}
+	if err := pipeOutput(cmd, report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// setWorksheets gives the report the loaded worksheets, so it can find their names in the messages.
+func (r *checkReport) setWorksheets(worksheets []*model.Worksheet) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.worksheets = append([]*model.Worksheet(nil), worksheets...)
+	sort.SliceStable(r.worksheets, func(i, j int) bool {
+		return len(r.worksheets[i].Source()) > len(r.worksheets[j].Source())
+	})
+}
+
+// add adds an event to the report when it is an error or warning.
+func (r *checkReport) add(event outputEvent) {
+	finding := &checkFinding{Severity: event.Type, Message: strings.TrimSpace(event.Message)}
+	switch event.Type {
+	case "error":
+		r.Errors++
+		finding.Message = strings.TrimPrefix(finding.Message, "error ")
+	case "warning":
+		r.Warnings++
+		if strings.HasPrefix(strings.ToLower(finding.Message), "warning:") {
+			finding.Message = strings.TrimSpace(finding.Message[len("warning:"):])
+		}
+	default:
+		return
+	}
+
+	if event.Worksheet != "" || event.Row != 0 {
+		finding.Worksheet, finding.File, finding.Row, finding.Column = event.Worksheet, event.File, event.Row, event.Column
+	} else {
+		r.locate(finding)
+	}
+	r.Findings = append(r.Findings, finding)
+}
+
+// locate fills in where the finding is from its message.
+func (r *checkReport) locate(finding *checkFinding) {
+	message := finding.Message
+	if m := findingRowRE.FindStringSubmatch(message); m != nil {
+		finding.Row, _ = strconv.Atoi(m[1])
+	}
+
+	if m := findingColumnRE.FindStringSubmatch(message); m != nil {
+		if column, err := strconv.Atoi(m[1]); err == nil {
+			finding.Column = model.ColumnName(column)
+		} else {
+			finding.Column = m[1]
+		}
+	}
+
+	lower := strings.ToLower(message)
+	if i := strings.Index(lower, "worksheet "); i != -1 {
+		rest := message[i+len("worksheet "):]
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for _, worksheet := range r.worksheets {
+			for _, name := range []string{worksheet.Source(), worksheet.Name} {
+				if strings.HasPrefix(rest, name+" ") || strings.HasPrefix(rest, name+":") || rest == name {
+					finding.Worksheet = worksheet.Name
+					finding.File = worksheet.File
+					return
+				}
+			}
+		}
+	}
+
+	// The worksheet isn't known when loading it failed, or it wasn't loaded, so the name is whatever comes
+	// before its file or the row
+	if m := findingSourceRE.FindStringSubmatch(message); m != nil {
+		finding.Worksheet, finding.File = m[1], m[2]
+	} else if m := findingSheetRE.FindStringSubmatch(message); m != nil {
+		finding.Worksheet = m[1]
+	}
+}
+
+// print prints the report for check exiting with status.
+func (r *checkReport) print(w io.Writer, status int) {
+	r.Valid = status == 0 && r.Errors == 0
+	contents, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		fmt.Fprintln(w, "Unable to write check report:", err)
+		return
+	}
+
+	fmt.Fprintf(w, "%s\n", contents)
+}
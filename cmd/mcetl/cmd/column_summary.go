@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// showColumnSummary prints the header row of each worksheet, and the preamble rows before it, and a
+// table of the type each column was loaded as and why. Columns that defaulted to sample attributes
+// because they have no keyword, and columns with an unknown keyword, are marked with a ! as they are
+// the usual cause of a column being loaded as the wrong type.
+func showColumnSummary(worksheets []*model.Worksheet) {
+	suspect := 0
+	for _, worksheet := range worksheets {
+		fmt.Println("Columns in worksheet", worksheet.Source())
+		fmt.Printf("Header row %d", worksheet.HeaderRow)
+		if len(worksheet.Preamble) != 0 {
+			fmt.Printf(", the %d row(s) before it aren't loaded:", len(worksheet.Preamble))
+		}
+		fmt.Println()
+		for i, row := range worksheet.Preamble {
+			fmt.Printf("  Row %d: %s\n", i+1, model.PreambleText(row))
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\tCOLUMN\tHEADER\tTYPE\tREASON")
+		for _, summary := range worksheet.Columns {
+			mark := ""
+			if summary.Suspect {
+				mark = "!"
+				suspect++
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", mark, model.ColumnName(summary.Column), summary.Header, summary.Type, summary.Reason)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	if suspect != 0 {
+		console.Warningf("%d column(s) marked with ! have no keyword or an unknown keyword, check they have the right type\n", suspect)
+	}
+}
+
+// maxShownOutliers is the number of outliers shown for each column
+const maxShownOutliers = 3
+
+// showColumnProfiles prints a table for each worksheet of the blank and numeric cells, range, number of
+// distinct values and outliers in each attribute column. Columns whose values look wrong are marked with a !.
+func showColumnProfiles(worksheets []*model.Worksheet) {
+	suspect := 0
+	for _, worksheet := range worksheets {
+		profiles := spreadsheet.ProfileColumns(worksheet)
+		if len(profiles) == 0 {
+			continue
+		}
+
+		fmt.Println("Values in worksheet", worksheet.Source())
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\tCOLUMN\tHEADER\tBLANK\tNUMERIC\tMIN\tMAX\tDISTINCT\tOUTLIERS")
+		for _, profile := range profiles {
+			mark := ""
+			if profile.Suspect != "" {
+				mark = "!"
+				suspect++
+			}
+
+			min, max := "", ""
+			if profile.Numeric != 0 {
+				min, max = formatProfileNumber(profile.Min), formatProfileNumber(profile.Max)
+			}
+
+			var outliers []string
+			for i, outlier := range profile.Outliers {
+				if i == maxShownOutliers {
+					outliers = append(outliers, fmt.Sprintf("and %d more", len(profile.Outliers)-i))
+					break
+				}
+				outliers = append(outliers, fmt.Sprintf("row %d (%s)", outlier.Row, formatProfileNumber(outlier.Value)))
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", mark, model.ColumnName(profile.Column), profile.Header,
+				percent(profile.Blank, profile.Rows), percent(profile.Numeric, profile.Rows-profile.Blank), min, max,
+				profile.Distinct, strings.Join(outliers, ", "))
+		}
+		w.Flush()
+
+		for _, profile := range profiles {
+			if profile.Suspect != "" {
+				fmt.Printf("  ! Column %s %s: %s\n", model.ColumnName(profile.Column), profile.Header, profile.Suspect)
+			}
+		}
+		fmt.Println()
+	}
+
+	if suspect != 0 {
+		console.Warningf("%d column(s) marked with ! have values that look wrong, check them before loading\n", suspect)
+	}
+}
+
+// percent returns n as a percentage of total.
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%d%%", int(math.Round(float64(n)*100/float64(total))))
+}
+
+// formatProfileNumber formats a number in a column profile compactly.
+func formatProfileNumber(n float64) string {
+	return strconv.FormatFloat(n, 'g', 6, 64)
+}
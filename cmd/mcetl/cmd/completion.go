@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// completionCmd represents the completion command
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generates shell completion scripts for mcetl.",
+	Long: `The completion command writes a shell completion script for mcetl to stdout. Load it into
+your shell to get completion of the mcetl subcommands and their flags.`,
+	Example: `  # bash, load completions for the current shell
+  source <(mcetl completion bash)
+
+  # zsh, write the completions to a directory on your $fpath
+  mcetl completion zsh > "${fpath[1]}/_mcetl"
+
+  # fish
+  mcetl completion fish > ~/.config/fish/completions/mcetl.fish
+
+  # powershell
+  mcetl completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+	Args:      cobra.ExactArgs(1),
+	Run:       cliCmdCompletion,
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
+
+func cliCmdCompletion(cmd *cobra.Command, args []string) {
+	var err error
+
+	switch args[0] {
+	case "bash":
+		err = rootCmd.GenBashCompletion(os.Stdout)
+	case "zsh":
+		err = rootCmd.GenZshCompletion(os.Stdout)
+	case "fish":
+		err = genFishCompletion(os.Stdout, rootCmd)
+	case "powershell":
+		err = rootCmd.GenPowerShellCompletion(os.Stdout)
+	default:
+		err = fmt.Errorf("unknown shell '%s', must be one of bash, zsh, fish or powershell", args[0])
+	}
+
+	if err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+}
+
+// genFishCompletion writes a fish completion script for the root command. The version of cobra
+// that mcetl uses doesn't have fish support so the script is generated here. Each subcommand is
+// completed when no subcommand has been given yet, and each subcommand's flags are completed
+// once that subcommand has been seen on the command line.
+func genFishCompletion(w io.Writer, root *cobra.Command) error {
+	name := root.Name()
+	if _, err := fmt.Fprintf(w, "# fish completion for %s\n", name); err != nil {
+		return err
+	}
+
+	for _, c := range root.Commands() {
+		if !c.IsAvailableCommand() {
+			continue
+		}
+
+		if _, err := fmt.Fprintf(w, "complete -c %s -f -n '__fish_use_subcommand' -a %s -d '%s'\n",
+			name, c.Name(), fishEscape(c.Short)); err != nil {
+			return err
+		}
+
+		var err error
+		c.NonInheritedFlags().VisitAll(func(flag *pflag.Flag) {
+			if err != nil || flag.Hidden {
+				return
+			}
+
+			line := fmt.Sprintf("complete -c %s -n '__fish_seen_subcommand_from %s' -l %s", name, c.Name(), flag.Name)
+			if flag.Shorthand != "" {
+				line = fmt.Sprintf("%s -s %s", line, flag.Shorthand)
+			}
+
+			if flag.Value.Type() != "bool" {
+				// Flag takes an argument
+				line = line + " -r"
+			}
+
+			_, err = fmt.Fprintf(w, "%s -d '%s'\n", line, fishEscape(flag.Usage))
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, arg := range c.ValidArgs {
+			if _, err := fmt.Fprintf(w, "complete -c %s -f -n '__fish_seen_subcommand_from %s' -a %s\n",
+				name, c.Name(), arg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fishEscape escapes single quotes so a description can be placed in a single quoted fish string.
+func fishEscape(s string) string {
+	return strings.Replace(s, "'", "\\'", -1)
+}
@@ -2,11 +2,11 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"strings"
 
-	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/console"
 	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
 	"github.com/spf13/cobra"
 )
 
@@ -16,50 +16,136 @@ var displayCmd = &cobra.Command{
 	Short: "Displays what the spreadsheet would look like. No ETL is performed.",
 	Long: `The display command validates the given spreadsheets, reports any errors and textually displays workflow. It will not perform
 any ETL operations on the spreadsheets.`,
+	Example: `  mcetl display -f heat-treatment.xlsx --has-parent
+  mcetl display -f casting.xlsx,rolling.xlsx -t -r 2
+  mcetl display -f heat-treatment.xlsx --has-parent --lineage
+  mcetl display -f heat-treatment.xlsx --process-diff
+  mcetl display -f heat-treatment.xlsx --coverage --coverage-csv coverage.csv
+  mcetl display -f campaign.xlsx --has-parent --graph campaign.svg`,
 	Run: cliCmdDisplay,
 }
 
 func init() {
 	rootCmd.AddCommand(displayCmd)
-	displayCmd.Flags().StringP("files", "f", "", "Path to the excel spreadsheet")
-	displayCmd.Flags().IntP("header-row", "r", 0, "Row to start reading from")
-	displayCmd.Flags().BoolP("has-parent", "t", false, "2nd column is the parent column")
+	addLoaderFlags(displayCmd)
+	addOutputFlag(displayCmd)
+	displayCmd.Flags().String("create-process-name", "", "Name to give the Create Samples processes")
+	displayCmd.Flags().String("genealogy", "", "Write the sample genealogy as a CSV edge list to this file")
+	displayCmd.Flags().String("graph", "", "Write the workflow as a Graphviz DOT graph to this file, or as SVG if it ends in .svg (needs Graphviz)")
+	displayCmd.Flags().Bool("process-diff", false, "Show the processes created from each worksheet and the attribute values that differ between them")
+	displayCmd.Flags().Bool("coverage", false, "Show a matrix of the samples against the attributes in each worksheet, marking the missing values")
+	displayCmd.Flags().String("coverage-csv", "", "Write the matrix of samples against attributes, 1 for a value and 0 for a blank, to this CSV file")
+	displayCmd.Flags().Bool("lineage", false, "Show the property sets each sample would accumulate as it moves through the workflow")
+	displayCmd.Flags().Bool("process-name-attrs", false, "Include the process attribute values that differ between processes from the same worksheet in their names")
+	displayCmd.Flags().Bool("normalize-units", false, "Convert values to common units when deciding if process attributes are the same")
 }
 
 func cliCmdDisplay(cmd *cobra.Command, args []string) {
-	files, err := cmd.Flags().GetString("files")
+	if err := startOutput(cmd); err != nil {
+		exitCommand(1)
+	}
+	defer finishOutput(0)
+
+	loader, err := loaderFromFlags(cmd)
 	if err != nil {
-		fmt.Print("error", err)
-		os.Exit(1)
+		exitCommand(1)
 	}
 
-	headerRow, err := cmd.Flags().GetInt("header-row")
+	worksheets, err := loader.Load()
 	if err != nil {
-		fmt.Print("error", err)
-		os.Exit(1)
+		printLoadErrors(cmd, loader, "Loading spreadsheet failed", err)
+		exitCommand(1)
 	}
 
-	hasParent, err := cmd.Flags().GetBool("has-parent")
+	options, err := workflowOptionsFromFlags(cmd)
 	if err != nil {
-		fmt.Println("error", err)
-		os.Exit(1)
+		exitCommand(1)
 	}
 
-	loader := spreadsheet.NewLoader(hasParent, headerRow, strings.Split(files, ","))
+	if err := checkDuplicateSamples(cmd, options, worksheets); err != nil {
+		exitCommand(1)
+	}
 
-	worksheets, err := loader.Load()
+	checkOrphanSamples(options, worksheets)
+
+	lineage, err := cmd.Flags().GetBool("lineage")
+	if err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	processDiff, err := cmd.Flags().GetBool("process-diff")
 	if err != nil {
-		fmt.Println("Loading spreadsheet failed")
-		if merr, ok := err.(*multierror.Error); ok {
-			for _, e := range merr.Errors {
-				fmt.Println(" ", e)
-			}
-		}
-		os.Exit(1)
+		console.Error("error", err)
+		exitCommand(1)
 	}
 
+	coverage, err := cmd.Flags().GetBool("coverage")
+	if err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	spreadsheet.Display.WorkflowOptions = options
+	spreadsheet.Display.Lineage = lineage
+	spreadsheet.Display.ProcessDiff = processDiff
+	spreadsheet.Display.Coverage = coverage
 	if err := spreadsheet.Display.Apply(worksheets); err != nil {
-		fmt.Println("Unable to process spreadsheet:", err)
-		os.Exit(1)
+		console.Error("Unable to process spreadsheet:", err)
+		exitCommand(1)
+	}
+
+	if err := writeGenealogy(cmd, options, worksheets); err != nil {
+		exitCommand(1)
+	}
+
+	if err := writeCoverage(cmd, worksheets); err != nil {
+		exitCommand(1)
+	}
+
+	if err := writeGraph(cmd, options, worksheets); err != nil {
+		exitCommand(1)
 	}
 }
+
+// writeGraph writes the workflow as a graph to the file given by --graph, if it was given.
+func writeGraph(cmd *cobra.Command, options processor.WorkflowOptions, worksheets []*model.Worksheet) error {
+	path, err := cmd.Flags().GetString("graph")
+	if err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	if err := spreadsheet.Graph(path, options).Apply(worksheets); err != nil {
+		console.Error("Unable to write graph:", err)
+		return err
+	}
+
+	fmt.Println("Wrote workflow graph to", path)
+	return nil
+}
+
+// writeCoverage writes the coverage matrix of the worksheets to the file given by --coverage-csv,
+// if it was given.
+func writeCoverage(cmd *cobra.Command, worksheets []*model.Worksheet) error {
+	path, err := cmd.Flags().GetString("coverage-csv")
+	if err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	if err := spreadsheet.Coverage(path).Apply(worksheets); err != nil {
+		console.Error("Unable to write coverage:", err)
+		return err
+	}
+
+	return nil
+}
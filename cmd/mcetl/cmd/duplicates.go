@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+	"github.com/spf13/cobra"
+)
+
+// checkDuplicateSamples reports samples that are on several rows of a worksheet with the same process
+// attributes but different sample attribute values. With the duplicate-samples flag set to replicates
+// they are warnings and the values are loaded as replicate measurements, set to error they are errors.
+func checkDuplicateSamples(cmd *cobra.Command, options processor.WorkflowOptions, worksheets []*model.Worksheet) error {
+	policy, err := cmd.Flags().GetString("duplicate-samples")
+	if err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if err := processor.ValidDuplicatesPolicy(policy); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	err = spreadsheet.Duplicates(options).Apply(worksheets)
+	if err == nil {
+		return nil
+	}
+
+	if policy == processor.DuplicatesAsErrors {
+		printErrors(cmd, "Samples with conflicting values:", err)
+		return err
+	}
+
+	if merr, ok := err.(*multierror.Error); ok {
+		for _, e := range merr.Errors {
+			console.Warningf("%s, the values will be loaded as replicate measurements\n", e)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/credentials"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/spf13/cobra"
+)
+
+// addErrorFlags adds the flags that control how errors found in the spreadsheets are reported.
+func addErrorFlags(c *cobra.Command) {
+	c.Flags().Int("max-errors", 0, "Stop after this many errors, 0 means no limit")
+	c.Flags().String("errors-file", "", "Write the errors to this file instead of the terminal")
+}
+
+// printErrors reports the errors in err under the heading. A multierror is reported one error per line
+// and any apikeys in the errors are redacted.
+// If the errors-file flag was given the errors are written to that file and only a summary is printed.
+// With JSON output each error is an error event, which gives the cell of an error in a cell.
+func printErrors(cmd *cobra.Command, heading string, err error) {
+	var errs []error
+	if merr, ok := err.(*multierror.Error); ok {
+		errs = merr.Errors
+	} else {
+		errs = []error{err}
+	}
+
+	fmt.Println(heading)
+
+	errorsFile, _ := cmd.Flags().GetString("errors-file")
+	if errorsFile != "" {
+		var lines []string
+		for _, e := range errs {
+			lines = append(lines, credentials.Redact(e.Error()))
+		}
+
+		if writeErr := ioutil.WriteFile(errorsFile, []byte(strings.Join(lines, "\n")+"\n"), 0644); writeErr != nil {
+			console.Error("Unable to write errors file:", writeErr)
+		} else {
+			fmt.Printf("  %d error(s) written to %s\n", len(errs), errorsFile)
+		}
+	} else if output != nil {
+		for _, e := range errs {
+			event := console.Event{Type: console.TypeError, Message: credentials.Redact(e.Error())}
+			var cellErr *spreadsheet.CellError
+			if errors.As(e, &cellErr) {
+				event.Worksheet, event.File, event.Row, event.Column = cellErr.Worksheet, cellErr.File, cellErr.Row, cellErr.Column
+			}
+			console.Print(event)
+		}
+	} else {
+		for _, e := range errs {
+			fmt.Println(" ", credentials.Redact(e.Error()))
+		}
+	}
+}
+
+// printLoadErrors reports the errors the loader found, as printErrors does, and says so when it stopped
+// at --max-errors with some of the spreadsheets left unread.
+func printLoadErrors(cmd *cobra.Command, loader *spreadsheet.Loader, heading string, err error) {
+	printErrors(cmd, heading, err)
+	if loader.StoppedAtMaxErrors {
+		fmt.Printf("Stopped after %d errors, there may be more. Fix these and check again, or raise --max-errors.\n", loader.MaxErrors)
+	}
+}
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// examplesCmd represents the examples command
+var examplesCmd = &cobra.Command{
+	Use:   "examples",
+	Short: "Shows worked examples of the mcetl commands and a sample spreadsheet.",
+	Long: `The examples command prints a small sample spreadsheet and complete command lines for checking,
+displaying and loading it. It is a quick reference for the spreadsheet format.`,
+	Run: cliCmdExamples,
+}
+
+func init() {
+	rootCmd.AddCommand(examplesCmd)
+}
+
+const examplesText = `A spreadsheet contains one worksheet per process. The worksheet name is the process name.
+Row 1 is the header row, column 1 is the sample name and, when --has-parent is given, column 2
+is the worksheet the sample came from. The remaining header cells use a keyword to identify
+the type of each column:
+    p: (process)  process attribute, unique values create separate processes
+    s: (sample)   sample attribute, stored as a measurement on the sample (the default)
+    f: (file)     file in the project to associate with the process, f:description:directory
+    file-in:      a file the process used, file: columns are inputs unless --file-direction-by-position
+    file-out:     a file the process produced, eg the images from an SEM
+    i: (ignore)   column is ignored, "note" and "notes" are also ignored
+    date:         when the process was performed (as does p:Date), used as the process timestamp
+
+Units are given in parenthesis after the attribute name, for example p:Temperature(c).
+
+When --has-parent isn't given, worksheets whose column 2 holds the names of other worksheets are reported,
+as the parent names would otherwise be loaded as a sample attribute. With --detect-parent those worksheets
+are loaded as if they had --has-parent.
+
+Templates that put the units in the row under the header, eg |C|h| under |p:Temperature|p:Time|, are
+loaded with --units-row, which adds those units to the headers rather than reading the row as a sample.
+
+Conditions that are the same for every sample on a worksheet can be written once, in a row under the
+header whose first cell is const:, as name=value cells, eg |const: |Instrument=SEM-1 |WD(mm)=10 |. Each
+is added to every sample as a process attribute.
+
+A sample that goes through the same process more than once, eg two anneals, has a row for each with a
+step: column numbering them, eg |S1|1|400| and |S1|2|550| under |sample|step:|p:Temperature(C)|. The
+second anneal takes S1 from the first rather than both taking it from S1's parent.
+
+A load can be split into an experiment per batch. Give the batch of each sample in a cohort: column, eg
+|cohort:Batch|, and load with --experiment-per-cohort. The samples in batch B1 are loaded into the
+experiment "<experiment name> - B1", and samples without a batch into the experiment itself.
+
+Processes and measurements can be loaded for samples already in the project, such as specimens that were
+registered earlier. Give the ID of the project's sample in an mcid: column, eg |S1|5e3f0a2b...|400| under
+|sample|mcid:|p:Temperature(C)|. S1 isn't created, the project's sample goes into the processes instead.
+
+Attributes kept on a reference worksheet, such as recipes, can be pulled in by key. Name the worksheet
+with --lookup-sheets Recipes, and give the steps a lookup:Recipes column whose cells hold the key in the
+first column of Recipes, eg R1, or a formula that shows it, eg =Recipes!A2. The attributes of the entry
+are added to the process. The lookup worksheets aren't loaded as processes.
+
+A worksheet that only measures the samples, such as hardness tests, can add its measurements to the
+samples its parent worksheet produced rather than being a process of its own. Load with
+--measurement-sheets Hardness, or yes in the measurement column of an index sheet, and give Hardness a
+parent column, eg |S1|Heat Treatment|320| under |sample|parent|s:Hardness(HV)|. S1's hardness is measured
+on the sample from the heat treatment, and worksheets whose parent is Hardness follow the heat treatment.
+
+The settings for loading a workbook can be kept in the workbook. With --index-sheet the first worksheet
+is an index with a row for each worksheet, eg
+    |sheet          |header row |has parent |template       |skip |
+    |Heat Treatment |2          |no         |heat-treatment |     |
+    |Notes          |           |           |               |yes  |
+A blank cell uses the command line setting. The template is the process template the worksheet's
+processes are created from, the default is the worksheet's name.
+
+Cell text is cleaned up before it is read: no-break and other unicode spaces become ordinary spaces and
+zero width characters are removed. The cells changed are reported. --normalize-text unicode also turns
+smart quotes and dashes into their keyboard forms, and full width characters such as the colon in a
+pasted p：Temperature header into ordinary ones, which changes the data cells too (eg ㎏ becomes kg and
+² becomes 2). Use --normalize-text none to read cells as they are.
+
+Example workbook heat-treatment.xlsx:
+
+  Worksheet "Heat Treatment"
+    |sample |parent |p:Time(s) |p:Temperature(c) |s:Hardness(HV) |f:Micrographs:ht/images |
+    |S1     |       |300       |400              |102            |s1.tif                  |
+    |S2     |       |300       |400              |98             |s2.tif                  |
+    |S3     |       |500       |50               |120            |                        |
+
+  Worksheet "SEM"
+    |sample |parent         |p:Voltage(kV) |s:Grain Size(um) |
+    |S1     |Heat Treatment |20            |2.5              |
+    |S3     |Heat Treatment |20            |4.1              |
+
+Heat Treatment creates 2 processes (S1 and S2 share the Time/Temperature values), then S1 and S3
+each move on to the SEM process. A parent can also be a process that is already on the server, written
+mc:process/<id>, which continues that process's output sample of the same name.
+A parent can also be a chain of the worksheets a sample went through, eg "Casting > Rolling", for a sample
+that the worksheets in between don't list. It is sent through a process on each of them so its lineage is
+complete.
+
+Start a new workbook with a worksheet for each process, with the headers laid out as above and, with
+--from-server, each worksheet named after the server's process template for it:
+  mcetl template -o heat-treatment.xlsx --processes "Casting,Heat Treatment,SEM" --from-server -k <apikey>
+
+Check the workbook for errors, including that the referenced files exist in the project:
+  mcetl check -f heat-treatment.xlsx --has-parent -p <project-id> -k <apikey>
+
+Display the worksheets and the workflow that would be created:
+  mcetl display -f heat-treatment.xlsx --has-parent
+
+Review the columns and workflow interactively, changing column types or skipping worksheets, then load:
+  mcetl tui -f heat-treatment.xlsx --has-parent -p <project-id> -n "Heat Treatment Study"
+
+Load the workbook into a new experiment, looking for files under the data/run1 project directory:
+  mcetl load -f heat-treatment.xlsx --has-parent -p <project-id> -n "Heat Treatment Study" \
+      -d data/run1 -k <apikey>
+
+Load several workbooks into one experiment, skipping 2 preamble rows before the header row:
+  mcetl load -f casting.xlsx,rolling.xlsx --has-parent -r 2 -p <project-id> -n "Campaign 1"
+
+CSV and TSV files exported by instruments can be loaded too, each one is a worksheet named after the file:
+  mcetl load -f casting.xlsx,hardness.csv --has-parent -p <project-id> -n "Campaign 1"
+Their encoding is detected, give it when the detection gets it wrong:
+  mcetl display -f hardness.csv --encoding windows-1252
+
+The workbooks can also be given as a zip. A manifest.txt in the zip lists the workbooks to load, one
+per line in the order they are loaded, otherwise all of them are loaded in name order:
+  mcetl load -f campaign.zip --has-parent -p <project-id> -n "Campaign 1"
+
+Load a later workbook into the same project, using the samples it already has rather than creating
+samples with the same names again ("rename" creates them with new names, "error" stops the load):
+  mcetl load -f batch2.xlsx --has-parent -p <project-id> -n "Batch 2" --existing-samples reuse
+
+Write a workbook of the workflow a load wrote to a bundle with --bundle-dir, after the options were applied:
+  mcetl load -f heat-treatment.xlsx --has-parent -p <project-id> -n "Heat Treatment Study" --bundle-dir ht-bundle
+  mcetl reconstruct -b ht-bundle -o ht-copy.xlsx
+
+Keep a record of what was created in the workbook itself. A copy of each workbook is written to the
+directory with an MC Summary worksheet of the sample and process IDs created for each row:
+  mcetl load -f heat-treatment.xlsx --has-parent -p <project-id> -n "Heat Treatment Study" --summary-dir loaded
+
+Worksheets with different length preambles can have their own header row, or it can be detected:
+  mcetl display -f study.xlsx --has-parent -r "SEM=3,Casting=1"
+  mcetl display -f study.xlsx --has-parent -r auto
+
+Round the noise off instrument values, or map categorical values such as pass and fail to the values they
+stand for, before they are stored, with a rule for the column in a schema file:
+  columns:
+    - name: Hardness
+      significant_figures: 3
+    - name: Inspection
+      value_map: {pass: true, fail: false}
+  mcetl load -f hardness.xlsx --has-parent --schema rules.yaml -p <project-id> -n "Hardness Survey"
+
+Compute attributes such as a density from the other attributes of each row, rather than with a formula
+column, with a derived rule in a schema file:
+  derived:
+    - name: Density
+      unit: g/cm^3
+      expression: Mass / Volume
+  mcetl display -f samples.xlsx --schema rules.yaml
+
+Load only some of the samples, with every row they are on, to correct them without loading the whole
+campaign again (--samples-file reads the names from a file, one per line):
+  mcetl load -f campaign.xlsx --has-parent --samples S1,S2,S17 -p <project-id> -n "Campaign Corrections"
+
+Keep a state file while loading so a load that fails part way through can be run again and carry on from
+where it stopped, in the same experiment, rather than starting over:
+  mcetl load -f campaign.xlsx --has-parent -p <project-id> -n "Campaign 1" --state-file campaign.state
+
+Create up to 8 steps of the workflow at once for a large load, each step still waits for the steps that
+send samples into it:
+  mcetl load -f campaign.xlsx --has-parent -p <project-id> -n "Campaign 1" --concurrency 8
+
+Check the worksheets with rules of your own lab. Each validator program is given the worksheets as JSON
+on its stdin and writes a JSON object per problem to its stdout, eg
+{"severity": "error", "worksheet": "SEM", "row": 4, "message": "S3 has no .tif image"}. Give a program
+with arguments as a JSON array, and a validator that takes longer than --validator-timeout is stopped:
+  mcetl check -f campaign.xlsx --has-parent --validator '["python3", "sem_rules.py"]' --validator ./hardness-check
+
+Check a spreadsheet for a program, such as a web portal, printing a single JSON document of the errors
+and warnings with the worksheet, row and column of each, and whether the spreadsheet is valid:
+  mcetl check -f campaign.xlsx --has-parent --format json
+
+Draw the workflow as a graph, to review a large workflow, as a Graphviz DOT file or, when Graphviz is
+installed, as SVG:
+  mcetl display -f campaign.xlsx --has-parent --graph campaign.svg
+
+Store the dates typed in a lab's own format, here day first, as ISO-8601 (YYYY-MM-DD). Cells Excel shows as
+dates, and unambiguous dates such as 21 Mar 2019, are always converted:
+  mcetl load -f campaign.xlsx -p <project-id> -n Campaign --date-formats "DD/MM/YYYY;DD/MM/YYYY hh:mm"
+
+Load the worksheets that only list samples, such as a polishing step that is always done the same way, as
+steps without attributes, rather than leaving them out with a warning:
+  mcetl load -f campaign.xlsx -p <project-id> -n Campaign --has-parent --attributeless-sheets associate
+
+Declare the type of a column's values after its header, one of string, int, float, bool, date or json, so
+that a lot number such as 0042 stays text and a cell that isn't a number in a float column is an error:
+  | Sample | p:Temperature(c):float | p:Lot:string | s:Passed:bool |
+A header whose name already ends in a type, such as s:Status:bool, is warned about by check, add :auto to
+keep the name and have each cell's type guessed, eg s:Status:bool:auto.
+
+Use a group's own header keywords, such as cond: for process attributes and meas: for sample attributes,
+from a YAML or JSON file (with replace: true they replace the defaults rather than being added to them):
+  mcetl check -f campaign.xlsx --keywords-file keywords.yaml
+
+Pass the files on a sample's row, such as the images from an SEM, on to the next process the sample goes
+through as its input files, rather than listing them again on the next worksheet:
+  mcetl load -f campaign.xlsx -p <project-id> -n Campaign --has-parent --inherit-files
+
+A cell that isn't a number in a column of numbers, such as 12,5 or 1O0 (a letter O), is warned about as
+a probable typo. Convert such cells to numbers where they can be, or leave them out, rather than loading
+them as text:
+  mcetl load -f campaign.xlsx -p <project-id> -n Campaign --has-parent --minority-cells coerce
+
+Print the messages of load, check or display as JSON events, one per line, for a program running mcetl:
+  mcetl load -f heat-treatment.xlsx --has-parent -p <project-id> -n "Heat Treatment Study" --output json
+
+The mcurl and apikey can also be set in the mcurl and apikey environment variables. To avoid
+putting the apikey in your shell history, store it once with "mcetl login" (it is kept in the OS
+keyring, or $HOME/.materialscommons/config.json when there is none) and leave off -k:
+  mcetl login          (paste the apikey at the prompt)
+  mcetl logout
+`
+
+func cliCmdExamples(cmd *cobra.Command, args []string) {
+	fmt.Print(examplesText)
+}
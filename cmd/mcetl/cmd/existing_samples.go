@@ -0,0 +1,185 @@
+package cmd
+
+/*
+ * existing_samples checks the project for samples with the same names as the samples a load creates, for
+ * projects that workbooks are loaded into over time. Without a check a second workbook that mentions S1
+ * creates another S1. --existing-samples chooses what happens to a sample whose name is already taken:
+ *
+ *   reuse   the sample in the project is used, it goes into the processes on the worksheets
+ *   rename  the sample is created with the next free name, eg S1 (2), on every worksheet it is on
+ *   error   the load stops and lists the samples
+ *
+ * Names are compared exactly. A reused sample's Create Samples attributes, from a samples sheet, aren't
+ * loaded since the sample isn't created, the load warns about them. Samples given the ID of a sample on the server in an mcid: column
+ * (see spreadsheet/sample_ids.go) are that sample whatever their name, so they aren't checked.
+ */
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/mcapix"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// The --existing-samples policies
+const (
+	existingSamplesReuse  = "reuse"
+	existingSamplesRename = "rename"
+	existingSamplesError  = "error"
+)
+
+// checkExistingSamples applies the --existing-samples policy to the samples that loading the worksheets
+// creates. It returns the samples to reuse, by name, which is nil unless the policy is reuse. The samples
+// with the resumed IDs were created by an earlier run of the load, they aren't in the way.
+func checkExistingSamples(cmd *cobra.Command, client *mcapix.Client, projectID string, options processor.WorkflowOptions,
+	worksheets []*model.Worksheet, resumed map[string]bool) (map[string]*mcapi.Sample, error) {
+	policy, err := cmd.Flags().GetString("existing-samples")
+	switch {
+	case err != nil:
+		console.Error("error", err)
+		return nil, err
+	case policy == "":
+		return nil, nil
+	case policy != existingSamplesReuse && policy != existingSamplesRename && policy != existingSamplesError:
+		err := errors.Errorf(`invalid --existing-samples '%s', must be "reuse", "rename" or "error"`, policy)
+		console.Error("error", err)
+		return nil, err
+	}
+
+	project, err := cachingClient(cmd, client).GetProjectOverview(projectID)
+	if err != nil {
+		console.Error("Unable to retrieve project's samples to check for samples with the same names:", err)
+		return nil, err
+	}
+
+	byName := make(map[string]*mcapi.Sample)
+	for _, sample := range project.Samples {
+		if _, ok := byName[sample.Name]; !ok && !resumed[sample.ID] {
+			byName[sample.Name] = sample
+		}
+	}
+
+	linked, _ := spreadsheet.SampleIDs(worksheets)
+
+	var taken []string
+	for _, name := range processor.SamplesToCreate(options, worksheets) {
+		if byName[name] != nil && linked[name] == "" {
+			taken = append(taken, name)
+		}
+	}
+
+	if len(taken) == 0 {
+		return nil, nil
+	}
+
+	switch policy {
+	case existingSamplesReuse:
+		reuse := make(map[string]*mcapi.Sample)
+		for _, name := range taken {
+			reuse[name] = byName[name]
+		}
+		fmt.Printf("Using %d sample(s) already in the project: %s\n", len(taken), strings.Join(taken, ", "))
+		return reuse, nil
+
+	case existingSamplesRename:
+		renames := renamesForExistingSamples(taken, byName, worksheets)
+		spreadsheet.RenameSamples(worksheets, renames)
+		for _, name := range taken {
+			fmt.Printf("Sample %s is already in the project, it is created as %s\n", name, renames[name])
+		}
+		return nil, nil
+
+	default:
+		err := errors.Errorf("%d sample(s) are already in the project: %s, use --existing-samples reuse or rename to load them",
+			len(taken), strings.Join(taken, ", "))
+		console.Error("error", err)
+		return nil, err
+	}
+}
+
+// linkedSamples returns the samples on the server that the samples given an ID in an mcid: column are, by
+// the samples' names on the worksheets. The server's samples are named as they are on the worksheets, as
+// the workflow finds samples by their names. The IDs are looked up in the project's samples, the creater
+// reads the property set each sample is in when the workflow is created (see processor/existing_samples.go).
+func linkedSamples(cmd *cobra.Command, client *mcapix.Client, projectID string, worksheets []*model.Worksheet) (map[string]*mcapi.Sample, error) {
+	ids, err := spreadsheet.SampleIDs(worksheets)
+	if err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	project, err := cachingClient(cmd, client).GetProjectOverview(projectID)
+	if err != nil {
+		console.Error("Unable to retrieve project's samples:", err)
+		return nil, err
+	}
+
+	byID := make(map[string]*mcapi.Sample)
+	for _, sample := range project.Samples {
+		byID[sample.ID] = sample
+	}
+
+	var names []string
+	for name := range ids {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	linked := make(map[string]*mcapi.Sample)
+	for _, name := range names {
+		sample := byID[ids[name]]
+		if sample == nil {
+			err := errors.Errorf("sample %s has mcid %s, which isn't a sample in the project", name, ids[name])
+			console.Error("error", err)
+			return nil, err
+		}
+
+		if sample.Name != name {
+			fmt.Printf("Sample %s is the project's sample %s (%s)\n", name, sample.Name, sample.ID)
+		}
+
+		s := *sample
+		s.Name = name
+		linked[name] = &s
+	}
+
+	fmt.Printf("Using %d sample(s) given by mcid that are already in the project\n", len(linked))
+	return linked, nil
+}
+
+// renamesForExistingSamples returns the new name for each of the taken sample names. The new name is the
+// name with the first number from 2 that is neither in the project nor on the worksheets, eg S1 (2).
+func renamesForExistingSamples(taken []string, projectSamples map[string]*mcapi.Sample, worksheets []*model.Worksheet) map[string]string {
+	used := make(map[string]bool)
+	for _, worksheet := range worksheets {
+		for _, sample := range worksheet.Samples {
+			used[sample.Name] = true
+		}
+	}
+
+	renames := make(map[string]string)
+	for _, name := range taken {
+		for n := 2; ; n++ {
+			rename := fmt.Sprintf("%s (%d)", name, n)
+			if projectSamples[rename] == nil && !used[rename] {
+				renames[name] = rename
+				used[rename] = true
+				break
+			}
+		}
+	}
+
+	return renames
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/spf13/cobra"
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists projects, experiments and process templates on the server.",
+	Long: `The list command queries the server so that project IDs, experiment names and process templates
+can be found without going to the web UI. Use the output when composing load commands.`,
+	Example: `  mcetl list projects -k <apikey>
+  mcetl list experiments -p <project-id> -k <apikey>
+  mcetl list experiments -m "My Project" -k <apikey>
+  mcetl list templates -k <apikey>`,
+}
+
+var listProjectsCmd = &cobra.Command{
+	Use:   "projects",
+	Short: "Lists the projects you have access to.",
+	Run:   cliCmdListProjects,
+}
+
+var listExperimentsCmd = &cobra.Command{
+	Use:   "experiments",
+	Short: "Lists the experiments in a project.",
+	Run:   cliCmdListExperiments,
+}
+
+var listTemplatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Lists the process templates.",
+	Run:   cliCmdListTemplates,
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.PersistentFlags().StringP("mcurl", "u", "http://localhost:5016/api", "URL for the API service")
+	listCmd.PersistentFlags().StringP("apikey", "k", "", "apikey to pass in REST API calls")
+	listCmd.PersistentFlags().Bool("no-cache", false, "Always read the server's templates rather than using what was cached from an earlier run")
+	listCmd.PersistentFlags().Bool("apikey-in-query", false, "Send the apikey as a query parameter rather than in the Authorization header, for older servers that reject the header")
+
+	listCmd.AddCommand(listProjectsCmd)
+	listCmd.AddCommand(listExperimentsCmd)
+	listCmd.AddCommand(listTemplatesCmd)
+
+	listExperimentsCmd.Flags().StringP("project-id", "p", "", "Project to list experiments for")
+	listExperimentsCmd.Flags().StringP("project-name", "m", "", "Name of the project to list experiments for")
+}
+
+func cliCmdListProjects(cmd *cobra.Command, args []string) {
+	client, err := createAPIClient(cmd)
+	if err != nil {
+		exitCommand(1)
+	}
+
+	projects, err := client.ListProjects()
+	if err != nil {
+		console.Error("Unable to list projects:", err)
+		exitCommand(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tOWNER\tFILES")
+	for _, project := range projects {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", project.ID, project.Name, project.Owner, project.FileCount)
+	}
+	w.Flush()
+}
+
+func cliCmdListExperiments(cmd *cobra.Command, args []string) {
+	var (
+		projectID   string
+		projectName string
+		project     *mcapi.Project
+		err         error
+	)
+
+	if projectID, err = cmd.Flags().GetString("project-id"); err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	if projectName, err = cmd.Flags().GetString("project-name"); err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	if projectID == "" && projectName == "" {
+		console.Error("You must specify a project-id or project-name")
+		exitCommand(1)
+	}
+
+	client, err := createAPIClient(cmd)
+	if err != nil {
+		exitCommand(1)
+	}
+
+	if projectID != "" {
+		project, err = client.GetProjectOverview(projectID)
+	} else {
+		project, err = client.GetProjectOverviewByName(projectName)
+	}
+
+	if err != nil {
+		console.Error("Unable to retrieve project:", err)
+		exitCommand(1)
+	}
+
+	fmt.Printf("Project %s (%s)\n", project.Name, project.ID)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tOWNER\tSTATUS")
+	for _, experiment := range project.Experiments {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", experiment.ID, experiment.Name, experiment.Owner, experiment.Status)
+	}
+	w.Flush()
+}
+
+func cliCmdListTemplates(cmd *cobra.Command, args []string) {
+	client, err := createAPIClient(cmd)
+	if err != nil {
+		exitCommand(1)
+	}
+
+	templates, err := cachingClient(cmd, client).ListTemplates()
+	if err != nil {
+		console.Error("Unable to list templates:", err)
+		exitCommand(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tPROCESS TYPE\tCATEGORY")
+	for _, template := range templates {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", template.ID, template.Name, template.ProcessType, template.Category)
+	}
+	w.Flush()
+}
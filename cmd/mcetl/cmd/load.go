@@ -6,13 +6,16 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/console"
 	"github.com/pkg/errors"
 
 	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
 
 	"github.com/materials-commons/config"
 	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/credentials"
+	"github.com/materials-commons/mcetl/internal/mcapix"
 	"github.com/materials-commons/mcetl/internal/spreadsheet"
 
 	"github.com/spf13/cobra"
@@ -23,86 +26,204 @@ var loadCmd = &cobra.Command{
 	Use:   "load",
 	Short: "Loads the given spreadsheet(s) and performs ETL.",
 	Long:  `The load command will read and process the given spreadsheets. The spreadsheets are processed in the order given.`,
-	Run:   cliCmdLoad,
+	Example: `  mcetl load -f heat-treatment.xlsx --has-parent -p <project-id> -n "Heat Treatment Study" -k <apikey>
+  mcetl load -f casting.xlsx,rolling.xlsx -t -r 2 -m "New Project" -n "Campaign 1" -d data/campaign1`,
+	Run: cliCmdLoad,
 }
 
 func init() {
 	rootCmd.AddCommand(loadCmd)
-	loadCmd.Flags().StringP("files", "f", "", "Path(s) to the excel spreadsheet(s) to create experiment from")
-	loadCmd.Flags().StringP("project-id", "p", "", "Project to create experiment in")
-	loadCmd.Flags().StringP("project-name", "m", "", "Project name to create experiment in")
-	loadCmd.Flags().StringP("experiment-name", "n", "", "Name of experiment to create")
-	loadCmd.Flags().StringP("mcurl", "u", "http://localhost:5016/api", "URL for the API service")
-	loadCmd.Flags().StringP("apikey", "k", "", "apikey to pass in REST API calls")
-	loadCmd.Flags().StringP("project-base-dir", "d", "", "project base dir on server to look for files")
-	loadCmd.Flags().IntP("header-row", "r", 0, "Row to start reading from")
-	loadCmd.Flags().BoolP("has-parent", "t", false, "2nd column is the parent column")
+	addLoaderFlags(loadCmd)
+	addLoadFlags(loadCmd)
+	addOutputFlag(loadCmd)
+}
+
+// addLoadFlags adds the flags that control where and how the worksheets are loaded. They are shared by
+// the load and tui commands.
+func addLoadFlags(c *cobra.Command) {
+	c.Flags().StringP("project-id", "p", "", "Project to create experiment in")
+	c.Flags().StringP("project-name", "m", "", "Project name to create experiment in")
+	c.Flags().StringP("experiment-name", "n", "", "Name of experiment to create")
+	c.Flags().Bool("experiment-per-cohort", false, "Load the samples of each cohort, given in a cohort: column, into an experiment of their own")
+	c.Flags().String("existing-samples", "", `What to do with samples whose names are already in the project: "reuse" them, "rename" the new ones or "error" (not checked when blank)`)
+	c.Flags().Bool("no-experiment", false, "Create the samples and processes in the project without an experiment (the check for an earlier load of the spreadsheet(s) is skipped)")
+	c.Flags().StringP("mcurl", "u", "http://localhost:5016/api", "URL for the API service")
+	c.Flags().StringP("apikey", "k", "", "apikey to pass in REST API calls")
+	c.Flags().Bool("apikey-in-query", false, "Send the apikey as a query parameter rather than in the Authorization header, for older servers that reject the header")
+	c.Flags().StringP("project-base-dir", "d", "", "project base dir on server to look for files")
+	c.Flags().Bool("force", false, "Load the spreadsheet(s) even if they have already been loaded into the project or exceed --max-samples/--max-processes")
+	c.Flags().Int("max-samples", 0, "Abort without creating anything if more than this many samples would be created, 0 means no limit")
+	c.Flags().Int("max-processes", 0, "Abort without creating anything if more than this many processes would be created, 0 means no limit")
+	c.Flags().String("bundle-dir", "", "Write an import bundle to this directory instead of calling the API")
+	c.Flags().String("report", "", "Write a JSON report of the samples and processes created, and any that failed or weren't attempted, to this file")
+	c.Flags().Duration("report-interval", processor.DefaultProgressInterval, "How often to save the --report file while loading, so a crash doesn't lose track of what was created (0 only saves it at the end or on a crash)")
+	c.Flags().String("state-file", "", "Record what the load creates in this file, and if it exists carry on from the earlier load that failed")
+	c.Flags().String("genealogy", "", "Write the sample genealogy as a CSV edge list to this file")
+	c.Flags().String("summary-dir", "", "Write a copy of each workbook to this directory with an MC Summary worksheet of the IDs created for each row")
+	c.Flags().String("create-process-name", "", "Name to give the Create Samples processes")
+	c.Flags().String("schedule", "", `Only make API calls during this daily window, eg "22:00-06:00", pausing outside of it`)
+	c.Flags().Bool("process-name-attrs", false, "Include the process attribute values that differ between processes from the same worksheet in their names")
+	c.Flags().String("pseudonyms", "", "Replace sample names with pseudonyms, keeping the sample to pseudonym mapping in this CSV file")
+	c.Flags().String("pseudonym-prefix", "S-", "Prefix for new pseudonyms, which are numbered, eg S-0001")
+	c.Flags().Int("concurrency", 1, "Number of workflow steps to create at once, each step waits for the steps that send samples into it")
+	c.Flags().Int("measurement-workers", processor.DefaultMeasurementWorkers, "Number of measurement batches to add to the server at once")
+	c.Flags().Bool("link-files-to-samples", false, "Also link the files in file columns to the samples on their rows, not just to the processes")
+	c.Flags().Bool("create-missing-dirs", false, "Create the directories of the files in file columns that don't exist in the project")
+	c.Flags().Bool("record-provenance", false, "Store the file, sheet, row and column each measurement came from in its metadata")
+	c.Flags().Int("verify-every", 0, "Read back every Nth process created and check it matches what was sent, 0 to not verify")
+	c.Flags().Int("max-idle-conns", mcapix.DefaultTransportOptions.MaxIdleConnsPerHost, "Most idle connections to keep open to the server for reuse")
+	c.Flags().Int("max-conns", 0, "Most connections to open to the server at once, 0 for no limit")
+	c.Flags().Bool("no-http2", false, "Only use HTTP/1.1 to talk to the server, even if it supports HTTP/2")
+	c.Flags().Bool("normalize-units", false, "Convert values to common units when deciding if process attributes are the same")
+	c.Flags().String("creation-order", processor.DepthFirst, `Order to create the samples and processes in, "depth-first", "breadth-first" or "leveled"`)
 }
 
 func cliCmdLoad(cmd *cobra.Command, args []string) {
+	if err := startOutput(cmd); err != nil {
+		exitCommand(1)
+	}
+	defer finishOutput(0)
+
+	if err := checkAPIAccess(cmd); err != nil {
+		exitCommand(1)
+	}
+
 	worksheets, err := loadSpreadsheet(cmd)
 	if err != nil {
-		os.Exit(1)
+		exitCommand(1)
+	}
+
+	if err := loadWorksheets(cmd, worksheets); err != nil {
+		exitCommand(1)
+	}
+}
+
+// loadWorksheets performs the ETL for worksheets that have been loaded from the spreadsheet(s), either
+// creating the workflow on the server or writing an import bundle. The command must have the flags
+// added by addLoaderFlags and addLoadFlags.
+func loadWorksheets(cmd *cobra.Command, worksheets []*model.Worksheet) error {
+	if err := pseudonymizeSamples(cmd, worksheets); err != nil {
+		return err
 	}
 
 	// add baseDir to all file entries in the worksheets
 	if err := addBaseDirToFilePaths(cmd, worksheets); err != nil {
-		os.Exit(1)
+		return err
 	}
 
-	client, err := createAPIClient(cmd)
+	options, err := workflowOptionsFromFlags(cmd)
 	if err != nil {
-		os.Exit(1)
+		return err
 	}
 
-	if err := createWorkflowFromWorksheets(cmd, client, worksheets); err != nil {
-		os.Exit(1)
+	if options.CreationOrder, err = cmd.Flags().GetString("creation-order"); err != nil {
+		console.Error("error", err)
+		return err
+	} else if err := processor.ValidCreationOrder(options.CreationOrder); err != nil {
+		console.Error("error", err)
+		return err
 	}
-}
 
-// loadSpreadsheet loads the excel spreadsheet file given in the file flag and
-// transforms it into the internal representation of worksheets.
-func loadSpreadsheet(cmd *cobra.Command) ([]*model.Worksheet, error) {
-	var (
-		files     string
-		headerRow int
-		hasParent bool
-		err       error
-	)
+	if err := checkDuplicateSamples(cmd, options, worksheets); err != nil {
+		return err
+	}
 
-	if files, err = cmd.Flags().GetString("files"); err != nil {
-		fmt.Println("error", err)
-		return nil, err
+	checkOrphanSamples(options, worksheets)
+
+	if err := writeGenealogy(cmd, options, worksheets); err != nil {
+		return err
 	}
 
-	if headerRow, err = cmd.Flags().GetInt("header-row"); err != nil {
-		fmt.Println("error", err)
-		return nil, err
+	if err := checkLimits(cmd, options, worksheets); err != nil {
+		return err
 	}
 
-	if hasParent, err = cmd.Flags().GetBool("has-parent"); err != nil {
-		fmt.Println("error", err)
-		return nil, err
+	bundleDir, err := cmd.Flags().GetString("bundle-dir")
+	if err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	perCohort, err := cmd.Flags().GetBool("experiment-per-cohort")
+	if err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if bundleDir != "" {
+		if perCohort {
+			err := errors.Errorf("--experiment-per-cohort can't be used with --bundle-dir")
+			console.Error("error", err)
+			return err
+		}
+
+		// No API calls are made when writing a bundle
+		return createBundleFromWorksheets(cmd, bundleDir, options, worksheets)
+	}
+
+	if err := setTransportOptionsFromFlags(cmd); err != nil {
+		return err
 	}
 
-	loader := spreadsheet.NewLoader(hasParent, headerRow, strings.Split(files, ","))
+	client, err := createAPIClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	return createWorkflowFromWorksheets(cmd, client, options, worksheets)
+}
+
+// loadSpreadsheet loads the excel spreadsheet file given in the file flag and
+// transforms it into the internal representation of worksheets.
+func loadSpreadsheet(cmd *cobra.Command) ([]*model.Worksheet, error) {
+	loader, err := loaderFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
 
 	worksheets, err := loader.Load()
 	if err != nil {
-		printLoadSpreadsheetErrors(err)
+		printLoadErrors(cmd, loader, "Loading spreadsheet failed:", err)
 		return nil, errors.Errorf("failed loading file")
 	}
 
 	return worksheets, nil
 }
 
-func printLoadSpreadsheetErrors(err error) {
-	fmt.Println("Loading spreadsheet failed:")
-	if merr, ok := err.(*multierror.Error); ok {
-		for _, e := range merr.Errors {
-			fmt.Println(" ", e)
-		}
+// pseudonymizeSamples replaces the sample names with pseudonyms when the pseudonyms flag was given.
+func pseudonymizeSamples(cmd *cobra.Command, worksheets []*model.Worksheet) error {
+	var (
+		path, prefix string
+		err          error
+	)
+
+	if path, err = cmd.Flags().GetString("pseudonyms"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	if prefix, err = cmd.Flags().GetString("pseudonym-prefix"); err != nil {
+		console.Error("error", err)
+		return err
 	}
+
+	pseudonyms, err := spreadsheet.LoadPseudonyms(path, prefix)
+	if err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if err := pseudonyms.Apply(worksheets); err != nil {
+		console.Error("Unable to write pseudonyms file:", err)
+		return err
+	}
+
+	fmt.Printf("Sample names replaced with pseudonyms, the mapping is in %s\n", path)
+	return nil
 }
 
 // addBaseDirToFilePaths goes through all the worksheets and their associated
@@ -112,7 +233,7 @@ func printLoadSpreadsheetErrors(err error) {
 // context of the project on the server.
 func addBaseDirToFilePaths(cmd *cobra.Command, worksheets []*model.Worksheet) error {
 	if baseDir, err := cmd.Flags().GetString("project-base-dir"); err != nil {
-		fmt.Println("error", err)
+		console.Error("error", err)
 		return err
 	} else {
 		for _, worksheet := range worksheets {
@@ -127,9 +248,105 @@ func addBaseDirToFilePaths(cmd *cobra.Command, worksheets []*model.Worksheet) er
 	return nil
 }
 
-// createAPIClient creates a mcapi.Client setting the url and apikey
+// setTransportOptionsFromFlags sets how the connections to the server are pooled from the flags. It must be
+// called before any API calls are made.
+func setTransportOptionsFromFlags(cmd *cobra.Command) error {
+	var (
+		options = mcapix.DefaultTransportOptions
+		err     error
+	)
+
+	if options.MaxIdleConnsPerHost, err = cmd.Flags().GetInt("max-idle-conns"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if options.MaxConnsPerHost, err = cmd.Flags().GetInt("max-conns"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if options.DisableHTTP2, err = cmd.Flags().GetBool("no-http2"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	mcapix.SetTransportOptions(options)
+	return nil
+}
+
+// checkAPIAccess checks the apikey, and that the project can be accessed, before the workbooks are parsed.
+// Parsing a large workbook takes minutes, which is a long wait to find out the apikey was mistyped. Nothing
+// is checked when writing a bundle, or when outside of the --schedule window.
+func checkAPIAccess(cmd *cobra.Command) error {
+	var (
+		bundleDir   string
+		projectName string
+		projectID   string
+		err         error
+	)
+
+	if bundleDir, err = cmd.Flags().GetString("bundle-dir"); err != nil {
+		console.Error("error", err)
+		return err
+	} else if bundleDir != "" {
+		return nil
+	}
+
+	schedule, err := scheduleFromFlags(cmd)
+	if err != nil {
+		return err
+	} else if !schedule.IsOpen() {
+		return nil
+	}
+
+	if projectName, err = cmd.Flags().GetString("project-name"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if projectID, err = cmd.Flags().GetString("project-id"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if err := setTransportOptionsFromFlags(cmd); err != nil {
+		return err
+	}
+
+	client, err := createAPIClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	// A project that is going to be created can't be checked, only the apikey
+	if projectName != "" || projectID == "" {
+		_, err = client.ListProjects()
+	} else {
+		_, err = client.GetProjectOverview(projectID)
+	}
+
+	switch {
+	case err == mcapix.ErrAuth:
+		err = errors.Errorf("the apikey was rejected by %s, check it or run mcetl login (servers that predate "+
+			"Authorization header support need --apikey-in-query)", client.BaseURL)
+	case err != nil && projectName == "" && projectID != "":
+		err = errors.Errorf("unable to access project %s: %s", projectID, err)
+	case err != nil:
+		err = errors.Errorf("unable to reach %s: %s", client.BaseURL, err)
+	}
+
+	if err != nil {
+		console.Error("error", err)
+	}
+
+	return err
+}
+
+// createAPIClient creates a mcapix.Client setting the url and apikey
 // from the mcurl and apikey environment variables or command line parameters.
-func createAPIClient(cmd *cobra.Command) (*mcapi.Client, error) {
+// If the apikey isn't given either way the key stored by mcetl login is used.
+func createAPIClient(cmd *cobra.Command) (*mcapix.Client, error) {
 	var (
 		mcurl  string
 		apikey string
@@ -142,7 +359,7 @@ func createAPIClient(cmd *cobra.Command) (*mcapi.Client, error) {
 
 	if mcurl == "" {
 		err = errors.New("mcurl not set")
-		fmt.Println("error", err)
+		console.Error("error", err)
 		return nil, err
 	}
 
@@ -151,55 +368,514 @@ func createAPIClient(cmd *cobra.Command) (*mcapi.Client, error) {
 	}
 
 	if apikey == "" {
-		err = errors.New("apikey not set")
-		fmt.Println("error", err)
+		apikey = credentials.LoadAPIKey()
+	}
+
+	if apikey == "" {
+		err = errors.New("apikey not set, use -k or run mcetl login")
+		console.Error("error", err)
 		return nil, err
 	}
 
-	client := mcapi.NewClient(mcurl)
+	client := mcapix.NewClient(mcurl)
 	client.APIKey = apikey
+	if client.APIKeyInQuery, err = cmd.Flags().GetBool("apikey-in-query"); err != nil {
+		// Not every command that talks to the server has the flag
+		client.APIKeyInQuery = false
+	}
 
 	return client, nil
 }
 
 // createWorkflowFromWorkWorksheets creates the server side workflow from the worksheets.
-func createWorkflowFromWorksheets(cmd *cobra.Command, client *mcapi.Client, worksheets []*model.Worksheet) error {
+func createWorkflowFromWorksheets(cmd *cobra.Command, client *mcapix.Client, options processor.WorkflowOptions, worksheets []*model.Worksheet) error {
 	var (
 		projectId      string
 		experimentName string
 		projectName    string
-		hasParent      bool
 		err            error
 	)
 
+	schedule, err := scheduleFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	// The project lookups below are API calls too
+	schedule.WaitUntilOpen()
+
+	if experimentName, err = cmd.Flags().GetString("experiment-name"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	noExperiment, err := noExperimentFromFlags(cmd, experimentName)
+	if err != nil {
+		return err
+	}
+
+	cohorts, err := cohortsFromFlags(cmd, noExperiment, worksheets)
+	if err != nil {
+		return err
+	}
+
+	// The state of an earlier run of the load that failed, for each cohort
+	states, err := resumeStates(cmd, cohorts)
+	if err != nil {
+		return err
+	}
+
 	if projectName, err = cmd.Flags().GetString("project-name"); err != nil || projectName == "" {
 		if projectId, err = cmd.Flags().GetString("project-id"); err != nil {
-			fmt.Println("error", err)
+			console.Error("error", err)
 			return err
 		}
+	} else if state := anyState(states); state != nil {
+		// The earlier run created the project
+		projectId, projectName = state.ProjectID, ""
 	} else {
 		project, err := client.CreateProject(projectName, "")
 		if err != nil {
-			fmt.Println("error", err)
+			console.Error("error", err)
 			return err
 		}
 
 		projectId = project.ID
 	}
 
-	if hasParent, err = cmd.Flags().GetBool("has-parent"); err != nil {
-		fmt.Println("error", err)
+	fingerprint, err := workbookFingerprint(cmd)
+	if err != nil {
+		return err
+	}
+
+	flags := stateFlags(cmd)
+	if err := checkResumeStates(states, projectId, noExperiment, fingerprint, flags); err != nil {
+		return err
+	}
+
+	// A newly created project can't already contain the spreadsheets. Earlier loads are found by
+	// the fingerprint in their experiment's description, so without an experiment they can't be. A
+	// load being resumed is the earlier load.
+	if projectName == "" && !noExperiment && anyState(states) == nil {
+		if err := checkNotAlreadyLoaded(cmd, client, projectId, fingerprint); err != nil {
+			return err
+		}
+	}
+
+	// A newly created project has no samples
+	var reuseSamples map[string]*mcapi.Sample
+	if projectName == "" {
+		if reuseSamples, err = checkExistingSamples(cmd, client, projectId, options, worksheets, resumedSampleIDs(states)); err != nil {
+			return err
+		}
+	}
+
+	linked, err := linkedSamples(cmd, client, projectId, worksheets)
+	if err != nil {
+		return err
+	}
+	for name, sample := range linked {
+		if reuseSamples == nil {
+			reuseSamples = make(map[string]*mcapi.Sample)
+		}
+		reuseSamples[name] = sample
+	}
+
+	// Create the server side representation of the workflow from the worksheets, in an experiment for
+	// each cohort when they are split by cohort
+	description := spreadsheet.FingerprintDescription(fingerprint)
+	var reports []*processor.LoadReport
+	for _, cohort := range cohorts {
+		name := cohort.ExperimentName(experimentName)
+		if cohort.Name != "" {
+			fmt.Printf("Loading cohort %s into experiment '%s'\n", cohort.Name, name)
+		} else if len(cohorts) > 1 {
+			fmt.Printf("Loading the samples without a cohort into experiment '%s'\n", name)
+		}
+
+		creater := spreadsheet.Create(projectId, name, description, options, client)
+		creater.Schedule = schedule
+		creater.NoExperiment = noExperiment
+		creater.ReuseSamples = reuseSamples
+		if creater.ProgressPath, err = loadReportPath(cmd, cohort.Name); err != nil {
+			return err
+		}
+		if creater.StatePath, err = loadStatePath(cmd, cohort.Name); err != nil {
+			return err
+		}
+		creater.Resume = states[cohort.Name]
+		creater.StateFingerprint, creater.StateFlags = fingerprint, flags
+		if err := createrOptionsFromFlags(cmd, creater); err != nil {
+			return err
+		}
+
+		err := applyCreater(cmd, creater, cohort)
+		if report := creater.Report(); report != nil {
+			reports = append(reports, report)
+		}
+
+		if err != nil {
+			// The summary records what was created before the load stopped
+			writeSummaryWorkbooks(cmd, worksheets, reports)
+			return err
+		}
+	}
+
+	return writeSummaryWorkbooks(cmd, worksheets, reports)
+}
+
+// writeSummaryWorkbooks writes the copies of the workbooks with a summary of what was loaded to the
+// directory given in the summary-dir flag.
+func writeSummaryWorkbooks(cmd *cobra.Command, worksheets []*model.Worksheet, reports []*processor.LoadReport) error {
+	dir, err := cmd.Flags().GetString("summary-dir")
+	if err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if dir == "" || len(reports) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		console.Error("Unable to create summary directory:", err)
+		return err
+	}
+
+	if err := spreadsheet.WriteSummaryWorkbooks(dir, worksheets, reports); err != nil {
+		console.Error("Unable to write summary:", err)
+		return err
+	}
+
+	return nil
+}
+
+// createrOptionsFromFlags sets the options of the creater that are given by flags.
+func createrOptionsFromFlags(cmd *cobra.Command, creater *processor.Creater) error {
+	var err error
+	if creater.MeasurementWorkers, err = cmd.Flags().GetInt("measurement-workers"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if creater.Concurrency, err = cmd.Flags().GetInt("concurrency"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if creater.VerifyEvery, err = cmd.Flags().GetInt("verify-every"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if creater.ProgressInterval, err = cmd.Flags().GetDuration("report-interval"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if creater.RecordProvenance, err = cmd.Flags().GetBool("record-provenance"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if creater.CreateMissingDirs, err = cmd.Flags().GetBool("create-missing-dirs"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if creater.LinkFilesToSamples, err = cmd.Flags().GetBool("link-files-to-samples"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	return nil
+}
+
+// cohortsFromFlags returns the cohorts to load. Unless --experiment-per-cohort is given all the worksheets
+// are loaded together, as a single cohort without a name.
+func cohortsFromFlags(cmd *cobra.Command, noExperiment bool, worksheets []*model.Worksheet) ([]*spreadsheet.Cohort, error) {
+	perCohort, err := cmd.Flags().GetBool("experiment-per-cohort")
+	switch {
+	case err != nil:
+		console.Error("error", err)
+		return nil, err
+	case !perCohort:
+		return []*spreadsheet.Cohort{{Worksheets: worksheets}}, nil
+	case noExperiment:
+		err := errors.Errorf("--experiment-per-cohort can't be given with --no-experiment")
+		console.Error("error", err)
+		return nil, err
+	}
+
+	cohorts, err := spreadsheet.SplitByCohort(worksheets)
+	if err != nil {
+		console.Error("Unable to split the samples by cohort:", err)
+		return nil, err
+	}
+
+	return cohorts, nil
+}
+
+// applyCreater creates the workflow for the cohort's worksheets on the server and writes the load report.
+// When the worksheets are split by cohort each cohort's report is written to a file of its own.
+func applyCreater(cmd *cobra.Command, creater *processor.Creater, cohort *spreadsheet.Cohort) error {
+	err := creater.Apply(cohort.Worksheets)
+	emitCounts(creater)
+	if reportErr := writeLoadReport(cmd, creater.Report(), cohort.Name); reportErr != nil && err == nil {
+		return reportErr
+	}
+
+	if err != nil {
+		console.Error("Unable to process spreadsheet:", err)
+		if report := creater.Report(); report != nil && len(report.Steps) != 0 {
+			counts := report.Counts()
+			fmt.Printf("%d of %d steps were created before the load stopped (%d failed, %d not attempted)\n",
+				counts[processor.StepCreated]+counts[processor.StepFound]+counts[processor.StepResumed], len(report.Steps),
+				counts[processor.StepFailed], counts[processor.StepNotAttempted])
+		}
+		if creater.StatePath != "" {
+			fmt.Printf("Run the load again with --state-file %s to carry on from where it stopped\n", creater.StatePath)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// loadReportPath returns the file the load report is written to, blank if the report flag wasn't given.
+// The report for a cohort is written to the file with the cohort's name added, eg report-B1.json.
+func loadReportPath(cmd *cobra.Command, cohort string) (string, error) {
+	path, err := cmd.Flags().GetString("report")
+	if err != nil {
+		console.Error("error", err)
+		return "", err
+	}
+
+	return cohortPath(path, cohort), nil
+}
+
+// cohortPath returns the path of the file for a cohort, which has the cohort's name added to it so each
+// cohort gets a file of its own. It is path when the cohort has no name.
+func cohortPath(path, cohort string) string {
+	if path == "" || cohort == "" {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + strings.Replace(filepath.ToSlash(cohort), "/", "_", -1) + ext
+}
+
+// writeLoadReport writes the report of what the load created to the file given in the report flag.
+func writeLoadReport(cmd *cobra.Command, report *processor.LoadReport, cohort string) error {
+	path, err := loadReportPath(cmd, cohort)
+	if err != nil {
+		return err
+	}
+
+	if path == "" || report == nil {
+		return nil
+	}
+
+	if err := report.Write(path); err != nil {
+		console.Error("Unable to write load report:", err)
+		return err
+	}
+
+	fmt.Println("Load report written to", path)
+	return nil
+}
+
+// scheduleFromFlags parses the schedule flag. It returns a nil schedule if no schedule was given.
+func scheduleFromFlags(cmd *cobra.Command) (*processor.Schedule, error) {
+	spec, err := cmd.Flags().GetString("schedule")
+	if err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if spec == "" {
+		return nil, nil
+	}
+
+	schedule, err := processor.ParseSchedule(spec)
+	if err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// createBundleFromWorksheets writes an import bundle for the worksheets into bundleDir rather than
+// creating the workflow on the server.
+func createBundleFromWorksheets(cmd *cobra.Command, bundleDir string, options processor.WorkflowOptions, worksheets []*model.Worksheet) error {
+	var (
+		projectID      string
+		experimentName string
+		err            error
+	)
+
+	if projectID, err = cmd.Flags().GetString("project-id"); err != nil {
+		console.Error("error", err)
 		return err
 	}
 
 	if experimentName, err = cmd.Flags().GetString("experiment-name"); err != nil {
-		fmt.Println("error", err)
+		console.Error("error", err)
+		return err
+	}
+
+	fingerprint, err := workbookFingerprint(cmd)
+	if err != nil {
+		return err
+	}
+
+	description := spreadsheet.FingerprintDescription(fingerprint)
+	bundler := spreadsheet.Bundle(projectID, experimentName, description, bundleDir, options)
+	if bundler.NoExperiment, err = noExperimentFromFlags(cmd, experimentName); err != nil {
+		return err
+	}
+
+	if bundler.RecordProvenance, err = cmd.Flags().GetBool("record-provenance"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if bundler.LinkFilesToSamples, err = cmd.Flags().GetBool("link-files-to-samples"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if err := bundler.Apply(worksheets); err != nil {
+		console.Error("Unable to write bundle:", err)
+		return err
+	}
+
+	return nil
+}
+
+// noExperimentFromFlags returns the no-experiment flag. It is an error to also name the experiment.
+func noExperimentFromFlags(cmd *cobra.Command, experimentName string) (bool, error) {
+	noExperiment, err := cmd.Flags().GetBool("no-experiment")
+	switch {
+	case err != nil:
+		console.Error("error", err)
+		return false, err
+	case noExperiment && experimentName != "":
+		err := errors.Errorf("--experiment-name can't be given with --no-experiment")
+		console.Error("error", err)
+		return false, err
+	}
+
+	return noExperiment, nil
+}
+
+// workbookFingerprint computes the fingerprint of the spreadsheet(s) given in the files flag, and of the
+// samples to load from them when only some are loaded.
+func workbookFingerprint(cmd *cobra.Command) (string, error) {
+	files, err := cmd.Flags().GetString("files")
+	if err != nil {
+		console.Error("error", err)
+		return "", err
+	}
+
+	fingerprint, err := spreadsheet.Fingerprint(strings.Split(files, ","))
+	if err != nil {
+		console.Error("Unable to fingerprint spreadsheet(s):", err)
+		return "", err
+	}
+
+	// Loading some of the samples isn't the same as loading all of them
+	samples, err := samplesFromFlags(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	return spreadsheet.SamplesFingerprint(fingerprint, samples), nil
+}
+
+// checkNotAlreadyLoaded looks for an experiment in the project that was loaded from spreadsheet(s)
+// with the same fingerprint. If one is found then the load is aborted unless the force flag was
+// given, in which case a warning is printed.
+func checkNotAlreadyLoaded(cmd *cobra.Command, client *mcapix.Client, projectID, fingerprint string) error {
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		console.Error("error", err)
 		return err
 	}
 
-	// Create the server side representation of the workflow from the worksheets
-	if err := spreadsheet.Create(projectId, experimentName, hasParent, client).Apply(worksheets); err != nil {
-		fmt.Println("Unable to process spreadsheet:", err)
+	project, err := client.GetProjectOverview(projectID)
+	if err != nil {
+		console.Error("Unable to retrieve project to check if spreadsheet(s) were already loaded:", err)
+		return err
+	}
+
+	experiment := spreadsheet.FindExperimentWithFingerprint(project.Experiments, fingerprint)
+	switch {
+	case experiment == nil:
+		return nil
+	case force:
+		console.Warningf("spreadsheet(s) were already loaded into experiment '%s', loading again because of --force\n", experiment.Name)
+		return nil
+	default:
+		err := errors.Errorf("spreadsheet(s) were already loaded into experiment '%s' (%s), use --force to load again", experiment.Name, experiment.ID)
+		console.Error("error", err)
+		return err
+	}
+}
+
+// checkLimits fails if the workflow would create more samples or processes than the max-samples and
+// max-processes flags allow, unless --force was given.
+func checkLimits(cmd *cobra.Command, options processor.WorkflowOptions, worksheets []*model.Worksheet) error {
+	var (
+		maxSamples, maxProcesses int
+		force                    bool
+		err                      error
+	)
+
+	if maxSamples, err = cmd.Flags().GetInt("max-samples"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if maxProcesses, err = cmd.Flags().GetInt("max-processes"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if force, err = cmd.Flags().GetBool("force"); err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if err := spreadsheet.Limits(maxSamples, maxProcesses, options).Apply(worksheets); err != nil {
+		if force {
+			console.Warning(err)
+			return nil
+		}
+
+		console.Error("Not loading,", err)
+		console.Error("This is often caused by footer or note rows being read as samples. Use --force to load anyway.")
+		return err
+	}
+
+	return nil
+}
+
+// writeGenealogy writes the sample genealogy CSV edge list if the genealogy flag was given.
+func writeGenealogy(cmd *cobra.Command, options processor.WorkflowOptions, worksheets []*model.Worksheet) error {
+	path, err := cmd.Flags().GetString("genealogy")
+	if err != nil {
+		console.Error("error", err)
+		return err
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	if err := spreadsheet.Genealogy(path, options).Apply(worksheets); err != nil {
+		console.Error("Unable to write genealogy:", err)
 		return err
 	}
 
@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/dates"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/spf13/cobra"
+)
+
+// addLoaderFlags adds the flags that control how the spreadsheets are loaded. These are shared by
+// all the commands that load spreadsheets.
+func addLoaderFlags(c *cobra.Command) {
+	c.Flags().StringP("files", "f", "", "Path(s) to the excel spreadsheet(s), CSV or TSV file(s), or zip(s) of spreadsheets")
+	c.Flags().StringP("header-row", "r", "0", `Rows to skip before the header row, eg "3", "SEM=3,Casting=1" or "auto"`)
+	c.Flags().BoolP("has-parent", "t", false, "2nd column is the parent column")
+	c.Flags().Bool("units-row", false, "The row under the header gives the units of the attributes, eg |C|h| under |p:Temperature|p:Time|")
+	c.Flags().Bool("index-sheet", false, "The first worksheet in each workbook is an index giving the header row, has parent, template and skip settings of the others")
+	c.Flags().Bool("detect-parent", false, "Treat column 2 as the parent column in worksheets where it holds the names of other worksheets")
+	c.Flags().Bool("fill-parent-down", false, "A blank parent cell means the same parent as the row above (requires --has-parent)")
+	c.Flags().Bool("convert-cell-units", false, `Convert values with their own unit, eg "350 K" in a Temperature(C) column, to the column's unit`)
+	c.Flags().Bool("ignore-merged-cells", false, "Don't copy the value of a merged cell into every row it covers")
+	c.Flags().String("schema", "", "YAML file with column rules, such as treating 0 as blank")
+	c.Flags().String("keywords-file", "", "YAML or JSON file of the header keywords for sample, process and file columns, and the blank cell values")
+	c.Flags().String("samples-sheet", "", "Worksheet describing how samples were created, its columns become Create Samples attributes")
+	c.Flags().String("master-sheet", "", "Worksheet of sample attributes that are merged into the samples on every other worksheet")
+	c.Flags().Bool("skip-orphan-samples", false, "Don't create samples that aren't used by any process, such as samples only on the samples sheet")
+	c.Flags().String("duplicate-samples", "replicates", `How to treat a sample on several rows with the same process attributes but different values, "replicates" or "error"`)
+	c.Flags().Bool("no-cache", false, "Always read the workbooks and the server's metadata rather than using what was cached from an earlier run")
+	addErrorFlags(c)
+	c.Flags().String("crosstab", "", "Comma separated worksheets laid out as a cross-tab of samples vs name(unit)=level columns")
+	c.Flags().String("lookup-sheets", "", "Comma separated worksheets of entries, such as recipes, that lookup:<worksheet> columns refer to by key")
+	c.Flags().String("measurement-sheets", "", "Comma separated worksheets, such as hardness tests, whose sample attributes are measurements of the samples on their parent worksheet")
+	c.Flags().String("normalize-text", spreadsheet.TextNormalizationWhitespace, `How to clean up look-alike characters in cells, "whitespace", "unicode" or "none"`)
+	c.Flags().String("encoding", spreadsheet.EncodingAuto, `Encoding of CSV and TSV files, "auto", "utf-8", "utf-16le", "utf-16be", "latin-1" or "windows-1252"`)
+	c.Flags().String("date-formats", "", `Semicolon separated formats of dates typed in cells, eg "DD/MM/YYYY;DD.MM.YYYY hh:mm", stored as ISO-8601`)
+	c.Flags().Int("max-cell-size", 0, "Most characters a data cell can have, 0 for no limit")
+	c.Flags().String("long-cells", spreadsheet.LongCellsAsErrors, `What to do with a cell over --max-cell-size, "error" or "truncate"`)
+	c.Flags().String("minority-cells", spreadsheet.MinorityCellsWarned, `What to do with the few cells that aren't numbers, or true/false, in a column of them, "warn", "error", "coerce" or "skip"`)
+	c.Flags().Bool("allow-empty-sheets", false, "Load worksheets that have a header but no data rows, rather than leaving them out")
+	c.Flags().String("attributeless-sheets", spreadsheet.AttributelessSheetsSkipped, `What to do with worksheets that have no attribute or file columns, "skip" or "associate" to load their samples as a step without attributes`)
+	c.Flags().Bool("inherit-files", false, "Files on the row of a sample's parent worksheet are also input files of the sample's next process")
+	c.Flags().Bool("file-direction-by-position", false, "File columns after the process attribute columns are outputs of the process rather than inputs")
+	c.Flags().String("include-attrs", "", `Comma separated glob patterns, only attributes whose names match one are loaded, eg "Hardness*,Temp*"`)
+	c.Flags().String("exclude-attrs", "", "Comma separated glob patterns, attributes whose names match one aren't loaded")
+	c.Flags().String("samples", "", "Comma separated samples, only their rows are loaded, eg S1,S2,S17")
+	c.Flags().String("samples-file", "", "File of samples to load, one per line, only their rows are loaded")
+}
+
+// loaderFromFlags creates a spreadsheet.Loader from the flags added by addLoaderFlags.
+func loaderFromFlags(cmd *cobra.Command) (*spreadsheet.Loader, error) {
+	var (
+		files      string
+		headerRow  string
+		headerRows *spreadsheet.HeaderRows
+		hasParent  bool
+		err        error
+	)
+
+	if files, err = cmd.Flags().GetString("files"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if headerRow, err = cmd.Flags().GetString("header-row"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if headerRows, err = spreadsheet.ParseHeaderRows(headerRow); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if hasParent, err = cmd.Flags().GetBool("has-parent"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	loader := spreadsheet.NewLoader(hasParent, headerRows, strings.Split(files, ","))
+
+	if loader.UnitsRow, err = cmd.Flags().GetBool("units-row"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.IndexSheet, err = cmd.Flags().GetBool("index-sheet"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.DetectParent, err = cmd.Flags().GetBool("detect-parent"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.FillParentDown, err = cmd.Flags().GetBool("fill-parent-down"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.ConvertCellUnits, err = cmd.Flags().GetBool("convert-cell-units"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.MaxErrors, err = cmd.Flags().GetInt("max-errors"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.IgnoreMergedCells, err = cmd.Flags().GetBool("ignore-merged-cells"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.SamplesSheet, err = cmd.Flags().GetString("samples-sheet"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.MasterSheet, err = cmd.Flags().GetString("master-sheet"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.FileDirectionByPosition, err = cmd.Flags().GetBool("file-direction-by-position"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.InheritFiles, err = cmd.Flags().GetBool("inherit-files"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.AllowEmptySheets, err = cmd.Flags().GetBool("allow-empty-sheets"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.AttributelessSheets, err = cmd.Flags().GetString("attributeless-sheets"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if err = spreadsheet.ValidAttributelessSheets(loader.AttributelessSheets); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.TextNormalization, err = cmd.Flags().GetString("normalize-text"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if err = spreadsheet.ValidTextNormalization(loader.TextNormalization); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.Encoding, err = cmd.Flags().GetString("encoding"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if err = spreadsheet.ValidEncoding(loader.Encoding); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if dateFormats, err := cmd.Flags().GetString("date-formats"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	} else if dateFormats != "" {
+		// Formats can have commas in them, such as MMM D, YYYY
+		loader.DateFormats = strings.Split(dateFormats, ";")
+	}
+
+	if err = dates.ValidFormats(loader.DateFormats); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.MaxCellSize, err = cmd.Flags().GetInt("max-cell-size"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.LongCells, err = cmd.Flags().GetString("long-cells"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if err = spreadsheet.ValidLongCellsPolicy(loader.LongCells); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if loader.MinorityCells, err = cmd.Flags().GetString("minority-cells"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if err = spreadsheet.ValidMinorityCellsPolicy(loader.MinorityCells); err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if crosstab, err := cmd.Flags().GetString("crosstab"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	} else if crosstab != "" {
+		loader.CrosstabSheets = strings.Split(crosstab, ",")
+	}
+
+	if lookupSheets, err := cmd.Flags().GetString("lookup-sheets"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	} else if lookupSheets != "" {
+		loader.LookupSheets = strings.Split(lookupSheets, ",")
+	}
+
+	if measurementSheets, err := cmd.Flags().GetString("measurement-sheets"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	} else if measurementSheets != "" {
+		loader.MeasurementSheets = strings.Split(measurementSheets, ",")
+	}
+
+	if include, err := cmd.Flags().GetString("include-attrs"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	} else if include != "" {
+		loader.IncludeAttrs = strings.Split(include, ",")
+	}
+
+	if exclude, err := cmd.Flags().GetString("exclude-attrs"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	} else if exclude != "" {
+		loader.ExcludeAttrs = strings.Split(exclude, ",")
+	}
+
+	if loader.Samples, err = samplesFromFlags(cmd); err != nil {
+		return nil, err
+	}
+
+	if keywordsFile, err := cmd.Flags().GetString("keywords-file"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	} else if keywordsFile != "" {
+		if err := spreadsheet.LoadKeywordsFile(keywordsFile); err != nil {
+			console.Error("error", err)
+			return nil, err
+		}
+	}
+
+	if loader.Schema, err = schemaFromFlags(cmd); err != nil {
+		return nil, err
+	}
+
+	if noCache, err := cmd.Flags().GetBool("no-cache"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	} else if !noCache {
+		// Without a cache directory the workbooks are just read each time
+		if dir, err := spreadsheet.DefaultParseCacheDir(); err == nil {
+			loader.Cache = spreadsheet.NewParseCache(dir)
+		}
+	}
+
+	return loader, nil
+}
+
+// samplesFromFlags returns the samples given with the samples and samples-file flags. It returns nil
+// if neither was given.
+func samplesFromFlags(cmd *cobra.Command) ([]string, error) {
+	var samples []string
+	if names, err := cmd.Flags().GetString("samples"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	} else if names != "" {
+		for _, name := range strings.Split(names, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				samples = append(samples, name)
+			}
+		}
+	}
+
+	if path, err := cmd.Flags().GetString("samples-file"); err != nil {
+		console.Error("error", err)
+		return nil, err
+	} else if path != "" {
+		names, err := spreadsheet.ReadSamplesFile(path)
+		if err != nil {
+			console.Error("Unable to read samples file:", err)
+			return nil, err
+		}
+		samples = append(samples, names...)
+	}
+
+	return samples, nil
+}
+
+// schemaFromFlags loads the schema file given in the schema flag. It returns a nil
+// schema if no schema file was given.
+func schemaFromFlags(cmd *cobra.Command) (*spreadsheet.Schema, error) {
+	path, err := cmd.Flags().GetString("schema")
+	if err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	if path == "" {
+		return nil, nil
+	}
+
+	schema, err := spreadsheet.LoadSchema(path)
+	if err != nil {
+		console.Error("error", err)
+		return nil, err
+	}
+
+	return schema, nil
+}
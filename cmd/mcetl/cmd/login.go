@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/credentials"
+	"github.com/spf13/cobra"
+)
+
+// loginCmd represents the login command
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Stores your apikey so it doesn't need to be given with -k.",
+	Long: `The login command stores your apikey in the OS keyring (the macOS keychain, or the secret service
+on Linux via secret-tool). When no keyring is available the key is stored in
+$HOME/.materialscommons/config.json, readable only by you. The key is read from stdin rather than
+the command line so that it doesn't end up in your shell history.
+
+Once stored the key is used by the commands that talk to the server when -k and the apikey
+environment variable aren't set. Use "mcetl logout" to remove it.`,
+	Example: `  mcetl login
+  mcetl login < apikey.txt`,
+	Run: cliCmdLogin,
+}
+
+// logoutCmd represents the logout command
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Removes the apikey stored by mcetl login.",
+	Run:   cliCmdLogout,
+}
+
+func init() {
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+}
+
+func cliCmdLogin(cmd *cobra.Command, args []string) {
+	apikey, err := readAPIKey()
+	if err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	where, err := credentials.SaveAPIKey(apikey)
+	if err != nil {
+		console.Error("Unable to store apikey:", err)
+		exitCommand(1)
+	}
+
+	fmt.Printf("apikey stored in the %s\n", where)
+}
+
+func cliCmdLogout(cmd *cobra.Command, args []string) {
+	deleted, err := credentials.DeleteAPIKey()
+	if err != nil {
+		console.Error("Unable to remove stored apikey:", err)
+		exitCommand(1)
+	}
+
+	if !deleted {
+		fmt.Println("No stored apikey to remove")
+		return
+	}
+
+	fmt.Println("Stored apikey removed")
+}
+
+// readAPIKey reads the apikey from the first line of stdin, prompting for it if stdin is a terminal.
+func readAPIKey() (string, error) {
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		fmt.Print("apikey: ")
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	apikey := strings.TrimSpace(line)
+	if apikey == "" {
+		if err != nil {
+			return "", fmt.Errorf("unable to read apikey: %s", err)
+		}
+		return "", errors.New("no apikey given")
+	}
+
+	return apikey, nil
+}
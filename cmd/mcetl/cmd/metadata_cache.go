@@ -0,0 +1,108 @@
+package cmd
+
+/*
+ * metadata_cache keeps the metadata read from the server between runs, so iterating on a load with
+ * --existing-samples or mcid: columns, or matching worksheets to the server's templates again and again,
+ * doesn't fetch all of it each time. Only those reads use the cache (see cachingClient), the rest of a load
+ * always asks the server. Each response is kept with the ETag the server gave it, and is only used when the server
+ * says it hasn't changed since (see the ResponseCache in internal/mcapix), so a run never sees stale metadata; it
+ * just doesn't download it again. Servers that don't send ETags are unaffected.
+ *
+ * The responses are kept in the user's cache directory, eg $HOME/.cache/mcetl/metadata on Linux. --no-cache
+ * turns the cache off for the commands that have it.
+ */
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/materials-commons/mcetl/internal/mcapix"
+	"github.com/spf13/cobra"
+)
+
+// cachingClient returns client with the metadata cache, or client itself when the command was given
+// --no-cache, doesn't have the flag or there's no cache directory.
+func cachingClient(cmd *cobra.Command, client *mcapix.Client) *mcapix.Client {
+	if noCache, err := cmd.Flags().GetBool("no-cache"); err != nil || noCache {
+		return client
+	}
+
+	cache := newMetadataCache()
+	if cache == nil {
+		return client
+	}
+
+	return client.WithCache(cache)
+}
+
+// metadataCache is a mcapix.ResponseCache that keeps each response in a file in Dir.
+type metadataCache struct {
+	Dir string
+}
+
+// cachedResponse is the form a response is stored in.
+type cachedResponse struct {
+	ETag string          `json:"etag"`
+	Body json.RawMessage `json:"body"`
+}
+
+// newMetadataCache returns the cache in the user's cache directory, nil if there isn't one.
+func newMetadataCache() *metadataCache {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil
+	}
+
+	return &metadataCache{Dir: filepath.Join(dir, "mcetl", "metadata")}
+}
+
+// Get implements mcapix.ResponseCache.
+func (c *metadataCache) Get(key string) (string, []byte, bool) {
+	contents, err := ioutil.ReadFile(filepath.Join(c.Dir, key+".json"))
+	if err != nil {
+		return "", nil, false
+	}
+
+	var response cachedResponse
+	if err := json.Unmarshal(contents, &response); err != nil || response.ETag == "" {
+		return "", nil, false
+	}
+
+	return response.ETag, response.Body, true
+}
+
+// Put implements mcapix.ResponseCache. The response is written to a temporary file and renamed so that a
+// concurrent mcetl never reads a partly written one.
+func (c *metadataCache) Put(key, etag string, body []byte) error {
+	if !json.Valid(body) {
+		return nil
+	}
+
+	contents, err := json.Marshal(cachedResponse{ETag: etag, Body: body})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(c.Dir, key+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(contents)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(c.Dir, key+".json"))
+}
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+)
+
+// checkOrphanSamples warns about samples that aren't sent into any process. Unless the
+// skip-orphan-samples flag was given they are created with nothing but a Create Samples process.
+func checkOrphanSamples(options processor.WorkflowOptions, worksheets []*model.Worksheet) {
+	if err := spreadsheet.Orphans(options).Apply(worksheets); err != nil {
+		console.Warning(err)
+		if !options.SkipOrphanSamples {
+			console.Warning("Use --skip-orphan-samples to not create them.")
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package cmd
+
+/*
+ * output lets the load, check and display commands print their messages as JSON events, one per line, for
+ * GUIs and pipelines that run mcetl:
+ *
+ *   {"time":"2026-10-16T09:12:03Z","command":"load","type":"warning","message":"Worksheet ..."}
+ *
+ * Warnings and errors are printed through the console package, which gives them a type and, for an error in
+ * a spreadsheet, the worksheet, row and column it is in. When --output json is given the console's events are
+ * printed as events of the same type. Other messages are printed throughout mcetl with fmt.Print, so stdout is
+ * replaced by a pipe and each line written to it is a message event. Each error found in the spreadsheets is
+ * an error event of its own. A load also sends a counts event with the number of steps in each status and the
+ * API calls made for each experiment, and every command ends with an exit event holding its exit status. check --format json gathers the warning and error events into a single
+ * report instead, see check_report.go.
+ */
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+	"github.com/spf13/cobra"
+)
+
+// outputEvent is an event printed when the output is JSON.
+type outputEvent struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+
+	// Type is message, warning, error, counts or exit
+	Type    string `json:"type"`
+	Message string `json:"message,omitempty"`
+
+	// Where a warning or error is, when it is about a cell
+	Worksheet string `json:"worksheet,omitempty"`
+	File      string `json:"file,omitempty"`
+	Row       int    `json:"row,omitempty"`
+	Column    string `json:"column,omitempty"`
+
+	// For counts events
+	ProjectID    string         `json:"project_id,omitempty"`
+	ExperimentID string         `json:"experiment_id,omitempty"`
+	Succeeded    *bool          `json:"succeeded,omitempty"`
+	Counts       map[string]int `json:"counts,omitempty"`
+
+	// The number of API calls made, in all and by call
+	Calls       int            `json:"calls,omitempty"`
+	CallsByCall map[string]int `json:"calls_by_call,omitempty"`
+
+	// For exit events
+	Status *int `json:"status,omitempty"`
+}
+
+// eventLinePrefix marks a line written to the pipe that is already an encoded event. Events are written
+// through the pipe, rather than straight to stdout, so they stay in order with the messages before them.
+const eventLinePrefix = "\x1emcetl-event:"
+
+// jsonOutput turns the lines written to stdout into events.
+type jsonOutput struct {
+	command string
+
+	// stdout is the real stdout, pipe is the end of the pipe that replaced it
+	stdout *os.File
+	pipe   *os.File
+	done   chan struct{}
+
+	// report gathers the warning and error events rather than printing them, for check --format json
+	report *checkReport
+}
+
+// output is set when the output is JSON.
+var output *jsonOutput
+
+// addOutputFlag adds the flag that chooses how messages are printed.
+func addOutputFlag(c *cobra.Command) {
+	c.Flags().String("output", "text", `How to print messages, "text" or "json" (a JSON event per line)`)
+}
+
+// startOutput starts turning the output into JSON events if --output json was given.
+func startOutput(cmd *cobra.Command) error {
+	format, err := cmd.Flags().GetString("output")
+	switch {
+	case err != nil:
+		console.Error("error", err)
+		return err
+	case format == "text":
+		return nil
+	case format != "json":
+		err := fmt.Errorf(`invalid --output '%s', must be "text" or "json"`, format)
+		console.Error("error", err)
+		return err
+	}
+
+	return pipeOutput(cmd, nil)
+}
+
+// pipeOutput replaces stdout with a pipe whose lines are turned into events. The events are gathered
+// into the report, when one is given, rather than printed.
+func pipeOutput(cmd *cobra.Command, report *checkReport) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		console.Error("Unable to start JSON output:", err)
+		return err
+	}
+
+	output = &jsonOutput{command: cmd.Name(), stdout: os.Stdout, pipe: w, done: make(chan struct{}), report: report}
+	os.Stdout = w
+	go output.forward(r)
+	console.SetHandler(func(e console.Event) {
+		emitEvent(outputEvent{Type: e.Type, Message: e.Message, Worksheet: e.Worksheet, File: e.File, Row: e.Row, Column: e.Column})
+	})
+	return nil
+}
+
+// forward reads the lines written to stdout and prints them as events on the real stdout.
+func (o *jsonOutput) forward(r *os.File) {
+	defer close(o.done)
+
+	encoder := json.NewEncoder(o.stdout)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		var event outputEvent
+		switch {
+		case strings.HasPrefix(line, eventLinePrefix):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, eventLinePrefix)), &event); err != nil {
+				continue
+			}
+		case strings.TrimSpace(line) != "":
+			event = outputEvent{Type: console.TypeMessage, Message: line}
+		default:
+			continue
+		}
+
+		if o.report != nil {
+			o.report.add(event)
+			continue
+		}
+
+		event.Time = time.Now().UTC()
+		event.Command = o.command
+		encoder.Encode(event)
+	}
+}
+
+// emitEvent prints an event when the output is JSON. The event's time and command are filled in
+// when it is printed.
+func emitEvent(event outputEvent) {
+	if output == nil {
+		return
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(output.pipe, "%s%s\n", eventLinePrefix, data)
+}
+
+// emitCounts prints a counts event for the report of a load and the API calls the creater made. Without
+// JSON output the calls are printed as text.
+func emitCounts(creater *processor.Creater) {
+	if output == nil {
+		fmt.Println("Total calls:", creater.Count)
+		fmt.Printf("%#v\n", creater.ByCallCounts)
+		return
+	}
+
+	report := creater.Report()
+	if report == nil {
+		return
+	}
+
+	succeeded := report.Succeeded
+	emitEvent(outputEvent{
+		Type:         "counts",
+		ProjectID:    report.ProjectID,
+		ExperimentID: report.ExperimentID,
+		Succeeded:    &succeeded,
+		Counts:       report.Counts(),
+		Calls:        creater.Count,
+		CallsByCall:  creater.ByCallCounts,
+	})
+}
+
+// finishOutput prints the exit event and waits for the events before it to be printed. It must be called
+// before the command exits, use exitCommand rather than os.Exit.
+func finishOutput(status int) {
+	if output == nil {
+		return
+	}
+
+	console.SetHandler(nil)
+	emitEvent(outputEvent{Type: "exit", Status: &status})
+	output.pipe.Close()
+	<-output.done
+	if output.report != nil {
+		output.report.print(output.stdout, status)
+	}
+	os.Stdout = output.stdout
+	output = nil
+}
+
+// exitCommand exits with status, finishing the JSON output first.
+func exitCommand(status int) {
+	finishOutput(status)
+	os.Exit(status)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+	"github.com/spf13/cobra"
+)
+
+// reconstructCmd represents the reconstruct command
+var reconstructCmd = &cobra.Command{
+	Use:   "reconstruct",
+	Short: "Writes a workbook of the workflow in a bundle written with load --bundle-dir.",
+	Long: `The reconstruct command rebuilds a workbook from a bundle written by load --bundle-dir. The workbook
+has a worksheet for each process type with the samples, parents, attributes and files in the bundle,
+so it shows what the load wrote after the schema, unit normalization and other options were applied.
+It can be loaded again with the options that reconstruct prints. It only reads the bundle, not the
+server, so it can't rebuild a workbook for a load that created the workflow through the API.`,
+	Example: `  mcetl reconstruct -b ht-bundle -o ht-copy.xlsx
+  mcetl reconstruct -b ht-bundle/bundle.json -o ht-copy.xlsx`,
+	Run: cliCmdReconstruct,
+}
+
+func init() {
+	rootCmd.AddCommand(reconstructCmd)
+	reconstructCmd.Flags().StringP("bundle", "b", "", "Bundle directory, or its bundle.json, written by load --bundle-dir")
+	reconstructCmd.Flags().StringP("output", "o", "", "Workbook to write")
+}
+
+func cliCmdReconstruct(cmd *cobra.Command, args []string) {
+	bundlePath, err := cmd.Flags().GetString("bundle")
+	if err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	output, err := cmd.Flags().GetString("output")
+	if err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	if bundlePath == "" || output == "" {
+		console.Error("You must specify the bundle (-b) and the workbook to write (-o)")
+		exitCommand(1)
+	}
+
+	bundle, err := processor.ReadBundle(bundlePath)
+	if err != nil {
+		console.Error("Unable to read bundle:", err)
+		exitCommand(1)
+	}
+
+	reconstruction, err := spreadsheet.Reconstruct(bundle, output)
+	if err != nil {
+		console.Error("Unable to reconstruct workbook:", err)
+		exitCommand(1)
+	}
+
+	fmt.Printf("Wrote %s with worksheets: %s\n", output, strings.Join(reconstruction.Worksheets, ", "))
+	fmt.Println("Load it with:")
+	fmt.Println("  " + reconstructedLoadCommand(output, bundle.ProjectID, reconstruction))
+}
+
+// reconstructedLoadCommand returns the load command line for a reconstructed workbook.
+func reconstructedLoadCommand(output, projectID string, r *spreadsheet.Reconstruction) string {
+	args := []string{"mcetl", "load", "-f", quoteArg(output), "--has-parent", "-p", quoteArg(projectID)}
+	if r.SamplesSheet != "" {
+		args = append(args, "--samples-sheet", quoteArg(r.SamplesSheet))
+	}
+
+	if r.CreateProcessName != "" {
+		args = append(args, "--create-process-name", quoteArg(r.CreateProcessName))
+	}
+
+	if r.LinkFilesToSamples {
+		args = append(args, "--link-files-to-samples")
+	}
+
+	if r.ExperimentName != "" {
+		args = append(args, "-n", quoteArg(r.ExperimentName))
+	} else {
+		args = append(args, "--no-experiment")
+	}
+
+	return strings.Join(args, " ")
+}
+
+func quoteArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\"'$&|;<>()*?") {
+		return fmt.Sprintf("%q", arg)
+	}
+
+	return arg
+}
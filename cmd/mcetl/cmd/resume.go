@@ -0,0 +1,137 @@
+package cmd
+
+/*
+ * resume carries on a load that failed part way through from the --state-file it wrote, see
+ * processor/load_state.go. Running the same command again, with the same --state-file, loads what wasn't
+ * created into the experiment the first run created:
+ *
+ *   mcetl load -f campaign.xlsx --has-parent -p <project-id> -n "Campaign 1" --state-file campaign.state
+ *
+ * A load that is resumed isn't stopped by the check for an earlier load of the spreadsheet(s), and the
+ * samples it created aren't treated as samples already in the project by --existing-samples. When the
+ * samples are loaded into an experiment per cohort each cohort has a state file of its own.
+ *
+ * A load is only resumed with the same workbooks, and the same values of the flags that decide what is
+ * created from them. Those are all the flags except the ones in stateIgnoredFlags.
+ */
+
+import (
+	"fmt"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// loadStatePath returns the file the state of the load is kept in for the cohort, blank if the state-file
+// flag wasn't given.
+func loadStatePath(cmd *cobra.Command, cohort string) (string, error) {
+	path, err := cmd.Flags().GetString("state-file")
+	if err != nil {
+		console.Error("error", err)
+		return "", err
+	}
+
+	return cohortPath(path, cohort), nil
+}
+
+// resumeStates reads the state of an earlier run of the load for each cohort, by cohort name. Cohorts
+// that weren't started by an earlier run have no state.
+func resumeStates(cmd *cobra.Command, cohorts []*spreadsheet.Cohort) (map[string]*processor.LoadState, error) {
+	states := make(map[string]*processor.LoadState)
+	for _, cohort := range cohorts {
+		path, err := loadStatePath(cmd, cohort.Name)
+		if err != nil || path == "" {
+			return states, err
+		}
+
+		state, err := processor.ReadLoadState(path)
+		if err != nil {
+			console.Error("Unable to read the state of the earlier load:", err)
+			return nil, err
+		}
+
+		if state != nil {
+			fmt.Printf("Resuming the load recorded in %s\n", path)
+			states[cohort.Name] = state
+		}
+	}
+
+	return states, nil
+}
+
+// anyState returns one of the states, nil if there are none.
+func anyState(states map[string]*processor.LoadState) *processor.LoadState {
+	for _, state := range states {
+		return state
+	}
+
+	return nil
+}
+
+// stateIgnoredFlags are the flags that don't change what a load creates, so they can differ when a load is
+// resumed. files and the samples flags are part of the workbook fingerprint, and the project and no-experiment
+// flags are checked by checkResumeStates.
+var stateIgnoredFlags = map[string]bool{
+	"apikey": true, "apikey-in-query": true, "mcurl": true, "config": true, "help": true,
+	"files": true, "samples": true, "samples-file": true,
+	"project-id": true, "project-name": true, "experiment-name": true, "no-experiment": true,
+	"state-file": true, "report": true, "report-interval": true, "genealogy": true, "summary-dir": true,
+	"errors-file": true, "max-errors": true, "output": true, "no-cache": true,
+	"force": true, "max-samples": true, "max-processes": true, "bundle-dir": true,
+	"schedule": true, "concurrency": true, "measurement-workers": true, "verify-every": true,
+	"max-idle-conns": true, "max-conns": true, "no-http2": true,
+}
+
+// stateFlags returns the flags that decide what the load creates, by name, with their values.
+func stateFlags(cmd *cobra.Command) map[string]string {
+	flags := make(map[string]string)
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if !stateIgnoredFlags[f.Name] {
+			flags[f.Name] = f.Value.String()
+		}
+	})
+
+	return flags
+}
+
+// checkResumeStates checks that the states are for a load into the project, with an experiment unless
+// noExperiment is true, of the workbooks with fingerprint and with the same flags.
+func checkResumeStates(states map[string]*processor.LoadState, projectID string, noExperiment bool, fingerprint string,
+	flags map[string]string) error {
+	for _, state := range states {
+		err := state.CheckInputs(fingerprint, flags)
+		switch {
+		case err != nil:
+			err = errors.Errorf("%s, remove it to start a new load", err)
+		case state.ProjectID != projectID:
+			err = errors.Errorf("the state file is for a load into project %s, not %s", state.ProjectID, projectID)
+		case noExperiment && state.ExperimentID != "":
+			err = errors.Errorf("the state file is for a load into experiment %s, it can't be resumed with --no-experiment", state.ExperimentID)
+		case !noExperiment && state.ExperimentID == "":
+			err = errors.Errorf("the state file is for a load with --no-experiment, it can only be resumed with it")
+		}
+
+		if err != nil {
+			console.Error("error", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resumedSampleIDs returns the IDs of the samples created by the earlier runs of the load.
+func resumedSampleIDs(states map[string]*processor.LoadState) map[string]bool {
+	ids := make(map[string]bool)
+	for _, state := range states {
+		for id := range state.SampleIDs() {
+			ids[id] = true
+		}
+	}
+
+	return ids
+}
@@ -2,8 +2,8 @@ package cmd
 
 import (
 	"fmt"
-	"os"
 
+	"github.com/materials-commons/mcetl/internal/console"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -14,13 +14,14 @@ var cfgFile string
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "mcetl",
-	Short: "A brief description of your application",
-	Long: `A longer description that spans multiple lines and likely contains
-examples and usage of using your application. For example:
+	Short: "Loads experimental data from spreadsheets into Materials Commons.",
+	Long: `mcetl performs ETL (extract, transform and load) on Excel spreadsheets describing an experiment.
+Each worksheet describes a process, and the rows describe the samples that went through that process
+along with their process attributes, sample attributes (measurements) and files. mcetl turns the
+spreadsheets into a workflow of processes and samples and creates it in a Materials Commons project.
 
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
+Use "mcetl check" and "mcetl display" to validate a spreadsheet and see the workflow it describes
+before running "mcetl load". Run "mcetl examples" to see a sample spreadsheet and worked examples.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	//	Run: func(cmd *cobra.Command, args []string) { },
@@ -30,8 +31,8 @@ to quickly create a Cobra application.`,
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		console.Error(err)
+		exitCommand(1)
 	}
 }
 
@@ -42,10 +43,6 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.mcetl.yaml)")
-
-	// Cobra also supports local flags, which will only run
-	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -57,8 +54,8 @@ func initConfig() {
 		// Find home directory.
 		home, err := homedir.Dir()
 		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+			console.Error(err)
+			exitCommand(1)
 		}
 
 		// Search config in home directory with name ".mcetl" (without extension).
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/selftest"
+	"github.com/spf13/cobra"
+)
+
+// selftestCmd represents the selftest command
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Runs an end to end smoke test against a built in mock server.",
+	Long: `The selftest command loads a canned workbook into a mock Materials Commons server that runs inside
+mcetl, and checks that the expected API calls were made. No server or network access is needed.
+
+With --serve the mock server is run on its own so that other tools (or mcetl load) can be pointed at it.`,
+	Example: `  mcetl selftest
+  mcetl selftest --serve :5016
+  mcetl load -f study.xlsx -p proj -n test -u http://localhost:5016/api -k test`,
+	Run: cliCmdSelftest,
+}
+
+func init() {
+	rootCmd.AddCommand(selftestCmd)
+	selftestCmd.Flags().String("serve", "", "Run the mock server on this address instead of running the self test")
+}
+
+func cliCmdSelftest(cmd *cobra.Command, args []string) {
+	addr, err := cmd.Flags().GetString("serve")
+	if err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	if addr != "" {
+		fmt.Println("Mock server listening on", addr)
+		if err := http.ListenAndServe(addr, selftest.NewMockServer()); err != nil {
+			console.Error("error", err)
+			exitCommand(1)
+		}
+		return
+	}
+
+	if err := selftest.Run(os.Stdout); err != nil {
+		console.Error("Self test FAILED")
+		console.Error(" ", err)
+		exitCommand(1)
+	}
+
+	fmt.Println("Self test passed")
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/mcapix"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/spf13/cobra"
+)
+
+// templateCmd represents the template command
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Writes a starter workbook with a worksheet for each process, laid out the way mcetl loads it.",
+	Long: `The template command writes an empty workbook to start a spreadsheet from. It has a worksheet for
+each of the processes given, in order, with the sample, parent, p:, s: and file: header columns that load
+with --has-parent, and a comment on each header saying what goes in the column. With --from-server each
+worksheet is named exactly as the process template with the same name on the server, so its processes
+are created from that template. A process whose name is longer than 31 characters, or has one of the
+characters []:*?/\ that Excel doesn't allow in a worksheet name, is warned about and its worksheet given a
+shortened name. The workbook then starts with an index sheet giving each worksheet's template, and is
+loaded with --index-sheet.`,
+	Example: `  mcetl template -o heat-treatment.xlsx --processes "Casting,Heat Treatment,SEM"
+  mcetl template -o heat-treatment.xlsx --processes "Heat Treatment,SEM" --from-server -k <apikey>`,
+	Run: cliCmdTemplate,
+}
+
+func init() {
+	rootCmd.AddCommand(templateCmd)
+	templateCmd.Flags().StringP("output", "o", "", "Workbook to write")
+	templateCmd.Flags().String("processes", "", "Comma separated names of the processes to write worksheets for, in the order they are done")
+	templateCmd.Flags().Bool("from-server", false, "Name the worksheets after the server's process templates with the same names")
+	templateCmd.Flags().StringP("mcurl", "u", "http://localhost:5016/api", "URL for the API service")
+	templateCmd.Flags().StringP("apikey", "k", "", "apikey to pass in REST API calls")
+	templateCmd.Flags().Bool("no-cache", false, "Always read the server's templates rather than using what was cached from an earlier run")
+	templateCmd.Flags().Bool("apikey-in-query", false, "Send the apikey as a query parameter rather than in the Authorization header, for older servers that reject the header")
+}
+
+func cliCmdTemplate(cmd *cobra.Command, args []string) {
+	var (
+		output     string
+		processes  string
+		fromServer bool
+		err        error
+	)
+
+	if output, err = cmd.Flags().GetString("output"); err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	if processes, err = cmd.Flags().GetString("processes"); err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	if fromServer, err = cmd.Flags().GetBool("from-server"); err != nil {
+		console.Error("error", err)
+		exitCommand(1)
+	}
+
+	if output == "" || processes == "" {
+		console.Error("You must specify the workbook to write (-o) and the processes (--processes)")
+		exitCommand(1)
+	}
+
+	var starters []spreadsheet.StarterProcess
+	for _, name := range strings.Split(processes, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			starters = append(starters, spreadsheet.StarterProcess{Name: name})
+		}
+	}
+
+	if fromServer {
+		client, err := createAPIClient(cmd)
+		if err != nil {
+			exitCommand(1)
+		}
+
+		templates, err := cachingClient(cmd, client).ListTemplates()
+		if err != nil {
+			console.Error("Unable to list templates:", err)
+			exitCommand(1)
+		}
+
+		for i := range starters {
+			template := findTemplate(starters[i].Name, templates)
+			if template == nil {
+				console.Warningf("there is no process template named '%s'\n", starters[i].Name)
+				continue
+			}
+			// The worksheet is named after the template so its processes are created from it
+			starters[i].Name = template.Name
+		}
+	}
+
+	workbook, err := spreadsheet.WriteStarterWorkbook(output, starters)
+	if err != nil {
+		console.Error("Unable to write workbook:", err)
+		exitCommand(1)
+	}
+
+	fmt.Printf("Wrote %s with worksheets: %s\n", output, strings.Join(workbook.Worksheets, ", "))
+	fmt.Println("Fill in a row for each sample in each process, then check it with:")
+	if workbook.IndexSheet != "" {
+		fmt.Println("  mcetl check -f " + quoteArg(output) + " --has-parent --index-sheet")
+	} else {
+		fmt.Println("  mcetl check -f " + quoteArg(output) + " --has-parent")
+	}
+}
+
+// findTemplate returns the template whose name, or id, is name, ignoring case. It returns nil if there
+// isn't one.
+func findTemplate(name string, templates []mcapix.Template) *mcapix.Template {
+	for i, template := range templates {
+		if strings.EqualFold(template.Name, name) || template.ID == name {
+			return &templates[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,414 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+	"github.com/spf13/cobra"
+)
+
+// tuiCmd represents the tui command
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Interactively review the spreadsheet(s), change column types and skip worksheets, then load.",
+	Long: `The tui command loads the spreadsheets and then reads commands from the terminal to show the
+worksheets, their columns and the type each column was given, the warnings and errors, and the
+workflow. Column types can be changed and worksheets skipped without editing the spreadsheet, the
+spreadsheets are reloaded after each change. The load command then performs the ETL using the load
+flags given to tui. Type help for the list of commands.`,
+	Example: `  mcetl tui -f heat-treatment.xlsx --has-parent -p <project-id> -n "Heat Treatment Study"
+  mcetl tui -f casting.xlsx,rolling.xlsx -t --bundle-dir bundle`,
+	Run: cliCmdTUI,
+}
+
+func init() {
+	rootCmd.AddCommand(tuiCmd)
+	addLoaderFlags(tuiCmd)
+	addLoadFlags(tuiCmd)
+}
+
+// tuiSession is the state of a tui command while the user reviews the spreadsheets.
+type tuiSession struct {
+	cmd     *cobra.Command
+	loader  *spreadsheet.Loader
+	options processor.WorkflowOptions
+
+	// sheets are the names of the worksheets in the order they were first loaded, including those
+	// that are now skipped. Commands refer to worksheets by their number in this list.
+	sheets []string
+
+	// worksheets and err are the result of the last reload
+	worksheets []*model.Worksheet
+	err        error
+}
+
+// tuiCommand is a command the user can type. args describes its arguments for help.
+type tuiCommand struct {
+	name string
+	args string
+	help string
+	run  func(t *tuiSession, arg string) (done bool)
+}
+
+// tuiCommands returns the commands in the order they are listed by help.
+func tuiCommands() []tuiCommand {
+	return []tuiCommand{
+		{"sheets", "", "List the worksheets", (*tuiSession).showSheets},
+		{"columns", "SHEET", "List the columns of a worksheet and their types", (*tuiSession).showColumns},
+		{"type", "SHEET COLUMN TYPE", "Change a column's type to process, sample, file, ignore or auto (from the header)", (*tuiSession).setColumnType},
+		{"skip", "SHEET", "Don't load a worksheet", (*tuiSession).skipSheet},
+		{"unskip", "SHEET", "Load a skipped worksheet again", (*tuiSession).unskipSheet},
+		{"warnings", "", "Reload and show the warnings and errors", (*tuiSession).showWarnings},
+		{"workflow", "", "Show the workflow that would be created", (*tuiSession).showWorkflow},
+		{"load", "", "Load the worksheets using the load flags and exit", (*tuiSession).load},
+		{"help", "", "Show this list of commands", (*tuiSession).showHelp},
+		{"quit", "", "Exit without loading", (*tuiSession).quit},
+	}
+}
+
+// tuiColumnTypes are the column types that can be given to the type command.
+var tuiColumnTypes = map[string]spreadsheet.ColumnAttributeType{
+	"process": spreadsheet.ProcessAttributeColumn,
+	"sample":  spreadsheet.SampleAttributeColumn,
+	"file":    spreadsheet.FileAttributeColumn,
+	"ignore":  spreadsheet.IgnoreAttributeColumn,
+}
+
+func cliCmdTUI(cmd *cobra.Command, args []string) {
+	loader, err := loaderFromFlags(cmd)
+	if err != nil {
+		exitCommand(1)
+	}
+
+	options, err := workflowOptionsFromFlags(cmd)
+	if err != nil {
+		exitCommand(1)
+	}
+
+	loader.ColumnTypes = make(map[string]map[int]spreadsheet.ColumnAttributeType)
+	t := &tuiSession{cmd: cmd, loader: loader, options: options}
+	t.reload()
+	t.showSheets("")
+	fmt.Println(`Type help for the list of commands. SHEET is a worksheet's number from "sheets" or its name.`)
+
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("mcetl> ")
+		if !in.Scan() {
+			fmt.Println()
+			return
+		}
+
+		fields := strings.Fields(in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		command := t.findCommand(fields[0])
+		if command == nil {
+			fmt.Printf("Unknown command '%s', type help for the list of commands\n", fields[0])
+			continue
+		}
+
+		arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(in.Text()), fields[0]))
+		if command.args == "" && arg != "" {
+			fmt.Printf("%s doesn't take any arguments\n", command.name)
+			continue
+		} else if command.args != "" && arg == "" {
+			fmt.Printf("usage: %s %s\n", command.name, command.args)
+			continue
+		}
+
+		if done := command.run(t, arg); done {
+			return
+		}
+	}
+}
+
+// findCommand returns the command with the given name, or nil if there isn't one.
+func (t *tuiSession) findCommand(name string) *tuiCommand {
+	for _, command := range tuiCommands() {
+		if command.name == strings.ToLower(name) {
+			return &command
+		}
+	}
+
+	return nil
+}
+
+// reload loads the spreadsheets again with the current skipped worksheets and column types. Warnings
+// are printed as the worksheets are loaded, errors are kept in t.err.
+func (t *tuiSession) reload() {
+	t.worksheets, t.err = t.loader.Load()
+	for _, worksheet := range t.worksheets {
+		t.addSheet(worksheet.Name)
+	}
+
+	if t.err != nil {
+		fmt.Println(`The spreadsheets have errors, type "warnings" to see them`)
+	}
+}
+
+func (t *tuiSession) addSheet(name string) {
+	for _, sheet := range t.sheets {
+		if sheet == name {
+			return
+		}
+	}
+
+	t.sheets = append(t.sheets, name)
+}
+
+// sheetArg returns the worksheet name for a SHEET argument, which is either a number from the sheets
+// command or a name.
+func (t *tuiSession) sheetArg(arg string) string {
+	if n, err := strconv.Atoi(arg); err == nil && n >= 1 && n <= len(t.sheets) {
+		return t.sheets[n-1]
+	}
+
+	return arg
+}
+
+// findWorksheet returns the loaded worksheet with the given name, or nil if it wasn't loaded.
+func (t *tuiSession) findWorksheet(name string) *model.Worksheet {
+	for _, worksheet := range t.worksheets {
+		if worksheet.Name == name {
+			return worksheet
+		}
+	}
+
+	return nil
+}
+
+func (t *tuiSession) isSkipped(name string) bool {
+	for _, sheet := range t.loader.SkipSheets {
+		if sheet == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *tuiSession) showSheets(arg string) bool {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tWORKSHEET\tSAMPLES\tPROCESS ATTRS\tSAMPLE ATTRS\tFILES\tSTATUS")
+	for i, name := range t.sheets {
+		worksheet := t.findWorksheet(name)
+		switch {
+		case t.isSkipped(name):
+			fmt.Fprintf(w, "%d\t%s\t\t\t\t\tskipped\n", i+1, name)
+		case worksheet == nil:
+			fmt.Fprintf(w, "%d\t%s\t\t\t\t\tnot loaded\n", i+1, name)
+		default:
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%d\t\n", i+1, worksheet.Source(), len(worksheet.Samples),
+				len(worksheet.ProcessAttrs), len(worksheet.SampleAttrs), len(worksheet.FileHeaders))
+		}
+	}
+	w.Flush()
+
+	return false
+}
+
+func (t *tuiSession) showColumns(arg string) bool {
+	name := t.sheetArg(arg)
+	worksheet := t.findWorksheet(name)
+	if worksheet == nil {
+		fmt.Printf("Worksheet '%s' isn't loaded\n", name)
+		return false
+	}
+
+	types := t.describeColumns(worksheet)
+	fmt.Println("Worksheet", worksheet.Source())
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COLUMN\tHEADER\tTYPE")
+	for i, header := range worksheet.Headers {
+		column := i + 1
+		if header == "" {
+			continue
+		}
+
+		description := types[column]
+		if _, ok := t.loader.ColumnTypes[name][column]; ok {
+			description += " (changed)"
+		}
+		fmt.Fprintf(w, "%s (%d)\t%s\t%s\n", model.ColumnName(column), column, header, description)
+	}
+	w.Flush()
+
+	return false
+}
+
+// describeColumns returns the type each column of the worksheet was loaded as, by column.
+func (t *tuiSession) describeColumns(worksheet *model.Worksheet) map[int]string {
+	types := make(map[int]string)
+	for _, summary := range worksheet.Columns {
+		types[summary.Column] = summary.Type
+	}
+
+	return types
+}
+
+func (t *tuiSession) setColumnType(arg string) bool {
+	// The worksheet name can contain spaces, so the column and type are taken from the end
+	fields := strings.Fields(arg)
+	if len(fields) < 3 {
+		fmt.Println("usage: type SHEET COLUMN TYPE")
+		return false
+	}
+
+	typeName := strings.ToLower(fields[len(fields)-1])
+	columnArg := fields[len(fields)-2]
+	name := t.sheetArg(strings.Join(fields[:len(fields)-2], " "))
+
+	worksheet := t.findWorksheet(name)
+	if worksheet == nil {
+		fmt.Printf("Worksheet '%s' isn't loaded\n", name)
+		return false
+	}
+
+	column, err := columnNumber(columnArg)
+	if err != nil || column > len(worksheet.Headers) {
+		fmt.Printf("Worksheet %s has no column '%s'\n", worksheet.Source(), columnArg)
+		return false
+	}
+
+	if types := t.describeColumns(worksheet); types[column] == "sample name" || types[column] == "parent" {
+		fmt.Printf("Column %s is the %s column, its type can't be changed\n", model.ColumnName(column), types[column])
+		return false
+	}
+
+	if typeName == "auto" {
+		delete(t.loader.ColumnTypes[name], column)
+	} else if columnType, ok := tuiColumnTypes[typeName]; ok {
+		if t.loader.ColumnTypes[name] == nil {
+			t.loader.ColumnTypes[name] = make(map[int]spreadsheet.ColumnAttributeType)
+		}
+		t.loader.ColumnTypes[name][column] = columnType
+	} else {
+		fmt.Printf("Unknown column type '%s', use process, sample, file, ignore or auto\n", typeName)
+		return false
+	}
+
+	t.reload()
+	return t.showColumns(name)
+}
+
+// columnNumber converts a column given as a number (starting at 1) or as letters, eg C, to its number.
+func columnNumber(arg string) (int, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 {
+			return 0, fmt.Errorf("invalid column %d", n)
+		}
+		return n, nil
+	}
+
+	column := 0
+	for _, c := range strings.ToUpper(arg) {
+		if c < 'A' || c > 'Z' {
+			return 0, fmt.Errorf("invalid column '%s'", arg)
+		}
+		column = column*26 + int(c-'A') + 1
+	}
+
+	return column, nil
+}
+
+func (t *tuiSession) skipSheet(arg string) bool {
+	name := t.sheetArg(arg)
+	if t.isSkipped(name) {
+		fmt.Printf("Worksheet '%s' is already skipped\n", name)
+		return false
+	}
+
+	t.addSheet(name)
+	t.loader.SkipSheets = append(t.loader.SkipSheets, name)
+	t.reload()
+	return t.showSheets("")
+}
+
+func (t *tuiSession) unskipSheet(arg string) bool {
+	name := t.sheetArg(arg)
+	var skipped []string
+	for _, sheet := range t.loader.SkipSheets {
+		if sheet != name {
+			skipped = append(skipped, sheet)
+		}
+	}
+
+	if len(skipped) == len(t.loader.SkipSheets) {
+		fmt.Printf("Worksheet '%s' isn't skipped\n", name)
+		return false
+	}
+
+	t.loader.SkipSheets = skipped
+	t.reload()
+	return t.showSheets("")
+}
+
+func (t *tuiSession) showWarnings(arg string) bool {
+	t.reload()
+	if t.err != nil {
+		printLoadErrors(t.cmd, t.loader, "Loading spreadsheet failed:", t.err)
+		return false
+	}
+
+	if err := checkDuplicateSamples(t.cmd, t.options, t.worksheets); err == nil {
+		fmt.Println("No errors found")
+	}
+
+	checkOrphanSamples(t.options, t.worksheets)
+
+	return false
+}
+
+func (t *tuiSession) showWorkflow(arg string) bool {
+	if t.err != nil {
+		fmt.Println(`The workflow can't be shown until the errors are fixed, type "warnings" to see them`)
+		return false
+	}
+
+	displayer := processor.NewDisplayer()
+	displayer.WorkflowOptions = t.options
+	displayer.HideWorksheets = true
+	if err := displayer.Apply(t.worksheets); err != nil {
+		console.Error("Unable to process spreadsheet:", err)
+	}
+
+	return false
+}
+
+func (t *tuiSession) load(arg string) bool {
+	if t.err != nil {
+		fmt.Println(`The spreadsheets can't be loaded until the errors are fixed, type "warnings" to see them`)
+		return false
+	}
+
+	if err := loadWorksheets(t.cmd, t.worksheets); err != nil {
+		exitCommand(1)
+	}
+
+	return true
+}
+
+func (t *tuiSession) showHelp(arg string) bool {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, command := range tuiCommands() {
+		fmt.Fprintf(w, "%s %s\t%s\n", command.name, command.args, command.help)
+	}
+	w.Flush()
+
+	return false
+}
+
+func (t *tuiSession) quit(arg string) bool {
+	return true
+}
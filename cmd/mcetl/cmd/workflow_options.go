@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+	"github.com/spf13/cobra"
+)
+
+// workflowOptionsFromFlags creates the processor.WorkflowOptions from the command line flags that
+// control how the workflow is constructed. Commands that construct a workflow must define the
+// has-parent, normalize-units, samples-sheet, create-process-name, process-name-attrs and
+// skip-orphan-samples flags.
+func workflowOptionsFromFlags(cmd *cobra.Command) (processor.WorkflowOptions, error) {
+	var (
+		options processor.WorkflowOptions
+		err     error
+	)
+
+	if options.HasParent, err = cmd.Flags().GetBool("has-parent"); err != nil {
+		console.Error("error", err)
+		return options, err
+	}
+
+	if options.NormalizeUnits, err = cmd.Flags().GetBool("normalize-units"); err != nil {
+		console.Error("error", err)
+		return options, err
+	}
+
+	if options.SamplesSheet, err = cmd.Flags().GetString("samples-sheet"); err != nil {
+		console.Error("error", err)
+		return options, err
+	}
+
+	if options.CreateProcessName, err = cmd.Flags().GetString("create-process-name"); err != nil {
+		console.Error("error", err)
+		return options, err
+	}
+
+	if options.ProcessNameAttrs, err = cmd.Flags().GetBool("process-name-attrs"); err != nil {
+		console.Error("error", err)
+		return options, err
+	}
+
+	if options.SkipOrphanSamples, err = cmd.Flags().GetBool("skip-orphan-samples"); err != nil {
+		console.Error("error", err)
+		return options, err
+	}
+
+	return options, nil
+}
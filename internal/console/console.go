@@ -0,0 +1,86 @@
+// Package console prints mcetl's warnings and errors. Each is printed with its type, and where in the
+// workbooks it is about when it is about a cell, rather than as a line of text whose meaning has to be
+// guessed from how it starts. The commands with --output json set a Handler that turns them into JSON
+// events, otherwise they are printed to stdout as text.
+package console
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// The types of event.
+const (
+	TypeMessage = "message"
+	TypeWarning = "warning"
+	TypeError   = "error"
+)
+
+// Event is a message, warning or error.
+type Event struct {
+	Type    string
+	Message string
+
+	// Worksheet, File, Row and Column are where the event is about, when it is about a cell in a workbook.
+	// Row starts at 1 and Column is a column name such as C.
+	Worksheet string
+	File      string
+	Row       int
+	Column    string
+}
+
+var (
+	mu      sync.Mutex
+	handler func(Event)
+)
+
+// SetHandler sets the function each event is given to instead of being printed, nil to print them.
+func SetHandler(h func(Event)) {
+	mu.Lock()
+	defer mu.Unlock()
+	handler = h
+}
+
+// Print prints the event, or gives it to the handler when one is set. A warning is printed with a
+// "Warning: " prefix, the prefix isn't part of its message.
+func Print(event Event) {
+	event.Message = strings.TrimRight(event.Message, "\n")
+
+	mu.Lock()
+	h := handler
+	mu.Unlock()
+
+	if h != nil {
+		h(event)
+		return
+	}
+
+	if event.Type == TypeWarning {
+		fmt.Fprintln(os.Stdout, "Warning: "+event.Message)
+		return
+	}
+
+	fmt.Fprintln(os.Stdout, event.Message)
+}
+
+// Warning prints a warning, formatting its arguments like fmt.Println.
+func Warning(a ...interface{}) {
+	Print(Event{Type: TypeWarning, Message: fmt.Sprintln(a...)})
+}
+
+// Warningf prints a warning, formatting it like fmt.Printf.
+func Warningf(format string, a ...interface{}) {
+	Print(Event{Type: TypeWarning, Message: fmt.Sprintf(format, a...)})
+}
+
+// Error prints an error, formatting its arguments like fmt.Println.
+func Error(a ...interface{}) {
+	Print(Event{Type: TypeError, Message: fmt.Sprintln(a...)})
+}
+
+// Errorf prints an error, formatting it like fmt.Printf.
+func Errorf(format string, a ...interface{}) {
+	Print(Event{Type: TypeError, Message: fmt.Sprintf(format, a...)})
+}
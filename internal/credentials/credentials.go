@@ -0,0 +1,170 @@
+// Package credentials stores the apikey so that it doesn't have to be given on the command line,
+// where it ends up in the shell history. The key is kept in the OS keyring when one is available,
+// otherwise in $HOME/.materialscommons/config.json, which is only readable by the user.
+package credentials
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// Where describes where a stored apikey was saved.
+type Where string
+
+const (
+	InKeyring    Where = "OS keyring"
+	InConfigFile Where = "config file"
+)
+
+// SaveAPIKey stores the apikey, preferring the OS keyring. If the key is saved in the keyring any copy
+// in the config file is removed.
+func SaveAPIKey(apikey string) (Where, error) {
+	if err := keyringSet(apikey); err == nil {
+		_ = removeFromConfigFile()
+		return InKeyring, nil
+	}
+
+	if err := saveToConfigFile(apikey); err != nil {
+		return "", err
+	}
+
+	return InConfigFile, nil
+}
+
+// LoadAPIKey returns the stored apikey, or "" if no key has been stored.
+func LoadAPIKey() string {
+	if apikey, err := keyringGet(); err == nil && apikey != "" {
+		return apikey
+	}
+
+	apikey, _ := loadFromConfigFile()
+	return apikey
+}
+
+// DeleteAPIKey removes the stored apikey from both the keyring and the config file. It returns
+// false if there was no key to remove.
+func DeleteAPIKey() (bool, error) {
+	deleted := keyringDelete() == nil
+
+	apikey, err := loadFromConfigFile()
+	if err != nil {
+		return deleted, err
+	}
+
+	if apikey == "" {
+		return deleted, nil
+	}
+
+	if err := removeFromConfigFile(); err != nil {
+		return deleted, err
+	}
+
+	return true, nil
+}
+
+var apikeyRegex = regexp.MustCompile(`(?i)(apikey["']?\s*[=:]\s*["']?)[^&\s"']+`)
+
+// Redact replaces apikey values, such as the apikey query parameter in a URL, with REDACTED so
+// that they don't end up in error output or log files.
+func Redact(s string) string {
+	return apikeyRegex.ReplaceAllString(s, "${1}REDACTED")
+}
+
+// ConfigFilePath returns the path to the config file used when the OS keyring isn't available.
+func ConfigFilePath() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".materialscommons", "config.json"), nil
+}
+
+// readConfigFile reads the config file as a generic map so that settings other than the apikey
+// are preserved when it is rewritten.
+func readConfigFile() (map[string]interface{}, error) {
+	settings := make(map[string]interface{})
+
+	path, err := ConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return settings, nil
+	case err != nil:
+		return nil, err
+	case len(contents) == 0:
+		return settings, nil
+	}
+
+	if err := json.Unmarshal(contents, &settings); err != nil {
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func writeConfigFile(settings map[string]interface{}) error {
+	path, err := ConfigFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	contents, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, contents, 0600); err != nil {
+		return err
+	}
+
+	// WriteFile only applies the permissions when it creates the file.
+	return os.Chmod(path, 0600)
+}
+
+func loadFromConfigFile() (string, error) {
+	settings, err := readConfigFile()
+	if err != nil {
+		return "", err
+	}
+
+	apikey, _ := settings["apikey"].(string)
+	return apikey, nil
+}
+
+func saveToConfigFile(apikey string) error {
+	settings, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+
+	settings["apikey"] = apikey
+	return writeConfigFile(settings)
+}
+
+func removeFromConfigFile() error {
+	settings, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := settings["apikey"]; !ok {
+		return nil
+	}
+
+	delete(settings, "apikey")
+	return writeConfigFile(settings)
+}
@@ -0,0 +1,99 @@
+package credentials
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// The keyring entry is identified by service and account names, both in the macOS keychain and in the
+// freedesktop secret service used on Linux.
+const (
+	keyringService = "mcetl"
+	keyringAccount = "apikey"
+)
+
+var errNoKeyring = errors.New("no OS keyring available")
+
+// keyringSet stores the apikey in the OS keyring. The key is never a command argument, where any user
+// could see it in the process list. On Linux the secret-tool command (libsecret) is used and the key is
+// passed on stdin. On macOS the security command only takes the password as an argument, or prompts for
+// it on the terminal, so the add-generic-password command is given to security's interactive mode on stdin.
+func keyringSet(apikey string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		if strings.ContainsAny(apikey, "\"\\\r\n") {
+			return errors.New("the apikey has quotes, backslashes or line breaks, which an apikey doesn't")
+		}
+		command := fmt.Sprintf("add-generic-password -U -s %s -a %s -w \"%s\"\n", keyringService, keyringAccount, apikey)
+		if err := run(command, "security", "-i"); err != nil {
+			return err
+		}
+
+		// Interactive mode exits successfully even when the command in it fails
+		if stored, err := keyringGet(); err != nil || stored != apikey {
+			return errors.New("the macOS keychain didn't store the apikey")
+		}
+		return nil
+	case "linux":
+		return run(apikey, "secret-tool", "store", "--label=mcetl apikey", "service", keyringService, "account", keyringAccount)
+	default:
+		return errNoKeyring
+	}
+}
+
+// keyringGet returns the apikey stored in the OS keyring.
+func keyringGet() (string, error) {
+	var out string
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		out, err = output("security", "find-generic-password", "-s", keyringService, "-a", keyringAccount, "-w")
+	case "linux":
+		out, err = output("secret-tool", "lookup", "service", keyringService, "account", keyringAccount)
+	default:
+		return "", errNoKeyring
+	}
+
+	return strings.TrimSpace(out), err
+}
+
+// keyringDelete removes the apikey from the OS keyring.
+func keyringDelete() error {
+	switch runtime.GOOS {
+	case "darwin":
+		return run("", "security", "delete-generic-password", "-s", keyringService, "-a", keyringAccount)
+	case "linux":
+		if _, err := keyringGet(); err != nil {
+			return err
+		}
+		return run("", "secret-tool", "clear", "service", keyringService, "account", keyringAccount)
+	default:
+		return errNoKeyring
+	}
+}
+
+func run(stdin, name string, args ...string) error {
+	if _, err := exec.LookPath(name); err != nil {
+		return errNoKeyring
+	}
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	return cmd.Run()
+}
+
+func output(name string, args ...string) (string, error) {
+	if _, err := exec.LookPath(name); err != nil {
+		return "", errNoKeyring
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	return stdout.String(), err
+}
@@ -0,0 +1,223 @@
+package dates
+
+/*
+ * dates parses the dates in spreadsheets. It is used both to store the dates in cells as ISO-8601 (see
+ * spreadsheet/date_cells.go) and to read the date column that says when a process was performed, so a
+ * date is understood the same way wherever it is. The formats are written with the letters below, any
+ * other character is matched as it is:
+ *
+ *   YYYY  2019      MMMM  March    DD  05     hh  14 (24 hour)
+ *   YY    19        MMM   Mar      D   5      mm  30
+ *                   MM    03                  ss  00
+ *                   M     3                   Z   +05:00 or Z
+ *
+ * Only formats that can't be mistaken for another, such as YYYY-MM-DD, are tried unless others are given, as
+ * 03/04/2019 is the 4th of March in some labs and the 3rd of April in others.
+ */
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFormats are the date formats that are always tried, after any others that are given.
+var DefaultFormats = []string{
+	"YYYY-MM-DDThh:mm:ssZ",
+	"YYYY-MM-DDThh:mm:ss",
+	"YYYY-MM-DD hh:mm:ss",
+	"YYYY-MM-DD hh:mm",
+	"YYYY-MM-DD",
+	"YYYY/MM/DD hh:mm:ss",
+	"YYYY/MM/DD hh:mm",
+	"YYYY/MM/DD",
+	"D MMM YYYY",
+	"D MMMM YYYY",
+	"MMM D, YYYY",
+	"MMMM D, YYYY",
+}
+
+// The ISO-8601 layouts a date is stored in, depending on which parts of it were given
+const (
+	ISODate     = "2006-01-02"
+	ISOTime     = "15:04:05"
+	ISODateTime = "2006-01-02T15:04:05"
+	ISOZoned    = time.RFC3339
+)
+
+// formatTokens map the letters of a date format to the parts of a Go time layout. Longer tokens come
+// first so that MMMM isn't read as MM twice.
+var formatTokens = []struct {
+	token, layout string
+	date, time    bool
+}{
+	{token: "YYYY", layout: "2006", date: true},
+	{token: "YY", layout: "06", date: true},
+	{token: "MMMM", layout: "January", date: true},
+	{token: "MMM", layout: "Jan", date: true},
+	{token: "MM", layout: "01", date: true},
+	{token: "M", layout: "1", date: true},
+	{token: "DD", layout: "02", date: true},
+	{token: "D", layout: "2", date: true},
+	{token: "hh", layout: "15", time: true},
+	{token: "mm", layout: "04", time: true},
+	{token: "ss", layout: "05", time: true},
+	{token: "Z", layout: "Z07:00", time: true},
+}
+
+// excelEpoch is day 0 of Excel's date serial numbers. Using Dec 30th rather than Dec 31st accounts
+// for Excel treating 1900 as a leap year. Workbooks using the 1904 date system count from excelEpoch1904.
+var (
+	excelEpoch     = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	excelEpoch1904 = time.Date(1904, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// maxSerial is the serial number of the day after 9999-12-31, the last date Excel shows.
+const maxSerial = 2958466
+
+// layout is a date format as a Go time layout, and the ISO-8601 layout it is stored in.
+type layout struct {
+	layout string
+	iso    string
+}
+
+// Parser parses dates in the formats it was created with, followed by the DefaultFormats.
+type Parser struct {
+	layouts []layout
+}
+
+// defaultParser parses the DefaultFormats.
+var defaultParser, _ = NewParser(nil)
+
+// NewParser returns a Parser for the formats, which are tried before the DefaultFormats.
+func NewParser(formats []string) (*Parser, error) {
+	p := &Parser{}
+	for _, format := range append(append([]string(nil), formats...), DefaultFormats...) {
+		l, err := parseFormat(format)
+		if err != nil {
+			return nil, err
+		}
+		p.layouts = append(p.layouts, l)
+	}
+
+	return p, nil
+}
+
+// ValidFormats returns an error if one of the date formats isn't valid.
+func ValidFormats(formats []string) error {
+	_, err := NewParser(formats)
+	return err
+}
+
+// Parse returns the date the value is written as, and the ISO-8601 layout for the parts of the date the
+// value gives. The bool is false when the value doesn't match any of the formats.
+func (p *Parser) Parse(value string) (time.Time, string, bool) {
+	// Every date has a digit, which skips most text quickly
+	if !strings.ContainsAny(value, "0123456789") {
+		return time.Time{}, "", false
+	}
+
+	for _, l := range p.layouts {
+		if t, err := time.Parse(l.layout, value); err == nil {
+			return t, l.iso, true
+		}
+	}
+
+	return time.Time{}, "", false
+}
+
+// ParseDate parses the value of a date column cell, which is either in one of the formats or an Excel date
+// serial number (the number of days since 1900, as stored for a cell formatted as a date).
+func (p *Parser) ParseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, _, ok := p.Parse(value); ok {
+		return t, nil
+	}
+
+	if serial, err := strconv.ParseFloat(value, 64); err == nil && serial > 0 {
+		if t, ok := FromSerial(serial, false); ok {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("'%s' is not a date, use a date such as 2019-03-21 or give its format with --date-formats", value)
+}
+
+// Parse parses the value of a date column cell in one of the DefaultFormats, see Parser.ParseDate.
+func Parse(value string) (time.Time, error) {
+	return defaultParser.ParseDate(value)
+}
+
+// FromSerial returns the date for an Excel date serial number. date1904 is set for workbooks that use the
+// 1904 date system. The bool is false if the serial number isn't a date Excel can show.
+func FromSerial(serial float64, date1904 bool) (time.Time, bool) {
+	if serial < 0 || serial >= maxSerial {
+		return time.Time{}, false
+	}
+
+	epoch := excelEpoch
+	if date1904 {
+		epoch = excelEpoch1904
+	}
+
+	// Round to the second to drop floating point noise from the fraction of a day
+	seconds := int64(math.Floor(serial*24*60*60 + 0.5))
+	return epoch.Add(time.Duration(seconds) * time.Second), true
+}
+
+// parseFormat turns a date format, such as DD/MM/YYYY, into a Go time layout.
+func parseFormat(format string) (layout, error) {
+	var (
+		b              strings.Builder
+		hasDate, hasTm bool
+		hasZone        bool
+	)
+
+	rest := strings.TrimSpace(format)
+	if rest == "" {
+		return layout{}, fmt.Errorf("blank date format")
+	}
+
+next:
+	for rest != "" {
+		for _, t := range formatTokens {
+			if strings.HasPrefix(rest, t.token) {
+				b.WriteString(t.layout)
+				hasDate = hasDate || t.date
+				hasTm = hasTm || t.time
+				hasZone = hasZone || t.token == "Z"
+				rest = rest[len(t.token):]
+				continue next
+			}
+		}
+
+		// Digits and letters in a Go layout could be read as part of a date, so only punctuation and
+		// spaces are matched as they are
+		c := rest[0]
+		if c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+			if c == 'T' {
+				b.WriteByte(c)
+				rest = rest[1:]
+				continue
+			}
+			return layout{}, fmt.Errorf("invalid date format '%s', '%c' isn't one of YYYY, YY, MMMM, MMM, MM, M, DD, D, hh, mm, ss or Z", format, c)
+		}
+		b.WriteByte(c)
+		rest = rest[1:]
+	}
+
+	switch {
+	case hasZone && hasDate:
+		return layout{layout: b.String(), iso: ISOZoned}, nil
+	case hasDate && hasTm:
+		return layout{layout: b.String(), iso: ISODateTime}, nil
+	case hasDate:
+		return layout{layout: b.String(), iso: ISODate}, nil
+	case hasTm:
+		return layout{layout: b.String(), iso: ISOTime}, nil
+	default:
+		return layout{}, fmt.Errorf("invalid date format '%s', it has no date or time", format)
+	}
+}
@@ -0,0 +1,73 @@
+package mcapix
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// ResponseCache stores the responses to the requests that read a project's metadata, such as its
+// templates, samples and experiments, with the ETag the server gave each. A cached response is only
+// used when the server answers 304 Not Modified to a request with its ETag, so it is never stale.
+// Responses without an ETag aren't cached.
+type ResponseCache interface {
+	// Get returns the ETag and body of the cached response for key, false if there isn't one
+	Get(key string) (etag string, body []byte, ok bool)
+
+	// Put stores the response for key
+	Put(key, etag string, body []byte) error
+}
+
+// WithCache returns a copy of the client that uses cache. The callers that read a lot of metadata use
+// it, rather than every client caching everything.
+func (c *Client) WithCache(cache ResponseCache) *Client {
+	cached := *c
+	cached.Cache = cache
+	return &cached
+}
+
+// cacheKey returns the key of the response to a request. The apikey is part of it as the response
+// depends on who asks.
+func (c *Client) cacheKey(body interface{}, p string) string {
+	b, _ := json.Marshal(body)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(c.APIKey+"\x00"+p+"\x00"+string(b))))
+}
+
+// postCached is post for requests that read metadata. When the client has a Cache the request is
+// sent with the ETag of the cached response, and the cached response is used if the server says it
+// hasn't changed.
+func (c *Client) postCached(result, body interface{}, paths ...string) error {
+	if c.Cache == nil {
+		return c.post(result, body, paths...)
+	}
+
+	p := c.join(paths...)
+	key := c.cacheKey(body, p)
+	etag, cached, ok := c.Cache.Get(key)
+
+	r := c.r().SetBody(body)
+	if ok {
+		r.SetHeader("If-None-Match", etag)
+	}
+
+	resp, err := r.Post(p)
+	if err == nil && resp.RawResponse.StatusCode == http.StatusNotModified && ok {
+		return json.Unmarshal(cached, result)
+	}
+
+	if err := c.getAPIError(p, resp, err); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(resp.Body(), result); err != nil {
+		return err
+	}
+
+	if etag := resp.Header().Get("ETag"); etag != "" {
+		// The response was read, failing to cache it only means it is fetched again next time
+		_ = c.Cache.Put(key, etag, resp.Body())
+	}
+
+	return nil
+}
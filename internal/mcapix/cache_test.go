@@ -0,0 +1,138 @@
+package mcapix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// memoryCache is a ResponseCache that keeps the responses in memory.
+type memoryCache map[string]struct {
+	etag string
+	body []byte
+}
+
+func (c memoryCache) Get(key string) (string, []byte, bool) {
+	r, ok := c[key]
+	return r.etag, r.body, ok
+}
+
+func (c memoryCache) Put(key, etag string, body []byte) error {
+	c[key] = struct {
+		etag string
+		body []byte
+	}{etag, body}
+	return nil
+}
+
+// templatesServer answers getAllTemplates with a template named after the version, which changes
+// each time the server is asked. etag says whether it sends an ETag, honour whether it answers 304
+// when If-None-Match has the ETag of the version it would send.
+func templatesServer(t *testing.T, etag, honour bool) (*httptest.Server, *[]string) {
+	var (
+		version     int
+		ifNoneMatch []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+		if honour && r.Header.Get("If-None-Match") == fmt.Sprintf(`"v%d"`, version) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		version++
+		if etag {
+			w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, version))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": []Template{{ID: "t", Name: fmt.Sprintf("v%d", version)}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &ifNoneMatch
+}
+
+func listTemplateName(t *testing.T, c *Client) string {
+	t.Helper()
+	templates, err := c.ListTemplates()
+	if err != nil {
+		t.Fatalf("ListTemplates: %s", err)
+	}
+	if len(templates) != 1 {
+		t.Fatalf("ListTemplates returned %d templates, want 1", len(templates))
+	}
+	return templates[0].Name
+}
+
+func TestCacheUsedWhenNotModified(t *testing.T) {
+	server, ifNoneMatch := templatesServer(t, true, true)
+	cache := memoryCache{}
+	c := NewClient(server.URL).WithCache(cache)
+
+	if name := listTemplateName(t, c); name != "v1" {
+		t.Fatalf("first read got %s, want v1", name)
+	}
+	if name := listTemplateName(t, c); name != "v1" {
+		t.Fatalf("read after 304 got %s, want the cached v1", name)
+	}
+
+	if want := []string{"", `"v1"`}; fmt.Sprint(*ifNoneMatch) != fmt.Sprint(want) {
+		t.Errorf("If-None-Match sent %q, want %q", *ifNoneMatch, want)
+	}
+}
+
+func TestCacheReplacedWhenServerIgnoresIfNoneMatch(t *testing.T) {
+	server, ifNoneMatch := templatesServer(t, true, false)
+	cache := memoryCache{}
+	c := NewClient(server.URL).WithCache(cache)
+
+	listTemplateName(t, c)
+	if name := listTemplateName(t, c); name != "v2" {
+		t.Fatalf("second read got %s, want the server's v2", name)
+	}
+	if name := listTemplateName(t, c); name != "v3" {
+		t.Fatalf("third read got %s, want the server's v3", name)
+	}
+
+	if want := []string{"", `"v1"`, `"v2"`}; fmt.Sprint(*ifNoneMatch) != fmt.Sprint(want) {
+		t.Errorf("If-None-Match sent %q, want %q", *ifNoneMatch, want)
+	}
+}
+
+func TestCacheUnusedWithoutETag(t *testing.T) {
+	server, ifNoneMatch := templatesServer(t, false, true)
+	cache := memoryCache{}
+	c := NewClient(server.URL).WithCache(cache)
+
+	listTemplateName(t, c)
+	if name := listTemplateName(t, c); name != "v2" {
+		t.Fatalf("second read got %s, want the server's v2", name)
+	}
+
+	if len(cache) != 0 {
+		t.Errorf("cached %d responses without an ETag", len(cache))
+	}
+	for _, etag := range *ifNoneMatch {
+		if etag != "" {
+			t.Errorf("sent If-None-Match %q without a cached ETag", etag)
+		}
+	}
+}
+
+func TestClientWithoutCache(t *testing.T) {
+	server, ifNoneMatch := templatesServer(t, true, true)
+	c := NewClient(server.URL)
+
+	listTemplateName(t, c)
+	if name := listTemplateName(t, c); name != "v2" {
+		t.Fatalf("second read got %s, want the server's v2", name)
+	}
+	if (*ifNoneMatch)[1] != "" {
+		t.Errorf("client without a cache sent If-None-Match %q", (*ifNoneMatch)[1])
+	}
+}
@@ -0,0 +1,141 @@
+// Package mcapix is the Materials Commons API client that mcetl uses. It wraps the vendored gomcapi
+// client, using its models and errors, and adds the calls, checks and connection handling that mcetl
+// needs but gomcapi doesn't have. gomcapi is vendored by dep, so it can't be changed in place.
+//
+// Only calls that the server provides belong here. A call the server doesn't have is left out rather
+// than guessed at.
+package mcapix
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/gomcapi/pkg/urlpath"
+	"github.com/pkg/errors"
+	"gopkg.in/resty.v1"
+)
+
+// ErrAuth is returned when the server rejects the apikey. It is gomcapi's, so either can be compared with.
+var ErrAuth = mcapi.ErrAuth
+
+// Client makes the calls to the server. Unlike gomcapi's client the apikey is sent in the Authorization
+// header rather than as a query parameter, so it doesn't end up in the server's and proxies' logs.
+type Client struct {
+	APIKey  string
+	BaseURL string
+
+	// APIKeyInQuery sends the apikey as the apikey query parameter rather than in the Authorization
+	// header, for older servers that reject the header. It is only ever set by the caller, a 401 could
+	// as well be a wrong or expired apikey and that shouldn't end up in a URL.
+	APIKeyInQuery bool
+
+	// Cache, when set, keeps the responses to the requests that read metadata between runs, see cache.go
+	Cache ResponseCache
+}
+
+var tlsConfig = tls.Config{InsecureSkipVerify: true}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL: urlpath.Join(baseURL, "v3"),
+	}
+}
+
+func (c *Client) r() *resty.Request {
+	r := httpClient().R()
+	if c.APIKeyInQuery {
+		return r.SetQueryParam("apikey", c.APIKey)
+	}
+
+	return r.SetHeader("Authorization", "Bearer "+c.APIKey)
+}
+
+func (c *Client) join(paths ...string) string {
+	return urlpath.Join(c.BaseURL, paths...)
+}
+
+func (c *Client) post(result, body interface{}, paths ...string) error {
+	p := c.join(paths...)
+	resp, err := c.r().SetResult(&result).SetBody(body).Post(p)
+	return c.getAPIError(p, resp, err)
+}
+
+func (c *Client) getAPIError(p string, resp *resty.Response, err error) error {
+	switch {
+	case err != nil:
+		return c.redact(err)
+	case resp.RawResponse.StatusCode == 401:
+		return ErrAuth
+	case resp.RawResponse.StatusCode > 299:
+		return c.toErrorFromResponse(p, resp)
+	default:
+		return nil
+	}
+}
+
+// redact removes the apikey from err. Transport errors include the request URL, and with it the
+// apikey query parameter.
+func (c *Client) redact(err error) error {
+	if c.APIKey == "" || !strings.Contains(err.Error(), c.APIKey) {
+		return err
+	}
+
+	return errors.New(strings.Replace(err.Error(), c.APIKey, "REDACTED", -1))
+}
+
+// StatusError is returned when the server answers a request with an HTTP error status.
+type StatusError struct {
+	// Endpoint is the URL the request was sent to
+	Endpoint string
+
+	StatusCode int
+
+	// Message is the error the server gave, or the start of the response if it didn't give one
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("mcapi '%s' (HTTP Status: %d %s)- %s", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsUnsupported returns true if err is the server answering that it doesn't have the endpoint, which is how
+// servers that predate a call answer it. Any other error, such as a timeout, a 500 or a 400 for a request the
+// server rejects as invalid, says nothing about whether the server has the call.
+func IsUnsupported(err error) bool {
+	e, ok := err.(*StatusError)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+func (c *Client) toErrorFromResponse(p string, resp *resty.Response) error {
+	var er struct {
+		Error string `json:"error"`
+	}
+
+	if err := json.Unmarshal(resp.Body(), &er); err != nil || er.Error == "" {
+		// Proxies and crashed servers answer with HTML or plain text, show the start of it rather
+		// than only that it isn't JSON
+		return &StatusError{Endpoint: p, StatusCode: resp.RawResponse.StatusCode,
+			Message: bodySnippet(resp.Body())}
+	}
+
+	return &StatusError{Endpoint: p, StatusCode: resp.RawResponse.StatusCode, Message: er.Error}
+}
+
+// bodySnippet returns the start of a response body on one line, for error messages.
+func bodySnippet(body []byte) string {
+	const maxSnippet = 200
+
+	snippet := []rune(strings.Join(strings.Fields(string(body)), " "))
+	switch {
+	case len(snippet) == 0:
+		return "empty response"
+	case len(snippet) > maxSnippet:
+		return "response: " + string(snippet[:maxSnippet]) + "..."
+	default:
+		return "response: " + string(snippet)
+	}
+}
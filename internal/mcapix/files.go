@@ -0,0 +1,72 @@
+package mcapix
+
+import mcapi "github.com/materials-commons/gomcapi"
+
+func (c *Client) GetFileByPathInProject(filePath, projectID string) (*mcapi.File, error) {
+	var result struct {
+		Data mcapi.File `json:"data"`
+	}
+
+	body := struct {
+		ProjectID string `json:"project_id"`
+		Path      string `json:"path"`
+	}{
+		ProjectID: projectID,
+		Path:      filePath,
+	}
+
+	if err := c.post(&result, body, "etl:getFileByPath"); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
+
+// GetProjectFileIndex retrieves the path of every file in the project in a single call. The paths
+// are in the same form as the paths given to GetFileByPathInProject.
+func (c *Client) GetProjectFileIndex(projectID string) ([]string, error) {
+	var result struct {
+		Data struct {
+			Paths []string `json:"paths"`
+		} `json:"data"`
+	}
+
+	body := struct {
+		ProjectID string `json:"project_id"`
+	}{
+		ProjectID: projectID,
+	}
+
+	if err := c.post(&result, body, "etl:getProjectFileIndex"); err != nil {
+		return nil, err
+	}
+
+	return result.Data.Paths, nil
+}
+
+// CreateDirectoryByPath creates the directory in the project, along with any of its parent
+// directories that don't exist. The path is in the same form as the paths given to
+// GetFileByPathInProject.
+func (c *Client) CreateDirectoryByPath(projectID, dirPath string) (*mcapi.File, error) {
+	var result struct {
+		Data mcapi.File `json:"data"`
+	}
+
+	body := struct {
+		ProjectID string `json:"project_id"`
+		Path      string `json:"path"`
+	}{
+		ProjectID: projectID,
+		Path:      dirPath,
+	}
+
+	if err := c.post(&result, body, "etl:createDirectoryByPath"); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkResponse(body, []string{"etl:createDirectoryByPath"}, field{"id", result.Data.ID}); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
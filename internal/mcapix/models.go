@@ -0,0 +1,22 @@
+package mcapix
+
+// A Template is a process template, it describes a type of process
+type Template struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Owner       string `json:"owner"`
+	Description string `json:"description"`
+	ProcessType string `json:"process_type"`
+	Category    string `json:"category"`
+}
+
+// Measurement is gomcapi's Measurement with the metadata that is stored with it
+type Measurement struct {
+	OType         string      `json:"otype"`
+	Unit          string      `json:"unit"`
+	Value         interface{} `json:"value"`
+	IsBestMeasure bool        `json:"is_best_measure"`
+
+	// Metadata is stored with the measurement, eg where the value came from
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
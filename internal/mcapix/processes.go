@@ -0,0 +1,75 @@
+package mcapix
+
+import (
+	"time"
+
+	mcapi "github.com/materials-commons/gomcapi"
+)
+
+func (c *Client) CreateProcess(projectID, experimentID, name, processType string, setups []mcapi.Setup) (*mcapi.Process, error) {
+	return c.createProcess(projectID, experimentID, name, processType, setups, nil)
+}
+
+// CreateProcessPerformedAt creates a process whose timestamp is when it was performed rather than
+// when it was created.
+func (c *Client) CreateProcessPerformedAt(projectID, experimentID, name, processType string, setups []mcapi.Setup, performed time.Time) (*mcapi.Process, error) {
+	birthtime := mcapi.Timestamp(performed)
+	return c.createProcess(projectID, experimentID, name, processType, setups, &birthtime)
+}
+
+func (c *Client) createProcess(projectID, experimentID, name, processType string, setups []mcapi.Setup, birthtime *mcapi.Timestamp) (*mcapi.Process, error) {
+	var result struct {
+		Data mcapi.Process `json:"data"`
+	}
+
+	if setups == nil {
+		setups = make([]mcapi.Setup, 0)
+	}
+
+	body := struct {
+		ProjectID    string           `json:"project_id"`
+		ExperimentID string           `json:"experiment_id,omitempty"`
+		Name         string           `json:"name"`
+		ProcessType  string           `json:"process_type"`
+		Attributes   []mcapi.Setup    `json:"attributes"`
+		Birthtime    *mcapi.Timestamp `json:"birthtime,omitempty"`
+	}{
+		ProjectID:    projectID,
+		ExperimentID: experimentID,
+		Name:         name,
+		Attributes:   setups,
+		ProcessType:  processType,
+		Birthtime:    birthtime,
+	}
+
+	if err := c.post(&result, body, "createProcess"); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkResponse(body, []string{"createProcess"}, field{"id", result.Data.ID}); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
+
+// GetProcess retrieves a process in the project, including its input and output samples.
+func (c *Client) GetProcess(projectID, processID string) (*mcapi.Process, error) {
+	var result struct {
+		Data mcapi.Process `json:"data"`
+	}
+
+	body := struct {
+		ProjectID string `json:"project_id"`
+		ProcessID string `json:"process_id"`
+	}{
+		ProjectID: projectID,
+		ProcessID: processID,
+	}
+
+	if err := c.post(&result, body, "getProcess"); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
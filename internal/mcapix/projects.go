@@ -0,0 +1,77 @@
+package mcapix
+
+import mcapi "github.com/materials-commons/gomcapi"
+
+func (c *Client) CreateProject(name, description string) (*mcapi.Project, error) {
+	body := struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}{
+		Name:        name,
+		Description: description,
+	}
+
+	var result struct {
+		Data mcapi.Project `json:"data"`
+	}
+
+	if err := c.post(&result, body, "createProject"); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkResponse(body, []string{"createProject"}, field{"id", result.Data.ID}); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
+
+func (c *Client) GetProjectOverviewByName(name string) (*mcapi.Project, error) {
+	body := struct {
+		Name string `json:"name"`
+	}{
+		Name: name,
+	}
+
+	var result struct {
+		Data mcapi.Project `json:"data"`
+	}
+
+	if err := c.post(&result, body, "getProjectOverviewByName"); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
+
+func (c *Client) GetProjectOverview(projectID string) (*mcapi.Project, error) {
+	body := struct {
+		ProjectID string `json:"project_id"`
+	}{
+		ProjectID: projectID,
+	}
+
+	var result struct {
+		Data mcapi.Project `json:"data"`
+	}
+
+	if err := c.postCached(&result, body, "getProjectOverview"); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
+
+// ListProjects returns the projects the user has access to. Only the project overview fields are
+// filled in, the experiments, samples, etc... are not included.
+func (c *Client) ListProjects() ([]mcapi.Project, error) {
+	var result struct {
+		Data []mcapi.Project `json:"data"`
+	}
+
+	if err := c.post(&result, struct{}{}, "getProjectsForUser"); err != nil {
+		return nil, err
+	}
+
+	return result.Data, nil
+}
@@ -0,0 +1,56 @@
+package mcapix
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	mcapi "github.com/materials-commons/gomcapi"
+)
+
+// MalformedResponseError is returned when the server answers a request with success but the response
+// is missing something the request should have produced, such as the id of a created sample. Without
+// it the empty value would be used by the calls that follow, and fail far from the call that caused it.
+type MalformedResponseError struct {
+	// Endpoint is the URL the request was sent to
+	Endpoint string
+
+	// Digest identifies the body of the request, so it can be matched to the server's logs
+	Digest string
+
+	// Missing is the field that the response doesn't have, eg property_set_id
+	Missing string
+}
+
+func (e *MalformedResponseError) Error() string {
+	return fmt.Sprintf("mcapi '%s' - the server's response is missing %s (request body sha256 %s)", e.Endpoint, e.Missing, e.Digest)
+}
+
+// field is a field that a response must have, and the value it was given.
+type field struct {
+	name  string
+	value string
+}
+
+// checkResponse returns a MalformedResponseError for the first of the fields that is blank in the
+// response to the request with body sent to paths.
+func (c *Client) checkResponse(body interface{}, paths []string, fields ...field) error {
+	for _, f := range fields {
+		if f.value == "" {
+			return &MalformedResponseError{Endpoint: c.join(paths...), Digest: bodyDigest(body), Missing: f.name}
+		}
+	}
+
+	return nil
+}
+
+// checkSampleResponse checks that a sample in a response has its id and property set id.
+func (c *Client) checkSampleResponse(sample mcapi.Sample, body interface{}, paths ...string) error {
+	return c.checkResponse(body, paths, field{"id", sample.ID}, field{"property_set_id", sample.PropertySetID})
+}
+
+// bodyDigest returns the start of the sha256 of the request body, which is enough to tell requests apart.
+func bodyDigest(body interface{}) string {
+	b, _ := json.Marshal(body)
+	return fmt.Sprintf("%x", sha256.Sum256(b))[:16]
+}
@@ -0,0 +1,295 @@
+package mcapix
+
+import (
+	"fmt"
+
+	mcapi "github.com/materials-commons/gomcapi"
+)
+
+// CreateSample creates a sample in the experiment. A blank experimentID creates it in the project
+// without an experiment, as it does for the other calls that take an experimentID.
+func (c *Client) CreateSample(projectID, experimentID, name string, attributes []mcapi.Property) (*mcapi.Sample, error) {
+	var result struct {
+		Data mcapi.Sample `json:"data"`
+	}
+
+	if attributes == nil {
+		attributes = make([]mcapi.Property, 0)
+	}
+
+	body := struct {
+		ProjectID    string           `json:"project_id"`
+		ExperimentID string           `json:"experiment_id,omitempty"`
+		Name         string           `json:"name"`
+		Attributes   []mcapi.Property `json:"attributes"`
+	}{
+		ProjectID:    projectID,
+		ExperimentID: experimentID,
+		Name:         name,
+		Attributes:   attributes,
+	}
+
+	if err := c.post(&result, body, "createSample"); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkSampleResponse(result.Data, body, "createSample"); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
+
+func (c *Client) AddSamplesToProcess(projectID, experimentID string, connect mcapi.ConnectSamplesToProcess) ([]mcapi.Sample, error) {
+	var result struct {
+		Data []mcapi.Sample `json:"data"`
+	}
+
+	body := struct {
+		ProjectID    string                  `json:"project_id"`
+		ExperimentID string                  `json:"experiment_id,omitempty"`
+		ProcessID    string                  `json:"process_id"`
+		Transform    bool                    `json:"transform"`
+		Samples      []mcapi.SampleToConnect `json:"samples"`
+	}{
+		ProjectID:    projectID,
+		ExperimentID: experimentID,
+		ProcessID:    connect.ProcessID,
+		Transform:    connect.Transform,
+		Samples:      connect.Samples,
+	}
+
+	if err := c.post(&result, body, "addSamplesToProcess"); err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) != len(connect.Samples) {
+		return nil, &MalformedResponseError{
+			Endpoint: c.join("addSamplesToProcess"),
+			Digest:   bodyDigest(body),
+			Missing:  fmt.Sprintf("samples (%d sent, %d returned)", len(connect.Samples), len(result.Data)),
+		}
+	}
+
+	for _, sample := range result.Data {
+		if err := c.checkSampleResponse(sample, body, "addSamplesToProcess"); err != nil {
+			return nil, err
+		}
+	}
+
+	return result.Data, nil
+}
+
+func (c *Client) AddSampleAndFilesToProcess(projectID, experimentID string, simple bool, connect mcapi.ConnectSampleAndFilesToProcess) (*mcapi.Sample, error) {
+	var result struct {
+		Data mcapi.Sample `json:"data"`
+	}
+
+	body := struct {
+		ProjectID        string                   `json:"project_id"`
+		ExperimentID     string                   `json:"experiment_id,omitempty"`
+		ProcessID        string                   `json:"process_id"`
+		SampleID         string                   `json:"sample_id"`
+		PropertySetID    string                   `json:"property_set_id"`
+		Transform        bool                     `json:"transform"`
+		FilesByName      []mcapi.FileAndDirection `json:"files_by_name,omitempty"`
+		FilesByID        []mcapi.FileAndDirection `json:"files_by_id,omitempty"`
+		ReturnFullSample bool                     `json:"return_full_sample"`
+	}{
+		ProjectID:        projectID,
+		ExperimentID:     experimentID,
+		ProcessID:        connect.ProcessID,
+		SampleID:         connect.SampleID,
+		PropertySetID:    connect.PropertySetID,
+		Transform:        connect.Transform,
+		ReturnFullSample: simple,
+	}
+
+	if len(connect.FilesByName) != 0 {
+		body.FilesByName = connect.FilesByName
+	}
+
+	if len(connect.FilesByID) != 0 {
+		body.FilesByID = connect.FilesByID
+	}
+
+	if err := c.post(&result, body, "addSampleAndFilesToProcess"); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkSampleResponse(result.Data, body, "addSampleAndFilesToProcess"); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
+
+// SampleProperty is gomcapi's SampleProperty with measurements that can have metadata
+type SampleProperty struct {
+	Name         string                 `json:"name"`
+	ID           string                 `json:"id,omitempty"`
+	Measurements []Measurement          `json:"measurements"`
+	Metadata     map[string]interface{} `json:"metadata"`
+}
+
+// SampleMeasurements are the measurements of a sample to add to a process
+type SampleMeasurements struct {
+	SampleID      string
+	PropertySetID string
+	Attributes    []SampleProperty
+}
+
+func (c *Client) AddMeasurementsToSampleInProcess(projectID, experimentID, processID string, simple bool, sm SampleMeasurements) (*mcapi.Sample, error) {
+	var result struct {
+		Data mcapi.Sample `json:"data"`
+	}
+
+	body := struct {
+		ProjectID        string           `json:"project_id"`
+		ExperimentID     string           `json:"experiment_id,omitempty"`
+		ProcessID        string           `json:"process_id"`
+		SampleID         string           `json:"sample_id"`
+		PropertySetID    string           `json:"property_set_id"`
+		Attributes       []SampleProperty `json:"attributes"`
+		ReturnFullSample bool             `json:"return_full_sample"`
+	}{
+		ProjectID:        projectID,
+		ExperimentID:     experimentID,
+		ProcessID:        processID,
+		SampleID:         sm.SampleID,
+		PropertySetID:    sm.PropertySetID,
+		Attributes:       sm.Attributes,
+		ReturnFullSample: simple,
+	}
+
+	if body.Attributes == nil {
+		body.Attributes = make([]SampleProperty, 0)
+	}
+
+	for _, attr := range body.Attributes {
+		if attr.Measurements == nil {
+			attr.Measurements = make([]Measurement, 0)
+		}
+
+		if attr.Metadata == nil {
+			attr.Metadata = make(map[string]interface{}, 0)
+		}
+	}
+
+	if err := c.post(&result, body, "addMeasurementsToSampleInProcess"); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkSampleResponse(result.Data, body, "addMeasurementsToSampleInProcess"); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
+
+// AddMeasurementsToSamplesInProcess adds the measurements for several samples in a process in a single call.
+// It is the bulk version of AddMeasurementsToSampleInProcess.
+func (c *Client) AddMeasurementsToSamplesInProcess(projectID, experimentID, processID string, measurements []SampleMeasurements) error {
+	var result struct {
+		Data struct {
+			Success bool `json:"success"`
+		} `json:"data"`
+	}
+
+	type sampleMeasurements struct {
+		SampleID      string           `json:"sample_id"`
+		PropertySetID string           `json:"property_set_id"`
+		Attributes    []SampleProperty `json:"attributes"`
+	}
+
+	body := struct {
+		ProjectID    string               `json:"project_id"`
+		ExperimentID string               `json:"experiment_id,omitempty"`
+		ProcessID    string               `json:"process_id"`
+		Samples      []sampleMeasurements `json:"samples"`
+	}{
+		ProjectID:    projectID,
+		ExperimentID: experimentID,
+		ProcessID:    processID,
+		Samples:      make([]sampleMeasurements, 0, len(measurements)),
+	}
+
+	for _, sm := range measurements {
+		attrs := sm.Attributes
+		if attrs == nil {
+			attrs = make([]SampleProperty, 0)
+		}
+
+		body.Samples = append(body.Samples, sampleMeasurements{
+			SampleID:      sm.SampleID,
+			PropertySetID: sm.PropertySetID,
+			Attributes:    attrs,
+		})
+	}
+
+	return c.post(&result, body, "addMeasurementsToSamplesInProcess")
+}
+
+// CreateSampleProcess describes the Create Samples process that a sample is created in.
+type CreateSampleProcess struct {
+	Name   string
+	Setups []mcapi.Setup
+}
+
+// CreateSampleInProcess creates a sample like CreateSample, but also names the Create Samples process
+// the server creates for the sample and sets that process's setup attributes.
+func (c *Client) CreateSampleInProcess(projectID, experimentID, name string, attributes []mcapi.Property, process CreateSampleProcess) (*mcapi.Sample, error) {
+	var result struct {
+		Data mcapi.Sample `json:"data"`
+	}
+
+	if attributes == nil {
+		attributes = make([]mcapi.Property, 0)
+	}
+
+	body := struct {
+		ProjectID         string           `json:"project_id"`
+		ExperimentID      string           `json:"experiment_id,omitempty"`
+		Name              string           `json:"name"`
+		Attributes        []mcapi.Property `json:"attributes"`
+		ProcessName       string           `json:"process_name,omitempty"`
+		ProcessAttributes []mcapi.Setup    `json:"process_attributes,omitempty"`
+	}{
+		ProjectID:         projectID,
+		ExperimentID:      experimentID,
+		Name:              name,
+		Attributes:        attributes,
+		ProcessName:       process.Name,
+		ProcessAttributes: process.Setups,
+	}
+
+	if err := c.post(&result, body, "createSample"); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkSampleResponse(result.Data, body, "createSample"); err != nil {
+		return nil, err
+	}
+
+	return &result.Data, nil
+}
+
+// AddFilesToSample links files in the project directly to a sample, rather than to a process the
+// sample is in.
+func (c *Client) AddFilesToSample(projectID, sampleID string, filePaths []string) error {
+	var result struct {
+		Data mcapi.Sample `json:"data"`
+	}
+
+	body := struct {
+		ProjectID string   `json:"project_id"`
+		SampleID  string   `json:"sample_id"`
+		FilePaths []string `json:"file_paths"`
+	}{
+		ProjectID: projectID,
+		SampleID:  sampleID,
+		FilePaths: filePaths,
+	}
+
+	return c.post(&result, body, "addFilesToSample")
+}
@@ -0,0 +1,14 @@
+package mcapix
+
+// ListTemplates returns the process templates available to the user.
+func (c *Client) ListTemplates() ([]Template, error) {
+	var result struct {
+		Data []Template `json:"data"`
+	}
+
+	if err := c.postCached(&result, struct{}{}, "getAllTemplates"); err != nil {
+		return nil, err
+	}
+
+	return result.Data, nil
+}
@@ -0,0 +1,75 @@
+package mcapix
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"gopkg.in/resty.v1"
+)
+
+// TransportOptions tune the connections made to the server. Loads make thousands of calls, so the
+// connections are kept open and reused rather than paying for a new TCP and TLS handshake each call.
+type TransportOptions struct {
+	// MaxIdleConnsPerHost is the most idle connections kept open to the server for reuse. It should
+	// be at least the number of calls made at once.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the connections open to the server at once, 0 means no limit
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept open
+	IdleConnTimeout time.Duration
+
+	// DisableHTTP2 only uses HTTP/1.1. Otherwise HTTP/2 is used when the server supports it, which
+	// sends all the calls over a single connection.
+	DisableHTTP2 bool
+}
+
+// DefaultTransportOptions are the options used unless SetTransportOptions is called.
+var DefaultTransportOptions = TransportOptions{
+	MaxIdleConnsPerHost: 16,
+	IdleConnTimeout:     90 * time.Second,
+}
+
+var (
+	transportOptions = DefaultTransportOptions
+
+	// client is the resty client, with its transport, that all the calls are made with. It is created
+	// once, when the first call is made. Creating the transport for each call throws away the open
+	// connections.
+	client     *resty.Client
+	clientOnce sync.Once
+)
+
+// SetTransportOptions sets the options for the connections to the server. It must be called before
+// the first call is made, after which the connections have been set up.
+func SetTransportOptions(options TransportOptions) {
+	transportOptions = options
+}
+
+// httpClient returns the resty client shared by all the calls to the server.
+func httpClient() *resty.Client {
+	clientOnce.Do(func() { client = resty.New().SetTransport(newTransport(transportOptions)) })
+	return client
+}
+
+// newTransport returns the transport shared by all the calls to the server.
+func newTransport(options TransportOptions) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       &tlsConfig,
+		ForceAttemptHTTP2:     !options.DisableHTTP2,
+		MaxIdleConns:          options.MaxIdleConnsPerHost,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       options.MaxConnsPerHost,
+		IdleConnTimeout:       options.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
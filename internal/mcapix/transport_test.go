@@ -0,0 +1,45 @@
+package mcapix
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopkg.in/resty.v1"
+)
+
+// benchmarkPosts makes b.N calls to a TLS server with the resty client returned by clientFor, which is
+// called before each call. The server is local, so most of the cost of a new connection is the handshake.
+func benchmarkPosts(b *testing.B, clientFor func() *resty.Client) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"success":true}}`))
+	}))
+	defer server.Close()
+
+	body := map[string]string{"project_id": "project"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := clientFor().R().SetBody(body).Post(server.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if resp.StatusCode() != http.StatusOK {
+			b.Fatalf("server answered %s", resp.Status())
+		}
+	}
+}
+
+func BenchmarkSharedTransport(b *testing.B) {
+	shared := resty.New().SetTransport(newTransport(DefaultTransportOptions))
+	benchmarkPosts(b, func() *resty.Client { return shared })
+}
+
+func BenchmarkTransportPerCall(b *testing.B) {
+	benchmarkPosts(b, func() *resty.Client {
+		// DisableKeepAlives releases the connection after the call, as the transport is dropped
+		transport := newTransport(DefaultTransportOptions)
+		transport.DisableKeepAlives = true
+		return resty.New().SetTransport(transport)
+	})
+}
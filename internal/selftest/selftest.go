@@ -0,0 +1,147 @@
+package selftest
+
+/*
+ * selftest is an end to end smoke test of mcetl. It writes a canned workbook, loads it, and creates
+ * the workflow against a MockServer. The calls the server received are then checked against the
+ * calls the workbook should produce. No Materials Commons server is needed, so maintainers and
+ * packagers can run it to check that a build works.
+ */
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/mcapix"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+)
+
+const projectID = "selftest-project"
+
+// expectedCalls are the number of calls to each endpoint that loading the canned workbook makes.
+// Each sample gets its own process, so there is a Heat Treatment process for each of the 3 samples
+// and a SEM process for each of the 2 samples measured in SEM. S1 is added to its SEM process along
+// with its image, the other samples are added in batches. The measurements for each process are added
+// in a single bulk call.
+var expectedCalls = map[string]int{
+	"etl:getFileByPath":                 1,
+	"createExperimentInProject":         1,
+	"createSample":                      3,
+	"createProcess":                     5,
+	"addSamplesToProcess":               4,
+	"addSampleAndFilesToProcess":        1,
+	"addMeasurementsToSamplesInProcess": 5,
+	"updateExperimentProgressStatus":    1,
+}
+
+// Run runs the self test, writing progress to out. It returns an error describing each check that failed.
+func Run(out io.Writer) error {
+	dir, err := ioutil.TempDir("", "mcetl-selftest")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "selftest.xlsx")
+	if err := writeCannedWorkbook(path); err != nil {
+		return fmt.Errorf("unable to write canned workbook: %s", err)
+	}
+	fmt.Fprintln(out, "Wrote canned workbook", path)
+
+	loader := spreadsheet.NewLoader(true, nil, []string{path})
+	worksheets, err := loader.Load()
+	if err != nil {
+		return fmt.Errorf("unable to load canned workbook: %s", err)
+	}
+	fmt.Fprintf(out, "Loaded %d worksheets\n", len(worksheets))
+
+	server := NewMockServer()
+	server.Files = []string{cannedImage}
+	client := mcapix.NewClient(server.Start())
+	client.APIKey = "selftest"
+	defer server.Close()
+	fmt.Fprintln(out, "Started mock server", server.server.URL)
+
+	if err := loader.ValidateFilesExistInProject(worksheets, projectID, client); err != nil {
+		return fmt.Errorf("file validation failed: %s", err)
+	}
+
+	options := processor.WorkflowOptions{HasParent: true}
+	creater := spreadsheet.Create(projectID, "selftest", "mcetl selftest", options, client)
+	if err := creater.Apply(worksheets); err != nil {
+		return fmt.Errorf("creating workflow failed: %s", err)
+	}
+
+	return verifyCalls(out, server)
+}
+
+// verifyCalls checks the calls the server received, reporting each check to out.
+func verifyCalls(out io.Writer, server *MockServer) error {
+	var errs *multierror.Error
+	check := func(ok bool, format string, args ...interface{}) {
+		msg := fmt.Sprintf(format, args...)
+		if ok {
+			fmt.Fprintln(out, "  ok  ", msg)
+			return
+		}
+
+		fmt.Fprintln(out, "  FAIL", msg)
+		errs = multierror.Append(errs, fmt.Errorf("%s", msg))
+	}
+
+	fmt.Fprintln(out, "Checking API calls:")
+
+	counts := make(map[string]int)
+	for _, call := range server.Calls() {
+		counts[call.Endpoint]++
+	}
+
+	for endpoint, count := range counts {
+		if _, ok := expectedCalls[endpoint]; !ok {
+			check(false, "unexpected calls to %s: %d", endpoint, count)
+		}
+	}
+
+	var endpoints []string
+	for endpoint := range expectedCalls {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	for _, endpoint := range endpoints {
+		expected := expectedCalls[endpoint]
+		check(counts[endpoint] == expected, "%s called %d times, expected %d", endpoint, counts[endpoint], expected)
+	}
+
+	wrongProject := 0
+	for _, call := range server.Calls() {
+		if call.Body["project_id"] != projectID {
+			wrongProject++
+		}
+	}
+	check(wrongProject == 0, "all calls sent project_id %s, %d did not", projectID, wrongProject)
+
+	processTypes := make(map[interface{}]int)
+	for _, call := range server.CallsTo("createProcess") {
+		processTypes[call.Body["process_type"]]++
+	}
+	check(processTypes["Heat Treatment"] == 3 && processTypes["SEM"] == 2,
+		"created 3 Heat Treatment and 2 SEM processes, got %v", processTypes)
+
+	for _, call := range server.CallsTo("addSampleAndFilesToProcess") {
+		files, _ := call.Body["files_by_name"].([]interface{})
+		path := ""
+		if len(files) == 1 {
+			file, _ := files[0].(map[string]interface{})
+			path, _ = file["path"].(string)
+		}
+		check(path == cannedImage, "added file %s to SEM process, got %v", cannedImage, files)
+	}
+
+	return errs.ErrorOrNil()
+}
@@ -0,0 +1,162 @@
+package selftest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+)
+
+// Call is a single API call received by the MockServer.
+type Call struct {
+	Endpoint string
+	Body     map[string]interface{}
+}
+
+// MockServer is an in memory Materials Commons API server. It implements the endpoints that the
+// Creater uses, returning made up IDs, and records each call so that they can be checked afterwards.
+// It doesn't validate the calls beyond decoding the request body, that is left to the caller.
+type MockServer struct {
+	// Files are the project file paths that etl:getFileByPath and etl:getProjectFileIndex know about
+	Files []string
+
+	// Fail are the endpoints that are answered with an HTTP error status, keyed by endpoint, rather than
+	// succeeding. The calls are still recorded.
+	Fail map[string]int
+
+	mu     sync.Mutex
+	calls  []Call
+	nextID int
+	server *httptest.Server
+}
+
+func NewMockServer() *MockServer {
+	return &MockServer{}
+}
+
+// Start starts the server on a random local port. The URL to pass to mcapix.NewClient is returned.
+func (m *MockServer) Start() string {
+	m.server = httptest.NewServer(m)
+	return m.server.URL + "/api"
+}
+
+// Close stops a server started with Start.
+func (m *MockServer) Close() {
+	if m.server != nil {
+		m.server.Close()
+	}
+}
+
+// Calls returns the calls received so far, in the order they were received.
+func (m *MockServer) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call{}, m.calls...)
+}
+
+// CallsTo returns the calls received for the given endpoint.
+func (m *MockServer) CallsTo(endpoint string) []Call {
+	var calls []Call
+	for _, call := range m.Calls() {
+		if call.Endpoint == endpoint {
+			calls = append(calls, call)
+		}
+	}
+
+	return calls
+}
+
+// ServeHTTP implements http.Handler so the server can also be run standalone with http.ListenAndServe.
+func (m *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := make(map[string]interface{})
+	if contents, err := ioutil.ReadAll(r.Body); err == nil && len(contents) != 0 {
+		if err := json.Unmarshal(contents, &body); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid json: %s", err))
+			return
+		}
+	}
+
+	endpoint := path.Base(r.URL.Path)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Endpoint: endpoint, Body: body})
+
+	if status, ok := m.Fail[endpoint]; ok {
+		writeError(w, status, fmt.Sprintf("%s failed", endpoint))
+		return
+	}
+
+	var data interface{}
+	switch endpoint {
+	case "createExperimentInProject":
+		data = map[string]interface{}{"id": m.id("experiment"), "name": body["name"], "description": body["description"]}
+	case "updateExperimentProgressStatus":
+		data = map[string]interface{}{"success": true}
+	case "createSample":
+		data = map[string]interface{}{"id": m.id("sample"), "name": body["name"], "property_set_id": m.id("ps")}
+	case "createProcess":
+		data = map[string]interface{}{"id": m.id("process"), "name": body["name"], "process_type": body["process_type"]}
+	case "addSampleToProcess", "addSampleAndFilesToProcess":
+		data = map[string]interface{}{"id": body["sample_id"], "property_set_id": m.id("ps")}
+	case "addSamplesToProcess":
+		var samples []map[string]interface{}
+		if toAdd, ok := body["samples"].([]interface{}); ok {
+			for _, s := range toAdd {
+				sample, _ := s.(map[string]interface{})
+				samples = append(samples, map[string]interface{}{
+					"id":              sample["sample_id"],
+					"name":            sample["name"],
+					"property_set_id": m.id("ps"),
+				})
+			}
+		}
+		data = samples
+	case "addMeasurementsToSampleInProcess":
+		data = map[string]interface{}{"id": body["sample_id"], "property_set_id": body["property_set_id"]}
+	case "addMeasurementsToSamplesInProcess":
+		data = map[string]interface{}{"success": true}
+	case "getProjectOverview":
+		data = map[string]interface{}{"id": body["project_id"], "name": "selftest"}
+	case "etl:getFileByPath":
+		filePath, _ := body["path"].(string)
+		if !m.hasFile(filePath) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("file %s not found", filePath))
+			return
+		}
+		data = map[string]interface{}{"id": m.id("file"), "name": path.Base(filePath)}
+	case "etl:getProjectFileIndex":
+		data = map[string]interface{}{"paths": m.Files}
+	default:
+		writeError(w, http.StatusNotFound, fmt.Sprintf("unknown endpoint %s", endpoint))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+// id returns a new unique id with the given prefix. It must be called with mu held.
+func (m *MockServer) id(prefix string) string {
+	m.nextID++
+	return fmt.Sprintf("%s-%d", prefix, m.nextID)
+}
+
+func (m *MockServer) hasFile(filePath string) bool {
+	for _, f := range m.Files {
+		if f == filePath {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
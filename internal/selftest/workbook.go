@@ -0,0 +1,58 @@
+package selftest
+
+import (
+	"fmt"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+)
+
+// cannedSheet is a worksheet in the canned workbook, the first row is the header.
+type cannedSheet struct {
+	name string
+	rows [][]interface{}
+}
+
+// cannedWorkbook is a small heat treatment study. It is loaded with has-parent, so column 2 is the
+// parent column. S1 and S3 go on to SEM, and S1 has an image attached in SEM.
+var cannedWorkbook = []cannedSheet{
+	{
+		name: "Heat Treatment",
+		rows: [][]interface{}{
+			{"sample", "parent", "p:Temperature(c)", "p:Time(h)", "s:Hardness(HV)"},
+			{"S1", "", 400, 2, 300},
+			{"S2", "", 400, 2, 310},
+			{"S3", "", 500, 2, 280},
+		},
+	},
+	{
+		name: "SEM",
+		rows: [][]interface{}{
+			{"sample", "parent", "p:Voltage(kV)", "s:Grain Size(um)", "f:Images"},
+			{"S1", "Heat Treatment", 20, 12.5, cannedImage},
+			{"S3", "Heat Treatment", 20, 15.1, ""},
+		},
+	},
+}
+
+const cannedImage = "selftest/sem/S1.tif"
+
+// writeCannedWorkbook writes the canned workbook to path.
+func writeCannedWorkbook(path string) error {
+	xlsx := excelize.NewFile()
+	for i, sheet := range cannedWorkbook {
+		if i == 0 {
+			xlsx.SetSheetName("Sheet1", sheet.name)
+		} else {
+			xlsx.NewSheet(sheet.name)
+		}
+
+		for row, cells := range sheet.rows {
+			for column, value := range cells {
+				axis := fmt.Sprintf("%s%d", excelize.ToAlphaString(column), row+1)
+				xlsx.SetCellValue(sheet.name, axis, value)
+			}
+		}
+	}
+
+	return xlsx.SaveAs(path)
+}
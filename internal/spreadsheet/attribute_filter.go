@@ -0,0 +1,66 @@
+package spreadsheet
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// attributeFilter selects the attributes to load by name. Dense instrument exports can have hundreds of
+// columns when only a few properties are wanted. The patterns are globs (see path.Match) matched case
+// insensitively against attribute names, eg "Hardness*" or "*(um)". An attribute is kept if it matches
+// one of the include patterns (or there are none) and doesn't match any of the exclude patterns. The
+// filter applies to both sample and process attributes. Columns that aren't kept are treated as ignored
+// columns, so their cells aren't even converted.
+type attributeFilter struct {
+	include []string
+	exclude []string
+}
+
+// newAttributeFilter returns nil if there are no patterns, which keeps every attribute.
+func newAttributeFilter(include, exclude []string) (*attributeFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	f := &attributeFilter{}
+	for _, pattern := range include {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid include attribute pattern '%s': %s", pattern, err)
+		}
+		f.include = append(f.include, strings.ToLower(pattern))
+	}
+
+	for _, pattern := range exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid exclude attribute pattern '%s': %s", pattern, err)
+		}
+		f.exclude = append(f.exclude, strings.ToLower(pattern))
+	}
+
+	return f, nil
+}
+
+// keep returns true if the attribute with the given name should be loaded.
+func (f *attributeFilter) keep(name string) bool {
+	if f == nil {
+		return true
+	}
+
+	name = strings.ToLower(name)
+	if len(f.include) != 0 && !matchesAny(f.include, name) {
+		return false
+	}
+
+	return !matchesAny(f.exclude, name)
+}
+
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
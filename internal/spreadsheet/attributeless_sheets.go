@@ -0,0 +1,62 @@
+package spreadsheet
+
+/*
+ * attributeless_sheets handles worksheets whose header has only the sample column, and the parent column,
+ * so that nothing is recorded about their samples. These are usually a list of samples pasted into the
+ * wrong workbook, or a step whose columns were never filled in, and loading them creates a process for
+ * each sample with nothing in it. By default they are left out with a warning, as empty worksheets are.
+ *
+ * Some labs use them on purpose, for a step that only records which samples went through it, such as a
+ * polishing step that is always done the same way. With --attributeless-sheets associate they are loaded,
+ * each sample going through the worksheet's process with no attributes.
+ */
+
+import (
+	"fmt"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// What to do with a worksheet that has no attribute or file columns.
+const (
+	// AttributelessSheetsSkipped leaves the worksheet out with a warning
+	AttributelessSheetsSkipped = "skip"
+
+	// AttributelessSheetsAssociated loads the worksheet as a step that only associates its samples
+	AttributelessSheetsAssociated = "associate"
+)
+
+// ValidAttributelessSheets returns an error if policy isn't one of the attributeless sheets policies.
+func ValidAttributelessSheets(policy string) error {
+	if policy != AttributelessSheetsSkipped && policy != AttributelessSheetsAssociated {
+		return fmt.Errorf("unknown attributeless sheets policy '%s', use '%s' or '%s'", policy,
+			AttributelessSheetsSkipped, AttributelessSheetsAssociated)
+	}
+
+	return nil
+}
+
+// isAttributeless returns true if nothing but the samples is loaded from the worksheet. The samples are
+// looked at as well as the header, as the const: rows give process attributes without columns.
+func isAttributeless(worksheet *model.Worksheet) bool {
+	if len(worksheet.ProcessAttrs) != 0 || len(worksheet.SampleAttrs) != 0 || len(worksheet.FileHeaders) != 0 {
+		return false
+	}
+
+	for _, sample := range worksheet.Samples {
+		if len(sample.ProcessAttrs) != 0 || len(sample.Attributes) != 0 || len(sample.Files) != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// skipAttributeless returns true if the worksheet has no attribute or file columns and is to be left out.
+func (l *Loader) skipAttributeless(worksheet *model.Worksheet) bool {
+	if l.AttributelessSheets == AttributelessSheetsAssociated || l.isSamplesOrMasterSheet(worksheet.Name) {
+		return false
+	}
+
+	return isAttributeless(worksheet)
+}
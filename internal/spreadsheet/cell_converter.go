@@ -3,8 +3,11 @@ package spreadsheet
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
+
+	"github.com/materials-commons/mcetl/internal/dates"
 )
 
 type cellConverter struct {
@@ -13,15 +16,26 @@ type cellConverter struct {
 	// to access it.
 	intVal int64
 
+	// floatVal stores the value that isFloat received from ParseFloat. This
+	// allows using that value without having to call ParseFloat a second time
+	// to access it.
+	floatVal float64
+
 	// boolVal stores the value that isBool received from ParseBool. This
 	// allows using that value without having to call ParseBool a second time
 	// to access it.
 	boolVal bool
+
+	// dateVal stores the ISO-8601 date that isDate converted the cell to.
+	dateVal string
+
+	// dates parses the dates isDate matches, see date_cells.go. No cell is a date when it is nil.
+	dates *dates.Parser
 }
 
 func newCellConverter() *cellConverter {
 	// explicitly initialize so we know what default values are
-	return &cellConverter{intVal: 0, boolVal: false}
+	return &cellConverter{intVal: 0, floatVal: 0, boolVal: false}
 }
 
 // cellToJSONMap will take a cell entry which is a string. It looks at the string to determine what type
@@ -41,12 +55,15 @@ func (c *cellConverter) cellToJSONMap(cell string) (map[string]interface{}, erro
 	case strings.HasPrefix(cell, "[") && strings.HasSuffix(cell, "]"):
 		// array
 		return c.cellToArray(cell)
-	case strings.Contains(cell, ".") && strings.Count(cell, ".") == 1:
+	case c.isFloat(cell):
 		// float
 		return c.cellToFloat(cell)
 	case c.isNumeric(cell):
 		// int
 		return c.cellToInt(cell)
+	case c.isDate(cell):
+		// date, time or both
+		return c.cellToDate(cell)
 	case c.isBool(cell):
 		// boolean
 		return c.cellToBool(cell)
@@ -64,6 +81,27 @@ func (c *cellConverter) isNumeric(str string) bool {
 	return err == nil
 }
 
+// isFloat will check if the cell is a float. A float has to contain a single decimal
+// point or an exponent (for example 1.5, 1.23456789e-7 or 1E5), otherwise integers would
+// also be treated as floats. If it is a float it stores the converted value in c.floatVal
+// and returns true.
+func (c *cellConverter) isFloat(str string) bool {
+	lowerStr := strings.ToLower(str)
+	switch {
+	case strings.HasPrefix(lowerStr, "0x") || strings.HasPrefix(lowerStr, "-0x"):
+		// ParseFloat accepts hex floats, but a user wouldn't type a measurement this way
+		return false
+	case strings.Count(str, ".") == 1:
+	case strings.Count(str, ".") == 0 && strings.Contains(lowerStr, "e"):
+	default:
+		return false
+	}
+
+	var err error
+	c.floatVal, err = strconv.ParseFloat(str, 64)
+	return err == nil
+}
+
 // isBool will check if the cell is a boolean. If it is it stores the converted
 // value in c.boolVal and returns true.
 func (c *cellConverter) isBool(str string) bool {
@@ -87,34 +125,17 @@ func (c *cellConverter) cellToArray(cell string) (map[string]interface{}, error)
 	return c.cellToObject(cell)
 }
 
-// cellToFloat will attempt to create json object with a float value. It uses ParseFloat to
-// convert the string to a float. If that fails then it will return cellToString(). If ParseFloat
-// succeeds then it will attempt to use json.Unmarshal to create the map. If that now fails
-// it will then again default to cellToString()
+// cellToFloat will create a json object with a float value. The value is taken from the
+// c.floatVal that isFloat stored, rather than formatting it into a JSON string, so that the
+// float keeps its full precision (formatting with %f would turn 1.23456789e-7 into 0.000000).
 func (c *cellConverter) cellToFloat(cell string) (map[string]interface{}, error) {
-	val := make(map[string]interface{})
-
-	floatVal, err := strconv.ParseFloat(cell, 64)
-	if err != nil {
-		// We thought it was a float, but its not so treat as a string
-		return c.cellToString(cell)
-	}
-
-	if err := json.Unmarshal([]byte(fmt.Sprintf(`{"value": %f}`, floatVal)), &val); err != nil {
-		return c.cellToString(cell)
-	}
-	return val, nil
+	return map[string]interface{}{"value": c.floatVal}, nil
 }
 
-// cellToInt returns a JSON value for an int, if that fails return as a string.
+// cellToInt returns a JSON value for an int. The int64 is stored directly because going
+// through json.Unmarshal would turn it into a float64 and lose precision for large values.
 func (c *cellConverter) cellToInt(cell string) (map[string]interface{}, error) {
-	val := make(map[string]interface{})
-
-	if err := json.Unmarshal([]byte(fmt.Sprintf(`{"value": %d}`, c.intVal)), &val); err != nil {
-		return c.cellToString(cell)
-	}
-
-	return val, nil
+	return map[string]interface{}{"value": c.intVal}, nil
 }
 
 // cellToBool returns a JSON value for a bool, if that fails return as a string.
@@ -130,12 +151,36 @@ func (c *cellConverter) cellToBool(cell string) (map[string]interface{}, error)
 
 // cellToString returns the JSON value as a string. It is the fallback case for the other
 // cellToXxx calls, as it is a last ditch attempt at converting the cell value into some
-// sort of JSON representation.
+// sort of JSON representation. Text that isn't valid inside a JSON string, such as text with
+// double quotes (which text normalization turns smart quotes into), is stored as it is.
 func (c *cellConverter) cellToString(cell string) (map[string]interface{}, error) {
 	val := make(map[string]interface{})
 	if err := json.Unmarshal([]byte(fmt.Sprintf(`{"value": "%s"}`, cell)), &val); err != nil {
-		return nil, err
+		return map[string]interface{}{"value": cell}, nil
 	}
 
 	return val, nil
 }
+
+// losesPrecision returns true if the numeric value that will be stored for a cell is not the
+// same number that is displayed in the cell. This happens when a cell contains more significant
+// digits than a float64 can hold, for example 3.14159265358979323846 will be stored as
+// 3.141592653589793. Non numeric values never lose precision.
+func (c *cellConverter) losesPrecision(cell string, value interface{}) bool {
+	floatVal, ok := value.(float64)
+	if !ok {
+		return false
+	}
+
+	displayed, _, err := big.ParseFloat(cell, 10, 256, big.ToNearestEven)
+	if err != nil {
+		return false
+	}
+
+	stored, _, err := big.ParseFloat(strconv.FormatFloat(floatVal, 'g', -1, 64), 10, 256, big.ToNearestEven)
+	if err != nil {
+		return false
+	}
+
+	return displayed.Cmp(stored) != 0
+}
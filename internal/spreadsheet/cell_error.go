@@ -0,0 +1,59 @@
+package spreadsheet
+
+/*
+ * cell_error gives the errors and warnings about a cell the worksheet, row and column of the cell, so that
+ * check --format json and --output json can say where they are without reading it from the message. The
+ * message still says where the cell is, for the text output.
+ */
+
+import (
+	"fmt"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// CellError is an error in a cell of a worksheet.
+type CellError struct {
+	Worksheet string
+	File      string
+
+	// Row starts at 1 and Column is a column name such as C
+	Row    int
+	Column string
+
+	Err error
+}
+
+func (e *CellError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CellError) Unwrap() error {
+	return e.Err
+}
+
+// cellErrorf returns a CellError for the cell at rowIndex and column of the worksheet, formatting
+// its message like fmt.Errorf.
+func cellErrorf(worksheet *model.Worksheet, rowIndex, column int, format string, a ...interface{}) error {
+	return &CellError{
+		Worksheet: worksheet.Name,
+		File:      worksheet.File,
+		Row:       rowIndex,
+		Column:    model.ColumnName(column),
+		Err:       fmt.Errorf(format, a...),
+	}
+}
+
+// cellWarningf prints a warning about the cell at rowIndex and column of the worksheet, formatting
+// it like fmt.Printf.
+func cellWarningf(worksheet *model.Worksheet, rowIndex, column int, format string, a ...interface{}) {
+	console.Print(console.Event{
+		Type:      console.TypeWarning,
+		Message:   fmt.Sprintf(format, a...),
+		Worksheet: worksheet.Name,
+		File:      worksheet.File,
+		Row:       rowIndex,
+		Column:    model.ColumnName(column),
+	})
+}
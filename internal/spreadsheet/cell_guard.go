@@ -0,0 +1,101 @@
+package spreadsheet
+
+/*
+ * cell_guard checks the cells of the data rows before they are interpreted. A cell holding a pasted
+ * dump of raw data can be megabytes, which makes the API payloads huge and can exceed the server's
+ * limits, so cells can be limited to a maximum size (--max-cell-size). A cell over the limit is an
+ * error, or with --long-cells truncate it is cut down to the limit with a warning.
+ *
+ * Cells are also checked for content that won't survive being stored as JSON text. Control characters,
+ * such as the NULs left by some instrument exports, are removed with a warning. Bytes that aren't valid
+ * UTF-8 text are an error, since JSON encoding would silently replace them. A cell containing the
+ * replacement character (U+FFFD) is warned about, as it means text was lost when the file was written.
+ * Only the columns that are loaded are checked, so a notes column can hold anything.
+ */
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// The policies for a cell longer than the maximum cell size.
+const (
+	// LongCellsAsErrors treats a cell over the maximum size as an error
+	LongCellsAsErrors = "error"
+
+	// LongCellsTruncated cuts a cell over the maximum size down to the maximum
+	LongCellsTruncated = "truncate"
+)
+
+// ValidLongCellsPolicy returns an error if policy isn't one of the long cell policies.
+func ValidLongCellsPolicy(policy string) error {
+	if policy != LongCellsAsErrors && policy != LongCellsTruncated {
+		return fmt.Errorf("unknown long cells policy '%s', use '%s' or '%s'", policy, LongCellsAsErrors, LongCellsTruncated)
+	}
+
+	return nil
+}
+
+// isLoadedColumn returns true if the cells in the column are loaded. The cells of ignored and notes
+// columns, and of columns with no known heading, are never loaded so they aren't guarded.
+func (r *rowProcessor) isLoadedColumn(column int) bool {
+	if column == 1 || (column == 2 && r.HasParent) {
+		return true
+	}
+
+	colType, ok := r.columnType[column]
+	return ok && colType != IgnoreAttributeColumn
+}
+
+// guardCell returns the cell with any control characters removed, checking that it is valid text
+// and no longer than the maximum cell size. Sizes are in characters, as Excel counts them.
+func (r *rowProcessor) guardCell(cell string, rowIndex, column int) (string, error) {
+	if !utf8.ValidString(cell) {
+		return "", cellErrorf(r.worksheet, rowIndex, column, "worksheet %s row %d column %s contains bytes that aren't valid text, check the file's encoding",
+			r.worksheet.Source(), rowIndex, model.ColumnName(column))
+	}
+
+	if strings.IndexFunc(cell, isControlCharacter) != -1 {
+		cell = strings.Map(func(c rune) rune {
+			if isControlCharacter(c) {
+				return -1
+			}
+			return c
+		}, cell)
+		cellWarningf(r.worksheet, rowIndex, column, "Worksheet %s row %d column %s contains control characters, they have been removed\n",
+			r.worksheet.Source(), rowIndex, model.ColumnName(column))
+	}
+
+	if strings.ContainsRune(cell, utf8.RuneError) {
+		cellWarningf(r.worksheet, rowIndex, column, "Worksheet %s row %d column %s contains the replacement character %c, some of its text was lost when the file was written\n",
+			r.worksheet.Source(), rowIndex, model.ColumnName(column), utf8.RuneError)
+	}
+
+	if r.maxCellSize <= 0 {
+		return cell, nil
+	}
+
+	size := utf8.RuneCountInString(cell)
+	if size <= r.maxCellSize {
+		return cell, nil
+	}
+
+	if r.longCells != LongCellsTruncated {
+		return "", cellErrorf(r.worksheet, rowIndex, column, "worksheet %s row %d column %s is %d characters, more than the maximum cell size of %d",
+			r.worksheet.Source(), rowIndex, model.ColumnName(column), size, r.maxCellSize)
+	}
+
+	cellWarningf(r.worksheet, rowIndex, column, "Worksheet %s row %d column %s is %d characters, it has been truncated to %d\n",
+		r.worksheet.Source(), rowIndex, model.ColumnName(column), size, r.maxCellSize)
+	return string([]rune(cell)[:r.maxCellSize]), nil
+}
+
+// isControlCharacter returns true for the control characters other than tab and the line breaks,
+// which are common in multi-line cells.
+func isControlCharacter(c rune) bool {
+	return unicode.IsControl(c) && c != '\t' && c != '\n' && c != '\r'
+}
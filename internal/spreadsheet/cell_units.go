@@ -0,0 +1,61 @@
+package spreadsheet
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/units"
+)
+
+// cellWithUnitRegex matches a number followed by a unit, eg "350 K", "1.5mm" or "-20 °C".
+var cellWithUnitRegex = regexp.MustCompile(`^([-+]?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?)\s*([^\d\s.+-].*)$`)
+
+// splitCellUnit splits a cell that contains a number followed by a unit into the number and the unit. Only
+// units that are known (see the units package) are split off, so a cell such as "3 samples" is left as is.
+func splitCellUnit(cell string) (value, unit string, ok bool) {
+	matches := cellWithUnitRegex.FindStringSubmatch(strings.TrimSpace(cell))
+	if matches == nil {
+		return cell, "", false
+	}
+
+	unit = strings.TrimSpace(matches[2])
+	if !units.IsKnown(unit) {
+		return cell, "", false
+	}
+
+	return matches[1], unit, true
+}
+
+// cellValueAndUnit returns the value to convert and the unit to use for an attribute cell. A cell can
+// override the unit in its column's header by giving a unit after the value, eg "350 K" in a
+// Temperature(C) column. When convertCellUnits is set the value is converted to the column's unit,
+// otherwise the value is kept as is with the unit from the cell. Units are compared case sensitively, as
+// ms and Ms are different units.
+func (r *rowProcessor) cellValueAndUnit(cell, columnUnit string, rowIndex, column int) (string, string) {
+	value, cellUnit, ok := splitCellUnit(cell)
+	if !ok {
+		return cell, columnUnit
+	}
+
+	if cellUnit == columnUnit || columnUnit == "" || !r.convertCellUnits {
+		return value, cellUnit
+	}
+
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value, cellUnit
+	}
+
+	converted, ok := units.Convert(number, cellUnit, columnUnit)
+	if !ok {
+		cellWarningf(r.worksheet, rowIndex, column, "Worksheet %s row %d column %s value '%s' can't be converted to %s, keeping unit %s\n",
+			r.worksheet.Source(), rowIndex, model.ColumnName(column), cell, columnUnit, cellUnit)
+		return value, cellUnit
+	}
+
+	// Format with fewer digits than a float64 holds so that rounding noise from the conversion
+	// (eg 76.85000000000002) isn't stored.
+	return strconv.FormatFloat(converted, 'g', 12, 64), columnUnit
+}
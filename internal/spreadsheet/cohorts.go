@@ -0,0 +1,112 @@
+package spreadsheet
+
+/*
+ * cohorts splits a load into several experiments, one for each cohort of samples, for campaigns where each
+ * batch should be its own experiment. The cohort of a sample is given in a column with the cohort: keyword:
+ *
+ *   |sample |cohort:Batch |p:Temperature(C) |
+ *   |S1     |B1           |400              |
+ *   |S2     |B2           |400              |
+ *
+ * With --experiment-per-cohort S1 is loaded into the experiment "<experiment name> - B1" and S2 into
+ * "<experiment name> - B2". A sample is in the same cohort on every worksheet, so its cohort only needs to
+ * be given on one of its rows. Samples without a cohort are loaded into the experiment named by
+ * --experiment-name. Without --experiment-per-cohort the cohort column is not loaded.
+ */
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// Cohort is the samples of a cohort and the worksheets they are on, which are loaded into an experiment.
+type Cohort struct {
+	// Name is the cohort's name, it is blank for the samples that aren't in a cohort
+	Name string
+
+	// Worksheets only have the rows for the samples in the cohort
+	Worksheets []*model.Worksheet
+}
+
+// ExperimentName returns the name of the experiment the cohort is loaded into. experimentName is the name
+// of the experiment the worksheets would be loaded into if they weren't split.
+func (c *Cohort) ExperimentName(experimentName string) string {
+	switch {
+	case c.Name == "":
+		return experimentName
+	case experimentName == "":
+		return c.Name
+	default:
+		return experimentName + " - " + c.Name
+	}
+}
+
+// SplitByCohort splits the worksheets by the cohorts of their samples. The cohorts are in the order
+// they are first seen. It is an error for a sample to be in more than one cohort.
+func SplitByCohort(worksheets []*model.Worksheet) ([]*Cohort, error) {
+	var savedErrs *multierror.Error
+
+	// The cohort of each sample, and where it was first given
+	cohorts := make(map[string]string)
+	givenAt := make(map[string]string)
+	var names []string
+	seen := make(map[string]bool)
+	for _, worksheet := range worksheets {
+		for _, sample := range worksheet.Samples {
+			if sample.Cohort == "" {
+				continue
+			}
+
+			at := fmt.Sprintf("worksheet %s row %d", worksheet.Source(), sample.Row)
+			if cohort, ok := cohorts[sample.Name]; ok && cohort != sample.Cohort {
+				savedErrs = multierror.Append(savedErrs, fmt.Errorf("sample %s is in cohort %s on %s and cohort %s on %s",
+					sample.Name, cohort, givenAt[sample.Name], sample.Cohort, at))
+				continue
+			} else if !ok {
+				cohorts[sample.Name] = sample.Cohort
+				givenAt[sample.Name] = at
+			}
+
+			if !seen[sample.Cohort] {
+				seen[sample.Cohort] = true
+				names = append(names, sample.Cohort)
+			}
+		}
+	}
+
+	if savedErrs != nil {
+		return nil, savedErrs.ErrorOrNil()
+	}
+
+	// Samples without a cohort are loaded into the experiment the worksheets would be loaded into
+	names = append(names, "")
+
+	var split []*Cohort
+	for _, name := range names {
+		cohort := &Cohort{Name: name}
+		for _, worksheet := range worksheets {
+			var samples []*model.Sample
+			for _, sample := range worksheet.Samples {
+				if cohorts[sample.Name] == name {
+					samples = append(samples, sample)
+				}
+			}
+
+			if len(samples) == 0 {
+				continue
+			}
+
+			cohortWorksheet := *worksheet
+			cohortWorksheet.Samples = samples
+			cohort.Worksheets = append(cohort.Worksheets, &cohortWorksheet)
+		}
+
+		if len(cohort.Worksheets) != 0 {
+			split = append(split, cohort)
+		}
+	}
+
+	return split, nil
+}
@@ -8,6 +8,11 @@ const (
 	FileAttributeColumn
 	IgnoreAttributeColumn
 	UnknownAttributeColumn
+	ConditionLevelColumn
+	LookupAttributeColumn
+	CohortColumn
+	StepColumn
+	SampleIDColumn
 )
 
 func (c ColumnAttributeType) String() string {
@@ -20,6 +25,16 @@ func (c ColumnAttributeType) String() string {
 		return "FileAttributeColumn"
 	case IgnoreAttributeColumn:
 		return "IgnoreAttributeColumn"
+	case ConditionLevelColumn:
+		return "ConditionLevelColumn"
+	case LookupAttributeColumn:
+		return "LookupAttributeColumn"
+	case CohortColumn:
+		return "CohortColumn"
+	case StepColumn:
+		return "StepColumn"
+	case SampleIDColumn:
+		return "SampleIDColumn"
 	default:
 		return "UnknownAttributeColumn"
 	}
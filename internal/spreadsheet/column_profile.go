@@ -0,0 +1,177 @@
+package spreadsheet
+
+/*
+ * column_profile profiles the values in each attribute column of a loaded worksheet, for check to show
+ * before anything is created: how many cells are blank and numeric, the range and number of distinct
+ * values, and the values that are outliers. Columns whose values look wrong are marked as suspect:
+ *
+ *   - every cell is blank, which usually means the header is in the wrong column
+ *   - most, but not all, of the values are numbers, which is usually a typo or a value with its unit
+ *   - a number is an outlier, more than OutlierZScore standard deviations from the column's mean, which is
+ *     usually a value in the wrong unit or with a misplaced decimal point
+ */
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// OutlierZScore is how many standard deviations a number must be from the mean of its column to be an outlier.
+const OutlierZScore = 3.0
+
+// minOutlierValues is the fewest numbers a column must have to look for outliers in. With fewer a single
+// value can't be OutlierZScore standard deviations from the mean.
+const minOutlierValues = 10
+
+// ColumnProfile describes the values in an attribute column.
+type ColumnProfile struct {
+	Column int
+	Header string
+
+	// Rows is the number of rows, Blank and Numeric how many of them have a blank cell or a number
+	Rows    int
+	Blank   int
+	Numeric int
+
+	// Min and Max are the smallest and largest numbers, when there are any
+	Min float64
+	Max float64
+
+	// Distinct is the number of different values
+	Distinct int
+
+	// Outliers are the cells with outlying numbers
+	Outliers []*ColumnOutlier
+
+	// Suspect gives why the column looks wrong, it is blank when it doesn't
+	Suspect string
+}
+
+// ColumnOutlier is a cell whose number is an outlier in its column.
+type ColumnOutlier struct {
+	Row    int
+	Value  float64
+	ZScore float64
+}
+
+// columnValue is a value in a column and the row it is on.
+type columnValue struct {
+	row   int
+	value interface{}
+}
+
+// ProfileColumns profiles the process and sample attribute columns of the worksheet.
+func ProfileColumns(worksheet *model.Worksheet) []*ColumnProfile {
+	values := make(map[int][]columnValue)
+	for _, sample := range worksheet.Samples {
+		for _, attrs := range [][]*model.Attribute{sample.ProcessAttrs, sample.Attributes} {
+			for _, attr := range attrs {
+				if attr.Column == 0 || attr.Value == nil {
+					continue
+				}
+
+				row := sample.Row
+				if attr.Source != nil {
+					row = attr.Source.Row
+				}
+				values[attr.Column] = append(values[attr.Column], columnValue{row: row, value: attr.Value["value"]})
+			}
+		}
+	}
+
+	var profiles []*ColumnProfile
+	for _, summary := range worksheet.Columns {
+		if summary.Type != "process attribute" && summary.Type != "sample attribute" {
+			continue
+		}
+
+		profiles = append(profiles, profileColumn(summary, len(worksheet.Samples), values[summary.Column]))
+	}
+
+	return profiles
+}
+
+// profileColumn profiles the values of a column in a worksheet with rows rows.
+func profileColumn(summary *model.ColumnSummary, rows int, values []columnValue) *ColumnProfile {
+	profile := &ColumnProfile{Column: summary.Column, Header: summary.Header, Rows: rows, Blank: rows - len(values)}
+
+	var numbers []columnValue
+	distinct := make(map[string]bool)
+	for _, v := range values {
+		distinct[fmt.Sprint(v.value)] = true
+
+		number, ok := numericValue(v.value)
+		if !ok {
+			continue
+		}
+
+		if len(numbers) == 0 || number < profile.Min {
+			profile.Min = number
+		}
+		if len(numbers) == 0 || number > profile.Max {
+			profile.Max = number
+		}
+		numbers = append(numbers, columnValue{row: v.row, value: number})
+	}
+	profile.Numeric = len(numbers)
+	profile.Distinct = len(distinct)
+	profile.Outliers = findOutliers(numbers)
+
+	switch {
+	case rows != 0 && profile.Blank == rows:
+		profile.Suspect = "every cell is blank"
+	case profile.Numeric != len(values) && profile.Numeric*2 > len(values):
+		profile.Suspect = fmt.Sprintf("%d value(s) aren't numbers", len(values)-profile.Numeric)
+	case len(profile.Outliers) != 0:
+		profile.Suspect = fmt.Sprintf("%d outlier(s)", len(profile.Outliers))
+	}
+
+	return profile
+}
+
+// findOutliers returns the numbers that are more than OutlierZScore standard deviations from their mean.
+func findOutliers(numbers []columnValue) []*ColumnOutlier {
+	if len(numbers) < minOutlierValues {
+		return nil
+	}
+
+	var sum float64
+	for _, n := range numbers {
+		sum += n.value.(float64)
+	}
+	mean := sum / float64(len(numbers))
+
+	var squares float64
+	for _, n := range numbers {
+		squares += math.Pow(n.value.(float64)-mean, 2)
+	}
+	stddev := math.Sqrt(squares / float64(len(numbers)))
+	if stddev == 0 {
+		return nil
+	}
+
+	var outliers []*ColumnOutlier
+	for _, n := range numbers {
+		if z := (n.value.(float64) - mean) / stddev; math.Abs(z) > OutlierZScore {
+			outliers = append(outliers, &ColumnOutlier{Row: n.row, Value: n.value.(float64), ZScore: z})
+		}
+	}
+
+	return outliers
+}
+
+// numericValue returns the value of a cell as a float64 if it is a number.
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,110 @@
+package spreadsheet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// summarizeColumns records the type each column of the header row was given and the reason for it
+// in the worksheet's Columns. It is called once the header row has been processed.
+func (r *rowProcessor) summarizeColumns() {
+	for i, header := range r.worksheet.Headers {
+		column := i + 1
+		summary := &model.ColumnSummary{Column: column, Header: header}
+		summary.Type, summary.Reason, summary.Suspect = r.describeColumn(column, header)
+		r.worksheet.Columns = append(r.worksheet.Columns, summary)
+	}
+}
+
+// describeColumn returns the type of the column, the reason it has that type and whether the type is
+// suspect because it wasn't given by a known keyword.
+func (r *rowProcessor) describeColumn(column int, header string) (columnType, reason string, suspect bool) {
+	switch {
+	case column == 1:
+		return "sample name", "column 1 is the sample name", false
+	case column == 2 && r.HasParent:
+		return "parent", "column 2 is the parent with --has-parent", false
+	case header == "":
+		return "ignored", "the header is blank", false
+	}
+
+	t, ok := r.columnType[column]
+	if !ok {
+		return "not loaded", fmt.Sprintf("unknown keyword '%s'", headerKeyword(header)), true
+	}
+
+	if _, ok := r.columnTypes[column]; ok {
+		reason = "type was changed from the header's"
+	}
+
+	header, declaredType := splitDeclaredType(header)
+
+	switch t {
+	case ConditionLevelColumn:
+		return "condition level", "cross-tab header name(unit)=level", false
+
+	case IgnoreAttributeColumn:
+		if reason != "" {
+			return "ignored", reason, false
+		}
+
+		if keywordType := columnAttributeTypeFromKeyword(header); keywordType == ProcessAttributeColumn || keywordType == SampleAttributeColumn {
+			return "ignored", "not selected by --include-attrs/--exclude-attrs", false
+		}
+
+		return "ignored", fmt.Sprintf("ignore keyword '%s'", headerKeyword(header)), false
+
+	case ProcessAttributeColumn:
+		columnType = "process attribute"
+		if name, _ := cell2NameAndUnit(header); name == r.worksheet.DateAttr || (r.worksheet.DateAttr == "Date" && name == "") {
+			columnType = "process date"
+		}
+
+	case SampleAttributeColumn:
+		columnType = "sample attribute"
+		if reason == "" && !hasKeyword(header) {
+			return columnType, "no keyword, columns default to sample attributes", true
+		}
+
+	case CohortColumn:
+		columnType = "cohort"
+
+	case StepColumn:
+		columnType = "step"
+
+	case SampleIDColumn:
+		columnType = "sample id"
+
+	case LookupAttributeColumn:
+		columnType = "lookup " + r.lookupColumns[column].worksheet.Name
+
+	case FileAttributeColumn:
+		columnType = "file"
+		if fileHeader := findFileHeader(r.worksheet.FileHeaders, column); fileHeader != nil {
+			columnType = "file " + fileHeader.Direction
+		}
+	}
+
+	if reason == "" {
+		reason = fmt.Sprintf("keyword '%s'", headerKeyword(header))
+	}
+
+	if declaredType != "" {
+		// See declared_types.go
+		reason = fmt.Sprintf("%s, declared %s", reason, declaredType)
+	}
+
+	return columnType, reason, false
+}
+
+// headerKeyword returns the keyword of a header including its colon, eg p:, or the whole header when
+// it is a keyword on its own, eg notes.
+func headerKeyword(header string) string {
+	if i := strings.Index(header, ":"); i != -1 {
+		return strings.ToLower(header[:i+1])
+	}
+
+	return strings.ToLower(header)
+}
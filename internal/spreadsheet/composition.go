@@ -0,0 +1,129 @@
+package spreadsheet
+
+/*
+ * composition parses composition cells into a map of element to amount, so the server stores a
+ * composition as structured data that can be searched rather than as an opaque string. It is opt-in
+ * for each column with composition: true in the schema (see schema.go). Two forms are understood:
+ *
+ *   Pairs of element and amount, eg "mg 20 al 80", "Mg 20, Al 80" or "Mg=20 Al=80". The element
+ *   symbols aren't case sensitive.
+ *
+ *   Alloy notation, eg "Fe-20Cr-5Al", where each amount comes before its element. One element can
+ *   be left without an amount, it is the balance and is given 100 less the other amounts.
+ *
+ * The amounts are stored as written, in the column's unit (eg at% or wt%).
+ */
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// elementSymbols are the symbols of the elements, keyed by their lower case form.
+var elementSymbols = make(map[string]string)
+
+func init() {
+	symbols := `H He Li Be B C N O F Ne Na Mg Al Si P S Cl Ar K Ca Sc Ti V Cr Mn Fe Co Ni Cu Zn Ga Ge As Se
+		Br Kr Rb Sr Y Zr Nb Mo Tc Ru Rh Pd Ag Cd In Sn Sb Te I Xe Cs Ba La Ce Pr Nd Pm Sm Eu Gd Tb Dy Ho Er
+		Tm Yb Lu Hf Ta W Re Os Ir Pt Au Hg Tl Pb Bi Po At Rn Fr Ra Ac Th Pa U Np Pu Am Cm Bk Cf Es Fm Md No
+		Lr Rf Db Sg Bh Hs Mt Ds Rg Cn Nh Fl Mc Lv Ts Og`
+	for _, symbol := range strings.Fields(symbols) {
+		elementSymbols[strings.ToLower(symbol)] = symbol
+	}
+}
+
+// alloyPart matches a part of a composition in alloy notation, an optional amount followed by an element
+var alloyPart = regexp.MustCompile(`^([0-9]*\.?[0-9]+)?\s*([A-Z][a-z]?)$`)
+
+// compositionSeparators split the pairs form into elements and amounts
+var compositionSeparators = regexp.MustCompile(`[\s,;:=]+`)
+
+// parseComposition parses a composition cell into a map of element symbol to amount.
+func parseComposition(cell string) (map[string]interface{}, error) {
+	cell = strings.TrimSpace(cell)
+	if strings.Contains(cell, "-") {
+		return parseAlloyNotation(cell)
+	}
+
+	fields := compositionSeparators.Split(cell, -1)
+	if len(fields)%2 != 0 {
+		return nil, fmt.Errorf("composition '%s' isn't pairs of element and amount", cell)
+	}
+
+	composition := make(map[string]interface{})
+	for i := 0; i < len(fields); i += 2 {
+		element, ok := elementSymbols[strings.ToLower(fields[i])]
+		if !ok {
+			return nil, fmt.Errorf("composition '%s' has '%s' where an element was expected", cell, fields[i])
+		}
+
+		amount, err := strconv.ParseFloat(fields[i+1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("composition '%s' has '%s' where the amount of %s was expected", cell, fields[i+1], element)
+		}
+
+		if err := addToComposition(composition, element, amount, cell); err != nil {
+			return nil, err
+		}
+	}
+
+	return composition, nil
+}
+
+// parseAlloyNotation parses a composition such as Fe-20Cr-5Al, where the element without an amount
+// is the balance.
+func parseAlloyNotation(cell string) (map[string]interface{}, error) {
+	var (
+		balance string
+		total   float64
+	)
+
+	composition := make(map[string]interface{})
+	for _, part := range strings.Split(cell, "-") {
+		match := alloyPart.FindStringSubmatch(strings.TrimSpace(part))
+		if match == nil || elementSymbols[strings.ToLower(match[2])] != match[2] {
+			return nil, fmt.Errorf("composition '%s' has '%s' where an amount and element were expected", cell, part)
+		}
+
+		element := match[2]
+		if match[1] == "" {
+			if balance != "" {
+				return nil, fmt.Errorf("composition '%s' has more than one element without an amount (%s and %s)", cell, balance, element)
+			}
+			balance = element
+			continue
+		}
+
+		amount, _ := strconv.ParseFloat(match[1], 64)
+		total += amount
+		if err := addToComposition(composition, element, amount, cell); err != nil {
+			return nil, err
+		}
+	}
+
+	if balance != "" {
+		if total > 100 {
+			return nil, fmt.Errorf("composition '%s' adds up to more than 100 without its balance %s", cell, balance)
+		}
+
+		// Round away the float error from the subtraction, eg 74.89999999999999 for Fe-20.1Cr-5Al
+		amount := math.Round((100-total)*1e9) / 1e9
+		if err := addToComposition(composition, balance, amount, cell); err != nil {
+			return nil, err
+		}
+	}
+
+	return composition, nil
+}
+
+func addToComposition(composition map[string]interface{}, element string, amount float64, cell string) error {
+	if _, ok := composition[element]; ok {
+		return fmt.Errorf("composition '%s' has %s more than once", cell, element)
+	}
+
+	composition[element] = amount
+	return nil
+}
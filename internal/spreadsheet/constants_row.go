@@ -0,0 +1,104 @@
+package spreadsheet
+
+/*
+ * constants_row lets conditions that are the same for every sample on a worksheet be written once,
+ * rather than repeated down every row. A row directly under the header row whose first cell is const:
+ * holds name=value cells, and each is added to every sample on the worksheet as a process attribute:
+ *
+ *   |sample |p:Voltage(kV)    |s:Grain Size(um) |
+ *   |const: |Instrument=SEM-1 |WD(mm)=10        |
+ *   |S1     |20               |2.5              |
+ *   |S2     |20               |3.1              |
+ *
+ * The name is written as it would be in a header, with the unit in parenthesis. A name with the date
+ * keyword, eg date:Performed=2020-03-01, dates every process on the worksheet. There can be several
+ * const: rows, the cells in each can be in any column.
+ *
+ * A constant has the same value for every sample, so it doesn't change which rows are the same
+ * process, and a constant that is later moved into a column of its own keeps the processes' keys.
+ */
+
+import (
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// isConstantsRow returns true if the row is a const: row.
+func isConstantsRow(row []string) bool {
+	if len(row) == 0 {
+		return false
+	}
+
+	first := strings.TrimSpace(row[0])
+	return strings.EqualFold(first, "const:") || strings.EqualFold(first, "const")
+}
+
+// processConstantsRow reads the name=value cells in a const: row into the worksheet's constants.
+func (r *rowProcessor) processConstantsRow(row []string, rowIndex int) error {
+	for i, rawCell := range row[1:] {
+		column := i + 2
+		cell, err := r.guardCell(strings.TrimSpace(rawCell), rowIndex, column)
+		if err != nil {
+			return err
+		}
+
+		if cell == "" {
+			continue
+		}
+
+		equals := strings.Index(cell, "=")
+		if equals == -1 {
+			return cellErrorf(r.worksheet, rowIndex, column, "worksheet %s row %d column %s: constant '%s' isn't written as name=value",
+				r.worksheet.Source(), rowIndex, model.ColumnName(column), cell)
+		}
+
+		header, value := strings.TrimSpace(cell[:equals]), strings.TrimSpace(cell[equals+1:])
+		name, unit := cell2NameAndUnit(header)
+		switch {
+		case name == "" || value == "":
+			return cellErrorf(r.worksheet, rowIndex, column, "worksheet %s row %d column %s: constant '%s' needs both a name and a value",
+				r.worksheet.Source(), rowIndex, model.ColumnName(column), cell)
+		case findAttrByName(r.worksheet.ProcessAttrs, name) != nil || findAttrByName(r.constants, name) != nil:
+			return cellErrorf(r.worksheet, rowIndex, column, "worksheet %s row %d column %s: constant %s is already a process attribute of the worksheet",
+				r.worksheet.Source(), rowIndex, model.ColumnName(column), name)
+		case !r.attrFilter.keep(name):
+			continue
+		}
+
+		constant := model.NewAttribute(name, unit, 0)
+		constant.Source = r.cellRef(rowIndex, column)
+		if isDateColumn(header, name) {
+			if r.worksheet.DateAttr != "" {
+				return cellErrorf(r.worksheet, rowIndex, column, "worksheet %s row %d column %s: constant %s is a date, but the worksheet already has the date column %s",
+					r.worksheet.Source(), rowIndex, model.ColumnName(column), name, r.worksheet.DateAttr)
+			}
+
+			date, err := r.converter.dates.ParseDate(value)
+			if err != nil {
+				return cellErrorf(r.worksheet, rowIndex, column, "worksheet %s row %d column %s: %s", r.worksheet.Source(), rowIndex, model.ColumnName(column), err)
+			}
+			r.worksheet.DateAttr = name
+			constant.Value = map[string]interface{}{"value": date.Format(processDateLayout(date))}
+		} else {
+			columnSchema := r.schema.findColumn(r.worksheet.Name, name)
+			if constant.Value, err = r.convertAttributeCell(value, columnSchema, rowIndex, column); err != nil {
+				return err
+			}
+		}
+
+		r.constants = append(r.constants, constant)
+	}
+
+	return nil
+}
+
+// addConstants adds the worksheet's constants to the sample's process attributes.
+func (r *rowProcessor) addConstants(sample *model.Sample) {
+	for _, constant := range r.constants {
+		attr := model.NewAttribute(constant.Name, constant.Unit, constant.Column)
+		attr.Value = constant.Value
+		attr.Source = constant.Source
+		sample.AddProcessAttribute(attr)
+	}
+}
@@ -0,0 +1,111 @@
+package spreadsheet
+
+/*
+ * crosstab handles worksheets laid out as a cross-tab, where rows are samples and columns are the levels
+ * of the experimental conditions. A condition level column has a header of the form name(unit)=level and
+ * a cell in that column marks that the sample was processed at that level. For example:
+ *
+ *   |sample|Temperature(c)=400|Temperature(c)=500|Time(h)=1|Time(h)=2|
+ *   |S1    |x                 |                  |x        |         |
+ *   |S2    |                  |x                 |x        |         |
+ *   |S3    |x                 |                  |         |2.5      |
+ *
+ * A cell containing an x marks participation at the level in the header. A cell containing any other
+ * value also marks participation, but the value is used in place of the level (S3 above was processed
+ * for 2.5h rather than the planned 2h). Each cross-tab row is expanded into the normal long format, where
+ * the condition levels become process attributes. If a sample is marked at more than one level of the
+ * same condition then the row is expanded into one sample row for each combination of levels.
+ *
+ * Columns in a cross-tab worksheet whose header doesn't contain a level are processed as in any other
+ * worksheet, so sample attributes and files can be mixed in with the condition levels.
+ */
+
+import (
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// conditionLevel is a single condition level column from a cross-tab header.
+type conditionLevel struct {
+	Name  string
+	Unit  string
+	Level string
+}
+
+// isConditionLevelHeader returns true if the header cell is a cross-tab condition level, that is it
+// contains a level and either has no keyword or a process keyword.
+func isConditionLevelHeader(cell string) bool {
+	if !strings.Contains(cell, "=") {
+		return false
+	}
+
+	nameAndUnit := cell[:strings.Index(cell, "=")]
+	return !hasKeyword(nameAndUnit) || hasProcessAttributeKeyword(nameAndUnit)
+}
+
+// parseConditionLevelHeader splits a header of the form <keyword:>name(unit)=level into its parts.
+func parseConditionLevelHeader(cell string) conditionLevel {
+	i := strings.Index(cell, "=")
+	name, unit := cell2NameAndUnit(cell[:i])
+	return conditionLevel{
+		Name:  name,
+		Unit:  unit,
+		Level: strings.TrimSpace(cell[i+1:]),
+	}
+}
+
+// isParticipationMark returns true if the cell only marks participation rather than giving a value.
+func isParticipationMark(cell string) bool {
+	return strings.EqualFold(strings.TrimSpace(cell), "x")
+}
+
+// expandConditionLevels turns a cross-tab sample row into one or more long format sample rows. The
+// marked levels are grouped by condition name and a sample row is created for each combination of
+// levels. The first combination is stored in sample, the rest are returned as new samples that are
+// copies of sample with different process attributes.
+func expandConditionLevels(sample *model.Sample, marked []*model.Attribute) []*model.Sample {
+	if len(marked) == 0 {
+		return nil
+	}
+
+	// Group the levels by condition name, keeping the conditions in column order
+	var names []string
+	levelsByName := make(map[string][]*model.Attribute)
+	for _, attr := range marked {
+		if _, ok := levelsByName[attr.Name]; !ok {
+			names = append(names, attr.Name)
+		}
+		levelsByName[attr.Name] = append(levelsByName[attr.Name], attr)
+	}
+
+	// Build the combinations of levels
+	combinations := [][]*model.Attribute{nil}
+	for _, name := range names {
+		var next [][]*model.Attribute
+		for _, combination := range combinations {
+			for _, level := range levelsByName[name] {
+				c := make([]*model.Attribute, len(combination), len(combination)+1)
+				copy(c, combination)
+				next = append(next, append(c, level))
+			}
+		}
+		combinations = next
+	}
+
+	baseProcessAttrs := sample.ProcessAttrs
+	sample.ProcessAttrs = append(append([]*model.Attribute{}, baseProcessAttrs...), combinations[0]...)
+
+	var expanded []*model.Sample
+	for _, combination := range combinations[1:] {
+		s := model.NewSample(sample.Name, sample.Row)
+		s.Parent = sample.Parent
+		s.ParentChain = sample.ParentChain
+		s.Attributes = sample.Attributes
+		s.Files = sample.Files
+		s.ProcessAttrs = append(append([]*model.Attribute{}, baseProcessAttrs...), combination...)
+		expanded = append(expanded, s)
+	}
+
+	return expanded
+}
@@ -0,0 +1,173 @@
+package spreadsheet
+
+/*
+ * date_cells converts the dates and times in cells to ISO-8601, so the same date is stored the same way
+ * whichever way it was typed or formatted:
+ *
+ *   21 Mar 2019            -> 2019-03-21
+ *   2019/03/21 14:30       -> 2019-03-21T14:30:00
+ *   43545 (as a date cell) -> 2019-03-21
+ *
+ * Excel stores a date as the number of days since 1900, and only shows it as a date because of the cell's
+ * number format. Without converting them, date cells were read either as that number or as the text of some
+ * of Excel's built in formats, such as 03-21-19. Cells whose number format shows a date, a time or both are
+ * converted when the workbook is read, keeping only the parts the format shows.
+ *
+ * Dates typed as text, and those in CSV files, are converted when they match one of the date formats, see
+ * internal/dates. Only formats that can't be mistaken for another, such as YYYY-MM-DD, are tried unless
+ * others are given with --date-formats.
+ */
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/materials-commons/mcetl/internal/dates"
+)
+
+// isDate checks if the cell matches one of the date formats. If it does it stores the date as ISO-8601
+// in c.dateVal and returns true.
+func (c *cellConverter) isDate(str string) bool {
+	if c.dates == nil {
+		return false
+	}
+
+	t, iso, ok := c.dates.Parse(str)
+	if !ok {
+		return false
+	}
+
+	c.dateVal = t.Format(iso)
+	return true
+}
+
+// cellToDate returns a JSON value for a date, the ISO-8601 string isDate stored.
+func (c *cellConverter) cellToDate(cell string) (map[string]interface{}, error) {
+	return map[string]interface{}{"value": c.dateVal}, nil
+}
+
+// convertDateCells replaces the cells of the worksheet whose number format is a date or time with the date
+// as ISO-8601. The rows are those read by readSheetRows.
+func convertDateCells(xlsx *excelize.File, index int, rows [][]string) {
+	ws, ok := xlsx.Sheet[fmt.Sprintf("xl/worksheets/sheet%d.xml", index)]
+	if !ok || xlsx.Styles == nil || xlsx.Styles.CellXfs == nil {
+		// Reading the rows reads the styles when a cell has one, so there are no date cells
+		return
+	}
+
+	date1904 := xlsx.WorkBook != nil && xlsx.WorkBook.WorkbookPr != nil && xlsx.WorkBook.WorkbookPr.Date1904
+
+	// The layout for each style, blank when it isn't a date
+	isoLayouts := make(map[int]string)
+	for _, row := range ws.SheetData.Row {
+		for _, cell := range row.C {
+			if cell.S == 0 || (cell.T != "" && cell.T != "n") || cell.V == "" {
+				continue
+			}
+
+			iso, ok := isoLayouts[cell.S]
+			if !ok {
+				iso = styleDateLayout(xlsx, cell.S)
+				isoLayouts[cell.S] = iso
+			}
+			if iso == "" {
+				continue
+			}
+
+			column, r, ok := splitAxis(cell.R)
+			if !ok || r >= len(rows) || column >= len(rows[r]) {
+				continue
+			}
+
+			serial, err := strconv.ParseFloat(cell.V, 64)
+			if err != nil {
+				continue
+			}
+
+			if t, ok := dates.FromSerial(serial, date1904); ok {
+				rows[r][column] = t.Format(iso)
+			}
+		}
+	}
+}
+
+// styleDateLayout returns the ISO-8601 layout for the cells with the style, blank if the style's number format
+// isn't a date or time.
+func styleDateLayout(xlsx *excelize.File, style int) string {
+	if style >= len(xlsx.Styles.CellXfs.Xf) {
+		return ""
+	}
+
+	numFmtID := xlsx.Styles.CellXfs.Xf[style].NumFmtID
+	switch {
+	case numFmtID >= 14 && numFmtID <= 17:
+		return dates.ISODate
+	case numFmtID >= 18 && numFmtID <= 21:
+		return dates.ISOTime
+	case numFmtID == 22:
+		return dates.ISODateTime
+	case numFmtID < 164 || xlsx.Styles.NumFmts == nil:
+		// The other built in formats are numbers, or durations such as [h]:mm:ss
+		return ""
+	}
+
+	for _, numFmt := range xlsx.Styles.NumFmts.NumFmt {
+		if numFmt.NumFmtID == numFmtID {
+			return numFormatDateLayout(numFmt.FormatCode)
+		}
+	}
+
+	return ""
+}
+
+// numFormatDateLayout returns the ISO-8601 layout for an Excel number format, such as dd/mm/yyyy hh:mm, blank if
+// it isn't a date or time. Durations, such as [h]:mm, aren't dates.
+func numFormatDateLayout(formatCode string) string {
+	// Only the first section is for positive numbers
+	if i := strings.Index(formatCode, ";"); i != -1 {
+		formatCode = formatCode[:i]
+	}
+
+	var hasDate, hasTime, inQuotes, inBrackets bool
+	for i := 0; i < len(formatCode); i++ {
+		c := formatCode[i]
+		switch {
+		case inQuotes:
+			inQuotes = c != '"'
+		case inBrackets:
+			if c == ']' {
+				inBrackets = false
+			} else if c == 'h' || c == 'H' || c == 's' || c == 'S' {
+				// An elapsed time, [h]:mm:ss
+				return ""
+			}
+		case c == '"':
+			inQuotes = true
+		case c == '[':
+			inBrackets = true
+		case c == '\\' || c == '_' || c == '*':
+			// The next character is shown as it is, or is padding
+			i++
+		default:
+			switch c {
+			case 'y', 'Y', 'd', 'D':
+				hasDate = true
+			case 'h', 'H', 's', 'S':
+				hasTime = true
+			}
+		}
+	}
+
+	switch {
+	case hasDate && hasTime:
+		return dates.ISODateTime
+	case hasDate:
+		return dates.ISODate
+	case hasTime:
+		return dates.ISOTime
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,115 @@
+package spreadsheet
+
+/*
+ * declared_types lets a header say what type the values in its column are, rather than each cell's type
+ * being guessed from what it looks like:
+ *
+ *   p:Temperature(c):float   s:Notes:string   s:Passed:bool   p:Batch:int   p:Started:date   s:Spectrum:json
+ *
+ * The type comes after the name and unit of a process or sample attribute. Every cell in the column is
+ * converted to it, so a lot number such as 0042 in a string column stays 0042 rather than becoming 42, and
+ * a cell that isn't of the type, such as 12O (a letter O) in a float column, is an error that check reports
+ * rather than a value silently stored as text.
+ *
+ * A header written before types could be declared whose name ends in one, such as s:Status:bool, is read as
+ * declaring the type, so check warns about every header that declares a type. Declaring auto keeps the name,
+ * s:Status:bool:auto is the attribute Status:bool with each cell's type guessed as it is without a declared type.
+ */
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// The types a header can declare.
+var declarableTypes = map[string]bool{
+	"string": true,
+	"int":    true,
+	"float":  true,
+	"bool":   true,
+	"date":   true,
+	"json":   true,
+	"auto":   true,
+}
+
+// autoType is the declared type that guesses each cell's type, as when no type is declared
+const autoType = "auto"
+
+// splitDeclaredType splits the declared type off the end of an attribute header, eg p:Time(h):float
+// gives p:Time(h) and float. The type is blank, and the header unchanged, when the header doesn't declare
+// one. Only the part after the keyword is looked at, so a header such as p:Ratio 1:2 is left alone.
+func splitDeclaredType(header string) (string, string) {
+	keywordEnd := strings.Index(header, ":")
+	if keywordEnd == -1 {
+		return header, ""
+	}
+
+	i := strings.LastIndex(header, ":")
+	if i == keywordEnd {
+		return header, ""
+	}
+
+	declaredType := strings.ToLower(strings.TrimSpace(header[i+1:]))
+	if !declarableTypes[declaredType] {
+		return header, ""
+	}
+
+	return strings.TrimSpace(header[:i]), declaredType
+}
+
+// warnDeclaredType warns that the header is read as the attribute header declaring the type, so that an
+// attribute whose name happens to end in a type, such as s:Status:bool, isn't renamed unnoticed.
+func (r *rowProcessor) warnDeclaredType(header, declaredType string, column int) {
+	name, _ := cell2NameAndUnit(header)
+	cellWarningf(r.worksheet, r.worksheet.HeaderRow, column, "Worksheet %s column %s is the attribute %s declared %s, if :%s is part of its name add :auto to the end of the header\n",
+		r.worksheet.Source(), model.ColumnName(column), name, declaredType, declaredType)
+}
+
+// convertDeclaredCell converts the cell to the type declared by its column's header. A cell that isn't of
+// that type is added to the typeErrors, so that every cell in the worksheet is checked, and is kept as text.
+func (r *rowProcessor) convertDeclaredCell(cell, declaredType string, rowIndex, column int) map[string]interface{} {
+	c := r.converter
+	var value interface{}
+	switch declaredType {
+	case "string":
+		value = cell
+	case "int":
+		if !c.isNumeric(cell) {
+			return r.declaredTypeError(cell, "an int", rowIndex, column)
+		}
+		value = c.intVal
+	case "float":
+		f, err := strconv.ParseFloat(cell, 64)
+		if err != nil || strings.HasPrefix(strings.ToLower(strings.TrimPrefix(cell, "-")), "0x") {
+			return r.declaredTypeError(cell, "a float", rowIndex, column)
+		}
+		value = f
+	case "bool":
+		if !c.isBool(cell) {
+			return r.declaredTypeError(cell, "a bool", rowIndex, column)
+		}
+		value = c.boolVal
+	case "date":
+		if !c.isDate(cell) {
+			return r.declaredTypeError(cell, "a date", rowIndex, column)
+		}
+		value = c.dateVal
+	case "json":
+		if err := json.Unmarshal([]byte(cell), &value); err != nil {
+			return r.declaredTypeError(cell, "JSON", rowIndex, column)
+		}
+	}
+
+	return map[string]interface{}{"value": value}
+}
+
+// declaredTypeError records a cell that isn't of its column's declared type, returning the cell as text.
+func (r *rowProcessor) declaredTypeError(cell, what string, rowIndex, column int) map[string]interface{} {
+	r.typeErrors = multierror.Append(r.typeErrors, cellErrorf(r.worksheet, rowIndex, column, "worksheet %s row %d column %s value '%s' isn't %s, which its header declares",
+		r.worksheet.Source(), rowIndex, model.ColumnName(column), cell, what))
+	return map[string]interface{}{"value": cell}
+}
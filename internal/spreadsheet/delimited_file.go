@@ -0,0 +1,77 @@
+package spreadsheet
+
+/*
+ * delimited_file loads CSV and TSV files, which many instruments export, as well as workbooks. A CSV (.csv)
+ * or TSV (.tsv or .tab) file is a single worksheet named after the file without its extension, so
+ * hardness.csv is the worksheet hardness, and is laid out the same way as a worksheet:
+ *
+ *   sample,parent,p:Temperature(C),s:Hardness
+ *   S1,Casting,400,12.5
+ *
+ * Quoted cells can contain the delimiter and line breaks. Files that aren't UTF-8 are decoded, see
+ * text_encoding.go, and a byte order mark, which Excel writes at the start of the CSV files it saves, is
+ * removed. CSV and TSV files can be given with -f alongside workbooks and in zips of workbooks.
+ */
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// delimiterForFile returns the delimiter of a CSV or TSV file, or 0 if the file isn't one.
+func delimiterForFile(p string) rune {
+	switch strings.ToLower(filepath.Ext(p)) {
+	case ".csv":
+		return ','
+	case ".tsv", ".tab":
+		return '\t'
+	default:
+		return 0
+	}
+}
+
+// delimitedSheetName returns the name of the worksheet for a CSV or TSV file, its name without the extension.
+func delimitedSheetName(p string) string {
+	base := filepath.Base(filepath.ToSlash(p))
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// readDelimitedSheet reads the rows of a CSV or TSV file in the encoding as a worksheet. Trailing blank
+// cells and rows are removed as they are for worksheets.
+func readDelimitedSheet(r io.Reader, file string, delimiter rune, encoding string) ([]*sheet, error) {
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if contents, err = decodeText(contents, file, encoding); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(contents))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, trimBlankCells(record))
+	}
+
+	for len(rows) != 0 && len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+
+	return []*sheet{{name: delimitedSheetName(file), file: file, index: 1, rows: rows}}, nil
+}
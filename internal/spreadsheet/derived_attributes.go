@@ -0,0 +1,337 @@
+package spreadsheet
+
+/*
+ * derived_attributes computes sample attributes from the other attributes of a row, such as a density from
+ * the mass and volume columns, so the spreadsheet doesn't need a formula column for them. The rules are
+ * given in the schema:
+ *
+ *   derived:
+ *     - name: Density
+ *       unit: g/cm^3
+ *       expression: Mass / Volume
+ *     - name: Area
+ *       worksheet: Coupons
+ *       unit: mm^2
+ *       expression: "{Coupon Width} * {Coupon Length}"
+ *
+ * An expression uses the numbers and attribute names of the row with +, -, *, /, ^ and parentheses. Names
+ * are matched against the sample and process attributes case insensitively, names that aren't just
+ * letters, digits and underscores are put in braces. The values are used in the units of their columns.
+ * The result is added to the row's sample attributes, unless the row already has an attribute with the
+ * derived name. Rows that are missing one of the attributes, or have a blank cell for it, don't get the
+ * derived attribute. A rounding rule for a column with the derived name rounds it.
+ */
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// DerivedSchema is a rule for an attribute computed from other attributes.
+type DerivedSchema struct {
+	// Name and Unit of the attribute that is added
+	Name string `yaml:"name"`
+	Unit string `yaml:"unit"`
+
+	// Worksheet the rule applies to, if blank the rule applies to all worksheets
+	Worksheet string `yaml:"worksheet"`
+
+	// Expression the attribute is computed with, eg Mass / Volume
+	Expression string `yaml:"expression"`
+
+	expr *exprNode
+}
+
+// exprNode is a node of a parsed expression. op is 0 for a number, 'v' for an attribute, 'n' for a
+// negation and otherwise the operator applied to left and right.
+type exprNode struct {
+	op          byte
+	number      float64
+	name        string
+	left, right *exprNode
+}
+
+// validateDerived checks the rule has a name and parses its expression.
+func (d *DerivedSchema) validateDerived() error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("derived entry has no name")
+	}
+
+	expr, err := parseExpression(d.Expression)
+	if err != nil {
+		return fmt.Errorf("derived '%s' expression '%s': %s", d.Name, d.Expression, err)
+	}
+
+	d.expr = expr
+	return nil
+}
+
+// derivedFor returns the derived attribute rules that apply to the worksheet. A nil schema has none.
+func (s *Schema) derivedFor(worksheetName string) []*DerivedSchema {
+	if s == nil {
+		return nil
+	}
+
+	var rules []*DerivedSchema
+	for _, derived := range s.Derived {
+		if derived.Worksheet == "" || strings.EqualFold(strings.TrimSpace(derived.Worksheet), worksheetName) {
+			rules = append(rules, derived)
+		}
+	}
+
+	return rules
+}
+
+// addDerivedAttributes adds the attributes the schema derives from the sample's attributes.
+func (r *rowProcessor) addDerivedAttributes(sample *model.Sample, rowIndex int) {
+	for _, derived := range r.schema.derivedFor(r.worksheet.Name) {
+		if findSampleAttribute(sample, derived.Name) != nil {
+			continue
+		}
+
+		value, ok, err := derived.expr.eval(sample)
+		switch {
+		case err != nil:
+			console.Warningf("worksheet %s row %d: unable to derive %s: %s\n", r.worksheet.Source(), rowIndex, derived.Name, err)
+			continue
+		case !ok:
+			continue
+		}
+
+		attr := model.NewAttribute(derived.Name, derived.Unit, 0)
+		attr.Value = map[string]interface{}{"value": value}
+		if column := r.schema.findColumn(r.worksheet.Name, derived.Name); column.rounds() {
+			attr.Value["value"] = column.round(value)
+		}
+		sample.AddAttribute(attr)
+	}
+}
+
+// findSampleAttribute returns the sample or process attribute of the sample with the name, matched case
+// insensitively, or nil if it has none.
+func findSampleAttribute(sample *model.Sample, name string) *model.Attribute {
+	for _, attrs := range [][]*model.Attribute{sample.Attributes, sample.ProcessAttrs} {
+		for _, attr := range attrs {
+			if strings.EqualFold(attr.Name, strings.TrimSpace(name)) {
+				return attr
+			}
+		}
+	}
+
+	return nil
+}
+
+// eval computes the expression for the sample. ok is false when the sample doesn't have a value for one
+// of the attributes in it.
+func (e *exprNode) eval(sample *model.Sample) (value float64, ok bool, err error) {
+	switch e.op {
+	case 0:
+		return e.number, true, nil
+	case 'v':
+		attr := findSampleAttribute(sample, e.name)
+		if attr == nil || attr.Value == nil {
+			return 0, false, nil
+		}
+
+		value, ok := numericValue(attr.Value["value"])
+		if !ok {
+			return 0, false, fmt.Errorf("%s is '%v', which isn't a number", attr.Name, attr.Value["value"])
+		}
+		return value, true, nil
+	case 'n':
+		value, ok, err = e.left.eval(sample)
+		return -value, ok, err
+	}
+
+	left, ok, err := e.left.eval(sample)
+	if !ok || err != nil {
+		return 0, ok, err
+	}
+
+	right, ok, err := e.right.eval(sample)
+	if !ok || err != nil {
+		return 0, ok, err
+	}
+
+	switch e.op {
+	case '+':
+		value = left + right
+	case '-':
+		value = left - right
+	case '*':
+		value = left * right
+	case '/':
+		if right == 0 {
+			return 0, false, fmt.Errorf("division by zero")
+		}
+		value = left / right
+	case '^':
+		value = math.Pow(left, right)
+	}
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false, fmt.Errorf("the result isn't a number")
+	}
+
+	return value, true, nil
+}
+
+// exprParser is a recursive descent parser for derived attribute expressions:
+//
+//	expression = term {("+" | "-") term}
+//	term       = factor {("*" | "/") factor}
+//	factor     = "-" factor | primary ["^" factor]
+//	primary    = number | name | "{" name "}" | "(" expression ")"
+type exprParser struct {
+	input []rune
+	pos   int
+}
+
+// parseExpression parses a derived attribute expression.
+func parseExpression(expression string) (*exprNode, error) {
+	p := &exprParser{input: []rune(expression)}
+	if p.peek() == 0 {
+		return nil, fmt.Errorf("the expression is blank")
+	}
+
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek() != 0 {
+		return nil, fmt.Errorf("unexpected '%c' at position %d", p.peek(), p.pos+1)
+	}
+
+	return expr, nil
+}
+
+// peek skips spaces and returns the next character, or 0 at the end of the expression.
+func (p *exprParser) peek() rune {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+
+	if p.pos == len(p.input) {
+		return 0
+	}
+
+	return p.input[p.pos]
+}
+
+func (p *exprParser) expression() (*exprNode, error) {
+	left, err := p.term()
+	for err == nil && (p.peek() == '+' || p.peek() == '-') {
+		op := byte(p.input[p.pos])
+		p.pos++
+		var right *exprNode
+		if right, err = p.term(); err == nil {
+			left = &exprNode{op: op, left: left, right: right}
+		}
+	}
+
+	return left, err
+}
+
+func (p *exprParser) term() (*exprNode, error) {
+	left, err := p.factor()
+	for err == nil && (p.peek() == '*' || p.peek() == '/') {
+		op := byte(p.input[p.pos])
+		p.pos++
+		var right *exprNode
+		if right, err = p.factor(); err == nil {
+			left = &exprNode{op: op, left: left, right: right}
+		}
+	}
+
+	return left, err
+}
+
+func (p *exprParser) factor() (*exprNode, error) {
+	if p.peek() == '-' {
+		p.pos++
+		operand, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return &exprNode{op: 'n', left: operand}, nil
+	}
+
+	base, err := p.primary()
+	if err != nil || p.peek() != '^' {
+		return base, err
+	}
+
+	p.pos++
+	exponent, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+
+	return &exprNode{op: '^', left: base, right: exponent}, nil
+}
+
+func (p *exprParser) primary() (*exprNode, error) {
+	c := p.peek()
+	start := p.pos
+	switch {
+	case c == 0:
+		return nil, fmt.Errorf("the expression ends early")
+
+	case c == '(':
+		p.pos++
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ')' for the '(' at position %d", start+1)
+		}
+		p.pos++
+		return expr, nil
+
+	case c == '{':
+		for p.pos < len(p.input) && p.input[p.pos] != '}' {
+			p.pos++
+		}
+		if p.pos == len(p.input) {
+			return nil, fmt.Errorf("missing '}' for the '{' at position %d", start+1)
+		}
+		p.pos++
+		name := strings.TrimSpace(string(p.input[start+1 : p.pos-1]))
+		if name == "" {
+			return nil, fmt.Errorf("blank name at position %d", start+1)
+		}
+		return &exprNode{op: 'v', name: name}, nil
+
+	case unicode.IsDigit(c) || c == '.':
+		for p.pos < len(p.input) && (unicode.IsDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+			p.pos++
+		}
+		number, err := strconv.ParseFloat(string(p.input[start:p.pos]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' at position %d isn't a number", string(p.input[start:p.pos]), start+1)
+		}
+		return &exprNode{number: number}, nil
+
+	case isNameRune(c):
+		for p.pos < len(p.input) && isNameRune(p.input[p.pos]) {
+			p.pos++
+		}
+		return &exprNode{op: 'v', name: string(p.input[start:p.pos])}, nil
+
+	default:
+		return nil, fmt.Errorf("unexpected '%c' at position %d", c, start+1)
+	}
+}
+
+// isNameRune returns true if c can be in an attribute name that isn't in braces.
+func isNameRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_'
+}
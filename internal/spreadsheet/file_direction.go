@@ -0,0 +1,56 @@
+package spreadsheet
+
+import (
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// fileDirectionFromKeyword returns the direction given by a file-in: or file-out: header, or blank
+// for a header that doesn't give one, eg file:.
+func fileDirectionFromKeyword(cell string) string {
+	switch {
+	case hasFileInputKeyword(cell):
+		return model.FileDirectionIn
+	case hasFileOutputKeyword(cell):
+		return model.FileDirectionOut
+	default:
+		return ""
+	}
+}
+
+// setFileDirections fills in the direction of the file headers that didn't give one. They are inputs
+// unless fileDirectionByPosition is set, when the position of the column decides. The convention is
+// that files the process used come before its process attributes, and the files it produced come after
+// them:
+//   |sample|f:Recipe|p:Voltage(kV)|p:Magnification|f:Images|
+// Recipe is an input and Images is an output. A file column between two process attribute columns is
+// ambiguous and is treated as an input. A worksheet without process attributes only has inputs.
+func (r *rowProcessor) setFileDirections() {
+	first, last := 0, 0
+	for _, attr := range r.worksheet.ProcessAttrs {
+		if first == 0 || attr.Column < first {
+			first = attr.Column
+		}
+
+		if attr.Column > last {
+			last = attr.Column
+		}
+	}
+
+	for _, fileHeader := range r.worksheet.FileHeaders {
+		switch {
+		case fileHeader.Direction != "":
+			// Given by the header keyword
+			continue
+		case !r.fileDirectionByPosition || first == 0 || fileHeader.Column < first:
+			fileHeader.Direction = model.FileDirectionIn
+		case fileHeader.Column > last:
+			fileHeader.Direction = model.FileDirectionOut
+		default:
+			console.Warningf("Worksheet %s file column %s is between process attribute columns, its files are treated as inputs (use file-out: if they are outputs)\n",
+				r.worksheet.Source(), model.ColumnName(fileHeader.Column))
+			fileHeader.Direction = model.FileDirectionIn
+		}
+	}
+}
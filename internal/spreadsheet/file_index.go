@@ -0,0 +1,104 @@
+package spreadsheet
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/materials-commons/mcetl/internal/mcapix"
+)
+
+// FileIndex is a local copy of the list of files in a project. Once it has been downloaded (or
+// loaded from a cache file) checking that the files referenced in the worksheets exist in the
+// project doesn't require any server calls.
+type FileIndex struct {
+	ProjectID  string    `json:"project_id"`
+	Downloaded time.Time `json:"downloaded"`
+	Paths      []string  `json:"paths"`
+
+	paths map[string]bool
+}
+
+// DownloadFileIndex retrieves the complete file index for the project from the server.
+func DownloadFileIndex(projectID string, c *mcapix.Client) (*FileIndex, error) {
+	paths, err := c.GetProjectFileIndex(projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &FileIndex{
+		ProjectID:  projectID,
+		Downloaded: time.Now(),
+		Paths:      paths,
+	}
+	index.buildLookup()
+	return index, nil
+}
+
+// LoadFileIndex reads a file index cache file. Files ending in .gz are gzip compressed.
+func LoadFileIndex(cachePath string) (*FileIndex, error) {
+	f, err := os.Open(cachePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(cachePath, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read file index %s: %s", cachePath, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var index FileIndex
+	if err := json.NewDecoder(r).Decode(&index); err != nil {
+		return nil, fmt.Errorf("unable to read file index %s: %s", cachePath, err)
+	}
+
+	index.buildLookup()
+	return &index, nil
+}
+
+// Save writes the file index to a cache file. If the cache file ends in .gz then it is gzip compressed.
+func (fi *FileIndex) Save(cachePath string) error {
+	f, err := os.Create(cachePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if strings.HasSuffix(cachePath, ".gz") {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+
+	return json.NewEncoder(w).Encode(fi)
+}
+
+// Contains returns true if the file path is in the project.
+func (fi *FileIndex) Contains(filePath string) bool {
+	return fi.paths[normalizeProjectPath(filePath)]
+}
+
+func (fi *FileIndex) buildLookup() {
+	fi.paths = make(map[string]bool, len(fi.Paths))
+	for _, p := range fi.Paths {
+		fi.paths[normalizeProjectPath(p)] = true
+	}
+}
+
+// normalizeProjectPath puts a project file path in a standard form so that paths such as
+// "/dir/file.txt" and "dir//file.txt" match.
+func normalizeProjectPath(filePath string) string {
+	return strings.TrimPrefix(path.Clean("/"+filePath), "/")
+}
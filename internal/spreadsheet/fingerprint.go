@@ -0,0 +1,68 @@
+package spreadsheet
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	mcapi "github.com/materials-commons/gomcapi"
+)
+
+// fingerprintTag marks the fingerprint in an experiment description.
+const fingerprintTag = "mcetl-fingerprint:"
+
+// Fingerprint computes a content hash of the given workbooks. Each workbook is hashed and the hashes are
+// combined in sorted order, so the fingerprint doesn't depend on the order the workbooks are given in.
+func Fingerprint(paths []string) (string, error) {
+	var hashes []string
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+
+		hashes = append(hashes, fmt.Sprintf("%x", h.Sum(nil)))
+	}
+
+	sort.Strings(hashes)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(hashes, "\n")))), nil
+}
+
+// SamplesFingerprint returns the fingerprint of loading only the samples from the workbooks with the
+// fingerprint. The order of the samples doesn't matter. With no samples it is the workbooks' fingerprint.
+func SamplesFingerprint(fingerprint string, samples []string) string {
+	if len(samples) == 0 {
+		return fingerprint
+	}
+
+	sorted := append([]string(nil), samples...)
+	sort.Strings(sorted)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(fingerprint+"\nsamples:\n"+strings.Join(sorted, "\n"))))
+}
+
+// FingerprintDescription returns the experiment description that records the fingerprint.
+func FingerprintDescription(fingerprint string) string {
+	return fmt.Sprintf("Loaded by mcetl (%s%s)", fingerprintTag, fingerprint)
+}
+
+// FindExperimentWithFingerprint returns the experiment in the project's experiments that was loaded from
+// workbooks with the given fingerprint. It returns nil if the workbooks haven't been loaded into the project.
+func FindExperimentWithFingerprint(experiments []*mcapi.Experiment, fingerprint string) *mcapi.Experiment {
+	for _, experiment := range experiments {
+		if strings.Contains(experiment.Description, fingerprintTag+fingerprint) {
+			return experiment
+		}
+	}
+
+	return nil
+}
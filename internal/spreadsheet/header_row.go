@@ -0,0 +1,150 @@
+package spreadsheet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// HeaderRows describes where the header row is in each worksheet. A row is given as the number of
+// rows to skip before the header, so 0 means the header is the first row. Worksheets can have
+// different length preambles, so the row can be given per worksheet, or automatically detected.
+type HeaderRows struct {
+	// Rows to skip in worksheets that aren't listed in Sheets
+	Default int
+
+	// When true the header row is detected in worksheets that aren't listed in Sheets
+	AutoDetect bool
+
+	// Rows to skip by worksheet name. A value of autoDetectHeaderRow means detect the header row.
+	Sheets map[string]int
+}
+
+const autoDetectHeaderRow = -1
+
+// ParseHeaderRows parses a header row specification. The specification is a comma separated list of
+// entries, where an entry is either a row that applies to all worksheets, or worksheet=row for a
+// particular worksheet. Anywhere a row can be given, "auto" can be given instead to detect the header
+// row. Examples:
+//   3                 Skip 3 rows in every worksheet
+//   SEM=3,Casting=1   Skip 3 rows in SEM, 1 row in Casting and no rows in the other worksheets
+//   2,SEM=auto        Detect the header row in SEM and skip 2 rows in the other worksheets
+//   auto              Detect the header row in every worksheet
+func ParseHeaderRows(spec string) (*HeaderRows, error) {
+	headerRows := &HeaderRows{Sheets: make(map[string]int)}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		i := strings.LastIndex(entry, "=")
+		if i == -1 {
+			if strings.EqualFold(entry, "auto") {
+				headerRows.AutoDetect = true
+				continue
+			}
+
+			row, err := parseHeaderRow(entry)
+			if err != nil {
+				return nil, err
+			}
+			headerRows.Default = row
+			continue
+		}
+
+		sheet := strings.TrimSpace(entry[:i])
+		if sheet == "" {
+			return nil, fmt.Errorf("header row entry '%s' has no worksheet name", entry)
+		}
+
+		value := strings.TrimSpace(entry[i+1:])
+		if strings.EqualFold(value, "auto") {
+			headerRows.Sheets[sheet] = autoDetectHeaderRow
+			continue
+		}
+
+		row, err := parseHeaderRow(value)
+		if err != nil {
+			return nil, err
+		}
+		headerRows.Sheets[sheet] = row
+	}
+
+	return headerRows, nil
+}
+
+func parseHeaderRow(value string) (int, error) {
+	row, err := strconv.Atoi(value)
+	if err != nil || row < 0 {
+		return 0, fmt.Errorf("invalid header row '%s', must be a number 0 or greater, or auto", value)
+	}
+
+	return row, nil
+}
+
+// rowsToSkip returns the number of rows to skip before the header row in the worksheet.
+func (h *HeaderRows) rowsToSkip(worksheet *model.Worksheet, rows [][]string) int {
+	if h == nil {
+		return 0
+	}
+
+	row, ok := h.Sheets[worksheet.Name]
+	switch {
+	case ok && row == autoDetectHeaderRow:
+		return detectHeaderRow(worksheet, rows)
+	case ok:
+		return row
+	case h.AutoDetect:
+		return detectHeaderRow(worksheet, rows)
+	default:
+		return h.Default
+	}
+}
+
+// detectHeaderRow finds the header row in a worksheet that has a preamble (title, notes, etc...) before
+// the header. The header is the first row that has a sample name in column 1 and a known keyword in one
+// of the other columns. If there is no such row then the first row with at least two non blank cells
+// is used. It returns the number of rows to skip.
+func detectHeaderRow(worksheet *model.Worksheet, rows [][]string) int {
+	firstMultiCellRow := -1
+	for i, row := range rows {
+		if len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+
+		nonBlank := 0
+		for column, cell := range row {
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				continue
+			}
+
+			nonBlank++
+			if column > 0 && hasKnownKeyword(cell) {
+				return i
+			}
+		}
+
+		if nonBlank > 1 && firstMultiCellRow == -1 {
+			firstMultiCellRow = i
+		}
+	}
+
+	if firstMultiCellRow == -1 {
+		console.Warningf("Worksheet %s unable to detect header row, using first row\n", worksheet.Source())
+		return 0
+	}
+
+	return firstMultiCellRow
+}
+
+// hasKnownKeyword returns true if the cell starts with one of the known attribute keywords.
+func hasKnownKeyword(cell string) bool {
+	return hasProcessAttributeKeyword(cell) || hasSampleAttributeKeyword(cell) ||
+		hasFileAttributeKeyword(cell) || hasIgnoreAttributeKeyword(cell) || hasCohortKeyword(cell) || hasStepKeyword(cell) || hasSampleIDKeyword(cell)
+}
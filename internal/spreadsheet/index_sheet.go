@@ -0,0 +1,172 @@
+package spreadsheet
+
+/*
+ * index_sheet lets the settings for loading a workbook live in the workbook, so that they travel with it
+ * rather than having to be remembered on the command line. With --index-sheet the first worksheet in each
+ * workbook is an index that lists the other worksheets and how to load them:
+ *
+ *   |sheet          |header row |has parent |template       |skip |measurement |
+ *   |Heat Treatment |2          |no         |heat-treatment |     |            |
+ *   |SEM            |auto       |yes        |sem            |     |            |
+ *   |Hardness       |           |yes        |               |     |yes         |
+ *   |Notes          |           |           |               |yes  |            |
+ *
+ * The columns can be in any order and only the sheet column is required.
+ *
+ *   header row  The rows to skip before the header row, or auto, as for --header-row
+ *   has parent  yes if the worksheet's second column is the parent column, as for --has-parent
+ *   template    The process template the worksheet's processes are created from. The default is the
+ *               name of the worksheet.
+ *   skip        yes if the worksheet isn't loaded
+ *   measurement yes if the worksheet's sample attributes are measurements of the samples on its parent
+ *               worksheet, as for --measurement-sheets
+ *
+ * A blank cell, or a worksheet that isn't listed, uses the setting from the command line. A setting in the
+ * index is used in place of the command line setting for that worksheet. The index sheet itself isn't loaded.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// The columns of an index sheet.
+const (
+	indexSheetColumn       = "sheet"
+	indexHeaderRowColumn   = "header row"
+	indexHasParentColumn   = "has parent"
+	indexTemplateColumn    = "template"
+	indexSkipColumn        = "skip"
+	indexMeasurementColumn = "measurement"
+)
+
+// sheetSettings are the settings given for a worksheet in an index sheet. headerRow and hasParent are
+// nil when they were left blank.
+type sheetSettings struct {
+	headerRow   *int
+	hasParent   *bool
+	template    string
+	skip        bool
+	measurement bool
+}
+
+// readIndexSheet reads the index from the first of the sheets read from a workbook. It returns the
+// settings for each worksheet by name, and the remaining sheets to load.
+func readIndexSheet(sheets []*sheet) (map[string]*sheetSettings, []*sheet, error) {
+	if len(sheets) == 0 {
+		return nil, sheets, nil
+	}
+
+	index, rest := sheets[0], sheets[1:]
+	if len(index.rows) == 0 {
+		return nil, nil, fmt.Errorf("index sheet %s (%s) is empty", index.name, index.file)
+	}
+
+	columns := make(map[string]int)
+	for i, cell := range index.rows[0] {
+		header := strings.ToLower(strings.TrimSpace(cell))
+		switch header {
+		case "":
+			continue
+		case indexSheetColumn, indexHeaderRowColumn, indexHasParentColumn, indexTemplateColumn, indexSkipColumn, indexMeasurementColumn:
+			columns[header] = i
+		default:
+			return nil, nil, fmt.Errorf("index sheet %s (%s) has unknown column '%s', the columns are '%s', '%s', '%s', '%s', '%s' and '%s'",
+				index.name, index.file, cell, indexSheetColumn, indexHeaderRowColumn, indexHasParentColumn, indexTemplateColumn, indexSkipColumn,
+				indexMeasurementColumn)
+		}
+	}
+
+	if _, ok := columns[indexSheetColumn]; !ok {
+		return nil, nil, fmt.Errorf("index sheet %s (%s) has no '%s' column", index.name, index.file, indexSheetColumn)
+	}
+
+	names := make(map[string]bool)
+	for _, s := range rest {
+		names[s.name] = true
+	}
+
+	settings := make(map[string]*sheetSettings)
+	for i, row := range index.rows[1:] {
+		rowIndex := i + 2
+		cell := func(column string) string {
+			if c, ok := columns[column]; ok && c < len(row) {
+				return strings.TrimSpace(row[c])
+			}
+			return ""
+		}
+
+		name := cell(indexSheetColumn)
+		switch {
+		case name == "":
+			continue
+		case !names[name]:
+			return nil, nil, fmt.Errorf("index sheet %s (%s) row %d lists worksheet '%s' that isn't in the workbook",
+				index.name, index.file, rowIndex, name)
+		case settings[name] != nil:
+			return nil, nil, fmt.Errorf("index sheet %s (%s) row %d lists worksheet '%s' more than once",
+				index.name, index.file, rowIndex, name)
+		}
+
+		s := &sheetSettings{template: cell(indexTemplateColumn)}
+		if value := cell(indexHeaderRowColumn); value != "" {
+			skipRows := autoDetectHeaderRow
+			if !strings.EqualFold(value, "auto") {
+				var err error
+				if skipRows, err = parseHeaderRow(value); err != nil {
+					return nil, nil, fmt.Errorf("index sheet %s (%s) row %d: %s", index.name, index.file, rowIndex, err)
+				}
+			}
+			s.headerRow = &skipRows
+		}
+
+		if value := cell(indexHasParentColumn); value != "" {
+			hasParent, err := parseIndexYesNo(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("index sheet %s (%s) row %d: %s", index.name, index.file, rowIndex, err)
+			}
+			s.hasParent = &hasParent
+		}
+
+		if value := cell(indexSkipColumn); value != "" {
+			var err error
+			if s.skip, err = parseIndexYesNo(value); err != nil {
+				return nil, nil, fmt.Errorf("index sheet %s (%s) row %d: %s", index.name, index.file, rowIndex, err)
+			}
+		}
+
+		if value := cell(indexMeasurementColumn); value != "" {
+			var err error
+			if s.measurement, err = parseIndexYesNo(value); err != nil {
+				return nil, nil, fmt.Errorf("index sheet %s (%s) row %d: %s", index.name, index.file, rowIndex, err)
+			}
+		}
+
+		settings[name] = s
+	}
+
+	return settings, rest, nil
+}
+
+// rowsToSkip returns the number of rows to skip before the header row in the worksheet.
+func (s *sheetSettings) rowsToSkip(worksheet *model.Worksheet, rows [][]string) int {
+	if *s.headerRow == autoDetectHeaderRow {
+		return detectHeaderRow(worksheet, rows)
+	}
+
+	return *s.headerRow
+}
+
+// parseIndexYesNo parses the yes or no value of a cell in an index sheet.
+func parseIndexYesNo(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "yes", "y", "true", "x":
+		return true, nil
+	case "no", "n", "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("'%s' should be yes or no", value)
+	}
+}
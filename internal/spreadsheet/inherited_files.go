@@ -0,0 +1,74 @@
+package spreadsheet
+
+/*
+ * inherited_files passes the files of a sample's row on to the next process the sample goes through.
+ * The files a process produces, such as the images from an SEM, are often what the next process works
+ * from, and without --inherit-files they have to be listed again in the next worksheet:
+ *
+ *   SEM                                      EBSD
+ *   |sample|parent |p:Voltage(kV)|file:|     |sample|parent|p:Step Size(um)|
+ *   |S1    |Casting|15           |s1.tif|    |S1    |SEM   |0.5            |
+ *
+ * With --inherit-files s1.tif is an input file of S1's EBSD process too. Only the files given on the
+ * parent's row are passed on, not the files that row inherited itself, so a file goes one step down
+ * the workflow. A file the child row already has isn't added again.
+ */
+
+import (
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+)
+
+// inheritFiles adds the files on the row of each sample's parent worksheet to the sample as input files.
+// Parents that aren't worksheets are left to validateParents.
+func inheritFiles(worksheets []*model.Worksheet) {
+	byName := make(map[string]*model.Worksheet)
+	for _, worksheet := range worksheets {
+		byName[worksheet.Name] = worksheet
+	}
+
+	// The files given on each row before any were inherited, so the order of the worksheets doesn't matter
+	ownFiles := make(map[*model.Sample][]model.File)
+	for _, worksheet := range worksheets {
+		for _, sample := range worksheet.Samples {
+			ownFiles[sample] = sample.Files
+		}
+	}
+
+	for _, worksheet := range worksheets {
+		for _, sample := range worksheet.Samples {
+			if _, existing := processor.ExistingProcessID(sample.Parent); existing || sample.Parent == "" {
+				continue
+			}
+
+			parent, ok := byName[sample.Parent]
+			if !ok {
+				continue
+			}
+
+			from := lastStepOf(parent, sample.Name)
+			if from == nil {
+				continue
+			}
+
+			for _, file := range ownFiles[from] {
+				if !hasFile(sample.Files, file.Path) {
+					// A new slice, as samples expanded from a cross-tab row share their files
+					sample.Files = append(append([]model.File{}, sample.Files...),
+						model.File{Path: file.Path, Direction: model.FileDirectionIn})
+				}
+			}
+		}
+	}
+}
+
+// hasFile returns true if one of the files has the path.
+func hasFile(files []model.File, path string) bool {
+	for _, file := range files {
+		if file.Path == path {
+			return true
+		}
+	}
+
+	return false
+}
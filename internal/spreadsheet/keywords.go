@@ -10,6 +10,8 @@ package spreadsheet
 import (
 	"fmt"
 	"strings"
+
+	"github.com/materials-commons/mcetl/internal/console"
 )
 
 // Default set of keywords for sample attributes
@@ -32,6 +34,49 @@ var FileAttributeKeywords = map[string]bool{
 	"files": true,
 }
 
+// Default set of keywords for file columns whose files are inputs to, or outputs of, the process.
+// Files in a column with one of the FileAttributeKeywords are inputs unless their direction is
+// inferred from the position of the column, see file_direction.go.
+var FileInputKeywords = map[string]bool{
+	"file-in": true,
+	"f-in":    true,
+}
+
+var FileOutputKeywords = map[string]bool{
+	"file-out": true,
+	"f-out":    true,
+}
+
+// Default set of keywords for the date column. The date column is a process attribute whose value
+// is when the process was performed, see hasDateAttributeKeyword.
+var DateAttributeKeywords = map[string]bool{
+	"date": true,
+}
+
+// Default set of keywords for lookup columns, whose cells refer to an entry on a lookup worksheet,
+// eg lookup:Recipes. See lookup_sheet.go.
+var LookupAttributeKeywords = map[string]bool{
+	"lookup": true,
+}
+
+// Default set of keywords for the cohort column, which gives the experiment each sample is loaded into
+// with --experiment-per-cohort. See cohorts.go.
+var CohortKeywords = map[string]bool{
+	"cohort": true,
+}
+
+// Default set of keywords for the step column, which orders the processes a sample goes through on a
+// worksheet. See process_steps.go.
+var StepKeywords = map[string]bool{
+	"step": true,
+}
+
+// Default set of keywords for the sample ID column, which gives the ID of the sample already on the
+// server that the sample on the row is. See sample_ids.go.
+var SampleIDKeywords = map[string]bool{
+	"mcid": true,
+}
+
 var IgnoreAttributeKeywords = map[string]bool{
 	"i":      true,
 	"ignore": true,
@@ -67,13 +112,25 @@ func columnAttributeTypeFromKeyword(cell string) ColumnAttributeType {
 	// in row_processor.go to handle those new keywords.
 
 	switch {
-	case hasProcessAttributeKeyword(cell):
+	case hasLookupAttributeKeyword(cell):
+		return LookupAttributeColumn
+
+	case hasCohortKeyword(cell):
+		return CohortColumn
+
+	case hasStepKeyword(cell):
+		return StepColumn
+
+	case hasSampleIDKeyword(cell):
+		return SampleIDColumn
+
+	case hasProcessAttributeKeyword(cell), hasDateAttributeKeyword(cell):
 		return ProcessAttributeColumn
 
 	case hasSampleAttributeKeyword(cell):
 		return SampleAttributeColumn
 
-	case hasFileAttributeKeyword(cell):
+	case hasFileAttributeKeyword(cell), hasFileInputKeyword(cell), hasFileOutputKeyword(cell):
 		return FileAttributeColumn
 
 	case hasIgnoreAttributeKeyword(cell):
@@ -101,12 +158,60 @@ func hasProcessAttributeKeyword(cell string) bool {
 	return hasKeywordInCell(cell, ProcessAttributeKeywords)
 }
 
+// hasDateAttributeKeyword returns true if the cell contains a keyword
+// from the DateAttributeKeywords, eg date: or date:Performed.
+func hasDateAttributeKeyword(cell string) bool {
+	return hasKeywordInCell(cell, DateAttributeKeywords)
+}
+
+// isDateColumn returns true if the process attribute header is the date column. This is either a
+// header with a date keyword or a process attribute named Date, eg p:Date.
+func isDateColumn(cell, name string) bool {
+	return hasDateAttributeKeyword(cell) || strings.EqualFold(name, "date")
+}
+
+// hasLookupAttributeKeyword returns true if the cell contains
+// a keyword from the LookupAttributeKeywords.
+func hasLookupAttributeKeyword(cell string) bool {
+	return hasKeywordInCell(cell, LookupAttributeKeywords)
+}
+
+// hasCohortKeyword returns true if the cell contains
+// a keyword from the CohortKeywords.
+func hasCohortKeyword(cell string) bool {
+	return hasKeywordInCell(cell, CohortKeywords)
+}
+
+// hasStepKeyword returns true if the cell contains
+// a keyword from the StepKeywords.
+func hasStepKeyword(cell string) bool {
+	return hasKeywordInCell(cell, StepKeywords)
+}
+
+// hasSampleIDKeyword returns true if the cell contains
+// a keyword from the SampleIDKeywords.
+func hasSampleIDKeyword(cell string) bool {
+	return hasKeywordInCell(cell, SampleIDKeywords)
+}
+
 // hasFileAttributeKeyword returns true if the cell contains
 // a keyword from the FileAttributeKeywords.
 func hasFileAttributeKeyword(cell string) bool {
 	return hasKeywordInCell(cell, FileAttributeKeywords)
 }
 
+// hasFileInputKeyword returns true if the cell contains
+// a keyword from the FileInputKeywords.
+func hasFileInputKeyword(cell string) bool {
+	return hasKeywordInCell(cell, FileInputKeywords)
+}
+
+// hasFileOutputKeyword returns true if the cell contains
+// a keyword from the FileOutputKeywords.
+func hasFileOutputKeyword(cell string) bool {
+	return hasKeywordInCell(cell, FileOutputKeywords)
+}
+
 // hasIgnoreAttributeKeyword returns true if the cell contains
 // a keyword from the IgnoreAttributeKeywords. Allow headers
 // to just be the word to ignore, ie: note, ignore, etc... as
@@ -203,6 +308,23 @@ func SetFileKeywords(keywords ...string) {
 	}
 }
 
+// AddBlankKeyword adds a new cell value to the BlankCellKeywords map.
+func AddBlankKeyword(keyword string) {
+	BlankCellKeywords[strings.ToLower(strings.TrimSpace(keyword))] = true
+}
+
+// SetBlankKeywords overrides the current BlankCellKeywords with the new set of
+// cell values. It clears the current set before setting the new set.
+func SetBlankKeywords(keywords ...string) {
+	// Clear BlankCellKeywords
+	BlankCellKeywords = make(map[string]bool)
+
+	// Add new set of keywords
+	for _, keyword := range keywords {
+		AddBlankKeyword(keyword)
+	}
+}
+
 // ValidateKeywords goes through the ProcessAttributeKeywords, SampleAttributeKeywords,
 // and FileAttributeKeywords
 func ValidateKeywords() error {
@@ -258,7 +380,7 @@ func overlappingKeywords() bool {
 	for key := range keywordCounts {
 		count, _ := keywordCounts[key]
 		if count != 1 {
-			fmt.Printf("Keyword '%s' repeated in multiple attribute keyword identifiers\n", key)
+			console.Errorf("Keyword '%s' repeated in multiple attribute keyword identifiers\n", key)
 			foundError = true
 		}
 	}
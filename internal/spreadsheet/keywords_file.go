@@ -0,0 +1,108 @@
+package spreadsheet
+
+/*
+ * keywords_file reads the header keywords from a file, for groups whose spreadsheets use their own
+ * conventions, such as cond: for process attributes and meas: for sample attributes:
+ *
+ *   sample: [meas, measurement]
+ *   process: [cond, condition]
+ *   file: [data]
+ *   blank: ["-", "nd"]
+ *
+ * The keywords are added to the default ones. With replace: true the lists that are given replace the
+ * defaults instead, so that, for example, s: is no longer a sample attribute. The file can be YAML or JSON.
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// KeywordsFile is the contents of a keywords file, see above.
+type KeywordsFile struct {
+	Sample  []string `yaml:"sample"`
+	Process []string `yaml:"process"`
+	File    []string `yaml:"file"`
+	Blank   []string `yaml:"blank"`
+
+	// When true the lists replace the default keywords rather than being added to them
+	Replace bool `yaml:"replace"`
+}
+
+// LoadKeywordsFile reads the keywords file at path and sets the keywords from it. It returns an error
+// if the file can't be read, or a keyword is already a keyword of another kind.
+func LoadKeywordsFile(path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var keywords KeywordsFile
+	if err := yaml.UnmarshalStrict(contents, &keywords); err != nil {
+		return fmt.Errorf("unable to parse keywords file %s: %s", path, err)
+	}
+
+	lists := []struct {
+		kind     string
+		keywords []string
+		add      func(string)
+		set      func(...string)
+	}{
+		{"sample", keywords.Sample, AddSampleKeyword, SetSampleKeywords},
+		{"process", keywords.Process, AddProcessKeyword, SetProcessKeywords},
+		{"file", keywords.File, AddFileKeyword, SetFileKeywords},
+	}
+
+	for _, list := range lists {
+		for i, keyword := range list.keywords {
+			keyword = strings.ToLower(strings.TrimSpace(keyword))
+			switch {
+			case keyword == "" || strings.Contains(keyword, ":"):
+				return fmt.Errorf("keywords file %s: %s keyword '%s' must be a word without a colon", path, list.kind, list.keywords[i])
+			case isOtherKeyword(keyword):
+				return fmt.Errorf("keywords file %s: %s keyword '%s' is already used for another kind of column", path, list.kind, keyword)
+			}
+			list.keywords[i] = keyword
+		}
+
+		switch {
+		case len(list.keywords) == 0:
+		case keywords.Replace:
+			list.set(list.keywords...)
+		default:
+			for _, keyword := range list.keywords {
+				list.add(keyword)
+			}
+		}
+	}
+
+	if keywords.Replace && len(keywords.Blank) != 0 {
+		SetBlankKeywords(keywords.Blank...)
+	} else {
+		for _, keyword := range keywords.Blank {
+			AddBlankKeyword(keyword)
+		}
+	}
+
+	if err := ValidateKeywords(); err != nil {
+		return fmt.Errorf("keywords file %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// isOtherKeyword returns true if the keyword is one of the keywords that aren't set by a keywords file,
+// such as cohort or notes.
+func isOtherKeyword(keyword string) bool {
+	for _, keywords := range []map[string]bool{FileInputKeywords, FileOutputKeywords, DateAttributeKeywords,
+		LookupAttributeKeywords, CohortKeywords, StepKeywords, SampleIDKeywords, IgnoreAttributeKeywords} {
+		if keywords[keyword] {
+			return true
+		}
+	}
+
+	return false
+}
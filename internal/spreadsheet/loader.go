@@ -2,26 +2,173 @@ package spreadsheet
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 
 	"github.com/360EntSecGroup-Skylar/excelize"
 
-	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/dates"
+	"github.com/materials-commons/mcetl/internal/mcapix"
 	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
 )
 
 type Loader struct {
 	HasParent bool
-	HeaderRow int
-	Paths     []string
+
+	// HeaderRows gives the header row for each worksheet. If nil the header is the first row.
+	HeaderRows *HeaderRows
+
+	Paths []string
+
+	// Schema contains optional column level rules for interpreting cells, it may be nil
+	Schema *Schema
+
+	// SamplesSheet is the name of the worksheet that describes how the samples were created. It
+	// never has a parent column.
+	SamplesSheet string
+
+	// MasterSheet is the name of the worksheet that lists sample attributes, such as composition,
+	// that apply to the sample on every worksheet. See master_sheet.go.
+	MasterSheet string
+
+	// FillParentDown treats a blank parent cell as the same parent as the previous row rather than
+	// as a sample that comes from the Create Samples process. Only used when HasParent is true.
+	FillParentDown bool
+
+	// ConvertCellUnits converts values that give their own unit, eg "350 K" in a Temperature(C)
+	// column, to the column's unit rather than keeping the unit from the cell.
+	ConvertCellUnits bool
+
+	// MaxErrors stops loading once this many errors have been found, 0 means no limit. Very
+	// broken workbooks can otherwise produce thousands of errors. Every error found is returned,
+	// and StoppedAtMaxErrors is set when there were rows or worksheets left unread.
+	MaxErrors          int
+	StoppedAtMaxErrors bool
+
+	// errorsFound is the number of errors found in the worksheets loaded so far, so loadWorksheet
+	// can stop at MaxErrors
+	errorsFound int
+
+	// IgnoreMergedCells turns off copying the value of a merged cell into all the rows it covers
+	IgnoreMergedCells bool
+
+	// IncludeAttrs and ExcludeAttrs are glob patterns that select the attributes to load by name, see
+	// attribute_filter.go. If neither is set all attributes are loaded.
+	IncludeAttrs []string
+	ExcludeAttrs []string
+
+	// Samples are the names of the only samples to load, if empty all samples are loaded. See
+	// sample_filter.go.
+	Samples []string
+
+	// SkipSheets are the names of worksheets that aren't loaded
+	SkipSheets []string
+
+	// ColumnTypes overrides the type given by the keyword in a column's header. It is keyed by worksheet
+	// name and then by column, starting at 1 for the sample column.
+	ColumnTypes map[string]map[int]ColumnAttributeType
+
+	// FileDirectionByPosition treats file columns that come after the process attribute columns as
+	// the outputs of the process, rather than inputs. See file_direction.go.
+	FileDirectionByPosition bool
+
+	// CrosstabSheets are the names of the worksheets that are laid out as a cross-tab of samples
+	// vs condition levels. See crosstab.go for the layout.
+	CrosstabSheets []string
+
+	// LookupSheets are the names of the worksheets whose rows are looked up by the lookup: columns of the
+	// other worksheets. See lookup_sheet.go.
+	LookupSheets []string
+
+	// MeasurementSheets are the names of the worksheets whose sample attributes are measurements of the
+	// samples on their parent worksheet rather than a process of their own. See measurement_sheets.go.
+	MeasurementSheets []string
+
+	// InheritFiles adds the files on the row of a sample's parent worksheet to the sample's row as input
+	// files. See inherited_files.go.
+	InheritFiles bool
+
+	// AllowEmptySheets keeps the worksheets that have a header but no data rows. By default they are
+	// left out, with a warning, so that they can't be a parent or take the name of another worksheet.
+	AllowEmptySheets bool
+
+	// AttributelessSheets is what to do with the worksheets that have no attribute or file columns, one of
+	// the AttributelessSheets constants. Blank is the same as AttributelessSheetsSkipped. See attributeless_sheets.go.
+	AttributelessSheets string
+
+	// MaxCellSize is the most characters a data cell can have, 0 means no limit. LongCells is the
+	// policy for a cell over the limit, LongCellsAsErrors (the default) or LongCellsTruncated.
+	MaxCellSize int
+	LongCells   string
+
+	// MinorityCells is the policy for the cells whose type isn't the type of nearly all the others in
+	// their column, one of the MinorityCells constants. Blank is the same as MinorityCellsWarned. See
+	// minority_cells.go.
+	MinorityCells string
+
+	// DateFormats are the formats of the dates typed in cells, such as DD/MM/YYYY, that are tried before the
+	// dates.DefaultFormats, both for cells and the date column. Dates are stored as ISO-8601. See date_cells.go.
+	DateFormats []string
+
+	// TextNormalization is how the text of the cells is cleaned up before they are read, one of the
+	// TextNormalization constants. Blank is the same as TextNormalizationWhitespace. See text_normalization.go.
+	TextNormalization string
+
+	// Encoding is the encoding of CSV and TSV files, one of the Encoding constants. Blank is the same as
+	// EncodingAuto. See text_encoding.go.
+	Encoding string
+
+	// UnitsRow treats the row under the header as the units of the attributes rather than as a sample.
+	// See units_row.go.
+	UnitsRow bool
+
+	// DetectParent loads the worksheets whose second column holds the names of other worksheets as if
+	// they had HasParent. See parent_detection.go.
+	DetectParent bool
+
+	// WarnDeclaredTypes warns about each header that declares the type of its column, as the attribute
+	// name in a header written before types could be declared can end in one. See declared_types.go.
+	WarnDeclaredTypes bool
+
+	// IndexSheet treats the first worksheet in each workbook as an index that gives the settings for
+	// loading the other worksheets. See index_sheet.go.
+	IndexSheet bool
+
+	// FileIndex is an optional local copy of the project's file list. When set, files are
+	// validated against it rather than by calling the server.
+	FileIndex *FileIndex
+
+	// Cache is an optional cache of the rows read from workbooks, so an unchanged workbook isn't
+	// read again. Only workbooks loaded from Paths are cached.
+	Cache *ParseCache
+
+	// lookups are the loaded LookupSheets by name
+	lookups map[string]*lookupTable
+
+	// sheetNames are the names of all the worksheets being loaded, which a parent column refers to
+	sheetNames map[string]bool
+}
+
+// sheet is a worksheet that has been read from a workbook but not yet processed.
+type sheet struct {
+	name  string
+	file  string
+	index int
+	rows  [][]string
 }
 
-func NewLoader(hasParent bool, headerRow int, paths []string) *Loader {
+func NewLoader(hasParent bool, headerRows *HeaderRows, paths []string) *Loader {
 	return &Loader{
-		HasParent: hasParent,
-		HeaderRow: headerRow,
-		Paths:     paths,
+		HasParent:  hasParent,
+		HeaderRows: headerRows,
+		Paths:      paths,
 	}
 }
 
@@ -29,9 +176,72 @@ func NewLoader(hasParent bool, headerRow int, paths []string) *Loader {
 // worksheet and the process will take on the name of the worksheet. The way that Load
 // works is it transforms the spreadsheet into a data structure that can be more easily
 // understood and worked with. This is encompassed in the model.Worksheet data structure.
-// The header row parameter is the starting row for the header. Rows before that will
-// be skipped.
+// The HeaderRows gives the starting row for the header in each worksheet. Rows before that
+// will be skipped. A path can also be a zip of workbooks, see zip_bundle.go, or a CSV or TSV
+// file, see delimited_file.go.
 func (l *Loader) Load() ([]*model.Worksheet, error) {
+	var workbooks []workbook
+	for _, path := range l.Paths {
+		if isZipFile(path) {
+			zipped, err := zippedWorkbooks(path)
+			if err != nil {
+				return nil, err
+			}
+			workbooks = append(workbooks, zipped...)
+			continue
+		}
+
+		path := path
+		workbooks = append(workbooks, workbook{
+			name:      path,
+			path:      path,
+			open:      func() (*excelize.File, error) { return excelize.OpenFile(path) },
+			delimiter: delimiterForFile(path),
+			openText:  func() (io.ReadCloser, error) { return os.Open(path) },
+		})
+	}
+
+	return l.load(workbooks)
+}
+
+// NamedReader is a spreadsheet to load that is read from Reader rather than a file. Name identifies
+// the spreadsheet in errors and displays, for example the name of an uploaded file.
+type NamedReader struct {
+	Name   string
+	Reader io.Reader
+}
+
+// LoadFromReaders is the same as Load except that the spreadsheets are read from readers rather than
+// from Paths. This lets services that embed mcetl load uploaded spreadsheets without writing them to
+// temporary files. A reader whose name ends in .csv or .tsv is read as a CSV or TSV file.
+func (l *Loader) LoadFromReaders(readers []NamedReader) ([]*model.Worksheet, error) {
+	var workbooks []workbook
+	for _, r := range readers {
+		r := r
+		workbooks = append(workbooks, workbook{
+			name:      r.Name,
+			open:      func() (*excelize.File, error) { return excelize.OpenReader(r.Reader) },
+			delimiter: delimiterForFile(r.Name),
+			openText:  func() (io.ReadCloser, error) { return ioutil.NopCloser(r.Reader), nil },
+		})
+	}
+
+	return l.load(workbooks)
+}
+
+// workbook is a spreadsheet to load, and how to open it. path is only set for workbooks that are
+// files, and is used to cache them.
+type workbook struct {
+	name string
+	path string
+	open func() (*excelize.File, error)
+
+	// delimiter is set when the workbook is a CSV or TSV file, which is opened with openText
+	delimiter rune
+	openText  func() (io.ReadCloser, error)
+}
+
+func (l *Loader) load(workbooks []workbook) ([]*model.Worksheet, error) {
 	var worksheets []*model.Worksheet
 
 	// Make sure the keywords are valid before we start processing the spreadsheet,
@@ -41,34 +251,142 @@ func (l *Loader) Load() ([]*model.Worksheet, error) {
 		return worksheets, err
 	}
 
+	attrFilter, err := newAttributeFilter(l.IncludeAttrs, l.ExcludeAttrs)
+	if err != nil {
+		return worksheets, err
+	}
+
 	var savedErrs *multierror.Error
+	l.StoppedAtMaxErrors = false
 
-	// Loop through each file and build up the list of worksheets across all of the files
-	for _, file := range l.Paths {
-		xlsx, err := excelize.OpenFile(file)
+	// leftOut are the worksheets left out because they have no data rows, or nothing but samples,
+	// and why
+	leftOut := make(map[string]string)
+
+	// parentColumns is set when a worksheet has a parent column, which an index sheet can give a
+	// worksheet without HasParent
+	parentColumns := l.HasParent
+
+	// Read all the files first, so the lookup worksheets are loaded before the worksheets that use them
+	// whichever file they are in
+	l.lookups = make(map[string]*lookupTable)
+	var workbookSheets [][]*sheet
+	var indexes []map[string]*sheetSettings
+	for _, wb := range workbooks {
+		sheets, err := l.readWorkbook(wb)
 		if err != nil {
 			return worksheets, err
 		}
 
+		var index map[string]*sheetSettings
+		if l.IndexSheet {
+			if index, sheets, err = readIndexSheet(sheets); err != nil {
+				return worksheets, err
+			}
+		}
+
+		if sheets, err = l.loadLookupSheets(sheets, attrFilter); err != nil {
+			return worksheets, err
+		}
+
+		workbookSheets = append(workbookSheets, sheets)
+		indexes = append(indexes, index)
+	}
+
+	// measurementSheets are the worksheets given in MeasurementSheets or by an index sheet
+	measurementSheets := make(map[string]bool)
+
+	l.sheetNames = make(map[string]bool)
+	for _, sheets := range workbookSheets {
+		for _, s := range sheets {
+			l.sheetNames[s.name] = true
+		}
+	}
+
+	// Loop through each file and build up the list of worksheets across all of the files
+	for i, sheets := range workbookSheets {
+		index := indexes[i]
+
 		// Loop through each of the worksheets in the excel file creating a list
 		// of loading errors so we can report back all the load/parsing errors
 		// to the user.
-		for index, name := range xlsx.GetSheetMap() {
-			worksheet, err := l.loadWorksheet(xlsx, name, index)
+		for _, s := range sheets {
+			if l.tooManyErrors(savedErrs) {
+				l.StoppedAtMaxErrors = true
+				return worksheets, savedErrs.ErrorOrNil()
+			}
+
+			settings := index[s.name]
+			if l.isSkippedSheet(s.name) || (settings != nil && settings.skip) {
+				continue
+			}
+			settings = l.detectParentColumn(s, settings, l.sheetNames)
+			if l.isMeasurementSheet(s.name) || (settings != nil && settings.measurement) {
+				measurementSheets[s.name] = true
+			}
+
+			l.errorsFound = errorCount(savedErrs)
+			worksheet, err := l.loadWorksheet(s, attrFilter, settings)
 			if err != nil {
 				savedErrs = multierror.Append(savedErrs, err)
 				continue
 			}
+
+			if worksheet.HasParent {
+				parentColumns = true
+			}
+
+			if len(worksheet.Samples) == 0 && !l.AllowEmptySheets && !l.isSamplesOrMasterSheet(s.name) {
+				console.Warningf("Worksheet %s has no data rows, it won't be loaded (use --allow-empty-sheets to keep it)\n", worksheet.Source())
+				leftOut[worksheet.Name] = "has no data rows"
+				continue
+			}
+
+			if len(worksheet.Samples) != 0 && l.skipAttributeless(worksheet) {
+				console.Warningf("Worksheet %s has no attribute or file columns, it won't be loaded (use --attributeless-sheets associate to load its samples as a step without attributes)\n",
+					worksheet.Source())
+				leftOut[worksheet.Name] = "has no attribute or file columns"
+				continue
+			}
 			worksheets = append(worksheets, worksheet)
 		}
 	}
 
+	if l.MasterSheet != "" && savedErrs == nil {
+		var err error
+		if worksheets, err = mergeMasterSheet(l.MasterSheet, worksheets); err != nil {
+			savedErrs = multierror.Append(savedErrs, err)
+		}
+	}
+
+	if len(measurementSheets) != 0 && savedErrs == nil {
+		var err error
+		if worksheets, err = mergeMeasurementSheets(measurementSheets, worksheets); err != nil {
+			savedErrs = multierror.Append(savedErrs, err)
+		}
+	}
+
+	if l.InheritFiles && savedErrs == nil {
+		inheritFiles(worksheets)
+	}
+
+	if len(l.Samples) != 0 && savedErrs == nil {
+		var err error
+		if worksheets, err = l.filterSamples(worksheets); err != nil {
+			savedErrs = multierror.Append(savedErrs, err)
+		}
+	}
+
+	if _, err := SampleIDs(worksheets); err != nil {
+		savedErrs = multierror.Append(savedErrs, err)
+	}
+
 	// To build the workflow column 2 in a worksheet is the parent column. It points to
 	// the sheet to that is sending a sample into this step. Validate that the parents
 	// were correctly specified. This step is only needed when column 2 points to other
 	// worksheets.
-	if l.HasParent {
-		if err := validateParents(worksheets); err != nil {
+	if parentColumns {
+		if err := validateParents(worksheets, leftOut); err != nil {
 			savedErrs = multierror.Append(savedErrs, err)
 		}
 	}
@@ -76,15 +394,106 @@ func (l *Loader) Load() ([]*model.Worksheet, error) {
 	return worksheets, savedErrs.ErrorOrNil()
 }
 
+// readWorkbook reads the sheets in the workbook, from the parse cache if the workbook is cached.
+func (l *Loader) readWorkbook(wb workbook) ([]*sheet, error) {
+	fillMerged := !l.IgnoreMergedCells
+
+	var key string
+	if l.Cache != nil && wb.path != "" {
+		if k, err := l.Cache.key(wb.path, fillMerged); err == nil {
+			key = k
+			if wb.delimiter != 0 {
+				// The rows of a CSV or TSV file depend on the encoding it is read in
+				key += "-encoding-" + l.Encoding
+			}
+			if sheets, ok := l.Cache.get(key, wb.name); ok {
+				return sheets, nil
+			}
+		}
+	}
+
+	sheets, err := l.readSheets(wb, fillMerged)
+	if err != nil {
+		return nil, err
+	}
+
+	if key != "" {
+		if err := l.Cache.put(key, sheets); err != nil {
+			console.Warningf("unable to cache workbook %s: %s\n", wb.name, err)
+		}
+	}
+
+	return sheets, nil
+}
+
+// readSheets reads the sheets in the workbook, or the single sheet of a CSV or TSV file.
+func (l *Loader) readSheets(wb workbook, fillMerged bool) ([]*sheet, error) {
+	if wb.delimiter != 0 {
+		r, err := wb.openText()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+
+		return readDelimitedSheet(r, wb.name, wb.delimiter, l.Encoding)
+	}
+
+	xlsx, err := wb.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return readSheets(xlsx, wb.name, fillMerged)
+}
+
+// tooManyErrors returns true if MaxErrors errors have been found.
+func (l *Loader) tooManyErrors(errs *multierror.Error) bool {
+	return l.MaxErrors > 0 && errorCount(errs) >= l.MaxErrors
+}
+
+// errorCount returns the number of errors in errs, which can be nil.
+func errorCount(errs *multierror.Error) int {
+	if errs == nil {
+		return 0
+	}
+
+	return len(errs.Errors)
+}
+
 // ValidateFilesExistInProject will check that all the files in a given spreadsheet exist. It is broken out as
 // a separate method from Load as checking can be expensive and the Load method is used both during
 // checking and during the process where the spreadsheet is used to create data on the server. In
-// this way the user of the API can decide when this potentially expensive step should be run.
-func (l *Loader) ValidateFilesExistInProject(worksheets []*model.Worksheet, projectID string, c *mcapi.Client) error {
-	uniqueFilePaths := make(map[string]bool)
+// this way the user of the API can decide when this potentially expensive step should be run. If the
+// Loader has a FileIndex then the files are checked against it and no server calls are made.
+func (l *Loader) ValidateFilesExistInProject(worksheets []*model.Worksheet, projectID string, c *mcapix.Client) error {
+	var savedErrors *multierror.Error
+	l.StoppedAtMaxErrors = false
 
-	// Construct a list of all the unique file paths so we don't check a path multiple times. This could
-	// occur because the same file path is used in multiple samples.
+	for _, path := range uniqueFilePaths(worksheets) {
+		if l.tooManyErrors(savedErrors) {
+			l.StoppedAtMaxErrors = true
+			break
+		}
+
+		if l.FileIndex != nil {
+			if !l.FileIndex.Contains(path) {
+				savedErrors = multierror.Append(savedErrors, fmt.Errorf("warning: file '%s' not found in project", path))
+			}
+			continue
+		}
+
+		if _, err := c.GetFileByPathInProject(path, projectID); err != nil {
+			savedErrors = multierror.Append(savedErrors, fmt.Errorf("warning: file '%s' not found in project", path))
+		}
+	}
+
+	return savedErrors.ErrorOrNil()
+}
+
+// uniqueFilePaths constructs a sorted list of all the unique file paths so we don't check a path multiple
+// times. This could occur because the same file path is used in multiple samples.
+func uniqueFilePaths(worksheets []*model.Worksheet) []string {
+	uniqueFilePaths := make(map[string]bool)
 	for _, worksheet := range worksheets {
 		for _, sample := range worksheet.Samples {
 			for _, file := range sample.Files {
@@ -93,15 +502,13 @@ func (l *Loader) ValidateFilesExistInProject(worksheets []*model.Worksheet, proj
 		}
 	}
 
-	var savedErrors *multierror.Error
-
+	var paths []string
 	for path := range uniqueFilePaths {
-		if _, err := c.GetFileByPathInProject(path, projectID); err != nil {
-			savedErrors = multierror.Append(savedErrors, fmt.Errorf("warning: file '%s' not found in project", path))
-		}
+		paths = append(paths, path)
 	}
 
-	return savedErrors.ErrorOrNil()
+	sort.Strings(paths)
+	return paths
 }
 
 // loadWorksheet will load the given worksheet into the model.Worksheet data structure. The spreadsheet
@@ -119,65 +526,284 @@ func (l *Loader) ValidateFilesExistInProject(worksheets []*model.Worksheet, proj
 //
 // The rows after the header row contain the data. Column 1 is special and column 2 may be special (if HasParent is true
 // then column 2 is a special column). Column 1 is the sample name, and column 2, if it is special is the worksheet that
-// is the parent process for this step.
-func (l *Loader) loadWorksheet(xlsx *excelize.File, worksheetName string, index int) (*model.Worksheet, error) {
-	rows, err := xlsx.Rows(worksheetName)
-	if err != nil {
+// is the parent process for this step. Attribute columns that attrFilter doesn't keep are ignored. The settings
+// from an index sheet, if there are any, are used in place of the Loader's.
+func (l *Loader) loadWorksheet(s *sheet, attrFilter *attributeFilter, settings *sheetSettings) (*model.Worksheet, error) {
+	hasParent := l.HasParent
+	if settings != nil && settings.hasParent != nil {
+		hasParent = *settings.hasParent
+	}
+
+	// Neither the samples sheet, the master sheet nor a lookup sheet are steps in the workflow, so they never
+	// have a parent column
+	hasParent = hasParent && !l.isSamplesOrMasterSheet(s.name) && !l.isLookupSheet(s.name)
+	rowProcessor := newRowProcessor(s.name, s.file, hasParent, s.index)
+	rowProcessor.worksheet.HasParent = hasParent
+	if settings != nil {
+		rowProcessor.worksheet.Template = settings.template
+	}
+	rowProcessor.schema = l.Schema
+	rowProcessor.crosstab = l.isCrosstabSheet(s.name)
+	rowProcessor.fillParentDown = l.FillParentDown
+	rowProcessor.convertCellUnits = l.ConvertCellUnits
+	rowProcessor.attrFilter = attrFilter
+	rowProcessor.columnTypes = l.ColumnTypes[s.name]
+	rowProcessor.fileDirectionByPosition = l.FileDirectionByPosition
+	rowProcessor.maxCellSize = l.MaxCellSize
+	rowProcessor.longCells = l.LongCells
+	rowProcessor.lookups = l.lookups
+	rowProcessor.sheetNames = l.sheetNames
+	rowProcessor.warnDeclaredTypes = l.WarnDeclaredTypes
+
+	var err error
+	if rowProcessor.converter.dates, err = dates.NewParser(l.DateFormats); err != nil {
 		return nil, err
 	}
 
-	rowProcessor := newRowProcessor(worksheetName, l.HasParent, index)
-	row := 0
+	// The text is normalized before anything else so the headers are matched against the keywords as they appear
+	rows, normalized := normalizeRows(s.rows, l.textNormalization(), hasParent)
 
 	// skip specified rows to header
-	for i := 0; i < l.HeaderRow; i++ {
-		rows.Next()
+	headerRow := l.headerRow(rowProcessor.worksheet, rows, settings)
+	if headerRow >= len(rows) {
+		// There is no header, so there is nothing to load
+		return rowProcessor.worksheet, nil
+	}
+
+	reportNormalizedCells(rowProcessor.worksheet, normalized, headerRow)
+	rowProcessor.worksheet.HeaderRow = headerRow + 1
+	rowProcessor.worksheet.Preamble = rows[:headerRow]
+
+	// The units of the attributes may be in the row under the header, see units_row.go
+	header := rows[headerRow]
+	firstDataRow := headerRow + 1
+	if l.UnitsRow && firstDataRow < len(rows) {
+		var err error
+		if header, err = mergeUnitsRow(rowProcessor.worksheet, header, rows[firstDataRow], firstDataRow+1, hasParent); err != nil {
+			return nil, err
+		}
+		firstDataRow++
 	}
 
 	// First row is the header row that contains all the attributes. We process this first
 	// outside of the loop that processes each of the sample rows.
-	if rows.Next() {
-		row++
-		rowProcessor.processHeaderRow(rows)
+	if err := rowProcessor.processHeaderRow(header); err != nil {
+		return nil, err
+	}
+
+	// The const: rows under the header hold process attributes for every sample, see constants_row.go
+	for ; firstDataRow < len(rows) && isConstantsRow(rows[firstDataRow]); firstDataRow++ {
+		if err := rowProcessor.processConstantsRow(rows[firstDataRow], firstDataRow+1); err != nil {
+			return nil, err
+		}
 	}
 
 	// Loop through the rest of the rows processing the samples, and their process, sample and file attributes.
-	for rows.Next() {
-		row++
-		if err := rowProcessor.processSampleRow(rows, row); err != nil {
+	// Rows are numbered as they are in the spreadsheet, starting at 1. The rows are only read until
+	// MaxErrors errors have been found.
+	for i := firstDataRow; i < len(rows); i++ {
+		if l.MaxErrors > 0 && l.errorsFound+errorCount(rowProcessor.typeErrors) >= l.MaxErrors {
+			l.StoppedAtMaxErrors = true
+			break
+		}
+
+		if err := rowProcessor.processSampleRow(rows[i], i+1); err != nil {
 			return nil, err
 		}
 	}
 
+	// Every cell that isn't of the type its header declares is reported, see declared_types.go
+	if err := rowProcessor.typeErrors.ErrorOrNil(); err != nil {
+		return nil, err
+	}
+
+	if err := checkMinorityCells(rowProcessor.worksheet, rowProcessor.declaredTypes, l.MinorityCells); err != nil {
+		return nil, err
+	}
+
+	if err := validateSteps(rowProcessor.worksheet); err != nil {
+		return nil, err
+	}
+
 	return rowProcessor.worksheet, nil
 }
 
+// readSheets reads all the worksheets in the excel file. The worksheets are returned in the order they
+// appear in the workbook. If fillMerged is true then the values of merged cells are copied into all the
+// rows they cover.
+func readSheets(xlsx *excelize.File, file string, fillMerged bool) ([]*sheet, error) {
+	sheetMap := xlsx.GetSheetMap()
+	var indexes []int
+	for index := range sheetMap {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var sheets []*sheet
+	for _, index := range indexes {
+		name := sheetMap[index]
+		rows, err := readSheetRows(xlsx, name, index)
+		if err != nil {
+			return nil, err
+		}
+
+		if fillMerged {
+			rows = fillMergedCells(xlsx, name, rows)
+		}
+
+		sheets = append(sheets, &sheet{
+			name:  name,
+			file:  file,
+			index: index,
+			rows:  rows,
+		})
+	}
+
+	return sheets, nil
+}
+
+// readSheetRows reads the rows in a worksheet. Row i in the result is row i+1 in the worksheet. Trailing
+// blank cells and rows are removed. This matters because some tools save worksheets that have every
+// possible row and column (1048576 rows by 1024 columns).
+//
+// The rows iterator is used rather than excelize GetRows because GetRows allocates every cell in the
+// worksheet up front. The iterator skips rows that aren't in the file, so the row numbers are taken
+// from the worksheet excelize has read to put each row in the right place.
+func readSheetRows(xlsx *excelize.File, name string, index int) ([][]string, error) {
+	iter, err := xlsx.Rows(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var rowNumbers []int
+	if ws, ok := xlsx.Sheet[fmt.Sprintf("xl/worksheets/sheet%d.xml", index)]; ok {
+		for _, row := range ws.SheetData.Row {
+			rowNumbers = append(rowNumbers, row.R)
+		}
+	}
+
+	var rows [][]string
+	for i := 0; iter.Next(); i++ {
+		if i < len(rowNumbers) {
+			for len(rows) < rowNumbers[i]-1 {
+				rows = append(rows, nil)
+			}
+		}
+
+		rows = append(rows, trimBlankCells(iter.Columns()))
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	convertDateCells(xlsx, index, rows)
+
+	for len(rows) != 0 && len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+
+	return rows, nil
+}
+
+// trimBlankCells removes the trailing blank cells from a row. It returns nil if the whole row is blank.
+func trimBlankCells(row []string) []string {
+	end := len(row)
+	for end > 0 && strings.TrimSpace(row[end-1]) == "" {
+		end--
+	}
+
+	if end == 0 {
+		return nil
+	}
+
+	return row[:end:end]
+}
+
+// isSkippedSheet returns true if the worksheet is one of the worksheets not to load.
+func (l *Loader) isSkippedSheet(worksheetName string) bool {
+	// The summary of an earlier load, see summary_sheet.go
+	if worksheetName == SummarySheetName {
+		return true
+	}
+
+	for _, name := range l.SkipSheets {
+		if name == worksheetName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// textNormalization returns the text normalization to use, TextNormalizationWhitespace if none was set.
+func (l *Loader) textNormalization() string {
+	if l.TextNormalization == "" {
+		return TextNormalizationWhitespace
+	}
+
+	return l.TextNormalization
+}
+
+// isSamplesOrMasterSheet returns true if the worksheet is the samples sheet or the master sheet.
+func (l *Loader) isSamplesOrMasterSheet(worksheetName string) bool {
+	return (l.SamplesSheet != "" && worksheetName == l.SamplesSheet) || (l.MasterSheet != "" && worksheetName == l.MasterSheet)
+}
+
+// isCrosstabSheet returns true if the worksheet is one of the cross-tab worksheets.
+func (l *Loader) isCrosstabSheet(worksheetName string) bool {
+	for _, name := range l.CrosstabSheets {
+		if name == worksheetName {
+			return true
+		}
+	}
+
+	return false
+}
+
 // validateParents goes through all the samples in the worksheets and checks
 // each of their Parent attributes. If Parent is not blank then it must contain
 // a reference to a known process. Additionally that process cannot be the
 // current process. This determination is done by name. Remember processes have
 // the name of their worksheet, so we check that a non blank Parent is equal to
-// a known process that isn't the process the sample is in. validateParent returns
-// a multierror containing all the errors encountered.
-func validateParents(worksheets []*model.Worksheet) error {
+// a known process that isn't the process the sample is in. A Parent can also be
+// a process already on the server, eg mc:process/<id>. leftOut are the worksheets
+// that weren't loaded, and why, a parent naming one of them is reported as such. validateParent returns a multierror containing all the errors
+// encountered.
+func validateParents(worksheets []*model.Worksheet, leftOut map[string]string) error {
 	knownProcesses := createKnownProcessesMap(worksheets)
 	var foundErrors *multierror.Error
 	for _, worksheet := range worksheets {
 		for _, sample := range worksheet.Samples {
 			if sample.Parent != "" {
+				id, existing := processor.ExistingProcessID(sample.Parent)
 				switch {
+				case existing && id == "":
+					e := fmt.Errorf("sample '%s' in process '%s' has parent '%s' that doesn't give a process id",
+						sample.Name, worksheet.Source(), sample.Parent)
+					foundErrors = multierror.Append(foundErrors, e)
+				case existing:
+					// The parent is a process already on the server, it is checked when the workflow is created
 				case sample.Parent == worksheet.Name:
-					e := fmt.Errorf("process '%s' has Sample '%s' who's parent is the current process", worksheet.Name, sample.Name)
+					e := fmt.Errorf("process '%s' has Sample '%s' who's parent is the current process", worksheet.Source(), sample.Name)
+					foundErrors = multierror.Append(foundErrors, e)
+				case knownProcesses[sample.Parent] == nil && leftOut[sample.Parent] != "":
+					e := fmt.Errorf("sample '%s' in process '%s' has parent '%s' that %s",
+						sample.Name, worksheet.Source(), sample.Parent, leftOut[sample.Parent])
 					foundErrors = multierror.Append(foundErrors, e)
 				default:
 					if _, ok := knownProcesses[sample.Parent]; !ok {
 						// Parent is set to a non-existent process
 						e := fmt.Errorf("sample '%s' in process '%s' has parent '%s' that does not exist",
-							sample.Name, worksheet.Name, sample.Parent)
+							sample.Name, worksheet.Source(), sample.Parent)
 						foundErrors = multierror.Append(foundErrors, e)
 					}
 				}
 			}
+
+			for _, e := range validateParentChain(sample, worksheet, knownProcesses) {
+				foundErrors = multierror.Append(foundErrors, e)
+			}
 		}
 	}
 
@@ -0,0 +1,142 @@
+package spreadsheet
+
+/*
+ * lookup_sheet lets a single cell stand for a set of process attributes kept on a reference worksheet, such
+ * as recipes or standards, rather than repeating them in columns on every step. The reference worksheets are
+ * named with --lookup-sheets and have a row for each entry, keyed by the first column:
+ *
+ *   Recipes:
+ *   |recipe |p:Temperature(C) |p:Time(h) |p:Atmosphere |
+ *   |R1     |400              |2         |Argon        |
+ *   |R2     |450              |4         |Air          |
+ *
+ * A column with the lookup: keyword followed by the name of a reference worksheet takes the key of an entry:
+ *
+ *   |sample |lookup:Recipes |p:Operator |
+ *   |S1     |R1             |Jane       |
+ *
+ * and the process for S1 gets the Temperature, Time and Atmosphere attributes of R1. A cell with a formula that
+ * references the key cell, eg =Recipes!A2, is read as the key it shows. Every attribute of an entry becomes a process attribute, with or without the p: keyword. The
+ * lookup worksheets are only used for their entries, they aren't loaded as processes.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// lookupTable holds the entries of a lookup worksheet by key.
+type lookupTable struct {
+	worksheet *model.Worksheet
+	byKey     map[string]*model.Sample
+}
+
+// newLookupTable creates a lookupTable from a loaded lookup worksheet. Each key must be on a single row.
+func newLookupTable(worksheet *model.Worksheet) (*lookupTable, error) {
+	t := &lookupTable{
+		worksheet: worksheet,
+		byKey:     make(map[string]*model.Sample),
+	}
+
+	for _, entry := range worksheet.Samples {
+		if existing, ok := t.byKey[entry.Name]; ok {
+			return nil, fmt.Errorf("lookup worksheet %s has key '%s' on rows %d and %d", worksheet.Source(), entry.Name, existing.Row, entry.Row)
+		}
+		t.byKey[entry.Name] = entry
+	}
+
+	return t, nil
+}
+
+// attrs returns the header attributes of the entries, the process attributes first.
+func (t *lookupTable) attrs() []*model.Attribute {
+	return append(append([]*model.Attribute{}, t.worksheet.ProcessAttrs...), t.worksheet.SampleAttrs...)
+}
+
+// find returns the entry with the key in the cell, or nil if there isn't one.
+func (t *lookupTable) find(cell string) *model.Sample {
+	return t.byKey[cell]
+}
+
+// lookupSheetName returns the name of the lookup worksheet in a lookup: header.
+func lookupSheetName(header string) string {
+	return strings.TrimSpace(header[strings.Index(header, ":")+1:])
+}
+
+// processLookupHeader sets up a lookup: column. The attributes of the lookup worksheet are added to the
+// worksheet's process attributes.
+func (r *rowProcessor) processLookupHeader(header string, column int) error {
+	name := lookupSheetName(header)
+	table, ok := r.lookups[name]
+	if !ok {
+		return fmt.Errorf("worksheet %s column %s: '%s' isn't one of the --lookup-sheets", r.worksheet.Source(), model.ColumnName(column), name)
+	}
+
+	for _, attr := range table.attrs() {
+		if findAttrByName(r.worksheet.ProcessAttrs, attr.Name) == nil {
+			r.worksheet.AddProcessAttr(model.NewAttribute(attr.Name, attr.Unit, column))
+		}
+	}
+
+	r.lookupColumns[column] = table
+	return nil
+}
+
+// addLookupAttributes adds the attributes of the lookup entry the cell refers to, to the sample's process
+// attributes. The attributes keep the lookup worksheet cell their value was read from as their source.
+func (r *rowProcessor) addLookupAttributes(sample *model.Sample, cell string, rowIndex, column int) error {
+	table := r.lookupColumns[column]
+	entry := table.find(cell)
+	if entry == nil {
+		return cellErrorf(r.worksheet, rowIndex, column, "worksheet %s row %d column %s: '%s' isn't an entry in lookup worksheet %s",
+			r.worksheet.Source(), rowIndex, model.ColumnName(column), cell, table.worksheet.Name)
+	}
+
+	for _, attr := range append(append([]*model.Attribute{}, entry.ProcessAttrs...), entry.Attributes...) {
+		processAttr := model.NewAttribute(attr.Name, attr.Unit, column)
+		processAttr.Value = attr.Value
+		processAttr.Source = attr.Source
+		sample.AddProcessAttribute(processAttr)
+	}
+
+	return nil
+}
+
+// loadLookupSheets loads the lookup worksheets, which are left out of the sheets that are returned.
+func (l *Loader) loadLookupSheets(sheets []*sheet, attrFilter *attributeFilter) ([]*sheet, error) {
+	var rest []*sheet
+	for _, s := range sheets {
+		if !l.isLookupSheet(s.name) {
+			rest = append(rest, s)
+			continue
+		}
+
+		worksheet, err := l.loadWorksheet(s, attrFilter, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := l.lookups[s.name]; ok {
+			return nil, fmt.Errorf("there is more than one lookup worksheet named %s", s.name)
+		}
+
+		if l.lookups[s.name], err = newLookupTable(worksheet); err != nil {
+			return nil, err
+		}
+	}
+
+	return rest, nil
+}
+
+// isLookupSheet returns true if the worksheet is one of the lookup worksheets.
+func (l *Loader) isLookupSheet(worksheetName string) bool {
+	for _, name := range l.LookupSheets {
+		if name == worksheetName {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,108 @@
+package spreadsheet
+
+/*
+ * master_sheet handles a master sample sheet. Attributes such as composition or geometry describe a
+ * sample for its whole life, but to reach the property sets of the processes they have to be repeated
+ * on every worksheet the sample appears in. A master sheet lists each sample once with these attributes:
+ *
+ *   |sample|s:composition|s:diameter(mm)|
+ *   |S1    |Ti-6Al-4V    |1.6           |
+ *   |S2    |Ti-6Al-4V    |2.0           |
+ *
+ * After loading, the sample attributes on the master sheet are merged into the samples with the same
+ * name on every other worksheet. A value given on a worksheet takes precedence over the master sheet
+ * value for the same attribute. The master sheet itself doesn't describe a process, so it is removed
+ * from the loaded worksheets.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// mergeMasterSheet merges the sample attributes from the master sheet into the samples on the other
+// worksheets and returns the worksheets without the master sheet. If there is more than one worksheet
+// with the master sheet name (eg in different files) then they are all used, with the first value seen
+// for an attribute winning.
+func mergeMasterSheet(masterSheet string, worksheets []*model.Worksheet) ([]*model.Worksheet, error) {
+	var (
+		remaining []*model.Worksheet
+		found     bool
+	)
+
+	masterAttrs := make(map[string][]*model.Attribute)
+	for _, worksheet := range worksheets {
+		if worksheet.Name != masterSheet {
+			remaining = append(remaining, worksheet)
+			continue
+		}
+
+		found = true
+		for _, sample := range worksheet.Samples {
+			masterAttrs[sample.Name] = mergeAttributes(masterAttrs[sample.Name], sample.Attributes)
+		}
+	}
+
+	if !found {
+		return worksheets, fmt.Errorf("master sheet '%s' does not exist", masterSheet)
+	}
+
+	for _, worksheet := range remaining {
+		for _, sample := range worksheet.Samples {
+			attrs, ok := masterAttrs[sample.Name]
+			if !ok {
+				continue
+			}
+
+			sample.Attributes = mergeAttributes(sample.Attributes, mergedAttributes(attrs))
+			for _, attr := range attrs {
+				if !hasAttribute(worksheet.SampleAttrs, attr.Name) {
+					worksheet.AddSampleAttr(model.NewAttribute(attr.Name, attr.Unit, 0))
+				}
+			}
+		}
+	}
+
+	return remaining, nil
+}
+
+// mergeAttributes returns attrs with the attributes in from that aren't already in attrs added. Attributes
+// are matched by name, case insensitively. A new slice is returned because samples expanded from a
+// cross-tab row share their attributes.
+func mergeAttributes(attrs, from []*model.Attribute) []*model.Attribute {
+	merged := append([]*model.Attribute{}, attrs...)
+	for _, attr := range from {
+		if !hasAttribute(merged, attr.Name) {
+			merged = append(merged, attr)
+		}
+	}
+
+	return merged
+}
+
+// mergedAttributes returns copies of the attributes for merging into the samples of another worksheet. The
+// copies have no column, as their column is on the worksheet they came from and would otherwise be taken
+// for a column of the worksheet they are merged into. Their source is still the cell they were read from.
+func mergedAttributes(attrs []*model.Attribute) []*model.Attribute {
+	var merged []*model.Attribute
+	for _, attr := range attrs {
+		copied := *attr
+		copied.Column = 0
+		merged = append(merged, &copied)
+	}
+
+	return merged
+}
+
+// hasAttribute returns true if there is an attribute with the given name, matched case insensitively.
+func hasAttribute(attrs []*model.Attribute, name string) bool {
+	for _, attr := range attrs {
+		if strings.EqualFold(attr.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
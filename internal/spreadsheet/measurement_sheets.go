@@ -0,0 +1,165 @@
+package spreadsheet
+
+/*
+ * measurement_sheets handles worksheets that only measure the samples, such as hardness testing, rather than
+ * describe a process that changes them. In Materials Commons a measurement is recorded on the property set of
+ * the sample that the process before it produced, so loading such a worksheet as a process of its own adds a
+ * transformation to the workflow that never happened. With --measurement-sheets, or yes in the measurement
+ * column of an index sheet, the worksheet's sample attributes are added to the samples on its parent
+ * worksheet instead:
+ *
+ *   Heat Treatment                      Hardness (a measurement sheet)
+ *   |sample|parent|p:Temperature(C)|    |sample|parent        |s:Hardness(HV)|
+ *   |S1    |      |400             |    |S1    |Heat Treatment|320           |
+ *
+ * loads S1's Hardness as measured on the sample the Heat Treatment produced, and a worksheet whose parent is
+ * Hardness takes its samples from the Heat Treatment. A measurement sheet can only have sample attributes, and
+ * each of its samples must have a parent worksheet that lists the sample.
+ */
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+)
+
+// isMeasurementSheet returns true if the worksheet was given in MeasurementSheets.
+func (l *Loader) isMeasurementSheet(worksheetName string) bool {
+	for _, name := range l.MeasurementSheets {
+		if name == worksheetName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mergeMeasurementSheets adds the sample attributes of the measurement sheets to the samples on their parent
+// worksheets, and returns the worksheets without the measurement sheets. The worksheets whose parent is a
+// measurement sheet are given the measurement sheet's parent.
+func mergeMeasurementSheets(measurementSheets map[string]bool, worksheets []*model.Worksheet) ([]*model.Worksheet, error) {
+	byName := make(map[string]*model.Worksheet)
+	for _, worksheet := range worksheets {
+		if !measurementSheets[worksheet.Name] {
+			byName[worksheet.Name] = worksheet
+		}
+	}
+
+	// The parent of each sample on each measurement sheet, so that the worksheets after a measurement sheet
+	// can take their samples from the parent
+	measuredFrom := make(map[string]map[string]string)
+
+	for _, worksheet := range worksheets {
+		if !measurementSheets[worksheet.Name] {
+			continue
+		}
+
+		if len(worksheet.ProcessAttrs) != 0 || len(worksheet.FileHeaders) != 0 {
+			return nil, fmt.Errorf("measurement sheet %s has process attribute or file columns, it can only have sample attributes",
+				worksheet.Source())
+		}
+
+		measuredFrom[worksheet.Name] = make(map[string]string)
+		for _, sample := range worksheet.Samples {
+			parent, err := measuredSample(worksheet, sample, byName)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, attr := range sample.Attributes {
+				if err := addMeasurement(parent, byName[sample.Parent], attr); err != nil {
+					return nil, fmt.Errorf("sample '%s' on measurement sheet %s: %s", sample.Name, worksheet.Source(), err)
+				}
+			}
+
+			measuredFrom[worksheet.Name][sample.Name] = sample.Parent
+		}
+	}
+
+	var remaining []*model.Worksheet
+	for _, worksheet := range worksheets {
+		if measurementSheets[worksheet.Name] {
+			continue
+		}
+
+		for _, sample := range worksheet.Samples {
+			from, ok := measuredFrom[sample.Parent]
+			if !ok {
+				continue
+			}
+
+			parent, ok := from[sample.Name]
+			if !ok {
+				return nil, fmt.Errorf("sample '%s' in process '%s' has parent '%s', a measurement sheet that doesn't list it",
+					sample.Name, worksheet.Source(), sample.Parent)
+			}
+			sample.Parent = parent
+		}
+
+		remaining = append(remaining, worksheet)
+	}
+
+	return remaining, nil
+}
+
+// measuredSample returns the sample on the parent worksheet that the sample on the measurement sheet measures.
+func measuredSample(worksheet *model.Worksheet, sample *model.Sample, byName map[string]*model.Worksheet) (*model.Sample, error) {
+	if _, existing := processor.ExistingProcessID(sample.Parent); existing {
+		return nil, fmt.Errorf("sample '%s' on measurement sheet %s has parent '%s', a measurement can't be added to a process already on the server",
+			sample.Name, worksheet.Source(), sample.Parent)
+	}
+
+	parent := byName[sample.Parent]
+	if sample.Parent == "" || parent == nil {
+		return nil, fmt.Errorf("sample '%s' on measurement sheet %s needs the worksheet it was measured after as its parent, it has '%s'",
+			sample.Name, worksheet.Source(), sample.Parent)
+	}
+
+	measured := lastStepOf(parent, sample.Name)
+	if measured == nil {
+		return nil, fmt.Errorf("sample '%s' on measurement sheet %s has parent '%s', which doesn't list it",
+			sample.Name, worksheet.Source(), sample.Parent)
+	}
+
+	return measured, nil
+}
+
+// lastStepOf returns the sample as it leaves the worksheet, after its last step there, or nil if the
+// worksheet doesn't list it.
+func lastStepOf(worksheet *model.Worksheet, sampleName string) *model.Sample {
+	var last *model.Sample
+	for _, s := range worksheet.Samples {
+		if s.Name == sampleName && (last == nil || s.Step > last.Step) {
+			last = s
+		}
+	}
+
+	return last
+}
+
+// addMeasurement adds the attribute to the sample on the worksheet. An attribute the sample already has with
+// the same value, such as one from the master sheet, is left as it is.
+func addMeasurement(sample *model.Sample, worksheet *model.Worksheet, attr *model.Attribute) error {
+	for _, existing := range sample.Attributes {
+		if !strings.EqualFold(existing.Name, attr.Name) {
+			continue
+		}
+
+		if existing.Unit == attr.Unit && reflect.DeepEqual(existing.Value, attr.Value) {
+			return nil
+		}
+
+		return fmt.Errorf("%s is already %v on %s", attr.Name, existing.Value["value"], worksheet.Source())
+	}
+
+	// A new slice, as samples expanded from a cross-tab row share their attributes
+	sample.Attributes = append(append([]*model.Attribute{}, sample.Attributes...), mergedAttributes([]*model.Attribute{attr})...)
+	if !hasAttribute(worksheet.SampleAttrs, attr.Name) {
+		worksheet.AddSampleAttr(model.NewAttribute(attr.Name, attr.Unit, 0))
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package spreadsheet
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+)
+
+// fillMergedCells copies the value of each merged region that spans several rows into every row the
+// region covers. Excel only stores the value in the top left cell of a merged region, so without this
+// a sample name merged across several rows would leave the rows below the first with a blank sample
+// name, and they would be skipped. Only the first column of a region is filled, so a header merged
+// across several columns doesn't become several headers.
+func fillMergedCells(xlsx *excelize.File, name string, rows [][]string) [][]string {
+	for _, mergeCell := range xlsx.GetMergeCells(name) {
+		startColumn, startRow, ok := splitAxis(mergeCell.GetStartAxis())
+		if !ok {
+			continue
+		}
+
+		_, endRow, ok := splitAxis(mergeCell.GetEndAxis())
+		if !ok || endRow <= startRow {
+			continue
+		}
+
+		value := mergeCell.GetCellValue()
+		if strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		for row := startRow + 1; row <= endRow; row++ {
+			for len(rows) <= row {
+				rows = append(rows, nil)
+			}
+
+			if len(rows[row]) <= startColumn {
+				cells := make([]string, startColumn+1)
+				copy(cells, rows[row])
+				rows[row] = cells
+			}
+
+			rows[row][startColumn] = value
+		}
+	}
+
+	return rows
+}
+
+// splitAxis splits a cell reference such as C12 into its 0 based column and row.
+func splitAxis(axis string) (column, row int, ok bool) {
+	i := strings.IndexFunc(axis, unicode.IsDigit)
+	if i < 1 {
+		return 0, 0, false
+	}
+
+	row, err := strconv.Atoi(axis[i:])
+	if err != nil || row < 1 {
+		return 0, 0, false
+	}
+
+	return excelize.TitleToNumber(axis[:i]), row - 1, true
+}
@@ -0,0 +1,247 @@
+package spreadsheet
+
+/*
+ * minority_cells finds the cells whose type differs from nearly all of the others in their column. When
+ * MajorityShare of the values in an attribute column are numbers, or are true/false, the few that aren't,
+ * such as 12,5 or 1O0 (a letter O) in a column of numbers, are probably typos, and would otherwise be
+ * stored as text without anyone noticing. --minority-cells decides what happens to them:
+ *
+ *   warn    a warning gives the row and column of each, and they are loaded as they are (the default)
+ *   error   each is an error, as for a cell that isn't of the type its header declares
+ *   coerce  each is converted to the type of the column when it can be, eg 12,5 to 12.5, 1O0 to 100 or
+ *           12 C in a Temperature(C) column to 12, and warned about and loaded as it is when it can't
+ *   skip    each is left out, as if the cell was blank
+ *
+ * Columns that declare their type (see declared_types.go) are already checked, so they are left alone.
+ */
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// The policies for the cells whose type isn't the type of nearly all the others in their column.
+const (
+	// MinorityCellsWarned warns about the cells and loads them as they are
+	MinorityCellsWarned = "warn"
+
+	// MinorityCellsAsErrors treats each of the cells as an error
+	MinorityCellsAsErrors = "error"
+
+	// MinorityCellsCoerced converts the cells to the type of the column when they can be
+	MinorityCellsCoerced = "coerce"
+
+	// MinorityCellsSkipped leaves the cells out
+	MinorityCellsSkipped = "skip"
+)
+
+// MajorityShare is the share of the values in a column that must be of one type for the values that
+// aren't to be treated as typos.
+const MajorityShare = 0.95
+
+// ValidMinorityCellsPolicy returns an error if policy isn't one of the minority cell policies.
+func ValidMinorityCellsPolicy(policy string) error {
+	switch policy {
+	case MinorityCellsWarned, MinorityCellsAsErrors, MinorityCellsCoerced, MinorityCellsSkipped:
+		return nil
+	default:
+		return fmt.Errorf("unknown minority cells policy '%s', use '%s', '%s', '%s' or '%s'", policy,
+			MinorityCellsWarned, MinorityCellsAsErrors, MinorityCellsCoerced, MinorityCellsSkipped)
+	}
+}
+
+// The types of value that a column can be made up of nearly entirely
+const (
+	numberValues = "number"
+	boolValues   = "true/false"
+)
+
+// columnCell is an attribute in a column and the sample whose row it is on.
+type columnCell struct {
+	sample *model.Sample
+	attr   *model.Attribute
+}
+
+// checkMinorityCells applies the policy to the cells in the attribute columns of the worksheet whose type
+// isn't the type of nearly all the others. declaredTypes are the columns that declare their type, which
+// are left alone. It returns the cells that are errors with MinorityCellsAsErrors.
+func checkMinorityCells(worksheet *model.Worksheet, declaredTypes map[int]string, policy string) error {
+	var (
+		columns []int
+		cells   = make(map[int][]columnCell)
+	)
+
+	for _, sample := range worksheet.Samples {
+		for _, attrs := range [][]*model.Attribute{sample.ProcessAttrs, sample.Attributes} {
+			for _, attr := range attrs {
+				if attr.Column == 0 || attr.Value == nil || declaredTypes[attr.Column] != "" {
+					continue
+				}
+
+				if _, ok := cells[attr.Column]; !ok {
+					columns = append(columns, attr.Column)
+				}
+				cells[attr.Column] = append(cells[attr.Column], columnCell{sample: sample, attr: attr})
+			}
+		}
+	}
+
+	var errs *multierror.Error
+	for _, column := range columns {
+		majority, count := majorityType(cells[column])
+		if majority == "" {
+			continue
+		}
+
+		for _, cell := range cells[column] {
+			if valueType(cell.attr.Value["value"]) == majority {
+				continue
+			}
+
+			like := fmt.Sprintf("%s like %d of the %d values in its column", indefinite(majority), count, len(cells[column]))
+			if err := applyMinorityCellsPolicy(worksheet, cell, majority, like, policy); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// majorityType returns the type of at least MajorityShare of the values of the cells, and how many
+// there are of it, when there are values of other types too. It returns blank otherwise.
+func majorityType(cells []columnCell) (string, int) {
+	counts := make(map[string]int)
+	for _, cell := range cells {
+		counts[valueType(cell.attr.Value["value"])]++
+	}
+
+	for _, t := range []string{numberValues, boolValues} {
+		if count := counts[t]; count != len(cells) && float64(count) >= MajorityShare*float64(len(cells)) {
+			return t, count
+		}
+	}
+
+	return "", 0
+}
+
+// valueType returns numberValues or boolValues for a value of that type, and blank for any other value.
+func valueType(value interface{}) string {
+	if _, ok := numericValue(value); ok {
+		return numberValues
+	}
+
+	if _, ok := value.(bool); ok {
+		return boolValues
+	}
+
+	return ""
+}
+
+// applyMinorityCellsPolicy applies the policy to the cell, whose value isn't of the majority type. like
+// describes the values it isn't like.
+func applyMinorityCellsPolicy(worksheet *model.Worksheet, cell columnCell, majority, like, policy string) error {
+	row := cell.sample.Row
+	if cell.attr.Source != nil {
+		row = cell.attr.Source.Row
+	}
+
+	value := cell.attr.Value["value"]
+	problem := fmt.Sprintf("%s row %d column %s value '%v' isn't %s, probably a typo",
+		worksheet.Source(), row, model.ColumnName(cell.attr.Column), value, like)
+
+	switch policy {
+	case MinorityCellsAsErrors:
+		return cellErrorf(worksheet, row, cell.attr.Column, "worksheet %s", problem)
+
+	case MinorityCellsSkipped:
+		cell.sample.ProcessAttrs = withoutAttribute(cell.sample.ProcessAttrs, cell.attr)
+		cell.sample.Attributes = withoutAttribute(cell.sample.Attributes, cell.attr)
+		cellWarningf(worksheet, row, cell.attr.Column, "Worksheet %s, it has been left out\n", problem)
+
+	case MinorityCellsCoerced:
+		if coerced, ok := coerceCell(fmt.Sprint(value), cell.attr.Unit, majority); ok {
+			cell.attr.Value = map[string]interface{}{"value": coerced}
+			cellWarningf(worksheet, row, cell.attr.Column, "Worksheet %s, it has been converted to %v\n", problem, coerced)
+		} else {
+			cellWarningf(worksheet, row, cell.attr.Column, "Worksheet %s, it couldn't be converted and is loaded as it is\n", problem)
+		}
+
+	default:
+		console.Warningf("Worksheet %s (use --minority-cells to convert or skip such cells)\n", problem)
+	}
+
+	return nil
+}
+
+// indefinite returns the type of value with an indefinite article, eg a number.
+func indefinite(majority string) string {
+	if majority == numberValues {
+		return "a number"
+	}
+
+	return majority
+}
+
+// withoutAttribute returns attrs without attr. A new slice is returned because samples expanded from a
+// cross-tab row share their attributes.
+func withoutAttribute(attrs []*model.Attribute, attr *model.Attribute) []*model.Attribute {
+	var remaining []*model.Attribute
+	for _, a := range attrs {
+		if a != attr {
+			remaining = append(remaining, a)
+		}
+	}
+
+	return remaining
+}
+
+// coerceCell converts the cell to a value of the majority type, correcting the usual typos. It returns
+// false if the cell can't be converted.
+func coerceCell(cell, unit, majority string) (interface{}, bool) {
+	cell = strings.TrimSpace(cell)
+	if majority == boolValues {
+		switch strings.ToLower(cell) {
+		case "yes", "y", "t", "1", "pass":
+			return true, true
+		case "no", "n", "f", "0", "fail":
+			return false, true
+		default:
+			return nil, false
+		}
+	}
+
+	// A value typed with the column's unit, eg 12 C
+	if unit != "" && len(cell) > len(unit) && strings.EqualFold(cell[len(cell)-len(unit):], unit) {
+		cell = strings.TrimSpace(cell[:len(cell)-len(unit)])
+	}
+
+	// A decimal comma, eg 12,5. With three digits after it, eg 12,500, it could be a thousands separator
+	if i := strings.Index(cell, ","); i != -1 && strings.Count(cell, ",") == 1 && !strings.Contains(cell, ".") {
+		if len(cell)-i-1 == 3 {
+			return nil, false
+		}
+		cell = strings.Replace(cell, ",", ".", 1)
+	}
+
+	// The letter O for a zero, eg 1O0
+	cell = strings.NewReplacer("O", "0", "o", "0").Replace(cell)
+
+	if i, err := strconv.ParseInt(cell, 10, 64); err == nil {
+		return i, true
+	}
+
+	// ParseFloat also takes hex, NaN and Inf, which aren't typos of a number
+	f, err := strconv.ParseFloat(cell, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) || strings.HasPrefix(strings.ToLower(strings.TrimPrefix(cell, "-")), "0x") {
+		return nil, false
+	}
+
+	return f, true
+}
@@ -1,5 +1,10 @@
 package model
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Worksheet represents a single worksheet in excel. Each worksheet
 // specifies a process template and the samples. Since the worksheet
 // is a model for the process that means that multiple processes
@@ -64,11 +69,51 @@ package model
 // However S3 has different values in ProcessAttrs so it will create a new process and the sample will be associated with it.
 type Worksheet struct {
 	Name         string
+	File         string // The file the worksheet was loaded from
 	Index        int
 	ProcessAttrs []*Attribute
 	Samples      []*Sample
 	SampleAttrs  []*Attribute
 	FileHeaders  []*FileHeader
+	DateAttr     string     // The process attribute giving when the process was performed, if any
+	HasParent    bool       // The second column is the parent column
+	Template     string     // The process template the processes are created from, if not the worksheet name
+	Headers      []string   // The cells in the header row
+	HeaderRow    int        // The row number of the header row, starting at 1
+	Preamble     [][]string // The rows before the header row, which aren't loaded
+	Columns      []*ColumnSummary
+}
+
+// ColumnSummary describes how a column in the header row was interpreted and why, so that a column
+// loaded as the wrong type can be spotted before the worksheet is loaded.
+type ColumnSummary struct {
+	Column int
+	Header string
+	Type   string // eg "process attribute" or "ignored"
+	Reason string // eg "keyword 'p:'"
+
+	// Suspect is true when the type wasn't given by a keyword, or the keyword wasn't recognized
+	Suspect bool
+}
+
+// Source describes where the worksheet came from, its name and the file it is in. This is used
+// in messages so that worksheets with the same name in different files can be told apart.
+func (w *Worksheet) Source() string {
+	if w.File == "" {
+		return w.Name
+	}
+
+	return fmt.Sprintf("%s (%s)", w.Name, w.File)
+}
+
+// ProcessType returns the type of the processes created from the worksheet, its Template if it has
+// one and otherwise its name.
+func (w *Worksheet) ProcessType() string {
+	if w.Template != "" {
+		return w.Template
+	}
+
+	return w.Name
 }
 
 func (w *Worksheet) AddSample(sample *Sample) {
@@ -96,13 +141,25 @@ type Sample struct {
 	Attributes   []*Attribute
 	ProcessAttrs []*Attribute
 	Files        []File
+	Cohort       string   // From a cohort: column, the experiment the sample is loaded into with --experiment-per-cohort
+	Step         int      // From a step: column, the order of the sample's processes on the worksheet, 0 when not given
+	ParentChain  []string // From a parent chain, eg Casting > Rolling, the worksheets the sample went through before Parent
+	MCID         string   // From an mcid: column, the ID of the sample already on the server that the sample is
 }
 
 type File struct {
-	Path   string
-	Column int
+	Path      string
+	Column    int
+	Direction string // FileDirectionIn or FileDirectionOut
 }
 
+// The direction of a file associated with a process. Input files are used by the process and output
+// files are produced by it, eg the images taken by an SEM.
+const (
+	FileDirectionIn  = "in"
+	FileDirectionOut = "out"
+)
+
 func (s *Sample) AddAttribute(attribute *Attribute) {
 	s.Attributes = append(s.Attributes, attribute)
 }
@@ -118,8 +175,8 @@ func NewSample(name string, row int) *Sample {
 	}
 }
 
-func (s *Sample) AddFile(path string, column int) {
-	file := File{Path: path, Column: column}
+func (s *Sample) AddFile(path, direction string, column int) {
+	file := File{Path: path, Column: column, Direction: direction}
 	s.Files = append(s.Files, file)
 }
 
@@ -130,6 +187,7 @@ type Attribute struct {
 	Unit   string
 	Column int
 	Value  map[string]interface{}
+	Source *CellRef // The cell the value was read from, nil for header attributes
 }
 
 func NewAttribute(name, unit string, column int) *Attribute {
@@ -142,10 +200,49 @@ func NewAttribute(name, unit string, column int) *Attribute {
 
 /////////////////////////////////////////////////////////////////
 
+// CellRef identifies a cell in a spreadsheet. Row and Column are 1 based.
+type CellRef struct {
+	File   string
+	Sheet  string
+	Row    int
+	Column int
+}
+
+// Cell returns the cell in Excel's A1 notation, eg C12.
+func (c *CellRef) Cell() string {
+	return fmt.Sprintf("%s%d", ColumnName(c.Column), c.Row)
+}
+
+// ColumnName returns the letters that name the column in a spreadsheet, eg 1 is A and 28 is AB.
+func ColumnName(column int) string {
+	var letters string
+	for ; column > 0; column = (column - 1) / 26 {
+		letters = string(rune('A'+(column-1)%26)) + letters
+	}
+
+	return letters
+}
+
+// PreambleText returns the cells of a preamble row that aren't blank, separated by |, so the
+// row can be shown on a single line.
+func PreambleText(row []string) string {
+	var cells []string
+	for _, cell := range row {
+		if cell = strings.TrimSpace(cell); cell != "" {
+			cells = append(cells, cell)
+		}
+	}
+
+	return strings.Join(cells, " | ")
+}
+
+/////////////////////////////////////////////////////////////////
+
 type FileHeader struct {
 	Description string
 	Path        string
 	Column      int
+	Direction   string // FileDirectionIn or FileDirectionOut, blank until it has been worked out
 }
 
 func NewFileHeader(description, path string, column int) *FileHeader {
@@ -0,0 +1,84 @@
+package spreadsheet
+
+/*
+ * parent_chain lets a parent cell spell out the worksheets a sample went through before this one, for
+ * samples that the worksheets in between don't list:
+ *
+ *   SEM:
+ *   |sample |parent            |p:Voltage(kV) |
+ *   |S1     |Casting > Rolling |20            |
+ *
+ * S1 goes from Casting into Rolling and from Rolling into SEM. Rolling is the parent, as if the cell only
+ * said Rolling. When Rolling doesn't list S1 the sample passes through a Rolling process of its own, without
+ * any attributes, so the lineage on the server is complete. When it does, S1's row on Rolling gives where it
+ * came from and the worksheets before Rolling in the chain are ignored. A chain starts with the first
+ * process the sample went through, so S1 is created and sent into Casting if Casting doesn't list it either.
+ * A worksheet whose name has a > in it, such as "Anneal > 500C", is matched by its whole name rather than split.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+)
+
+// parentChainSeparator separates the worksheets in a parent chain.
+const parentChainSeparator = ">"
+
+// splitParentChain splits a parent cell into the parent, which is the last worksheet in the chain, and
+// the worksheets before it. A cell that isn't a chain is the parent. sheetNames are the names of the
+// worksheets being loaded, the longest of them that a step could be is used so that a worksheet whose name
+// has the separator in it isn't split.
+func splitParentChain(cell string, sheetNames map[string]bool) (string, []string) {
+	if !strings.Contains(cell, parentChainSeparator) || sheetNames[strings.TrimSpace(cell)] {
+		return cell, nil
+	}
+
+	parts := strings.Split(cell, parentChainSeparator)
+	var chain []string
+	for i := 0; i < len(parts); {
+		// Join as many of the parts as make a worksheet name, or take the one part if none do
+		end := i + 1
+		for j := len(parts); j > end; j-- {
+			if sheetNames[strings.TrimSpace(strings.Join(parts[i:j], parentChainSeparator))] {
+				end = j
+				break
+			}
+		}
+
+		chain = append(chain, strings.TrimSpace(strings.Join(parts[i:end], parentChainSeparator)))
+		i = end
+	}
+
+	return chain[len(chain)-1], chain[:len(chain)-1]
+}
+
+// validateParentChain checks the worksheets before the parent in the sample's parent chain. Each one
+// must be a worksheet other than the sample's, and appear in the chain once.
+func validateParentChain(sample *model.Sample, worksheet *model.Worksheet, knownProcesses map[string]*model.Worksheet) []error {
+	var errs []error
+	seen := map[string]bool{sample.Parent: true}
+	for _, name := range sample.ParentChain {
+		_, existing := processor.ExistingProcessID(name)
+		switch {
+		case name == "":
+			errs = append(errs, fmt.Errorf("sample '%s' in process '%s' has a parent chain with a blank step", sample.Name, worksheet.Source()))
+		case existing:
+			errs = append(errs, fmt.Errorf("sample '%s' in process '%s' has parent chain step '%s', only worksheets can be in a chain",
+				sample.Name, worksheet.Source(), name))
+		case name == worksheet.Name:
+			errs = append(errs, fmt.Errorf("sample '%s' in process '%s' has the current process in its parent chain", sample.Name, worksheet.Source()))
+		case seen[name]:
+			errs = append(errs, fmt.Errorf("sample '%s' in process '%s' has '%s' in its parent chain more than once",
+				sample.Name, worksheet.Source(), name))
+		case knownProcesses[name] == nil:
+			errs = append(errs, fmt.Errorf("sample '%s' in process '%s' has parent chain step '%s' that does not exist",
+				sample.Name, worksheet.Source(), name))
+		}
+		seen[name] = true
+	}
+
+	return errs
+}
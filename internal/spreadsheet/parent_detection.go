@@ -0,0 +1,90 @@
+package spreadsheet
+
+/*
+ * parent_detection looks for worksheets whose second column is a parent column when --has-parent wasn't
+ * given. Without the flag the parent names are loaded as a sample attribute and the workflow is built
+ * without them, which is easy to miss. Column 2 looks like a parent column when every value in it is the
+ * name of a worksheet, eg
+ *
+ *   |sample |parent  |p:Temperature(C) |
+ *   |S1     |Casting |400              |
+ *
+ * With --detect-parent such worksheets are loaded as if they had --has-parent, otherwise a warning suggests
+ * the flag. A worksheet whose has parent setting is given by an index sheet isn't checked.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// detectParentColumn returns the settings to load the worksheet with, which have has parent set when
+// DetectParent is true and column 2 looks like a parent column. sheetNames are the names of all the
+// worksheets being loaded.
+func (l *Loader) detectParentColumn(s *sheet, settings *sheetSettings, sheetNames map[string]bool) *sheetSettings {
+	if l.HasParent || (settings != nil && settings.hasParent != nil) || l.isSamplesOrMasterSheet(s.name) || l.isLookupSheet(s.name) {
+		return settings
+	}
+
+	worksheet := &model.Worksheet{Name: s.name, File: s.file}
+	headerRow := l.headerRow(worksheet, s.rows, settings)
+	if headerRow >= len(s.rows) || !looksLikeParentColumn(s.rows[headerRow:], s.name, sheetNames) {
+		return settings
+	}
+
+	if !l.DetectParent {
+		console.Warningf("Worksheet %s column B holds worksheet names, use --has-parent or --detect-parent if it is the parent column\n", worksheet.Source())
+		return settings
+	}
+
+	fmt.Printf("Worksheet %s column B holds worksheet names, it has been loaded as the parent column\n", worksheet.Source())
+	detected := &sheetSettings{}
+	if settings != nil {
+		*detected = *settings
+	}
+	hasParent := true
+	detected.hasParent = &hasParent
+	return detected
+}
+
+// headerRow returns the number of rows to skip before the header row of the worksheet.
+func (l *Loader) headerRow(worksheet *model.Worksheet, rows [][]string, settings *sheetSettings) int {
+	if settings != nil && settings.headerRow != nil {
+		return settings.rowsToSkip(worksheet, rows)
+	}
+
+	return l.HeaderRows.rowsToSkip(worksheet, rows)
+}
+
+// looksLikeParentColumn returns true if every value in column 2 of the rows after the header is the name
+// of another worksheet, or a parent chain of them. At least one row must have a value.
+func looksLikeParentColumn(rows [][]string, worksheetName string, sheetNames map[string]bool) bool {
+	if len(rows) < 2 {
+		return false
+	}
+
+	found := false
+	for _, row := range rows[1:] {
+		if len(row) < 2 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+
+		value := strings.TrimSpace(row[1])
+		if value == "" {
+			continue
+		}
+
+		parent, chain := splitParentChain(value, sheetNames)
+		for _, name := range append(chain, parent) {
+			if name == worksheetName || !sheetNames[name] {
+				return false
+			}
+		}
+		found = true
+	}
+
+	return found
+}
@@ -0,0 +1,125 @@
+package spreadsheet
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// parseCacheVersion is part of each cache key. Change it when the way rows are read from a workbook
+// changes, so that rows cached by an older mcetl aren't used.
+const parseCacheVersion = 2
+
+// ParseCache stores the rows read from workbooks on disk, keyed by a hash of the workbook's contents.
+// Reading a large workbook with excelize is slow, and while iterating on flags with check, display and
+// load the same unchanged workbook is read over and over. Only the rows are cached, the worksheets are
+// still processed from the rows each time so flags that change how rows are interpreted take effect.
+type ParseCache struct {
+	Dir string
+}
+
+// cachedSheet is the form a sheet is stored in the cache. The file isn't stored, it is the name the
+// workbook is loaded under, which can differ between runs for the same contents.
+type cachedSheet struct {
+	Name  string
+	Index int
+	Rows  [][]string
+}
+
+func NewParseCache(dir string) *ParseCache {
+	return &ParseCache{Dir: dir}
+}
+
+// DefaultParseCacheDir returns the directory the parse cache is kept in, under the user's cache
+// directory (eg $HOME/.cache/mcetl/parsed on Linux).
+func DefaultParseCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "mcetl", "parsed"), nil
+}
+
+// key returns the cache key for the workbook at path. fillMerged is included because it changes
+// the rows that are read.
+func (c *ParseCache) key(path string, fillMerged bool) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x-v%d-merged-%t", h.Sum(nil), parseCacheVersion, fillMerged), nil
+}
+
+// get returns the cached sheets for the workbook, or false if they aren't cached.
+func (c *ParseCache) get(key, file string) ([]*sheet, bool) {
+	f, err := os.Open(filepath.Join(c.Dir, key+".gob.gz"))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, false
+	}
+	defer gz.Close()
+
+	var cached []cachedSheet
+	if err := gob.NewDecoder(gz).Decode(&cached); err != nil {
+		return nil, false
+	}
+
+	var sheets []*sheet
+	for _, cs := range cached {
+		sheets = append(sheets, &sheet{name: cs.Name, file: file, index: cs.Index, rows: cs.Rows})
+	}
+
+	return sheets, true
+}
+
+// put stores the sheets in the cache. The cache file is written to a temporary file and renamed so
+// that a concurrent mcetl never reads a partly written entry.
+func (c *ParseCache) put(key string, sheets []*sheet) error {
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return err
+	}
+
+	var cached []cachedSheet
+	for _, s := range sheets {
+		cached = append(cached, cachedSheet{Name: s.name, Index: s.index, Rows: s.rows})
+	}
+
+	f, err := ioutil.TempFile(c.Dir, key+".tmp")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(f)
+	err = gob.NewEncoder(gz).Encode(cached)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(c.Dir, key+".gob.gz"))
+}
@@ -0,0 +1,153 @@
+package spreadsheet
+
+/*
+ * pattern_headers keeps very wide worksheets manageable. Instruments such as EDS export one column for
+ * each of dozens of elements, with headers that only differ by the element. Rather than writing a header
+ * for every column, a pattern header contains a placeholder that is expanded against a list of values
+ * declared in the schema (see schema.go). The pattern header takes the first column and the blank header
+ * cells after it take the rest of the values, in order. For example with the schema
+ *
+ *   patterns:
+ *     - name: element
+ *       values: [Fe, Ni, Cr]
+ *
+ * the header row
+ *
+ *   |sample|s:EDS_{element}(at%)|  |  |p:Voltage(kV)|
+ *
+ * is loaded as
+ *
+ *   |sample|s:EDS_Fe(at%)|s:EDS_Ni(at%)|s:EDS_Cr(at%)|p:Voltage(kV)|
+ *
+ * When the pattern is declared with combine: true the columns are loaded as a single attribute, named
+ * after the header without the placeholder (EDS above), whose value is an object keyed by the values,
+ * eg {"Fe": 70.2, "Ni": 10.1, "Cr": 19.7}. This lets the server store a composition as one structured
+ * measurement rather than as dozens of separate ones.
+ */
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// patternPlaceholder matches the placeholder in a pattern header, eg {element}
+var patternPlaceholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// patternGroup is the columns that a combined pattern header was expanded to.
+type patternGroup struct {
+	// name and unit of the attribute the columns are combined into
+	name string
+	unit string
+
+	// column is the column of the pattern header
+	column int
+
+	// keys maps each column to the value it was expanded with
+	keys map[int]string
+}
+
+// expandPatternHeaders returns the header row with each pattern header expanded into one header for each
+// of its values. A value takes the place of a blank header cell, so the columns of the expanded row are
+// the same as the columns of the sample rows. If there aren't enough blank header cells after a pattern
+// header the values that don't fit are dropped with a warning.
+func (r *rowProcessor) expandPatternHeaders(row []string) []string {
+	var expanded []string
+	for i := 0; i < len(row); i++ {
+		cell := strings.TrimSpace(row[i])
+		match := patternPlaceholder.FindStringSubmatchIndex(cell)
+		if match == nil {
+			expanded = append(expanded, row[i])
+			continue
+		}
+
+		column := len(expanded) + 1
+		name := cell[match[2]:match[3]]
+		pattern := r.schema.findPattern(name)
+		if pattern == nil {
+			console.Warningf("Worksheet %s column %s header '%s' has the placeholder {%s} but the schema doesn't declare a pattern named %s\n",
+				r.worksheet.Source(), model.ColumnName(column), cell, name, name)
+			expanded = append(expanded, row[i])
+			continue
+		}
+
+		var group *patternGroup
+		if pattern.Combine {
+			group = newPatternGroup(cell[:match[0]]+cell[match[1]:], pattern.Name, column)
+			r.patternGroups = append(r.patternGroups, group)
+		}
+
+		for j, value := range pattern.Values {
+			if j != 0 {
+				if i+1 < len(row) && strings.TrimSpace(row[i+1]) != "" {
+					console.Warningf("Worksheet %s column %s pattern header '%s' needs %d columns, but column %s has the header '%s'. Only the first %d values are loaded\n",
+						r.worksheet.Source(), model.ColumnName(column), cell, len(pattern.Values), model.ColumnName(column+j), row[i+1], j)
+					break
+				}
+
+				// The sample rows may have more cells than the header row, whose trailing blank cells aren't read
+				i++
+			}
+
+			if group != nil {
+				group.keys[column+j] = value
+			}
+			expanded = append(expanded, cell[:match[0]]+value+cell[match[1]:])
+		}
+	}
+
+	return expanded
+}
+
+// newPatternGroup creates the group for a combined pattern header. header is the pattern header with the
+// placeholder removed, eg s:EDS_(at%). The attribute takes its name from what is left, or from the pattern
+// if nothing is left.
+func newPatternGroup(header, patternName string, column int) *patternGroup {
+	name, unit := cell2NameAndUnit(header)
+	name = strings.Trim(name, " _-")
+	if name == "" {
+		name = patternName
+	}
+
+	return &patternGroup{name: name, unit: unit, column: column, keys: make(map[int]string)}
+}
+
+// combinePatternAttributes replaces the attributes of the sample that came from a combined pattern
+// header with a single attribute for each pattern.
+func (r *rowProcessor) combinePatternAttributes(sample *model.Sample) {
+	for _, group := range r.patternGroups {
+		sample.Attributes = group.combine(sample.Attributes)
+		sample.ProcessAttrs = group.combine(sample.ProcessAttrs)
+	}
+}
+
+// combine returns the attributes with those from the group's columns replaced by one attribute. The
+// combined attribute takes the place of the first of them.
+func (g *patternGroup) combine(attrs []*model.Attribute) []*model.Attribute {
+	var (
+		kept     []*model.Attribute
+		combined map[string]interface{}
+	)
+
+	for _, attr := range attrs {
+		key, ok := g.keys[attr.Column]
+		if !ok {
+			kept = append(kept, attr)
+			continue
+		}
+
+		if combined == nil {
+			combined = make(map[string]interface{})
+			combinedAttr := model.NewAttribute(g.name, g.unit, g.column)
+			combinedAttr.Source = attr.Source
+			combinedAttr.Value = map[string]interface{}{"value": combined}
+			kept = append(kept, combinedAttr)
+		}
+
+		combined[key] = attr.Value["value"]
+	}
+
+	return kept
+}
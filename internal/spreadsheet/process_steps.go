@@ -0,0 +1,57 @@
+package spreadsheet
+
+/*
+ * process_steps orders the processes a sample goes through on a single worksheet, for samples that go through
+ * the same process more than once, such as two anneals. Without an order the rows are separate processes that
+ * the sample goes into side by side. A column with the step: keyword numbers them:
+ *
+ *   Anneal:
+ *   |sample |step: |p:Temperature(C) |p:Time(h) |
+ *   |S1     |1     |400              |2         |
+ *   |S1     |2     |550              |1         |
+ *
+ * S1 then goes from its parent (or is created) into the first anneal, and from the first anneal into the
+ * second. The parent column only applies to a sample's first step, each later step's input is the step
+ * before it. A worksheet that names Anneal as the parent gets S1 from its last step. Steps don't need to be
+ * consecutive, and rows with the same step are the same process when their process attributes match.
+ */
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// parseStep parses the cell of a step: column, which must be a positive whole number.
+func parseStep(cell string) (int, error) {
+	step, err := strconv.Atoi(cell)
+	if err != nil || step < 1 {
+		return 0, fmt.Errorf("step '%s' should be a whole number from 1", cell)
+	}
+
+	return step, nil
+}
+
+// validateSteps checks that a sample with a step on one of its rows in the worksheet has a step on all of them.
+func validateSteps(worksheet *model.Worksheet) error {
+	stepped := make(map[string]*model.Sample)
+	unstepped := make(map[string]*model.Sample)
+	for _, sample := range worksheet.Samples {
+		if sample.Step != 0 {
+			stepped[sample.Name] = sample
+		} else {
+			unstepped[sample.Name] = sample
+		}
+	}
+
+	for _, sample := range worksheet.Samples {
+		withStep, withoutStep := stepped[sample.Name], unstepped[sample.Name]
+		if withStep != nil && withoutStep != nil {
+			return fmt.Errorf("worksheet %s sample %s has a step on row %d but not on row %d",
+				worksheet.Source(), sample.Name, withStep.Row, withoutStep.Row)
+		}
+	}
+
+	return nil
+}
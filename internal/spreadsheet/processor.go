@@ -1,7 +1,7 @@
 package spreadsheet
 
 import (
-	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/mcapix"
 	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
 	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
 )
@@ -12,8 +12,48 @@ type Processor interface {
 
 var Display = processor.NewDisplayer()
 
-func Create(projectID, name string, hasParent bool, client *mcapi.Client) *processor.Creater {
-	c := processor.NewCreater(projectID, name, "", client)
-	c.HasParent = hasParent
+func Create(projectID, name, description string, options processor.WorkflowOptions, client *mcapix.Client) *processor.Creater {
+	c := processor.NewCreater(projectID, name, description, client)
+	c.WorkflowOptions = options
 	return c
 }
+
+func Bundle(projectID, name, description, dir string, options processor.WorkflowOptions) *processor.Bundler {
+	b := processor.NewBundler(projectID, name, description, dir)
+	b.WorkflowOptions = options
+	return b
+}
+
+func Genealogy(path string, options processor.WorkflowOptions) *processor.GenealogyExporter {
+	g := processor.NewGenealogyExporter(path)
+	g.WorkflowOptions = options
+	return g
+}
+
+func Graph(path string, options processor.WorkflowOptions) *processor.GraphExporter {
+	g := processor.NewGraphExporter(path)
+	g.WorkflowOptions = options
+	return g
+}
+
+func Coverage(path string) *processor.CoverageExporter {
+	return processor.NewCoverageExporter(path)
+}
+
+func Limits(maxSamples, maxProcesses int, options processor.WorkflowOptions) *processor.LimitChecker {
+	l := processor.NewLimitChecker(maxSamples, maxProcesses)
+	l.WorkflowOptions = options
+	return l
+}
+
+func Duplicates(options processor.WorkflowOptions) *processor.DuplicateChecker {
+	d := processor.NewDuplicateChecker()
+	d.WorkflowOptions = options
+	return d
+}
+
+func Orphans(options processor.WorkflowOptions) *processor.OrphanChecker {
+	o := processor.NewOrphanChecker()
+	o.WorkflowOptions = options
+	return o
+}
@@ -0,0 +1,331 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/mcapix"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// Bundler is an alternate backend to the Creater. Instead of calling the REST API to create the
+// workflow it writes a Materials Commons import bundle to a local directory. The bundle can then
+// be bulk imported by a server admin. This is useful for facilities that have no network access
+// to the server.
+//
+// A bundle consists of two files:
+//
+//	bundle.json - The experiment, samples, processes and measurements
+//	files.txt   - The list of project files referenced by the processes, one per line
+//
+// Since nothing is created on the server the bundle uses local IDs (sample-1, process-1, ps-1)
+// to connect the entities together. The import replaces these with server IDs.
+type Bundler struct {
+	// The project the bundle will be imported into
+	ProjectID string
+
+	// Name of the experiment to create
+	Name string
+
+	// Description of the experiment to create
+	Description string
+
+	// Directory to write the bundle into
+	Dir string
+
+	// Options for constructing the workflow
+	WorkflowOptions
+
+	// RecordProvenance stores the cell each measurement was read from in its metadata (see Creater)
+	RecordProvenance bool
+
+	// LinkFilesToSamples lists the files for each sample on its output sample references too, so the
+	// import links them to the sample as well as the process (see Creater)
+	LinkFilesToSamples bool
+
+	// NoExperiment leaves the experiment out of the bundle, so the import creates the samples and
+	// processes in the project without one (see Creater)
+	NoExperiment bool
+
+	bundle *Bundle
+
+	sampleCount      int
+	processCount     int
+	propertySetCount int
+
+	// existingSamples are the samples output by existing processes, with the id of the process
+	existingSamples map[*mcapi.Sample]string
+}
+
+// Bundle is the JSON document that is written to bundle.json.
+type Bundle struct {
+	ProjectID  string            `json:"project_id"`
+	Experiment *BundleExperiment `json:"experiment,omitempty"`
+	Samples    []*BundleSample   `json:"samples"`
+	Processes  []*BundleProcess  `json:"processes"`
+	Files      []string          `json:"files"`
+	Counts     map[string]int    `json:"counts"`
+}
+
+type BundleExperiment struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type BundleSample struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	ProcessName string        `json:"process_name,omitempty"`
+	Setup       []mcapi.Setup `json:"setup,omitempty"`
+}
+
+// BundleSampleRef is a reference to a particular property set (state) of a sample. A sample that is
+// already on the server is given a local ID of the form existing-1. Where it is first used
+// ExistingProcessID is the server process it is output by, and the import finds it by name in the
+// output samples of that process.
+type BundleSampleRef struct {
+	SampleID          string   `json:"sample_id"`
+	PropertySetID     string   `json:"property_set_id"`
+	Name              string   `json:"name"`
+	ExistingProcessID string   `json:"existing_process_id,omitempty"`
+	Files             []string `json:"files,omitempty"` // Files to link to the sample itself
+}
+
+type BundleProcess struct {
+	ID            string                   `json:"id"`
+	Name          string                   `json:"name"`
+	ProcessType   string                   `json:"process_type"`
+	SourceFile    string                   `json:"source_file,omitempty"`
+	Performed     string                   `json:"performed,omitempty"`
+	Setup         []mcapi.Setup            `json:"setup"`
+	InputSamples  []BundleSampleRef        `json:"input_samples"`
+	OutputSamples []BundleSampleRef        `json:"output_samples"`
+	Files         []mcapi.FileAndDirection `json:"files"`
+	Measurements  []BundleMeasurements     `json:"measurements"`
+}
+
+// BundleMeasurements are the measurements for a sample property set in a process.
+type BundleMeasurements struct {
+	SampleID      string                  `json:"sample_id"`
+	PropertySetID string                  `json:"property_set_id"`
+	Attributes    []mcapix.SampleProperty `json:"attributes"`
+}
+
+func NewBundler(projectID, name, description, dir string) *Bundler {
+	return &Bundler{
+		ProjectID:   projectID,
+		Name:        name,
+		Description: description,
+		Dir:         dir,
+	}
+}
+
+// Apply implements the Process interface. This version writes the workflow to a bundle on disk.
+func (b *Bundler) Apply(worksheets []*model.Worksheet) error {
+	b.bundle = &Bundle{
+		ProjectID: b.ProjectID,
+		Counts:    make(map[string]int),
+	}
+
+	if !b.NoExperiment {
+		b.bundle.Experiment = &BundleExperiment{
+			Name:        b.Name,
+			Description: b.Description,
+		}
+	}
+	b.existingSamples = make(map[*mcapi.Sample]string)
+
+	wf := newWorkflow()
+	wf.WorkflowOptions = b.WorkflowOptions
+
+	wf.constructWorkflow(worksheets)
+
+	order, err := wf.creationOrder()
+	if err != nil {
+		return err
+	}
+
+	for _, wp := range order {
+		b.addWorkflowStep(wp)
+	}
+
+	b.bundle.Files = b.uniqueFiles()
+	b.bundle.Counts["samples"] = len(b.bundle.Samples)
+	b.bundle.Counts["processes"] = len(b.bundle.Processes)
+	b.bundle.Counts["files"] = len(b.bundle.Files)
+
+	return b.write()
+}
+
+// addWorkflowStep adds the sample or process for a step in the workflow to the bundle. Steps are
+// added in the same order that the Creater creates them.
+func (b *Bundler) addWorkflowStep(wp *WorkflowProcess) {
+	if wp.ExistingProcessID != "" {
+		// The samples are already on the server, so they are only referenced
+		for _, name := range wp.sampleNamesFrom() {
+			s := &mcapi.Sample{ID: fmt.Sprintf("existing-%d", len(b.existingSamples)+1), Name: name}
+			b.existingSamples[s] = wp.ExistingProcessID
+			wp.Out = append(wp.Out, s)
+		}
+	} else if wp.Worksheet == nil {
+		// Creating the sample
+		wp.Out = append(wp.Out, b.addSample(wp))
+	} else if wp.Process == nil {
+		b.processCount++
+		wp.Process = &mcapi.Process{
+			ID:          fmt.Sprintf("process-%d", b.processCount),
+			Name:        wp.ProcessName(),
+			ProcessType: wp.Worksheet.ProcessType(),
+		}
+
+		bp := &BundleProcess{
+			ID:          wp.Process.ID,
+			Name:        wp.Process.Name,
+			ProcessType: wp.Process.ProcessType,
+			SourceFile:  wp.Worksheet.File,
+			Setup:       []mcapi.Setup{createConditionsSetup(wp.Samples[0].ProcessAttrs)},
+		}
+
+		if performed, ok := processDate(wp.Worksheet, wp.Samples[0].ProcessAttrs); ok {
+			bp.Performed = performed.Format(time.RFC3339)
+		}
+
+		for _, sample := range wp.getInputSamples() {
+			bp.InputSamples = append(bp.InputSamples, BundleSampleRef{
+				SampleID:          sample.ID,
+				PropertySetID:     sample.PropertySetID,
+				Name:              sample.Name,
+				ExistingProcessID: b.existingSamples[sample],
+			})
+
+			// Each process transforms the sample, which gives it a new property set
+			b.propertySetCount++
+			out := &mcapi.Sample{
+				ID:            sample.ID,
+				Name:          sample.Name,
+				PropertySetID: fmt.Sprintf("ps-%d", b.propertySetCount),
+			}
+			wp.Out = append(wp.Out, out)
+			bp.OutputSamples = append(bp.OutputSamples, BundleSampleRef{
+				SampleID:      out.ID,
+				PropertySetID: out.PropertySetID,
+				Name:          out.Name,
+			})
+
+			worksheetSample := wp.worksheetSample(sample.Name)
+			if worksheetSample == nil {
+				continue
+			}
+
+			for _, file := range worksheetSample.Files {
+				bp.Files = append(bp.Files, mcapi.FileAndDirection{Path: file.Path, Direction: file.Direction})
+				if b.LinkFilesToSamples {
+					ref := &bp.OutputSamples[len(bp.OutputSamples)-1]
+					ref.Files = append(ref.Files, file.Path)
+				}
+			}
+
+			bp.Measurements = append(bp.Measurements, BundleMeasurements{
+				SampleID:      out.ID,
+				PropertySetID: out.PropertySetID,
+				Attributes:    createAttributeMeasurements(worksheetSample.Attributes, b.RecordProvenance),
+			})
+		}
+
+		b.bundle.Processes = append(b.bundle.Processes, bp)
+	}
+}
+
+// addSample adds a new sample for a Create Samples process to the bundle and returns its local representation.
+func (b *Bundler) addSample(wp *WorkflowProcess) *mcapi.Sample {
+	sample := wp.Samples[0]
+	b.sampleCount++
+	b.propertySetCount++
+	s := &mcapi.Sample{
+		ID:            fmt.Sprintf("sample-%d", b.sampleCount),
+		Name:          sample.Name,
+		PropertySetID: fmt.Sprintf("ps-%d", b.propertySetCount),
+	}
+
+	bs := &BundleSample{ID: s.ID, Name: s.Name, ProcessName: b.CreateProcessName}
+	if len(wp.CreateAttrs) != 0 {
+		bs.Setup = []mcapi.Setup{createConditionsSetup(wp.CreateAttrs)}
+	}
+
+	b.bundle.Samples = append(b.bundle.Samples, bs)
+	return s
+}
+
+// uniqueFiles returns the sorted list of unique file paths referenced by the processes in the bundle.
+func (b *Bundler) uniqueFiles() []string {
+	uniqueFilePaths := make(map[string]bool)
+	for _, p := range b.bundle.Processes {
+		for _, file := range p.Files {
+			uniqueFilePaths[file.Path] = true
+		}
+	}
+
+	var files []string
+	for path := range uniqueFilePaths {
+		files = append(files, path)
+	}
+
+	sort.Strings(files)
+	return files
+}
+
+// write writes bundle.json and files.txt into the bundle directory, creating the directory
+// if it doesn't exist.
+func (b *Bundler) write() error {
+	if err := os.MkdirAll(b.Dir, 0755); err != nil {
+		return err
+	}
+
+	contents, err := json.MarshalIndent(b.bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(b.Dir, "bundle.json"), contents, 0644); err != nil {
+		return err
+	}
+
+	fileList := strings.Join(b.bundle.Files, "\n")
+	if fileList != "" {
+		fileList = fileList + "\n"
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(b.Dir, "files.txt"), []byte(fileList), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote bundle to %s: %d samples, %d processes, %d files\n", b.Dir,
+		len(b.bundle.Samples), len(b.bundle.Processes), len(b.bundle.Files))
+	return nil
+}
+
+// ReadBundle reads a bundle written by the Bundler. path is either the bundle directory or its bundle.json.
+func ReadBundle(path string) (*Bundle, error) {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		path = filepath.Join(path, "bundle.json")
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var bundle Bundle
+	if err := json.Unmarshal(contents, &bundle); err != nil {
+		return nil, fmt.Errorf("%s isn't a bundle: %s", path, err)
+	}
+
+	return &bundle, nil
+}
@@ -0,0 +1,165 @@
+package processor
+
+/*
+ * concurrent_steps creates the steps of the workflow Concurrency at a time, for large loads where creating
+ * them one at a time takes hours. A step can be created once every step that sends samples into it has been
+ * created, so independent branches of the workflow, such as the lineages of different samples, are created
+ * side by side. The steps that are ready are started in the creation order, so the order still decides
+ * what is created first. A leveled load reports each level as the first of its steps is started.
+ *
+ * Once a step fails no more are started, the steps already being created are finished and the load stops.
+ * The measurements are added after all the steps have been created, as they are for a load one step at a
+ * time.
+ */
+
+import (
+	"sort"
+	"sync"
+)
+
+// stepResult is the outcome of creating a step. step is the report the step was created with, which is
+// copied into the load report once the step is finished so that the report can be saved while other steps
+// are being created.
+type stepResult struct {
+	wp   *WorkflowProcess
+	step StepReport
+	err  error
+}
+
+// createStepsConcurrently creates the steps Concurrency at a time, each once the steps it depends on have
+// been created. It returns the error of the first step that failed.
+func (c *Creater) createStepsConcurrently(order []*WorkflowProcess) error {
+	position := make(map[*WorkflowProcess]int)
+	for i, wp := range order {
+		position[wp] = i
+	}
+
+	// The number of steps each step is waiting on
+	waitingOn := make(map[*WorkflowProcess]int)
+	for _, wp := range order {
+		for _, parent := range uniqueProcesses(wp.From) {
+			if _, ok := position[parent]; ok {
+				waitingOn[wp]++
+			}
+		}
+	}
+
+	var (
+		work    = make(chan *WorkflowProcess)
+		results = make(chan stepResult)
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < c.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer c.saveProgressOnPanic()
+			for wp := range work {
+				c.mu.Lock()
+				step := *c.steps[wp]
+				c.mu.Unlock()
+
+				err := c.createWorkflowStep(wp, &step)
+				results <- stepResult{wp: wp, step: step, err: err}
+			}
+		}()
+	}
+
+	// ready are the steps whose parents have been created, in the creation order
+	var ready []*WorkflowProcess
+	makeReady := func(wp *WorkflowProcess) {
+		i := sort.Search(len(ready), func(i int) bool { return position[ready[i]] > position[wp] })
+		ready = append(ready, nil)
+		copy(ready[i+1:], ready[i:])
+		ready[i] = wp
+	}
+
+	// finished makes the children of a step that has been created ready once all their parents are
+	finished := func(wp *WorkflowProcess) {
+		for _, child := range uniqueProcesses(wp.To) {
+			if _, ok := position[child]; !ok {
+				continue
+			}
+
+			waitingOn[child]--
+			if waitingOn[child] == 0 {
+				makeReady(child)
+			}
+		}
+	}
+
+	for _, wp := range order {
+		if waitingOn[wp] == 0 {
+			ready = append(ready, wp)
+		}
+	}
+
+	// Leveled loads report their progress as the first step of each level is started
+	var levels map[*WorkflowProcess]int
+	if c.CreationOrder == Leveled {
+		levels = processLevels(order)
+	}
+	shownLevels := make(map[int]bool)
+
+	var (
+		firstErr error
+		running  int
+	)
+	for running != 0 || (firstErr == nil && len(ready) != 0) {
+		var (
+			next   *WorkflowProcess
+			sendTo chan *WorkflowProcess
+		)
+		if firstErr == nil && len(ready) != 0 {
+			next = ready[0]
+			if levels != nil && !shownLevels[levels[next]] {
+				shownLevels[levels[next]] = true
+				showLevelProgress(levels, levels[next])
+			}
+			if c.resumeStep(next, c.steps[next]) {
+				ready = ready[1:]
+				finished(next)
+				continue
+			}
+			sendTo = work
+		}
+
+		select {
+		case sendTo <- next:
+			ready = ready[1:]
+			running++
+
+		case result := <-results:
+			running--
+			if result.err != nil {
+				result.step.Status = StepFailed
+				result.step.Error = result.err.Error()
+			}
+
+			c.mu.Lock()
+			*c.steps[result.wp] = result.step
+			c.mu.Unlock()
+
+			switch {
+			case firstErr != nil:
+				// The load is stopping, the step is only recorded
+				if result.err == nil {
+					c.stepCreated(result.wp)
+				}
+			case result.err != nil:
+				firstErr = result.err
+			default:
+				if err := c.stepCreated(result.wp); err != nil {
+					firstErr = err
+					continue
+				}
+				finished(result.wp)
+			}
+		}
+	}
+
+	close(work)
+	wg.Wait()
+	return firstErr
+}
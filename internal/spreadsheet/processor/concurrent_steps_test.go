@@ -0,0 +1,123 @@
+package processor_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/materials-commons/mcetl/internal/mcapix"
+	"github.com/materials-commons/mcetl/internal/selftest"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+)
+
+// lineageStudy has 6 samples that are heat treated, 6 that go on to SEM and 4 that go on to hardness
+// testing, so the workflow has 3 levels of independent lineages.
+func lineageStudy() []testSheet {
+	heatTreatment := testSheet{name: "Heat Treatment", rows: [][]interface{}{{"sample", "parent", "p:Temperature(c)"}}}
+	sem := testSheet{name: "SEM", rows: [][]interface{}{{"sample", "parent", "p:Voltage(kV)"}}}
+	hardness := testSheet{name: "Hardness", rows: [][]interface{}{{"sample", "parent", "s:Hardness(HV)"}}}
+	for i := 1; i <= 6; i++ {
+		sample := fmt.Sprintf("S%d", i)
+		heatTreatment.rows = append(heatTreatment.rows, []interface{}{sample, "", 300 + 50*i})
+		sem.rows = append(sem.rows, []interface{}{sample, "Heat Treatment", 10 + i})
+		if i <= 4 {
+			hardness.rows = append(hardness.rows, []interface{}{sample, "SEM", 200 + i})
+		}
+	}
+
+	return []testSheet{heatTreatment, sem, hardness}
+}
+
+// overlapServer serves the API with a MockServer, recording the most calls it was answering at once.
+type overlapServer struct {
+	server *selftest.MockServer
+
+	mu       sync.Mutex
+	inFlight int
+	maxCalls int
+}
+
+func (o *overlapServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	o.inFlight++
+	if o.inFlight > o.maxCalls {
+		o.maxCalls = o.inFlight
+	}
+	o.mu.Unlock()
+
+	// The MockServer answers at once, the delay lets calls that are made at once overlap
+	time.Sleep(2 * time.Millisecond)
+	o.server.ServeHTTP(w, r)
+
+	o.mu.Lock()
+	o.inFlight--
+	o.mu.Unlock()
+}
+
+// samplesAdded returns the number of samples added to each process. A process's input samples are the
+// samples its parents output, so a process created before its parents were created has fewer of them.
+func samplesAdded(server *selftest.MockServer) map[string]int {
+	added := make(map[string]int)
+	for _, call := range server.Calls() {
+		switch call.Endpoint {
+		case "addSamplesToProcess":
+			samples, _ := call.Body["samples"].([]interface{})
+			added[fmt.Sprint(call.Body["process_id"])] += len(samples)
+		case "addSampleToProcess", "addSampleAndFilesToProcess":
+			added[fmt.Sprint(call.Body["process_id"])]++
+		}
+	}
+
+	return added
+}
+
+func TestConcurrentStepsWaitForTheirParents(t *testing.T) {
+	for _, order := range []string{processor.DepthFirst, processor.Leveled} {
+		t.Run(order, func(t *testing.T) {
+			serial := selftest.NewMockServer()
+			c := newTestCreater(t, serial)
+			c.CreationOrder = order
+			if err := c.Apply(loadWorksheets(t, lineageStudy())); err != nil {
+				t.Fatalf("serial load failed: %s", err)
+			}
+
+			overlap := &overlapServer{server: selftest.NewMockServer()}
+			server := httptest.NewServer(overlap)
+			defer server.Close()
+
+			client := mcapix.NewClient(server.URL + "/api")
+			client.APIKey = "test"
+			c = processor.NewCreater("project", "study", "", client)
+			c.WorkflowOptions = processor.WorkflowOptions{HasParent: true}
+			c.CreationOrder = order
+			c.Concurrency = 4
+			if err := c.Apply(loadWorksheets(t, lineageStudy())); err != nil {
+				t.Fatalf("concurrent load failed: %s", err)
+			}
+
+			// The mock servers give out ids in the order they are asked for, so the processes are compared
+			// by how many of them had each number of samples added
+			want, got := make(map[int]int), make(map[int]int)
+			for _, n := range samplesAdded(serial) {
+				want[n]++
+			}
+			for _, n := range samplesAdded(overlap.server) {
+				got[n]++
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("concurrent load added %v samples to processes, the serial load %v", got, want)
+			}
+			for _, status := range []string{processor.StepFailed, processor.StepNotAttempted} {
+				if n := c.Report().Counts()[status]; n != 0 {
+					t.Errorf("%d steps are %s", n, status)
+				}
+			}
+			if overlap.maxCalls < 2 {
+				t.Errorf("at most %d call was made at once, want steps created side by side", overlap.maxCalls)
+			}
+		})
+	}
+}
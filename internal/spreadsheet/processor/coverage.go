@@ -0,0 +1,171 @@
+package processor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// coverageColumn is an attribute column in a coverage matrix. A name that is the header of several
+// columns, such as a measurement repeated three times, is a single column in the matrix.
+type coverageColumn struct {
+	name    string
+	process bool
+}
+
+// header returns the column's header, with the keyword that says whether it is a process or sample attribute.
+func (c coverageColumn) header() string {
+	if c.process {
+		return "p:" + c.name
+	}
+
+	return "s:" + c.name
+}
+
+// covered returns true if the sample has a value for the column's attribute.
+func (c coverageColumn) covered(sample *model.Sample) bool {
+	if c.process {
+		return findAttrByName(sample.ProcessAttrs, c.name) != nil
+	}
+
+	return findAttrByName(sample.Attributes, c.name) != nil
+}
+
+// coverageColumns returns the attribute columns of the worksheet, process attributes first.
+func coverageColumns(worksheet *model.Worksheet) []coverageColumn {
+	var columns []coverageColumn
+	seen := make(map[coverageColumn]bool)
+	add := func(attrs []*model.Attribute, process bool) {
+		for _, attr := range attrs {
+			column := coverageColumn{name: attr.Name, process: process}
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+
+	add(worksheet.ProcessAttrs, true)
+	add(worksheet.SampleAttrs, false)
+	return columns
+}
+
+// printCoverage shows, for each worksheet, a matrix of the samples against the attributes with an x where
+// the sample's row has a value and a - where the cell is blank, followed by the attributes that have
+// missing values. This shows which measurements are missing before anything is loaded.
+func (d *Displayer) printCoverage(worksheets []*model.Worksheet) {
+	fmt.Println("======= coverage =======")
+	for _, worksheet := range worksheets {
+		columns := coverageColumns(worksheet)
+		if len(columns) == 0 || len(worksheet.Samples) == 0 {
+			continue
+		}
+
+		missing := make([]int, len(columns))
+		total := 0
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%sROW\tSAMPLE", spaces(2))
+		for _, column := range columns {
+			fmt.Fprintf(w, "\t%s", column.header())
+		}
+		fmt.Fprintln(w)
+
+		for _, sample := range worksheet.Samples {
+			fmt.Fprintf(w, "%s%d\t%s", spaces(2), sample.Row, sample.Name)
+			for i, column := range columns {
+				mark := "x"
+				if !column.covered(sample) {
+					mark = "-"
+					missing[i]++
+					total++
+				}
+				fmt.Fprintf(w, "\t%s", mark)
+			}
+			fmt.Fprintln(w)
+		}
+
+		fmt.Printf("Worksheet %s: %d row(s) x %d attribute(s), %d value(s) missing\n",
+			worksheet.Source(), len(worksheet.Samples), len(columns), total)
+		w.Flush()
+
+		for i, column := range columns {
+			if missing[i] != 0 {
+				fmt.Printf("%s%s is missing in %d of %d row(s)\n", spaces(2), column.header(), missing[i], len(worksheet.Samples))
+			}
+		}
+	}
+}
+
+// CoverageExporter writes the coverage matrix of the worksheets to a CSV file, with a row for each sample
+// row and a column for each attribute. A cell is 1 when the sample's row has a value for the attribute,
+// 0 when it is blank and empty when the worksheet doesn't have the attribute.
+type CoverageExporter struct {
+	// Path of the CSV file to write
+	Path string
+}
+
+func NewCoverageExporter(path string) *CoverageExporter {
+	return &CoverageExporter{Path: path}
+}
+
+// Apply implements the Process interface. It writes the coverage of the worksheets to Path.
+func (c *CoverageExporter) Apply(worksheets []*model.Worksheet) error {
+	// The columns are the attributes across all the worksheets, in the order they are first seen
+	var all []coverageColumn
+	seen := make(map[coverageColumn]bool)
+	for _, worksheet := range worksheets {
+		for _, column := range coverageColumns(worksheet) {
+			if !seen[column] {
+				seen[column] = true
+				all = append(all, column)
+			}
+		}
+	}
+
+	f, err := os.Create(c.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"worksheet", "file", "row", "sample"}
+	for _, column := range all {
+		header = append(header, column.header())
+	}
+
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	for _, worksheet := range worksheets {
+		has := make(map[coverageColumn]bool)
+		for _, column := range coverageColumns(worksheet) {
+			has[column] = true
+		}
+
+		for _, sample := range worksheet.Samples {
+			record := []string{worksheet.Name, worksheet.File, fmt.Sprint(sample.Row), sample.Name}
+			for _, column := range all {
+				switch {
+				case !has[column]:
+					record = append(record, "")
+				case column.covered(sample):
+					record = append(record, "1")
+				default:
+					record = append(record, "0")
+				}
+			}
+
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -5,11 +5,31 @@ package processor
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/go-multierror"
 	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/mcapix"
 	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
 )
 
+// measurementBatchSize is the most samples whose measurements are added in a single bulk call.
+const measurementBatchSize = 100
+
+// DefaultMeasurementWorkers is the number of measurement batches that are added at once.
+const DefaultMeasurementWorkers = 4
+
+// measurementBatch is a batch of sample measurements waiting to be added to a process. step is the
+// report for the process and key its key in the load state.
+type measurementBatch struct {
+	process      *mcapi.Process
+	step         *StepReport
+	key          string
+	measurements []mcapix.SampleMeasurements
+}
+
 // Creater holds the state needed to create the workflow on the server.
 type Creater struct {
 	// The project we are adding to
@@ -25,9 +45,13 @@ type Creater struct {
 	// for many of the mcapi REST calls.
 	ExperimentID string
 
-	// Does the second column represent the parent column that points to other worksheets. This allows the user
-	// to construct a workflow graph.
-	HasParent bool
+	// NoExperiment creates the samples and processes in the project without creating an experiment
+	// to hold them. ExperimentID is left blank, which the API treats as the project's sample pool.
+	NoExperiment bool
+
+	// Options for constructing the workflow. HasParent (does the second column represent the parent column
+	// that points to other worksheets) allows the user to construct a workflow graph.
+	WorkflowOptions
 
 	// Total number of API calls made
 	Count int
@@ -35,108 +59,426 @@ type Creater struct {
 	// Counts by API call
 	ByCallCounts map[string]int
 
-	client *mcapi.Client
+	// Schedule is the window when API calls can be made, if nil calls can be made at any time
+	Schedule *Schedule
+
+	// RecordProvenance stores the file, sheet, row and column each measurement was read from in
+	// the measurement's metadata, so values on the server can be traced back to their cell.
+	RecordProvenance bool
+
+	// LinkFilesToSamples also links the files for a sample directly to the sample, so that a micrograph
+	// is attached to the sample it depicts as well as to the process that produced it.
+	LinkFilesToSamples bool
+
+	// Concurrency is the number of workflow steps that are created at once, 1 or less creates them one at
+	// a time in the creation order. See concurrent_steps.go.
+	Concurrency int
+
+	// MeasurementWorkers is the number of measurement batches that are added at once. The measurements
+	// are added after all the processes and samples have been created. They don't depend on each other,
+	// so unlike the workflow steps they can be added concurrently.
+	MeasurementWorkers int
+
+	// CreateMissingDirs creates the directories of the files in the worksheets that don't exist in the
+	// project before the workflow is created. See missing_dirs.go.
+	CreateMissingDirs bool
+
+	// VerifyEvery reads back every Nth process created and checks it against what was created, 0 turns
+	// verification off. See verify.go.
+	VerifyEvery int
+
+	// ReuseSamples are the samples already in the project, by name, that are used rather than creating
+	// samples with the same names. See existing_samples.go.
+	ReuseSamples map[string]*mcapi.Sample
+
+	// ProgressPath is where the load report is saved every ProgressInterval while the load runs, and
+	// when it panics. See progress.go.
+	ProgressPath     string
+	ProgressInterval time.Duration
+
+	// progressSaved is when the progress was last saved, progressFailed is set once saving it fails.
+	// progressMu serializes saving it, the measurement workers save it at the same time.
+	progressSaved  time.Time
+	progressFailed bool
+	progressMu     sync.Mutex
+
+	// StatePath is where what the load creates is recorded, so that it can be resumed if it fails. Resume
+	// is the state of an earlier run of the load to carry on from, nil to start afresh. See load_state.go.
+	StatePath string
+	Resume    *LoadState
+
+	// StateFingerprint and StateFlags are the fingerprint of the workbooks and the flags that decide what
+	// is created from them, recorded in the state so it is only resumed with the same ones
+	StateFingerprint string
+	StateFlags       map[string]string
+
+	// state is what the load has created, stateFailed is set once saving it fails. stateMu serializes
+	// saving it.
+	state       *LoadState
+	stateFailed bool
+	stateMu     sync.Mutex
+
+	// created and verified count the processes created and verified
+	created  int
+	verified int
+
+	// measurementQueue holds the measurements to add once the workflow has been created
+	measurementQueue []measurementBatch
+
+	// noBulkMeasurements is set when a bulk measurements call fails, after which measurements are
+	// added one sample at a time.
+	noBulkMeasurements bool
+
+	// noSampleFiles is set when linking files to a sample fails, after which files are only linked
+	// to processes.
+	noSampleFiles bool
+
+	// mu guards the call counts, noBulkMeasurements, noSampleFiles, batchErrs, the measurement queue and
+	// the steps and measurements in the report, which are updated by the step and measurement workers
+	mu sync.Mutex
+
+	// report records the outcome of each step, see Report. steps are the reports of the steps in the
+	// workflow.
+	report *LoadReport
+	steps  map[*WorkflowProcess]*StepReport
+
+	// batchErrs are the samples that couldn't be added to a process in a batch call. These are
+	// reported when the workflow has been created rather than stopping the load.
+	batchErrs *multierror.Error
+
+	client *mcapix.Client
 }
 
-func NewCreater(projectID, name, description string, client *mcapi.Client) *Creater {
+func NewCreater(projectID, name, description string, client *mcapix.Client) *Creater {
 	return &Creater{
-		ProjectID:    projectID,
-		Name:         name,
-		Description:  description,
-		client:       client,
-		ByCallCounts: make(map[string]int),
+		ProjectID:          projectID,
+		Name:               name,
+		Description:        description,
+		client:             client,
+		ByCallCounts:       make(map[string]int),
+		MeasurementWorkers: DefaultMeasurementWorkers,
+		ProgressInterval:   DefaultProgressInterval,
 	}
 }
 
-// Apply implements the Process interface. This version creates the workflow on the server.
+// Apply implements the Process interface. This version creates the workflow on the server. Whether
+// or not it succeeds, Report describes what was created.
 func (c *Creater) Apply(worksheets []*model.Worksheet) error {
-	// 1. Create the experiment on the server to load the workflow into.
-	if err := c.createExperiment(); err != nil {
-		return nil
+	c.report = &LoadReport{ProjectID: c.ProjectID}
+	defer c.saveProgressOnPanic()
+
+	// 1. Create the experiment on the server to load the workflow into, unless the workflow is
+	// being loaded directly into the project or an earlier run created it.
+	switch {
+	case c.Resume != nil:
+		c.resumeExperiment()
+		c.report.ExperimentID = c.ExperimentID
+	case !c.NoExperiment:
+		if err := c.createExperiment(); err != nil {
+			c.report.Error = err.Error()
+			return err
+		}
+		c.report.ExperimentID = c.ExperimentID
+	}
+
+	if c.StatePath != "" {
+		c.state = c.Resume
+		if c.state == nil {
+			c.state = &LoadState{ProjectID: c.ProjectID, ExperimentID: c.ExperimentID, Fingerprint: c.StateFingerprint,
+				Flags: c.StateFlags, Steps: make(map[string]*StateStep)}
+		}
+		c.saveState()
+	}
+
+	if c.CreateMissingDirs {
+		if err := c.createMissingDirectories(worksheets); err != nil {
+			return c.failed(err)
+		}
 	}
 
 	// 2. Create the workflow from the worksheets
 	wf := newWorkflow()
-	wf.HasParent = c.HasParent
+	wf.WorkflowOptions = c.WorkflowOptions
 
 	wf.constructWorkflow(worksheets)
 
-	// 3. Walk through the workflow creating each of the steps.
-	for _, wp := range wf.root {
-		if err := c.createWorkflowSteps(wp); err != nil {
-			// Even though there were errors the experiment loading is no longer "in progress", so
-			// adjust its status. Ignore errors as there is nothing we can do if this fails.
-			var _ = c.client.UpdateExperimentProgressStatus(c.ProjectID, c.ExperimentID, false)
+	order, err := wf.creationOrder()
+	if err != nil {
+		return c.failed(err)
+	}
+
+	if err := c.refreshReusedSamples(); err != nil {
+		return c.failed(err)
+	}
+	c.warnUnaddedReusedSamples(wf)
+
+	c.steps = make(map[*WorkflowProcess]*StepReport)
+	for _, wp := range order {
+		c.steps[wp] = newStepReport(wp)
+		c.report.Steps = append(c.report.Steps, c.steps[wp])
+	}
+
+	// 3. Create each of the steps, parents before the processes they send samples into. The
+	// measurements for the samples are queued rather than added as each step is created.
+	if c.Concurrency > 1 {
+		err = c.createStepsConcurrently(order)
+	} else {
+		err = c.createSteps(order)
+	}
+	if err != nil {
+		return c.failed(err)
+	}
+
+	// 4. Add the queued measurements now that all the samples they are for exist
+	if err := c.addQueuedMeasurements(); err != nil {
+		return c.failed(err)
+	}
+
+	if c.VerifyEvery > 0 {
+		fmt.Printf("Verified %d of the %d processes created\n", c.verified, c.created)
+	}
+
+	c.experimentLoaded()
+
+	err = c.batchErrs.ErrorOrNil()
+	if err != nil {
+		c.report.Error = err.Error()
+	}
+	c.report.Succeeded = err == nil
+	if err == nil {
+		c.loadComplete()
+	}
+	return err
+}
+
+// createSteps creates the steps one at a time in the creation order.
+func (c *Creater) createSteps(order []*WorkflowProcess) error {
+	// Leveled loads report their progress as each level is started
+	var levels map[*WorkflowProcess]int
+	if c.CreationOrder == Leveled {
+		levels = processLevels(order)
+	}
+
+	for i, wp := range order {
+		if levels != nil && (i == 0 || levels[wp] != levels[order[i-1]]) {
+			showLevelProgress(levels, levels[wp])
+		}
+
+		if c.resumeStep(wp, c.steps[wp]) {
+			continue
+		}
+
+		if err := c.createWorkflowStep(wp, c.steps[wp]); err != nil {
+			c.steps[wp].Status = StepFailed
+			c.steps[wp].Error = err.Error()
+			return err
+		}
+
+		if err := c.stepCreated(wp); err != nil {
 			return err
 		}
 	}
 
-	fmt.Println("Total calls:", c.Count)
-	fmt.Printf("%#v\n", c.ByCallCounts)
+	return nil
+}
 
-	// Ignore error - doesn't really matter if this succeeds
-	var _ = c.client.UpdateExperimentProgressStatus(c.ProjectID, c.ExperimentID, false)
+// stepCreated records a step that has been created in the load state, verifies it if it is one of the
+// processes to verify and saves the progress.
+func (c *Creater) stepCreated(wp *WorkflowProcess) error {
+	if c.steps[wp].Status != StepCreated {
+		c.saveProgress(false)
+		return nil
+	}
+
+	c.recordStep(wp)
+
+	if c.VerifyEvery > 0 && wp.Worksheet != nil {
+		c.created++
+		if c.created%c.VerifyEvery == 0 {
+			if err := c.verifyProcess(wp); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.saveProgress(false)
 	return nil
 }
 
-// createWorkflowSteps walks the list of steps for a particular workflow item creating the
-// samples and processes.
-func (c *Creater) createWorkflowSteps(wp *WorkflowProcess) error {
+// showLevelProgress prints the level that is about to be created and how many steps it has.
+func showLevelProgress(levels map[*WorkflowProcess]int, level int) {
+	steps, maxLevel := 0, 0
+	for _, l := range levels {
+		if l == level {
+			steps++
+		}
+
+		if l > maxLevel {
+			maxLevel = l
+		}
+	}
+
+	fmt.Printf("Creating level %d of %d (%d steps)\n", level+1, maxLevel+1, steps)
+}
+
+// Report returns the outcome of each step of the last Apply, so that a load that failed part way
+// through can be resumed or rolled back.
+func (c *Creater) Report() *LoadReport {
+	return c.report
+}
+
+// failed records the error that stopped the load in the report and returns it.
+func (c *Creater) failed(err error) error {
+	c.report.Error = err.Error()
+
+	// Even though there were errors the experiment loading is no longer "in progress"
+	c.experimentLoaded()
+	return err
+}
+
+// experimentLoaded marks the experiment as no longer being loaded. Errors are ignored as there
+// is nothing we can do if this fails.
+func (c *Creater) experimentLoaded() {
+	if c.NoExperiment {
+		return
+	}
+
+	c.Schedule.WaitUntilOpen()
+	var _ = c.client.UpdateExperimentProgressStatus(c.ProjectID, c.ExperimentID, false)
+}
+
+// createWorkflowStep creates the sample or process for a step in the workflow. The steps that send
+// samples into it must already have been created. The server IDs are recorded in step.
+func (c *Creater) createWorkflowStep(wp *WorkflowProcess, step *StepReport) error {
+	if wp.ExistingProcessID != "" {
+		if err := c.getExistingProcess(wp); err != nil {
+			return err
+		}
+		step.Status = StepFound
+		return nil
+	}
+
 	if wp.Worksheet == nil {
+		if c.reuseSample(wp, step) {
+			return nil
+		}
+
 		// Creating the sample
-		if sample, err := c.createSample(wp.Samples[0]); err != nil {
+		if sample, err := c.createSample(wp); err != nil {
 			return err
 		} else {
 			wp.Out = append(wp.Out, sample)
+			step.ID = sample.ID
+			step.Status = StepCreated
 		}
 	} else {
 		// Create the process if it doesn't already exist
 		// 1. Find the input sample
 		// 2. Create the process with that input sample and attr
-		if wp.Process == nil {
+		if wp.Process == nil && !c.reconcileStep(wp, step) {
 			// Create the process
-			p, err := c.createProcessWithAttrs(wp.Worksheet, wp.Samples[0].ProcessAttrs)
+			p, err := c.createProcessWithAttrs(wp.ProcessName(), wp.Worksheet, wp.Samples[0].ProcessAttrs)
 			if err != nil {
 				return err
 			}
 
 			wp.Process = p
+			step.ID = p.ID
+			step.Status = StepCreated
 
-			// Add the samples to the process
-			inputSamples := c.getInputSamples(wp)
+			// Add the samples to the process. Samples that have files are added one at a time along with
+			// their files. The rest are added in a single batch call.
+			inputSamples := wp.getInputSamples()
+			var (
+				batch        []*mcapi.Sample
+				measurements []mcapix.SampleMeasurements
+			)
 
 			for _, sample := range inputSamples {
-				worksheetSample := c.findSampleInWorksheet(sample.Name, wp.Worksheet.Samples)
+				worksheetSample := wp.worksheetSample(sample.Name)
+				if s := c.findSampleFromServer(sample.Name, wp.Out); s != nil {
+					// An earlier run added it, see reconcileStep
+					if worksheetSample != nil {
+						measurements = append(measurements, createSampleMeasurements(s, worksheetSample, c.RecordProvenance))
+					}
+					continue
+				}
+
+				if worksheetSample == nil || len(worksheetSample.Files) == 0 {
+					batch = append(batch, sample)
+					continue
+				}
+
 				if s, err := c.addSampleAndFilesToProcess(wp.Process.ID, sample, worksheetSample); err != nil {
+					if isMalformedResponse(err) {
+						c.recordSent(wp, []*mcapi.Sample{sample})
+					}
+					return err
+				} else if err := c.linkFilesToSample(s, worksheetSample); err != nil {
 					return err
 				} else {
 					wp.Out = append(wp.Out, s)
+					measurements = append(measurements, createSampleMeasurements(s, worksheetSample, c.RecordProvenance))
+				}
+			}
 
-					// Add measurements
-					if worksheetSample != nil {
-						if err := c.addMeasurements(wp.Process.ID, s.ID, s.PropertySetID, worksheetSample); err != nil {
-							return err
-						}
+			if len(batch) != 0 {
+				added, err := c.addSamplesToProcessWithRetry(wp.Process, batch)
+				if err != nil {
+					if isMalformedResponse(err) {
+						c.recordSent(wp, batch)
+					}
+					return err
+				}
+
+				step.FailedSamples = missingSamples(batch, added)
+				for _, s := range added {
+					wp.Out = append(wp.Out, s)
+					if worksheetSample := wp.worksheetSample(s.Name); worksheetSample != nil {
+						measurements = append(measurements, createSampleMeasurements(s, worksheetSample, c.RecordProvenance))
 					}
 				}
 			}
+
+			// The measurements for all the samples in the process are added after the workflow is created
+			c.queueMeasurements(wp, step, measurements)
 		}
 
 	}
 
-	// Now walk all the WorkflowProcess steps that it sends samples into
-	// and create those workflow steps. Do this by recursively calling
-	// ourselves (createWorkflowSteps).
-	for _, next := range wp.To {
-		if err := c.createWorkflowSteps(next); err != nil {
-			return err
+	return nil
+}
+
+// getExistingProcess retrieves a process that is already on the server. Its output samples are the
+// samples sent into the processes that name it as their parent, so they must all be there.
+func (c *Creater) getExistingProcess(wp *WorkflowProcess) error {
+	c.AddCount("getProcess")
+	p, err := c.client.GetProcess(c.ProjectID, wp.ExistingProcessID)
+	if err == mcapix.ErrAuth {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("unable to retrieve existing process %s: %s", wp.Name(), err)
+	}
+
+	wp.Process = p
+	for _, name := range wp.sampleNamesFrom() {
+		sample := c.findSampleFromServer(name, p.OutputSamples)
+		if sample == nil {
+			return fmt.Errorf("existing process %s ('%s') has no output sample named '%s'", wp.Name(), p.Name, name)
 		}
+		wp.Out = append(wp.Out, sample)
 	}
 
 	return nil
 }
 
+// AddCount counts an API call. It is called before each API call, so it also pauses the load when
+// the call would be made outside of the Schedule window.
 func (c *Creater) AddCount(what string) {
+	c.Schedule.WaitUntilOpen()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Count++
 	value := c.ByCallCounts[what]
 	value++
 	c.ByCallCounts[what] = value
@@ -144,7 +486,6 @@ func (c *Creater) AddCount(what string) {
 
 // createExperiment will create a new experiment in the given project
 func (c *Creater) createExperiment() error {
-	c.Count++
 	c.AddCount("createExperiment")
 	experiment, err := c.client.CreateExperiment(c.ProjectID, c.Name, c.Description, true)
 	if err != nil {
@@ -155,11 +496,207 @@ func (c *Creater) createExperiment() error {
 	return nil
 }
 
-// createProcessWithAttrs will create a new process with the given set of process attributes.
-func (c *Creater) createProcessWithAttrs(process *model.Worksheet, attrs []*model.Attribute) (*mcapi.Process, error) {
-	c.Count++
+// createProcessWithAttrs will create a new process with the given name and set of process attributes.
+func (c *Creater) createProcessWithAttrs(name string, process *model.Worksheet, attrs []*model.Attribute) (*mcapi.Process, error) {
 	c.AddCount("createProcessWithAttrs")
 	//return &mcapi.Process{}, nil
+	setup := createConditionsSetup(attrs)
+
+	// The process type is the worksheet name, unless an index sheet gave the worksheet a template. Since there
+	// are a limited number of worksheets the assumption is that all processes created from a particular worksheet
+	// are equivalent. The name distinguishes the processes from the same worksheet.
+	if performed, ok := processDate(process, attrs); ok {
+		// Date the process by when it was performed so the experiment timeline matches the lab's
+		return c.client.CreateProcessPerformedAt(c.ProjectID, c.ExperimentID, name, process.ProcessType(), []mcapi.Setup{setup}, performed)
+	}
+
+	return c.client.CreateProcess(c.ProjectID, c.ExperimentID, name, process.ProcessType(), []mcapi.Setup{setup})
+}
+
+// createSample creates a new sample in the project on the server. If the Create Samples process has
+// a name or setup attributes then the sample is created in a process with that name and setup.
+func (c *Creater) createSample(wp *WorkflowProcess) (*mcapi.Sample, error) {
+	c.AddCount("createSample")
+	sample := wp.Samples[0]
+	if c.CreateProcessName == "" && len(wp.CreateAttrs) == 0 {
+		return c.client.CreateSample(c.ProjectID, c.ExperimentID, sample.Name, nil)
+	}
+
+	process := mcapix.CreateSampleProcess{
+		Name:   c.CreateProcessName,
+		Setups: []mcapi.Setup{createConditionsSetup(wp.CreateAttrs)},
+	}
+	return c.client.CreateSampleInProcess(c.ProjectID, c.ExperimentID, sample.Name, nil, process)
+}
+
+// createSampleMeasurements creates the measurements from the model.Sample for the server side sample/property set.
+// In the workflow a model.Sample contains all the measurements for a sample reference in the spreadsheet.
+func createSampleMeasurements(s *mcapi.Sample, sample *model.Sample, provenance bool) mcapix.SampleMeasurements {
+	return mcapix.SampleMeasurements{
+		SampleID:      s.ID,
+		PropertySetID: s.PropertySetID,
+		Attributes:    createAttributeMeasurements(sample.Attributes, provenance),
+	}
+}
+
+// missingSamples returns the names of the samples that weren't added.
+func missingSamples(samples, added []*mcapi.Sample) []string {
+	addedNames := make(map[string]bool)
+	for _, sample := range added {
+		addedNames[sample.Name] = true
+	}
+
+	var missing []string
+	for _, sample := range samples {
+		if !addedNames[sample.Name] {
+			missing = append(missing, sample.Name)
+		}
+	}
+
+	return missing
+}
+
+// queueMeasurements queues the measurements for the samples in a process, split into batches of up to
+// measurementBatchSize samples. step is the report the step is being created with, the outcome of the
+// measurements is recorded in the step's report in the load report.
+func (c *Creater) queueMeasurements(wp *WorkflowProcess, step *StepReport, measurements []mcapix.SampleMeasurements) {
+	if len(measurements) != 0 {
+		step.Measurements = StepNotAttempted
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for start := 0; start < len(measurements); start += measurementBatchSize {
+		end := start + measurementBatchSize
+		if end > len(measurements) {
+			end = len(measurements)
+		}
+		c.measurementQueue = append(c.measurementQueue, measurementBatch{process: wp.Process, step: c.steps[wp], key: stateKey(wp), measurements: measurements[start:end]})
+	}
+}
+
+// addQueuedMeasurements adds the queued measurements using MeasurementWorkers concurrent workers. A batch
+// that fails doesn't stop the other batches being added, the failures are returned together. An
+// authentication error stops the workers taking any more batches as every call would fail.
+func (c *Creater) addQueuedMeasurements() error {
+	workers := c.MeasurementWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		batches  = make(chan measurementBatch)
+		stop     = make(chan struct{})
+		stopOnce sync.Once
+		wg       sync.WaitGroup
+		errsMu   sync.Mutex
+		errs     *multierror.Error
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer c.saveProgressOnPanic()
+			for batch := range batches {
+				err := c.addMeasurementBatch(batch)
+				c.recordMeasurements(batch.step, err)
+				c.saveProgress(false)
+				if err == nil {
+					c.recordMeasured(batch)
+					continue
+				}
+
+				if err == mcapix.ErrAuth {
+					stopOnce.Do(func() { close(stop) })
+				} else {
+					err = fmt.Errorf("unable to add measurements to process '%s' (id %s): %s", batch.process.Name, batch.process.ID, err)
+				}
+
+				errsMu.Lock()
+				errs = multierror.Append(errs, err)
+				errsMu.Unlock()
+			}
+		}()
+	}
+
+queue:
+	for _, batch := range c.measurementQueue {
+		select {
+		case batches <- batch:
+		case <-stop:
+			break queue
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	return errs.ErrorOrNil()
+}
+
+// addMeasurementBatch adds a batch of measurements in a single bulk call. If the server answers that it
+// doesn't support bulk measurements then the measurements are added one sample at a time for the rest of
+// the load. Any other error is returned, it isn't a reason to stop using bulk calls.
+func (c *Creater) addMeasurementBatch(batch measurementBatch) error {
+	if !c.bulkMeasurementsFailed() {
+		err := c.addBulkMeasurements(batch.process.ID, batch.measurements)
+		if !mcapix.IsUnsupported(err) {
+			return err
+		}
+
+		c.mu.Lock()
+		if !c.noBulkMeasurements {
+			console.Warning("the server doesn't support adding measurements in bulk, adding them one sample at a time:", err)
+			c.noBulkMeasurements = true
+		}
+		c.mu.Unlock()
+	}
+
+	for _, sm := range batch.measurements {
+		if err := c.addMeasurements(batch.process.ID, sm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordMeasurements records whether a batch of measurements for a process was added in its report. If
+// any batch fails the process's measurements have failed.
+func (c *Creater) recordMeasurements(step *StepReport, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err != nil:
+		step.Measurements = StepFailed
+		step.MeasurementsError = err.Error()
+	case step.Measurements != StepFailed:
+		step.Measurements = MeasurementsAdded
+	}
+}
+
+func (c *Creater) bulkMeasurementsFailed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.noBulkMeasurements
+}
+
+// addBulkMeasurements adds the measurements for several samples in a process in a single call.
+func (c *Creater) addBulkMeasurements(processID string, measurements []mcapix.SampleMeasurements) error {
+	c.AddCount("addBulkMeasurements")
+	return c.client.AddMeasurementsToSamplesInProcess(c.ProjectID, c.ExperimentID, processID, measurements)
+}
+
+// addMeasurements adds measurements for a single sample/property set to the server side process.
+func (c *Creater) addMeasurements(processID string, sm mcapix.SampleMeasurements) error {
+	c.AddCount("addMeasurements")
+	_, err := c.client.AddMeasurementsToSampleInProcess(c.ProjectID, c.ExperimentID, processID, false, sm)
+	return err
+}
+
+// createConditionsSetup creates the "Conditions" setup for a process from the given process
+// attributes. Attributes without a value are skipped.
+func createConditionsSetup(attrs []*model.Attribute) mcapi.Setup {
 	setup := mcapi.Setup{
 		Name:      "Conditions",
 		Attribute: "conditions",
@@ -178,59 +715,36 @@ func (c *Creater) createProcessWithAttrs(process *model.Worksheet, attrs []*mode
 		}
 	}
 
-	// The second process.Name is the process type. For ETL we set the type to the process name which is the
-	// same as the worksheet name. Since there are a limited number of worksheets the assumption is that all
-	// processe created from a particular worksheet are equivalent.
-	return c.client.CreateProcess(c.ProjectID, c.ExperimentID, process.Name, process.Name, []mcapi.Setup{setup})
-}
-
-// createSample creates a new sample in the project on the server.
-func (c *Creater) createSample(sample *model.Sample) (*mcapi.Sample, error) {
-	c.Count++
-	c.AddCount("createSample")
-	return c.client.CreateSample(c.ProjectID, c.ExperimentID, sample.Name, nil)
-}
-
-// addMeasurements adds measurements from the model.Sample to the server side process and sample/property set.
-// In the workflow a model.Sample contains all the measurements for a sample reference in the spreadsheet.
-func (c *Creater) addMeasurements(processID string, sampleID, propertySetID string, sample *model.Sample) error {
-	c.Count++
-	c.AddCount("addMeasurements")
-	//return nil
-	attrs := c.createAttributeMeasurements(sample.Attributes)
-
-	sm := mcapi.SampleMeasurements{
-		SampleID:      sampleID,
-		PropertySetID: propertySetID,
-		Attributes:    attrs,
-	}
-
-	_, err := c.client.AddMeasurementsToSampleInProcess(c.ProjectID, c.ExperimentID, processID, false, sm)
-	return err
+	return setup
 }
 
 // createAttributeMeasurements iterates over the list of sample attributes creating a single
 // SampleProperty for each attribute and merging the other attributes that match that name
-// as separate measurements of that attribute.
-func (c *Creater) createAttributeMeasurements(attrs []*model.Attribute) []mcapi.SampleProperty {
-	samplePropertiesMap := make(map[string]*mcapi.SampleProperty)
+// as separate measurements of that attribute. If provenance is true each measurement's metadata
+// records the cell it was read from.
+func createAttributeMeasurements(attrs []*model.Attribute, provenance bool) []mcapix.SampleProperty {
+	samplePropertiesMap := make(map[string]*mcapix.SampleProperty)
 	for _, attr := range attrs {
 		sp, ok := samplePropertiesMap[attr.Name]
 		if !ok {
-			sp = &mcapi.SampleProperty{Name: attr.Name}
+			sp = &mcapix.SampleProperty{Name: attr.Name}
 			samplePropertiesMap[attr.Name] = sp
 		}
 
-		m := mcapi.Measurement{
+		m := mcapix.Measurement{
 			Unit:  attr.Unit,
 			Value: attr.Value["value"],
 			OType: "object",
 		}
 
+		if provenance && attr.Source != nil {
+			m.Metadata = provenanceMetadata(attr.Source)
+		}
+
 		sp.Measurements = append(sp.Measurements, m)
 	}
 
-	var sampleProperties []mcapi.SampleProperty
+	var sampleProperties []mcapix.SampleProperty
 
 	for key := range samplePropertiesMap {
 		sampleProperties = append(sampleProperties, *samplePropertiesMap[key])
@@ -239,16 +753,17 @@ func (c *Creater) createAttributeMeasurements(attrs []*model.Attribute) []mcapi.
 	return sampleProperties
 }
 
-// findSample finds the model.Sample that corresponds to the server side sample. Matching is based
-// on name as each sample in the worksheets will have a unique name.
-func (c *Creater) findSampleInWorksheet(sampleName string, samples []*model.Sample) *model.Sample {
-	for _, sample := range samples {
-		if sample.Name == sampleName {
-			return sample
-		}
+// provenanceMetadata describes the cell a measurement was read from.
+func provenanceMetadata(source *model.CellRef) map[string]interface{} {
+	return map[string]interface{}{
+		"source": map[string]interface{}{
+			"file":   source.File,
+			"sheet":  source.Sheet,
+			"row":    source.Row,
+			"column": source.Column,
+			"cell":   source.Cell(),
+		},
 	}
-
-	return nil
 }
 
 func (c *Creater) findSampleFromServer(sampleName string, samples []*mcapi.Sample) *mcapi.Sample {
@@ -264,7 +779,6 @@ func (c *Creater) findSampleFromServer(sampleName string, samples []*mcapi.Sampl
 // addSampleAndFilesToProcess will add the sample and associated files to the process on the server. It hides the details
 // of constructing the go-mcapi call.
 func (c *Creater) addSampleAndFilesToProcess(processID string, sample *mcapi.Sample, worksheetSample *model.Sample) (*mcapi.Sample, error) {
-	c.Count++
 	c.AddCount("addSampleAndFilesToProcess")
 	//return &mcapi.Sample{}, nil
 	connect := mcapi.ConnectSampleAndFilesToProcess{
@@ -278,17 +792,57 @@ func (c *Creater) addSampleAndFilesToProcess(processID string, sample *mcapi.Sam
 		for _, file := range worksheetSample.Files {
 			f := mcapi.FileAndDirection{
 				Path:      file.Path,
-				Direction: "in",
+				Direction: file.Direction,
 			}
 			connect.FilesByName = append(connect.FilesByName, f)
 		}
 	}
-	s, err := c.client.AddSampleAndFilesToProcess(c.ProjectID, c.ExperimentID, false, connect)
-	return s, err
+	return c.client.AddSampleAndFilesToProcess(c.ProjectID, c.ExperimentID, false, connect)
+}
+
+// linkFilesToSample links the files for the sample directly to it when LinkFilesToSamples is set. When the
+// server answers that it doesn't support linking files to samples a warning is printed and files are only
+// linked to processes for the rest of the load. Any other error is returned.
+func (c *Creater) linkFilesToSample(sample *mcapi.Sample, worksheetSample *model.Sample) error {
+	c.mu.Lock()
+	noSampleFiles := c.noSampleFiles
+	c.mu.Unlock()
+	if !c.LinkFilesToSamples || noSampleFiles {
+		return nil
+	}
+
+	var paths []string
+	for _, file := range worksheetSample.Files {
+		if file.Path != "" {
+			paths = append(paths, file.Path)
+		}
+	}
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	c.AddCount("addFilesToSample")
+	err := c.client.AddFilesToSample(c.ProjectID, sample.ID, paths)
+	switch {
+	case err == nil:
+		return nil
+	case err == mcapix.ErrAuth:
+		return err
+	case mcapix.IsUnsupported(err):
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if !c.noSampleFiles {
+			console.Warning("the server doesn't support linking files to samples, files will only be linked to their processes:", err)
+			c.noSampleFiles = true
+		}
+		return nil
+	default:
+		return fmt.Errorf("unable to link files to sample '%s' (id %s): %s", sample.Name, sample.ID, err)
+	}
 }
 
 func (c *Creater) addSamplesToProcess(processID string, samples []*mcapi.Sample) ([]*mcapi.Sample, error) {
-	c.Count++
 	c.AddCount("addSamplesToProcess")
 	connect := mcapi.ConnectSamplesToProcess{
 		ProcessID: processID,
@@ -311,22 +865,53 @@ func (c *Creater) addSamplesToProcess(processID string, samples []*mcapi.Sample)
 
 	// API call returns []mcapi.Sample, we need to return []*mcapi.Sample
 	var transformUpdatedSamples []*mcapi.Sample
-	for _, sample := range updatedSamples {
-		transformUpdatedSamples = append(transformUpdatedSamples, &sample)
+	for i := range updatedSamples {
+		transformUpdatedSamples = append(transformUpdatedSamples, &updatedSamples[i])
 	}
 
 	return transformUpdatedSamples, nil
 }
 
-// getInputSamples goes to the parent workflow processes and constructs the list
-// of samples that are input into the workflow process (in this case the wp
-// parameter).
-func (c *Creater) getInputSamples(wp *WorkflowProcess) []*mcapi.Sample {
-	var samples []*mcapi.Sample
-	// A WorkflowProcess contains a pointer to its parent workflow processes, this allows
-	// it to retrieve all samples from the parent workflow process steps.
-	for _, parentWorkflow := range wp.From {
-		samples = append(samples, parentWorkflow.Out...)
+// addSamplesToProcessWithRetry adds the samples to the process in a single batch call. If the call fails
+// the batch is split in half and each half is retried. This continues until the samples that can't be
+// added have been isolated. Each sample that can't be added is recorded in batchErrs and the samples that
+// were added are returned so the workflow can continue with them. Authentication errors and malformed
+// responses are not retried and are returned immediately.
+func (c *Creater) addSamplesToProcessWithRetry(process *mcapi.Process, samples []*mcapi.Sample) ([]*mcapi.Sample, error) {
+	added, err := c.addSamplesToProcess(process.ID, samples)
+	switch {
+	case err == nil:
+		return added, nil
+	case err == mcapix.ErrAuth:
+		return nil, err
+	case isMalformedResponse(err):
+		// The server may have added the samples, retrying could add them twice
+		return nil, err
+	case len(samples) == 1:
+		e := fmt.Errorf("unable to add sample '%s' (id %s) to process '%s' (id %s): %s",
+			samples[0].Name, samples[0].ID, process.Name, process.ID, err)
+		console.Warning(e)
+		c.mu.Lock()
+		c.batchErrs = multierror.Append(c.batchErrs, e)
+		c.mu.Unlock()
+		return nil, nil
+	}
+
+	mid := len(samples) / 2
+	console.Warningf("adding %d samples to process '%s' failed, retrying in batches of %d and %d\n",
+		len(samples), process.Name, mid, len(samples)-mid)
+
+	first, err := c.addSamplesToProcessWithRetry(process, samples[:mid])
+	if err != nil {
+		return first, err
 	}
-	return samples
+
+	second, err := c.addSamplesToProcessWithRetry(process, samples[mid:])
+	return append(first, second...), err
+}
+
+// isMalformedResponse returns true if err is a response from the server that is missing ids.
+func isMalformedResponse(err error) bool {
+	_, ok := err.(*mcapix.MalformedResponseError)
+	return ok
 }
@@ -0,0 +1,128 @@
+package processor_test
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/materials-commons/mcetl/internal/mcapix"
+	"github.com/materials-commons/mcetl/internal/selftest"
+	"github.com/materials-commons/mcetl/internal/spreadsheet"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+)
+
+// testSheet is a worksheet to load, the first row is the header.
+type testSheet struct {
+	name string
+	rows [][]interface{}
+}
+
+// heatTreatmentStudy has 3 heat treated samples, 2 of which go on to SEM. Each sample gets a process
+// of its own, so there are 5 processes.
+var heatTreatmentStudy = []testSheet{
+	{
+		name: "Heat Treatment",
+		rows: [][]interface{}{
+			{"sample", "parent", "p:Temperature(c)", "s:Hardness(HV)"},
+			{"S1", "", 400, 300},
+			{"S2", "", 400, 310},
+			{"S3", "", 500, 280},
+		},
+	},
+	{
+		name: "SEM",
+		rows: [][]interface{}{
+			{"sample", "parent", "p:Voltage(kV)", "s:Grain Size(um)"},
+			{"S1", "Heat Treatment", 20, 12.5},
+			{"S3", "Heat Treatment", 20, 15.1},
+		},
+	},
+}
+
+// loadWorksheets writes sheets to a workbook and loads it with has-parent.
+func loadWorksheets(t *testing.T, sheets []testSheet) []*model.Worksheet {
+	t.Helper()
+	xlsx := excelize.NewFile()
+	for i, sheet := range sheets {
+		if i == 0 {
+			xlsx.SetSheetName("Sheet1", sheet.name)
+		} else {
+			xlsx.NewSheet(sheet.name)
+		}
+
+		for row, cells := range sheet.rows {
+			for column, value := range cells {
+				xlsx.SetCellValue(sheet.name, fmt.Sprintf("%s%d", excelize.ToAlphaString(column), row+1), value)
+			}
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "study.xlsx")
+	if err := xlsx.SaveAs(path); err != nil {
+		t.Fatalf("unable to write workbook: %s", err)
+	}
+
+	worksheets, err := spreadsheet.NewLoader(true, nil, []string{path}).Load()
+	if err != nil {
+		t.Fatalf("unable to load workbook: %s", err)
+	}
+
+	return worksheets
+}
+
+// newTestCreater returns a Creater that loads into server.
+func newTestCreater(t *testing.T, server *selftest.MockServer) *processor.Creater {
+	t.Helper()
+	client := mcapix.NewClient(server.Start())
+	client.APIKey = "test"
+	t.Cleanup(server.Close)
+
+	c := processor.NewCreater("project", "study", "", client)
+	c.WorkflowOptions = processor.WorkflowOptions{HasParent: true}
+	return c
+}
+
+func TestBulkMeasurementsUnsupportedFallsBackToOneSampleAtATime(t *testing.T) {
+	server := selftest.NewMockServer()
+	server.Fail = map[string]int{"addMeasurementsToSamplesInProcess": http.StatusNotFound}
+	c := newTestCreater(t, server)
+	c.MeasurementWorkers = 1
+
+	if err := c.Apply(loadWorksheets(t, heatTreatmentStudy)); err != nil {
+		t.Fatalf("Apply failed: %s", err)
+	}
+
+	if n := len(server.CallsTo("addMeasurementsToSamplesInProcess")); n != 1 {
+		t.Errorf("made %d bulk calls, want 1 before falling back", n)
+	}
+	if n := len(server.CallsTo("addMeasurementsToSampleInProcess")); n != 5 {
+		t.Errorf("made %d per sample calls, want one for each of the 5 measured samples", n)
+	}
+}
+
+func TestBulkMeasurementsOtherErrorsAreReturned(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusBadRequest} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			server := selftest.NewMockServer()
+			server.Fail = map[string]int{"addMeasurementsToSamplesInProcess": status}
+			c := newTestCreater(t, server)
+			c.MeasurementWorkers = 1
+
+			if err := c.Apply(loadWorksheets(t, heatTreatmentStudy)); err == nil {
+				t.Fatal("Apply succeeded, want the bulk call's error")
+			}
+
+			// Each process's batch is tried in bulk, a 500 or a batch rejected as invalid isn't a reason to
+			// stop using bulk calls
+			if n := len(server.CallsTo("addMeasurementsToSamplesInProcess")); n != 5 {
+				t.Errorf("made %d bulk calls, want one for each of the 5 processes", n)
+			}
+			if n := len(server.CallsTo("addMeasurementsToSampleInProcess")); n != 0 {
+				t.Errorf("made %d per sample calls, want none", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// The orders the workflow processes can be created in. Each is a valid creation order, where a process
+// comes after the processes that send samples into it, they differ in what is created first.
+const (
+	// DepthFirst follows the samples through the workflow, so the full lineage of the first samples
+	// is on the server early in the load. It is the default, and is recommended for most loads as a
+	// load that fails part way through leaves complete lineages behind.
+	DepthFirst = "depth-first"
+
+	// BreadthFirst creates the processes in the order they are reached from the Create Samples
+	// processes, so all of the samples are created before the processes that use them.
+	BreadthFirst = "breadth-first"
+
+	// Leveled creates the processes one level of the workflow at a time, where a process's level is
+	// one more than the highest level of its parents. Every process at a level is created before any
+	// process at the next level, which gives the most useful progress reporting for large loads.
+	Leveled = "leveled"
+)
+
+// ValidCreationOrder returns an error if order isn't one of the creation orders. A blank order is
+// depth first.
+func ValidCreationOrder(order string) error {
+	switch order {
+	case "", DepthFirst, BreadthFirst, Leveled:
+		return nil
+	default:
+		return fmt.Errorf("unknown creation order '%s', use '%s', '%s' or '%s'", order, DepthFirst, BreadthFirst, Leveled)
+	}
+}
+
+// creationOrder returns the workflow processes in the order they must be created, using the
+// CreationOrder option to choose between the valid orders.
+func (w *Workflow) creationOrder() ([]*WorkflowProcess, error) {
+	order, err := w.depthFirstOrder()
+	if err != nil {
+		return nil, err
+	}
+
+	switch w.CreationOrder {
+	case BreadthFirst:
+		return w.breadthFirstOrder(), nil
+	case Leveled:
+		return leveledOrder(order), nil
+	default:
+		return order, nil
+	}
+}
+
+// depthFirstOrder returns the workflow processes in the order they must be created: a process comes after
+// every process that sends samples into it. A process with several parents takes samples from each of
+// them, so it can't be created until all of its parents have been created and their output samples exist.
+// Walking the workflow depth first from each Create Samples process doesn't guarantee that. Existing
+// processes are in the order too, the Creater looks them up rather than creating them.
+//
+// The order is otherwise kept as close as possible to the order of the depth first walk, so processes
+// are still created in the order their samples flow through the workflow. An error is returned if the
+// parents form a cycle, for example two worksheets that each name the other as the parent of a sample.
+func (w *Workflow) depthFirstOrder() ([]*WorkflowProcess, error) {
+	waitingOn := w.parentCounts()
+
+	var (
+		order   []*WorkflowProcess
+		created = make(map[*WorkflowProcess]bool)
+		visit   func(wp *WorkflowProcess)
+	)
+
+	// visit creates wp and then, depth first, each child whose parents have now all been created.
+	visit = func(wp *WorkflowProcess) {
+		created[wp] = true
+		order = append(order, wp)
+		for _, next := range uniqueProcesses(wp.To) {
+			waitingOn[next]--
+			if waitingOn[next] == 0 && !created[next] {
+				visit(next)
+			}
+		}
+	}
+
+	// Existing processes come first, they only need to be looked up
+	for _, wp := range append(append([]*WorkflowProcess{}, w.existing...), w.root...) {
+		if !created[wp] && waitingOn[wp] == 0 {
+			visit(wp)
+		}
+	}
+
+	if len(order) != len(waitingOn) {
+		var names []string
+		for wp := range waitingOn {
+			if !created[wp] {
+				names = append(names, wp.Name())
+			}
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("the parent columns form a cycle, unable to order processes: %s", strings.Join(names, ", "))
+	}
+
+	return order, nil
+}
+
+// breadthFirstOrder returns the workflow processes in the order they are reached from the existing and
+// Create Samples processes, a process being reached once all of its parents have been. The parents must
+// not form a cycle, which depthFirstOrder checks.
+func (w *Workflow) breadthFirstOrder() []*WorkflowProcess {
+	waitingOn := w.parentCounts()
+
+	var order []*WorkflowProcess
+	for _, wp := range append(append([]*WorkflowProcess{}, w.existing...), w.root...) {
+		if waitingOn[wp] == 0 {
+			order = append(order, wp)
+		}
+	}
+
+	// order is also the queue of processes whose children haven't been visited
+	for i := 0; i < len(order); i++ {
+		for _, next := range uniqueProcesses(order[i].To) {
+			waitingOn[next]--
+			if waitingOn[next] == 0 {
+				order = append(order, next)
+			}
+		}
+	}
+
+	return order
+}
+
+// leveledOrder sorts a creation order by level, keeping the order of the processes within a level.
+func leveledOrder(order []*WorkflowProcess) []*WorkflowProcess {
+	levels := processLevels(order)
+	leveled := append([]*WorkflowProcess{}, order...)
+	sort.SliceStable(leveled, func(i, j int) bool {
+		return levels[leveled[i]] < levels[leveled[j]]
+	})
+
+	return leveled
+}
+
+// processLevels returns the level of each process in a creation order. Processes without parents are
+// level 0, any other process is one more than the highest level of its parents.
+func processLevels(order []*WorkflowProcess) map[*WorkflowProcess]int {
+	levels := make(map[*WorkflowProcess]int)
+	for _, wp := range order {
+		levels[wp] = 0
+		for _, parent := range wp.From {
+			if levels[parent]+1 > levels[wp] {
+				levels[wp] = levels[parent] + 1
+			}
+		}
+	}
+
+	return levels
+}
+
+// parentCounts returns the number of distinct parents of each process. A process is wired to the same
+// parent once for each sample row, so From can contain a parent more than once.
+func (w *Workflow) parentCounts() map[*WorkflowProcess]int {
+	counts := make(map[*WorkflowProcess]int)
+	for _, wp := range w.allProcesses() {
+		counts[wp] = len(uniqueProcesses(wp.From))
+	}
+
+	return counts
+}
+
+// allProcesses returns the existing processes, the Create Samples processes and the processes from
+// the worksheets.
+func (w *Workflow) allProcesses() []*WorkflowProcess {
+	processes := append([]*WorkflowProcess{}, w.existing...)
+	processes = append(processes, w.root...)
+	for _, wp := range w.uniqueProcessInstances {
+		processes = append(processes, wp)
+	}
+
+	return processes
+}
+
+// uniqueProcesses returns the processes with duplicates removed, keeping the first of each.
+func uniqueProcesses(processes []*WorkflowProcess) []*WorkflowProcess {
+	var unique []*WorkflowProcess
+	seen := make(map[*WorkflowProcess]bool)
+	for _, wp := range processes {
+		if !seen[wp] {
+			seen[wp] = true
+			unique = append(unique, wp)
+		}
+	}
+
+	return unique
+}
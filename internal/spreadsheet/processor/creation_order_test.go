@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"fmt"
+	"testing"
+)
+
+// largeWorkflow returns a workflow where each of the samples goes through steps processes of its own, and
+// every 10 samples then go into a process together, so there are processes with many parents.
+func largeWorkflow(samples, steps int) *Workflow {
+	w := newWorkflow()
+	var merge *WorkflowProcess
+	for i := 0; i < samples; i++ {
+		wp := newWorkflowProcess()
+		w.root = append(w.root, wp)
+
+		for step := 0; step < steps; step++ {
+			next := newWorkflowProcess()
+			wp.To = append(wp.To, next)
+			next.From = append(next.From, wp)
+			w.uniqueProcessInstances[fmt.Sprintf("%d/%d", i, step)] = next
+			wp = next
+		}
+
+		if i%10 == 0 {
+			merge = newWorkflowProcess()
+			w.uniqueProcessInstances[fmt.Sprintf("merge/%d", i)] = merge
+		}
+		wp.To = append(wp.To, merge)
+		merge.From = append(merge.From, wp)
+	}
+
+	return w
+}
+
+func BenchmarkCreationOrder(b *testing.B) {
+	for _, size := range []struct{ samples, steps int }{{1000, 5}, {10000, 5}, {1000, 50}} {
+		for _, order := range []string{DepthFirst, BreadthFirst, Leveled} {
+			w := largeWorkflow(size.samples, size.steps)
+			w.CreationOrder = order
+			b.Run(fmt.Sprintf("%s/%dx%d", order, size.samples, size.steps), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := w.creationOrder(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -7,21 +7,51 @@ import (
 	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
 )
 
-type Displayer struct{}
+type Displayer struct {
+	// Options for constructing the workflow that is displayed
+	WorkflowOptions
+
+	// Lineage turns on displaying the property sets each sample would accumulate
+	Lineage bool
+
+	// ProcessDiff turns on displaying the process instances in each worksheet and the attribute
+	// values that differ between them
+	ProcessDiff bool
+
+	// Coverage turns on displaying a matrix of the samples against the attributes in each worksheet,
+	// showing which values are missing
+	Coverage bool
+
+	// HideWorksheets turns off displaying the samples and attributes in each worksheet, so only the
+	// workflow is shown
+	HideWorksheets bool
+}
 
 func NewDisplayer() *Displayer {
 	return &Displayer{}
 }
 
 func (d *Displayer) Apply(worksheets []*model.Worksheet) error {
-	d.printWorksheets(worksheets)
+	if !d.HideWorksheets {
+		d.printWorksheets(worksheets)
+	}
 	d.printWorkflow(worksheets)
+	if d.Lineage {
+		d.printLineage(worksheets)
+	}
+	if d.ProcessDiff {
+		d.printProcessDiff(worksheets)
+	}
+	if d.Coverage {
+		d.printCoverage(worksheets)
+	}
 	return nil
 }
 
 func (d *Displayer) printWorksheets(worksheets []*model.Worksheet) {
 	for _, worksheet := range worksheets {
-		fmt.Println("Worksheet", worksheet.Name)
+		fmt.Println("Worksheet", worksheet.Source())
+		d.printHeaderRow(worksheet)
 		fmt.Printf("%sProcess Attributes:\n", spaces(4))
 		for _, sample := range worksheet.Samples {
 			fmt.Printf("%sAssociated with sample %s\n", spaces(6), sample.Name)
@@ -32,7 +62,7 @@ func (d *Displayer) printWorksheets(worksheets []*model.Worksheet) {
 			if len(sample.Files) != 0 {
 				fmt.Printf("%sFiles associated with process:\n", spaces(6))
 				for _, file := range sample.Files {
-					fmt.Printf("%s%s\n", spaces(8), file.Path)
+					fmt.Printf("%s%s (%s)\n", spaces(8), file.Path, file.Direction)
 				}
 			}
 		}
@@ -54,6 +84,7 @@ func (d *Displayer) printWorksheets(worksheets []*model.Worksheet) {
 func (d *Displayer) printWorkflow(worksheets []*model.Worksheet) {
 	fmt.Println("======= workflow =======")
 	wf := newWorkflow()
+	wf.WorkflowOptions = d.WorkflowOptions
 	wf.constructWorkflow(worksheets)
 	fmt.Println("Create samples:")
 	for _, wp := range wf.root {
@@ -62,11 +93,23 @@ func (d *Displayer) printWorkflow(worksheets []*model.Worksheet) {
 			d.printWorkflowSteps(4, wp)
 		}
 	}
+
+	if len(wf.existing) != 0 {
+		fmt.Println("Existing processes:")
+		for _, wp := range wf.existing {
+			d.printWorkflowSteps(2, wp)
+		}
+	}
 }
 
 func (d *Displayer) printWorkflowSteps(indent int, wp *WorkflowProcess) {
-	if wp.Worksheet != nil {
-		fmt.Printf("%s%s", spaces(indent), wp.Worksheet.Name)
+	if wp.ExistingProcessID != "" {
+		fmt.Printf("%s%s: %s", spaces(indent), wp.Name(), strings.Join(wp.sampleNamesFrom(), ", "))
+	} else if wp.Worksheet != nil {
+		fmt.Printf("%s%s", spaces(indent), wp.ProcessName())
+		if wp.Worksheet.File != "" {
+			fmt.Printf(" (%s)", wp.Worksheet.File)
+		}
 	} else {
 		fmt.Printf("%sCreate Sample: %s", spaces(indent), wp.Samples[0].Name)
 	}
@@ -77,6 +120,149 @@ func (d *Displayer) printWorkflowSteps(indent int, wp *WorkflowProcess) {
 	fmt.Println("")
 }
 
+// printLineage simulates the transforms the Creater performs and shows, for each sample, the sequence
+// of property sets it would accumulate. Creating a sample gives it its first property set, and each
+// process the sample goes through creates a new property set. Measurements and files from a worksheet
+// are attached to the property set the process outputs. When a sample is sent from one process into
+// several processes its lineage branches.
+func (d *Displayer) printLineage(worksheets []*model.Worksheet) {
+	fmt.Println("======= lineage =======")
+	wf := newWorkflow()
+	wf.WorkflowOptions = d.WorkflowOptions
+	wf.constructWorkflow(worksheets)
+
+	// Build the list of processes each sample is sent into from each process. A sample that appears on
+	// several rows with the same process attributes is wired up once per row, so remove duplicates.
+	type sampleInProcess struct {
+		wp         *WorkflowProcess
+		sampleName string
+	}
+	next := make(map[sampleInProcess][]*WorkflowProcess)
+	seen := make(map[sampleInProcess]map[*WorkflowProcess]bool)
+	for _, edge := range wf.edges {
+		from := sampleInProcess{wp: edge.From, sampleName: edge.SampleName}
+		if seen[from] == nil {
+			seen[from] = make(map[*WorkflowProcess]bool)
+		}
+
+		if !seen[from][edge.To] {
+			seen[from][edge.To] = true
+			next[from] = append(next[from], edge.To)
+		}
+	}
+
+	var printSteps func(indent int, wp *WorkflowProcess, sampleName string, propertySet *int)
+	printSteps = func(indent int, wp *WorkflowProcess, sampleName string, propertySet *int) {
+		*propertySet++
+		fmt.Printf("%sProperty set %d: %s\n", spaces(indent), *propertySet, wp.Name())
+		for _, attr := range wp.CreateAttrs {
+			fmt.Printf("%sSetup ", spaces(indent+2))
+			d.showAttr(0, attr)
+		}
+
+		if wp.Worksheet != nil {
+			if sample := wp.worksheetSample(sampleName); sample != nil {
+				for _, attr := range sample.Attributes {
+					fmt.Printf("%sMeasurement ", spaces(indent+2))
+					d.showAttr(0, attr)
+				}
+
+				for _, file := range sample.Files {
+					fmt.Printf("%sFile %s (%s)\n", spaces(indent+2), file.Path, file.Direction)
+				}
+			}
+		}
+
+		for _, to := range next[sampleInProcess{wp: wp, sampleName: sampleName}] {
+			printSteps(indent+2, to, sampleName, propertySet)
+		}
+	}
+
+	for _, wp := range wf.root {
+		sampleName := wp.Samples[0].Name
+		fmt.Printf("%sSample %s\n", spaces(2), sampleName)
+		propertySet := 0
+		printSteps(4, wp, sampleName, &propertySet)
+	}
+
+	// Samples from existing processes already have property sets on the server, the numbering
+	// starts from the property set the existing process output.
+	for _, wp := range wf.existing {
+		for _, sampleName := range wp.sampleNamesFrom() {
+			fmt.Printf("%sSample %s (from %s)\n", spaces(2), sampleName, wp.Name())
+			propertySet := 0
+			printSteps(4, wp, sampleName, &propertySet)
+		}
+	}
+}
+
+// printProcessDiff shows, for each worksheet, the distinct processes that will be created and the process
+// attribute values that differ between them. Attributes that are the same in every process are shown once.
+// This lets the user check that processes were split where they intended.
+func (d *Displayer) printProcessDiff(worksheets []*model.Worksheet) {
+	fmt.Println("======= process instances =======")
+	wf := newWorkflow()
+	wf.WorkflowOptions = d.WorkflowOptions
+	wf.constructWorkflow(worksheets)
+
+	for _, worksheet := range worksheets {
+		processes := wf.processesInWorksheet(worksheet)
+		if len(processes) == 0 {
+			continue
+		}
+
+		varying := wf.varyingProcessAttrs(processes)
+		fmt.Printf("Worksheet %s: %d process(es)\n", worksheet.Source(), len(processes))
+		if len(varying) != 0 {
+			fmt.Printf("%sDiffering attributes: %s\n", spaces(2), strings.Join(varying, ", "))
+		}
+
+		var same []*model.Attribute
+		for _, attr := range processes[0].Samples[0].ProcessAttrs {
+			if !containsString(varying, attr.Name) {
+				same = append(same, attr)
+			}
+		}
+		if len(same) != 0 {
+			fmt.Printf("%sSame in all processes:\n", spaces(2))
+			d.showAttributes(4, same)
+		}
+
+		for _, wp := range processes {
+			fmt.Printf("%s%s: samples %s\n", spaces(2), wp.ProcessName(), strings.Join(uniqueSampleNames(wp.Samples), ", "))
+			for _, name := range varying {
+				if attr := findAttrByName(wp.Samples[0].ProcessAttrs, name); attr != nil {
+					d.showAttr(4, attr)
+				} else {
+					fmt.Printf("%s%s: not given\n", spaces(4), name)
+				}
+			}
+		}
+	}
+}
+
+// uniqueSampleNames returns the names of the samples, in order, with duplicates removed.
+func uniqueSampleNames(samples []*model.Sample) []string {
+	var names []string
+	for _, sample := range samples {
+		if !containsString(names, sample.Name) {
+			names = append(names, sample.Name)
+		}
+	}
+
+	return names
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (d *Displayer) showAttributes(numberOfSpaces int, attrs []*model.Attribute) {
 	for _, attr := range attrs {
 		d.showAttr(numberOfSpaces, attr)
@@ -98,3 +284,17 @@ func (d *Displayer) showAttr(numberOfSpaces int, attr *model.Attribute) {
 func spaces(count int) string {
 	return strings.Repeat(" ", count)
 }
+
+// printHeaderRow shows the row that was used as the header row, and the preamble rows before it that
+// weren't loaded, so the header row setting can be checked.
+func (d *Displayer) printHeaderRow(worksheet *model.Worksheet) {
+	fmt.Printf("%sHeader row: %d\n", spaces(4), worksheet.HeaderRow)
+	if len(worksheet.Preamble) == 0 {
+		return
+	}
+
+	fmt.Printf("%sPreamble (not loaded):\n", spaces(4))
+	for i, row := range worksheet.Preamble {
+		fmt.Printf("%sRow %d: %s\n", spaces(6), i+1, model.PreambleText(row))
+	}
+}
@@ -0,0 +1,152 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// The policies for a sample that appears on several rows of a worksheet with the same process
+// attributes but different sample attribute values.
+const (
+	// DuplicatesAsReplicates merges the rows, each value becoming a separate measurement
+	DuplicatesAsReplicates = "replicates"
+
+	// DuplicatesAsErrors treats the rows as a mistake in the spreadsheet
+	DuplicatesAsErrors = "error"
+)
+
+// DuplicateChecker finds samples that appear on several rows of a worksheet with the same process
+// attributes but different sample attribute values. The rows are the same process, so their values
+// become several measurements of each attribute. That is what replicate measurements look like, but
+// it is also what a copy and paste mistake looks like, so the conflicts are reported.
+type DuplicateChecker struct {
+	// Options for constructing the workflow
+	WorkflowOptions
+}
+
+func NewDuplicateChecker() *DuplicateChecker {
+	return &DuplicateChecker{}
+}
+
+// ValidDuplicatesPolicy returns an error if policy isn't one of the duplicate sample policies.
+func ValidDuplicatesPolicy(policy string) error {
+	if policy != DuplicatesAsReplicates && policy != DuplicatesAsErrors {
+		return fmt.Errorf("unknown duplicate samples policy '%s', use '%s' or '%s'", policy, DuplicatesAsReplicates, DuplicatesAsErrors)
+	}
+
+	return nil
+}
+
+// Apply implements the Process interface. It returns a multierror with an error for each sample
+// whose rows conflict.
+func (d *DuplicateChecker) Apply(worksheets []*model.Worksheet) error {
+	wf := newWorkflow()
+	wf.WorkflowOptions = d.WorkflowOptions
+	wf.constructWorkflow(worksheets)
+
+	var processes []*WorkflowProcess
+	for _, wp := range wf.uniqueProcessInstances {
+		processes = append(processes, wp)
+	}
+
+	// Report the conflicts in worksheet and row order
+	sort.Slice(processes, func(i, j int) bool {
+		if processes[i].Worksheet.Index != processes[j].Worksheet.Index {
+			return processes[i].Worksheet.Index < processes[j].Worksheet.Index
+		}
+		return processes[i].Samples[0].Row < processes[j].Samples[0].Row
+	})
+
+	var errs *multierror.Error
+	for _, wp := range processes {
+		if conflicting := wf.conflictingAttrs(wp.Samples); len(conflicting) != 0 {
+			var rows []string
+			for _, sample := range wp.Samples {
+				rows = append(rows, fmt.Sprintf("%d", sample.Row))
+			}
+
+			errs = multierror.Append(errs, fmt.Errorf("sample '%s' in worksheet %s is on rows %s with the same process attributes but different values for %s",
+				wp.SampleName, wp.Worksheet.Source(), strings.Join(rows, ", "), strings.Join(conflicting, ", ")))
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// conflictingAttrs returns the names of the sample attributes whose values differ between the rows,
+// in the order they first appear. An attribute that is blank on some rows and not others differs.
+func (w *Workflow) conflictingAttrs(rows []*model.Sample) []string {
+	if len(rows) < 2 {
+		return nil
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		for _, attr := range row.Attributes {
+			if !seen[attr.Name] {
+				seen[attr.Name] = true
+				names = append(names, attr.Name)
+			}
+		}
+	}
+
+	var conflicting []string
+	for _, name := range names {
+		values := make(map[string]bool)
+		for _, row := range rows {
+			var parts []string
+			for _, attr := range row.Attributes {
+				if attr.Name == name {
+					parts = append(parts, w.attrKey(attr))
+				}
+			}
+			values[strings.Join(parts, "\x00")] = true
+		}
+
+		if len(values) > 1 {
+			conflicting = append(conflicting, name)
+		}
+	}
+
+	return conflicting
+}
+
+// worksheetSample returns the worksheet row for the named sample in the process. When a sample is on
+// several rows with the same process attributes the rows are combined, so their sample attributes
+// become replicate measurements and all their files are included.
+func (wp *WorkflowProcess) worksheetSample(name string) *model.Sample {
+	var rows []*model.Sample
+	for _, sample := range wp.Samples {
+		if sample.Name == name {
+			rows = append(rows, sample)
+		}
+	}
+
+	switch len(rows) {
+	case 0:
+		return nil
+	case 1:
+		return rows[0]
+	}
+
+	merged := *rows[0]
+	merged.Attributes = nil
+	merged.Files = nil
+	files := make(map[string]bool)
+	for _, row := range rows {
+		merged.Attributes = append(merged.Attributes, row.Attributes...)
+		for _, file := range row.Files {
+			if !files[file.Path] {
+				files[file.Path] = true
+				merged.Files = append(merged.Files, file)
+			}
+		}
+	}
+
+	return &merged
+}
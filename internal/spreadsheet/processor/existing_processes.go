@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// ExistingProcessPrefix starts a parent cell that refers to a process that is already on the server rather
+// than to a worksheet, eg mc:process/<id>. The sample on the row is the sample with the same name in the
+// process's output samples. This lets new worksheets extend an experiment that was created by hand or by an
+// earlier load. Samples that only come from existing processes aren't created.
+const ExistingProcessPrefix = "mc:process/"
+
+// ExistingProcessID returns the process id from a parent cell of the form mc:process/<id>, and false if
+// the parent isn't a reference to an existing process.
+func ExistingProcessID(parent string) (string, bool) {
+	if len(parent) < len(ExistingProcessPrefix) || !strings.EqualFold(parent[:len(ExistingProcessPrefix)], ExistingProcessPrefix) {
+		return "", false
+	}
+
+	return strings.TrimSpace(parent[len(ExistingProcessPrefix):]), true
+}
+
+// findExistingProcess returns the workflow process for the existing process with the given id, adding it
+// to the workflow the first time it is referenced.
+func (w *Workflow) findExistingProcess(id string) *WorkflowProcess {
+	for _, wp := range w.existing {
+		if wp.ExistingProcessID == id {
+			return wp
+		}
+	}
+
+	wp := newWorkflowProcess()
+	wp.ExistingProcessID = id
+	w.existing = append(w.existing, wp)
+	return wp
+}
+
+// samplesFromExistingProcesses returns the names of the samples that only come from existing processes.
+// These samples are already on the server, so there is no Create Samples process for them. A sample that
+// is on a row with a blank parent still needs to be created.
+func (w *Workflow) samplesFromExistingProcesses(worksheets []*model.Worksheet) map[string]bool {
+	fromExisting := make(map[string]bool)
+	created := make(map[string]bool)
+	for _, worksheet := range worksheets {
+		if w.isSamplesSheet(worksheet) {
+			continue
+		}
+
+		for _, sample := range worksheet.Samples {
+			if _, ok := ExistingProcessID(sample.Parent); ok {
+				fromExisting[sample.Name] = true
+			} else if sample.Parent == "" || sample.Parent == w.SamplesSheet {
+				created[sample.Name] = true
+			}
+		}
+	}
+
+	for name := range created {
+		delete(fromExisting, name)
+	}
+
+	return fromExisting
+}
+
+// sampleNamesFrom returns the names of the samples that the workflow sends from wp into other processes.
+func (wp *WorkflowProcess) sampleNamesFrom() []string {
+	var names []string
+	for _, to := range uniqueProcesses(wp.To) {
+		if !containsString(names, to.SampleName) {
+			names = append(names, to.SampleName)
+		}
+	}
+
+	return names
+}
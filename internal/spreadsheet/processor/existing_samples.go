@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/mcapix"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// SamplesToCreate returns the names of the samples that loading the worksheets creates, in the order they
+// are first seen. Samples that only come from processes already on the server aren't created.
+func SamplesToCreate(options WorkflowOptions, worksheets []*model.Worksheet) []string {
+	wf := newWorkflow()
+	wf.WorkflowOptions = options
+	wf.constructWorkflow(worksheets)
+
+	var names []string
+	for _, wp := range wf.root {
+		names = append(names, wp.Samples[0].Name)
+	}
+
+	return names
+}
+
+// refreshReusedSamples reads the property set that each of the ReuseSamples is in now from the project,
+// just before the workflow is created. The samples were looked up before the worksheets were checked,
+// and a process the sample went into since then would leave the workflow forking the sample's lineage
+// from an earlier state.
+func (c *Creater) refreshReusedSamples() error {
+	if len(c.ReuseSamples) == 0 {
+		return nil
+	}
+
+	c.AddCount("getProjectOverview")
+	project, err := c.client.GetProjectOverview(c.ProjectID)
+	if err == mcapix.ErrAuth {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("unable to retrieve the project's samples to reuse: %s", err)
+	}
+
+	current := make(map[string]*mcapi.Sample)
+	for _, sample := range project.Samples {
+		current[sample.ID] = sample
+	}
+
+	for name, sample := range c.ReuseSamples {
+		s := current[sample.ID]
+		switch {
+		case s == nil:
+			return fmt.Errorf("sample %s (id %s) is no longer in the project", name, sample.ID)
+		case s.PropertySetID == "":
+			return fmt.Errorf("the project's sample %s (id %s) has no property set", name, sample.ID)
+		}
+
+		refreshed := *sample
+		refreshed.PropertySetID = s.PropertySetID
+		c.ReuseSamples[name] = &refreshed
+	}
+
+	return nil
+}
+
+// warnUnaddedReusedSamples warns about the ReuseSamples that none of the processes in the workflow take.
+// A sample is added to an experiment by adding it to a process in the experiment, so they aren't added
+// to the experiment.
+func (c *Creater) warnUnaddedReusedSamples(wf *Workflow) {
+	if c.NoExperiment || len(c.ReuseSamples) == 0 {
+		return
+	}
+
+	for _, wp := range wf.root {
+		name := wp.Samples[0].Name
+		if _, ok := c.ReuseSamples[name]; ok && len(wp.To) == 0 {
+			console.Warningf("sample %s is already in the project and no process on the worksheets takes it, it isn't added to the experiment\n", name)
+		}
+	}
+}
+
+// reuseSample uses the sample that is already in the project, rather than creating it, when it is one
+// of the ReuseSamples. It returns false if the sample needs to be created. The sample goes into the
+// experiment along with the processes on the worksheets that it is added to.
+func (c *Creater) reuseSample(wp *WorkflowProcess, step *StepReport) bool {
+	existing, ok := c.ReuseSamples[wp.Samples[0].Name]
+	if !ok {
+		return false
+	}
+
+	if len(wp.CreateAttrs) != 0 {
+		var names []string
+		for _, attr := range wp.CreateAttrs {
+			names = append(names, attr.Name)
+		}
+		console.Warningf("sample %s is already in the project, its samples sheet attributes aren't loaded: %s\n",
+			wp.Samples[0].Name, strings.Join(names, ", "))
+	}
+
+	wp.Out = append(wp.Out, existing)
+	step.ID = existing.ID
+	step.Status = StepFound
+	return true
+}
@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"encoding/csv"
+	"os"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// GenealogyExporter writes the sample genealogy of the workflow as a CSV edge list. Each row is an
+// edge (parent_process, sample, child_process) meaning that the sample was sent from the parent
+// process into the child process. Processes are named as in WorkflowProcess.Name(), so the root
+// of every sample's lineage is the Create Samples process. The edge list can be loaded into tools such as
+// pandas or Neo4j to analyze sample lineage without loading anything onto the server. The file
+// columns name the spreadsheet each process was loaded from, and are blank for Create Samples.
+type GenealogyExporter struct {
+	// Path of the CSV file to write
+	Path string
+
+	// Options for constructing the workflow
+	WorkflowOptions
+}
+
+func NewGenealogyExporter(path string) *GenealogyExporter {
+	return &GenealogyExporter{Path: path}
+}
+
+// Apply implements the Process interface. It constructs the workflow and writes its edges to Path.
+func (g *GenealogyExporter) Apply(worksheets []*model.Worksheet) error {
+	wf := newWorkflow()
+	wf.WorkflowOptions = g.WorkflowOptions
+
+	wf.constructWorkflow(worksheets)
+
+	f, err := os.Create(g.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"parent_process", "sample", "child_process", "parent_file", "child_file"}); err != nil {
+		return err
+	}
+
+	// A sample that appears on several rows with the same process attributes is wired up once per
+	// row. Only write each edge once.
+	seen := make(map[string]bool)
+	for _, edge := range wf.edges {
+		record := []string{edge.From.Name(), edge.SampleName, edge.To.Name(), edge.From.sourceFile(), edge.To.sourceFile()}
+		key := strings.Join(record, "\x00")
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
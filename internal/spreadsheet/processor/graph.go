@@ -0,0 +1,153 @@
+package processor
+
+/*
+ * graph writes the workflow as a Graphviz DOT graph, for reviewing workflows that are too large to follow as
+ * the indented text display prints. Each process is a node, grouped in a box for the worksheet it comes from,
+ * and each edge is labeled with the samples sent along it:
+ *
+ *   mcetl display -f campaign.xlsx --has-parent --graph campaign.dot
+ *   dot -Tpdf campaign.dot -o campaign.pdf
+ *
+ * A path ending in .svg is rendered to SVG with Graphviz's dot program, which must be installed.
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// maxEdgeLabelSamples is the most sample names shown on an edge, the rest are counted.
+const maxEdgeLabelSamples = 3
+
+// GraphExporter writes the workflow as a DOT graph, see above.
+type GraphExporter struct {
+	// Path of the DOT or SVG file to write
+	Path string
+
+	// Options for constructing the workflow
+	WorkflowOptions
+}
+
+func NewGraphExporter(path string) *GraphExporter {
+	return &GraphExporter{Path: path}
+}
+
+// Apply implements the Process interface. It constructs the workflow and writes it to Path.
+func (g *GraphExporter) Apply(worksheets []*model.Worksheet) error {
+	wf := newWorkflow()
+	wf.WorkflowOptions = g.WorkflowOptions
+	wf.constructWorkflow(worksheets)
+
+	dot := wf.dot()
+	if !strings.EqualFold(filepath.Ext(g.Path), ".svg") {
+		return ioutil.WriteFile(g.Path, dot, 0644)
+	}
+
+	if _, err := exec.LookPath("dot"); err != nil {
+		return fmt.Errorf("writing %s needs Graphviz's dot program, which wasn't found, write a .dot file instead", g.Path)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("dot", "-Tsvg", "-o", g.Path)
+	cmd.Stdin = bytes.NewReader(dot)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("dot failed: %s %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+// dot returns the workflow as a DOT graph.
+func (w *Workflow) dot() []byte {
+	// The nodes in the order they are first seen, so the graph is the same each time it is written
+	ids := make(map[*WorkflowProcess]string)
+	var nodes []*WorkflowProcess
+	addNode := func(wp *WorkflowProcess) {
+		if _, ok := ids[wp]; !ok {
+			ids[wp] = fmt.Sprintf("n%d", len(nodes)+1)
+			nodes = append(nodes, wp)
+		}
+	}
+
+	for _, wp := range w.root {
+		addNode(wp)
+	}
+	for _, wp := range w.existing {
+		addNode(wp)
+	}
+
+	// The samples sent along each edge, an edge is wired up once per row
+	type edgeKey struct{ from, to *WorkflowProcess }
+	var edges []edgeKey
+	samples := make(map[edgeKey][]string)
+	for _, edge := range w.edges {
+		addNode(edge.From)
+		addNode(edge.To)
+
+		key := edgeKey{from: edge.From, to: edge.To}
+		if _, ok := samples[key]; !ok {
+			edges = append(edges, key)
+		}
+		if !containsString(samples[key], edge.SampleName) {
+			samples[key] = append(samples[key], edge.SampleName)
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("digraph workflow {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, style=rounded];\n")
+
+	// Processes from the same worksheet are grouped together
+	var worksheets []*model.Worksheet
+	byWorksheet := make(map[*model.Worksheet][]*WorkflowProcess)
+	for _, wp := range nodes {
+		switch {
+		case wp.ExistingProcessID != "":
+			fmt.Fprintf(&b, "  %s [label=%s, style=dashed];\n", ids[wp], dotQuote(wp.Name()))
+		case wp.Worksheet == nil:
+			fmt.Fprintf(&b, "  %s [label=%s, shape=ellipse, style=solid];\n", ids[wp], dotQuote(wp.Name()+"\n"+wp.Samples[0].Name))
+		default:
+			if _, ok := byWorksheet[wp.Worksheet]; !ok {
+				worksheets = append(worksheets, wp.Worksheet)
+			}
+			byWorksheet[wp.Worksheet] = append(byWorksheet[wp.Worksheet], wp)
+		}
+	}
+
+	for i, worksheet := range worksheets {
+		fmt.Fprintf(&b, "  subgraph cluster_%d {\n", i+1)
+		fmt.Fprintf(&b, "    label=%s;\n", dotQuote(worksheet.Source()))
+		for _, wp := range byWorksheet[worksheet] {
+			fmt.Fprintf(&b, "    %s [label=%s];\n", ids[wp], dotQuote(wp.ProcessName()))
+		}
+		b.WriteString("  }\n")
+	}
+
+	for _, key := range edges {
+		names := samples[key]
+		label := strings.Join(names, ", ")
+		if len(names) > maxEdgeLabelSamples {
+			label = fmt.Sprintf("%s +%d more", strings.Join(names[:maxEdgeLabelSamples], ", "), len(names)-maxEdgeLabelSamples)
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", ids[key.from], ids[key.to], dotQuote(label))
+	}
+
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	return `"` + s + `"`
+}
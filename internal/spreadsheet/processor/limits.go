@@ -0,0 +1,82 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// LimitChecker guards against creating far more samples or processes than intended. A workbook that
+// would create thousands of samples is usually a sign of a parsing mistake, such as footer rows or
+// notes below the data being read as samples. Apply fails with a per worksheet summary when the
+// workflow would exceed either limit, so the problem can be found before anything is created.
+type LimitChecker struct {
+	// MaxSamples is the largest number of samples the workflow may create, 0 means no limit
+	MaxSamples int
+
+	// MaxProcesses is the largest number of processes the workflow may create, including the Create
+	// Samples processes, 0 means no limit
+	MaxProcesses int
+
+	// Options for constructing the workflow
+	WorkflowOptions
+}
+
+func NewLimitChecker(maxSamples, maxProcesses int) *LimitChecker {
+	return &LimitChecker{MaxSamples: maxSamples, MaxProcesses: maxProcesses}
+}
+
+// Apply implements the Process interface. It constructs the workflow and checks the number of
+// samples and processes it would create against the limits.
+func (l *LimitChecker) Apply(worksheets []*model.Worksheet) error {
+	if l.MaxSamples <= 0 && l.MaxProcesses <= 0 {
+		return nil
+	}
+
+	wf := newWorkflow()
+	wf.WorkflowOptions = l.WorkflowOptions
+	wf.constructWorkflow(worksheets)
+
+	samples := len(wf.root)
+	processes := len(wf.root) + len(wf.uniqueProcessInstances)
+
+	var exceeded []string
+	if l.MaxSamples > 0 && samples > l.MaxSamples {
+		exceeded = append(exceeded, fmt.Sprintf("%d samples (limit %d)", samples, l.MaxSamples))
+	}
+
+	if l.MaxProcesses > 0 && processes > l.MaxProcesses {
+		exceeded = append(exceeded, fmt.Sprintf("%d processes (limit %d)", processes, l.MaxProcesses))
+	}
+
+	if len(exceeded) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("the workflow would create %s\n%s", strings.Join(exceeded, " and "), wf.limitsSummary(worksheets))
+}
+
+// limitsSummary lists the rows and processes from each worksheet, largest first, so that a worksheet
+// with unexpected rows stands out.
+func (w *Workflow) limitsSummary(worksheets []*model.Worksheet) string {
+	processes := make(map[*model.Worksheet]int)
+	for _, wp := range w.uniqueProcessInstances {
+		processes[wp.Worksheet]++
+	}
+
+	sorted := append([]*model.Worksheet{}, worksheets...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].Samples) > len(sorted[j].Samples) })
+
+	var lines []string
+	for _, worksheet := range sorted {
+		line := fmt.Sprintf("  Worksheet %s: %d rows, %d processes", worksheet.Source(), len(worksheet.Samples), processes[worksheet])
+		if n := len(worksheet.Samples); n > 0 {
+			line += fmt.Sprintf(", last sample '%s'", worksheet.Samples[n-1].Name)
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+)
+
+// The status of a step in a LoadReport
+const (
+	// StepCreated is a sample or process that was created on the server
+	StepCreated = "created"
+
+	// StepFound is an existing process that was found on the server
+	StepFound = "found"
+
+	// StepResumed is a step that was created by an earlier run of the load, see load_state.go
+	StepResumed = "resumed"
+
+	// StepFailed is a step whose API call failed
+	StepFailed = "failed"
+
+	// StepNotAttempted is a step that wasn't reached because an earlier step failed
+	StepNotAttempted = "not_attempted"
+
+	// MeasurementsAdded is a process whose measurements were all added
+	MeasurementsAdded = "added"
+)
+
+// LoadReport describes how far the Creater got. When a load fails part way through it records
+// which samples and processes were created, with their server IDs, which failed and which were never
+// attempted. It is written as JSON so tooling can resume or roll back the load.
+type LoadReport struct {
+	ProjectID    string        `json:"project_id"`
+	ExperimentID string        `json:"experiment_id,omitempty"`
+	Succeeded    bool          `json:"succeeded"`
+	Error        string        `json:"error,omitempty"`
+	Steps        []*StepReport `json:"steps"`
+}
+
+// StepReport is the outcome of a step in the workflow. Steps are in the order they are created.
+type StepReport struct {
+	// Type is sample for a Create Samples step, process for a worksheet process and
+	// existing_process for a process that was already on the server
+	Type string `json:"type"`
+
+	Name      string   `json:"name"`
+	Worksheet string   `json:"worksheet,omitempty"`
+	File      string   `json:"file,omitempty"`
+	Rows      []int    `json:"rows,omitempty"`
+	Samples   []string `json:"samples"`
+
+	// ID is the server ID of the sample or process
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// FailedSamples are the samples that couldn't be added to the process
+	FailedSamples []string `json:"failed_samples,omitempty"`
+
+	// Measurements is the status of the process's measurements, blank if it has none
+	Measurements      string `json:"measurements,omitempty"`
+	MeasurementsError string `json:"measurements_error,omitempty"`
+}
+
+// newStepReport returns a report for a step that hasn't been attempted yet.
+func newStepReport(wp *WorkflowProcess) *StepReport {
+	step := &StepReport{Name: wp.Name(), Status: StepNotAttempted}
+	switch {
+	case wp.ExistingProcessID != "":
+		step.Type = "existing_process"
+		step.ID = wp.ExistingProcessID
+		step.Samples = wp.sampleNamesFrom()
+	case wp.Worksheet == nil:
+		step.Type = "sample"
+		step.Samples = []string{wp.Samples[0].Name}
+		step.Rows = []int{wp.Samples[0].Row}
+	default:
+		step.Type = "process"
+		step.Name = wp.ProcessName()
+		step.Worksheet = wp.Worksheet.Name
+		step.File = wp.Worksheet.File
+		step.Samples = uniqueSampleNames(wp.Samples)
+		for _, sample := range wp.Samples {
+			// A sample passing through from a parent chain isn't on a row
+			if sample.Row != 0 {
+				step.Rows = append(step.Rows, sample.Row)
+			}
+		}
+	}
+
+	return step
+}
+
+// Counts returns the number of steps with each status.
+func (r *LoadReport) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, step := range r.Steps {
+		counts[step.Status]++
+	}
+
+	return counts
+}
+
+// Write writes the report as JSON to path.
+func (r *LoadReport) Write(path string) error {
+	contents, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, contents, 0644)
+}
@@ -0,0 +1,431 @@
+package processor
+
+/*
+ * load_state checkpoints a load so that one that fails part way through, such as when the server goes away,
+ * can be run again and carry on where it stopped rather than starting over in a new experiment. The state
+ * file records the experiment, and each sample and process that was created with the server side samples
+ * it output, keyed by the step in the workflow they were created for. It also records the samples whose
+ * measurements have been added to each process.
+ *
+ * When a load is given the state of an earlier run it loads into the same experiment and skips the steps
+ * that were created, using the recorded samples and processes in their place, and only adds the measurements
+ * that weren't added. A step is recorded once it is complete, so a step that failed part way through is
+ * created again. The exception is a process whose samples were sent in a call that the server answered with
+ * a response missing the samples' ids: the server may have added them, so the process and the samples sent
+ * are recorded, and resuming carries on with that process after asking the server which samples it has.
+ * The state file is removed once the load succeeds.
+ *
+ * The state also records the fingerprint of the workbooks and the flags that decide what is created from
+ * them. A load is only resumed with the same workbooks and flags, otherwise the steps recorded could be
+ * for rows that have since changed.
+ *
+ * The state file is a journal, one JSON document per line. The first line is the load, each line after
+ * it records a step, or the measurements added to one, and is appended as it happens. Rewriting the whole
+ * state each time would write it once per step, which for a large load is most of the time it takes. The
+ * file is rewritten with a line per step when the load starts, so a resumed load doesn't keep the earlier
+ * runs' lines. A line left part written by a crash is ignored.
+ */
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/mcapix"
+)
+
+// LoadState is what a load has created, see above.
+type LoadState struct {
+	ProjectID    string `json:"project_id"`
+	ExperimentID string `json:"experiment_id,omitempty"`
+
+	// Fingerprint is the fingerprint of the workbooks loaded and Flags the flags that decide what is
+	// created from them, by name
+	Fingerprint string            `json:"fingerprint"`
+	Flags       map[string]string `json:"flags"`
+
+	// Steps are read from the lines after the first, see stateRecord
+	Steps map[string]*StateStep `json:"-"`
+}
+
+// stateRecord is a line of the state file after the first. It either records a step, replacing what was
+// recorded for it, or adds to the samples whose measurements were added to it.
+type stateRecord struct {
+	Key      string     `json:"key"`
+	Step     *StateStep `json:"step,omitempty"`
+	Measured []string   `json:"measured,omitempty"`
+}
+
+// StateStep is a step of the workflow that has been created.
+type StateStep struct {
+	// Process is the process that was created, nil for a sample
+	Process *mcapi.Process `json:"process,omitempty"`
+
+	// Out are the server side samples output by the step
+	Out []*mcapi.Sample `json:"out"`
+
+	// Measured are the IDs of the samples whose measurements have been added to the process
+	Measured []string `json:"measured,omitempty"`
+
+	// Sent are the names of the samples sent to the process in a call whose response was malformed. The
+	// step isn't complete, see reconcileStep.
+	Sent []string `json:"sent,omitempty"`
+}
+
+// ReadLoadState reads the state saved in path. It returns nil if there is no state file.
+func ReadLoadState(path string) (*LoadState, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		state  LoadState
+		r      = bufio.NewReader(f)
+		lineNo int
+	)
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		// Without a newline the line was being written when the load stopped
+		complete := err == nil
+		if line = bytes.TrimSpace(line); len(line) != 0 {
+			lineNo++
+			if lineNo == 1 {
+				if err := json.Unmarshal(line, &state); err != nil {
+					return nil, fmt.Errorf("unable to parse state file %s: %s", path, err)
+				}
+				state.Steps = make(map[string]*StateStep)
+			} else if parseErr := state.apply(line); parseErr != nil && complete {
+				return nil, fmt.Errorf("unable to parse line %d of state file %s: %s", lineNo, path, parseErr)
+			}
+		}
+
+		if !complete {
+			break
+		}
+	}
+
+	if lineNo == 0 {
+		return nil, fmt.Errorf("state file %s is empty", path)
+	}
+
+	return &state, nil
+}
+
+// apply adds a line of the state file after the first to the state.
+func (s *LoadState) apply(line []byte) error {
+	var record stateRecord
+	if err := json.Unmarshal(line, &record); err != nil {
+		return err
+	}
+
+	if record.Step != nil {
+		s.Steps[record.Key] = record.Step
+	}
+
+	if saved, ok := s.Steps[record.Key]; ok {
+		saved.Measured = append(saved.Measured, record.Measured...)
+	}
+
+	return nil
+}
+
+// CheckInputs returns an error if the state is for a load of other workbooks than those with fingerprint,
+// or with flags other than flags.
+func (s *LoadState) CheckInputs(fingerprint string, flags map[string]string) error {
+	if s.Fingerprint != fingerprint {
+		return fmt.Errorf("the state file is for a load of other workbooks, or they have changed since it was started")
+	}
+
+	var changed []string
+	for name, value := range flags {
+		if was, ok := s.Flags[name]; !ok || was != value {
+			changed = append(changed, fmt.Sprintf("--%s is %q, was %q", name, value, was))
+		}
+	}
+
+	for name, was := range s.Flags {
+		if _, ok := flags[name]; !ok {
+			changed = append(changed, fmt.Sprintf("--%s isn't given, was %q", name, was))
+		}
+	}
+
+	if len(changed) != 0 {
+		sort.Strings(changed)
+		return fmt.Errorf("the state file is for a load with other flags: %s", strings.Join(changed, ", "))
+	}
+
+	return nil
+}
+
+// SampleIDs returns the IDs of the samples the load created.
+func (s *LoadState) SampleIDs() map[string]bool {
+	ids := make(map[string]bool)
+	if s == nil {
+		return ids
+	}
+
+	for key, step := range s.Steps {
+		if strings.HasPrefix(key, "sample:") {
+			for _, sample := range step.Out {
+				ids[sample.ID] = true
+			}
+		}
+	}
+
+	return ids
+}
+
+// stateKey returns the key of the step in the state. Processes already on the server aren't created so
+// they have no key.
+func stateKey(wp *WorkflowProcess) string {
+	switch {
+	case wp.ExistingProcessID != "":
+		return ""
+	case wp.Worksheet == nil:
+		return "sample:" + wp.Samples[0].Name
+	default:
+		return "process:" + wp.Key
+	}
+}
+
+// resumeExperiment loads into the experiment of the state being resumed, marking it as being loaded again.
+func (c *Creater) resumeExperiment() {
+	c.ExperimentID = c.Resume.ExperimentID
+	fmt.Printf("Resuming the load, %d step(s) were created by an earlier run\n", len(c.Resume.Steps))
+
+	if !c.NoExperiment {
+		c.AddCount("updateExperimentProgressStatus")
+		var _ = c.client.UpdateExperimentProgressStatus(c.ProjectID, c.ExperimentID, true)
+	}
+}
+
+// resumeStep uses what an earlier run created for the step, queueing the measurements that weren't added.
+// It returns false if the step wasn't created by an earlier run.
+func (c *Creater) resumeStep(wp *WorkflowProcess, step *StepReport) bool {
+	if c.state == nil {
+		return false
+	}
+
+	key := stateKey(wp)
+	saved, ok := c.state.Steps[key]
+	if key == "" || !ok || len(saved.Sent) != 0 || len(saved.Out) == 0 && saved.Process == nil {
+		return false
+	}
+
+	wp.Out = append(wp.Out, saved.Out...)
+	step.Status = StepResumed
+	if saved.Process == nil {
+		step.ID = saved.Out[0].ID
+		return true
+	}
+
+	wp.Process = saved.Process
+	step.ID = saved.Process.ID
+
+	measured := make(map[string]bool)
+	for _, id := range saved.Measured {
+		measured[id] = true
+	}
+
+	var measurements []mcapix.SampleMeasurements
+	for _, s := range saved.Out {
+		if worksheetSample := wp.worksheetSample(s.Name); worksheetSample != nil && !measured[s.ID] {
+			measurements = append(measurements, createSampleMeasurements(s, worksheetSample, c.RecordProvenance))
+		}
+	}
+
+	if len(measurements) == 0 && len(saved.Measured) != 0 {
+		step.Measurements = MeasurementsAdded
+	}
+	c.queueMeasurements(wp, step, measurements)
+	return true
+}
+
+// reconcileStep carries on with the process of a step that an earlier run left part way through, when the
+// server's answer to adding samples to it was malformed. The server is asked which of the samples sent it
+// added, they are output by the step and aren't sent again. It returns false when there is nothing to
+// reconcile, or the process can't be read, and the process is created again.
+func (c *Creater) reconcileStep(wp *WorkflowProcess, step *StepReport) bool {
+	if c.state == nil {
+		return false
+	}
+
+	key := stateKey(wp)
+	c.mu.Lock()
+	saved, ok := c.state.Steps[key]
+	c.mu.Unlock()
+	if key == "" || !ok || saved.Process == nil || len(saved.Sent) == 0 {
+		return false
+	}
+
+	c.AddCount("getProcess")
+	p, err := c.client.GetProcess(c.ProjectID, saved.Process.ID)
+	if err != nil {
+		console.Warningf("unable to read process '%s' (id %s) to see which samples an earlier run added, creating it again: %s\n",
+			saved.Process.Name, saved.Process.ID, err)
+		return false
+	}
+
+	wp.Process = saved.Process
+	wp.Out = append(wp.Out, saved.Out...)
+	added := 0
+	for _, name := range saved.Sent {
+		if s := c.findSampleFromServer(name, p.OutputSamples); s != nil && c.findSampleFromServer(name, wp.Out) == nil {
+			wp.Out = append(wp.Out, s)
+			added++
+		}
+	}
+
+	step.ID = saved.Process.ID
+	step.Status = StepCreated
+	fmt.Printf("Carrying on with process '%s' (id %s), the server added %d of the %d samples an earlier run sent to it\n",
+		saved.Process.Name, saved.Process.ID, added, len(saved.Sent))
+	return true
+}
+
+// recordSent records a process and the samples sent to it in a call whose response was malformed, so that
+// resuming the load can reconcile them rather than create the process again.
+func (c *Creater) recordSent(wp *WorkflowProcess, sent []*mcapi.Sample) {
+	key := stateKey(wp)
+	if c.state == nil || key == "" {
+		return
+	}
+
+	saved := &StateStep{Process: wp.Process, Out: append([]*mcapi.Sample(nil), wp.Out...)}
+	for _, s := range sent {
+		saved.Sent = append(saved.Sent, s.Name)
+	}
+
+	c.mu.Lock()
+	c.state.Steps[key] = saved
+	c.mu.Unlock()
+	c.appendState(stateRecord{Key: key, Step: saved})
+}
+
+// recordStep records a step that has been created in the state.
+func (c *Creater) recordStep(wp *WorkflowProcess) {
+	key := stateKey(wp)
+	if c.state == nil || key == "" {
+		return
+	}
+
+	saved := &StateStep{Process: wp.Process, Out: wp.Out}
+	c.mu.Lock()
+	c.state.Steps[key] = saved
+	c.mu.Unlock()
+	c.appendState(stateRecord{Key: key, Step: saved})
+}
+
+// recordMeasured records the samples whose measurements were added to a process in the state.
+func (c *Creater) recordMeasured(batch measurementBatch) {
+	if c.state == nil {
+		return
+	}
+
+	record := stateRecord{Key: batch.key}
+	for _, sm := range batch.measurements {
+		record.Measured = append(record.Measured, sm.SampleID)
+	}
+
+	c.mu.Lock()
+	saved, ok := c.state.Steps[batch.key]
+	if ok {
+		saved.Measured = append(saved.Measured, record.Measured...)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		c.appendState(record)
+	}
+}
+
+// saveState writes the whole state to StatePath, a line for the load and one for each step.
+func (c *Creater) saveState() {
+	if c.state == nil {
+		return
+	}
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	c.mu.Lock()
+	err := enc.Encode(c.state)
+	keys := make([]string, 0, len(c.state.Steps))
+	for key := range c.state.Steps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err == nil {
+			err = enc.Encode(stateRecord{Key: key, Step: c.state.Steps[key]})
+		}
+	}
+	c.mu.Unlock()
+
+	if err == nil {
+		err = writeFileAtomically(c.StatePath, buf.Bytes())
+	}
+
+	c.stateSaveFailed(err)
+}
+
+// appendState appends a record to the state file.
+func (c *Creater) appendState(record stateRecord) {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	c.mu.Lock()
+	line, err := json.Marshal(record)
+	c.mu.Unlock()
+
+	if err == nil {
+		var f *os.File
+		if f, err = os.OpenFile(c.StatePath, os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+			_, err = f.Write(append(line, '\n'))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	}
+
+	c.stateSaveFailed(err)
+}
+
+// stateSaveFailed warns, once, that saving the state failed when err isn't nil. It must be called with
+// stateMu held.
+func (c *Creater) stateSaveFailed(err error) {
+	if err != nil && !c.stateFailed {
+		console.Warning("unable to save the state of the load, it can't be resumed:", err)
+		c.stateFailed = true
+	}
+}
+
+// loadComplete removes the state file once the load has succeeded, there is nothing left to resume.
+func (c *Creater) loadComplete() {
+	if c.state == nil {
+		return
+	}
+
+	if err := os.Remove(c.StatePath); err != nil && !os.IsNotExist(err) {
+		console.Warning("unable to remove the state file of the completed load:", err)
+	}
+}
@@ -0,0 +1,75 @@
+package processor_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/materials-commons/mcetl/internal/selftest"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+)
+
+func TestResumeFromStateFile(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "study.state")
+	flags := map[string]string{"has-parent": "true"}
+
+	// The first run creates every step, then fails to add the measurements
+	server := selftest.NewMockServer()
+	server.Fail = map[string]int{"addMeasurementsToSamplesInProcess": http.StatusInternalServerError}
+	c := newTestCreater(t, server)
+	c.StatePath, c.StateFingerprint, c.StateFlags = statePath, "fingerprint", flags
+	if err := c.Apply(loadWorksheets(t, heatTreatmentStudy)); err == nil {
+		t.Fatal("first run succeeded, want the measurements to fail")
+	}
+
+	state, err := processor.ReadLoadState(statePath)
+	if err != nil || state == nil {
+		t.Fatalf("unable to read the state of the first run: %v", err)
+	}
+	if len(state.Steps) != 8 {
+		t.Errorf("state has %d steps, want the 3 samples and 5 processes", len(state.Steps))
+	}
+
+	if err := state.CheckInputs("other", flags); err == nil {
+		t.Error("state resumed with other workbooks")
+	}
+	if err := state.CheckInputs("fingerprint", map[string]string{"has-parent": "false"}); err == nil {
+		t.Error("state resumed with other flags")
+	}
+	if err := state.CheckInputs("fingerprint", flags); err != nil {
+		t.Errorf("state not resumed with the same workbooks and flags: %s", err)
+	}
+
+	// A line left part written by a crash is ignored
+	f, err := os.OpenFile(statePath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"key":"process:`)
+	f.Close()
+	if state, err = processor.ReadLoadState(statePath); err != nil {
+		t.Fatalf("unable to read the state with a part written line: %s", err)
+	}
+
+	// The second run only adds the measurements
+	server = selftest.NewMockServer()
+	c = newTestCreater(t, server)
+	c.StatePath, c.Resume = statePath, state
+	if err := c.Apply(loadWorksheets(t, heatTreatmentStudy)); err != nil {
+		t.Fatalf("resumed run failed: %s", err)
+	}
+
+	for _, call := range server.Calls() {
+		if strings.HasPrefix(call.Endpoint, "create") {
+			t.Errorf("resumed run called %s", call.Endpoint)
+		}
+	}
+	if n := len(server.CallsTo("addMeasurementsToSamplesInProcess")); n != 5 {
+		t.Errorf("resumed run made %d bulk measurement calls, want 5", n)
+	}
+	if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+		t.Errorf("state file not removed after the load succeeded: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package processor
+
+/*
+ * missing_dirs creates the directories in the project that the files in the worksheets are in, when they
+ * don't exist yet, so that the files can be linked to the processes once they are uploaded rather than the
+ * load failing on a directory that hasn't been made. Only the deepest directories are checked, as creating
+ * a directory also creates its parents.
+ */
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/mcapix"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// createMissingDirectories creates the directories of the files in the worksheets that aren't in the project.
+func (c *Creater) createMissingDirectories(worksheets []*model.Worksheet) error {
+	for _, dir := range fileDirectories(worksheets) {
+		c.AddCount("getFileByPath")
+		_, err := c.client.GetFileByPathInProject(dir, c.ProjectID)
+		if err == nil {
+			continue
+		} else if err == mcapix.ErrAuth {
+			return err
+		}
+
+		c.AddCount("createDirectoryByPath")
+		if _, err := c.client.CreateDirectoryByPath(c.ProjectID, dir); err == mcapix.ErrAuth {
+			return err
+		} else if err != nil {
+			return fmt.Errorf("unable to create directory %s in the project: %s", dir, err)
+		}
+		fmt.Printf("Created directory %s in the project\n", dir)
+	}
+
+	return nil
+}
+
+// fileDirectories returns the directories the files in the worksheets are in, leaving out the directories
+// that are the parent of another one in the list. The directories are sorted.
+func fileDirectories(worksheets []*model.Worksheet) []string {
+	unique := make(map[string]bool)
+	for _, worksheet := range worksheets {
+		for _, sample := range worksheet.Samples {
+			for _, file := range sample.Files {
+				if dir := path.Dir(file.Path); dir != "." && dir != "/" {
+					unique[dir] = true
+				}
+			}
+		}
+	}
+
+	var dirs []string
+	for dir := range unique {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	var deepest []string
+	for i, dir := range dirs {
+		// Sorted, a directory's subdirectories come straight after it
+		if i+1 < len(dirs) && strings.HasPrefix(dirs[i+1], dir+"/") {
+			continue
+		}
+		deepest = append(deepest, dir)
+	}
+
+	return deepest
+}
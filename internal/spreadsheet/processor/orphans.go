@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// OrphanChecker finds samples that aren't sent into any process. A sample that is only on the samples
+// sheet has nothing but a Create Samples process, so it is created on the server without any
+// measurements. That is usually a sample that was described but never processed, or a typo in the
+// sample name on one of the other worksheets.
+type OrphanChecker struct {
+	// Options for constructing the workflow
+	WorkflowOptions
+}
+
+func NewOrphanChecker() *OrphanChecker {
+	return &OrphanChecker{}
+}
+
+// Apply implements the Process interface. It returns an error listing the samples that aren't sent
+// into any process. When SkipOrphanSamples is set the error says they won't be created.
+func (o *OrphanChecker) Apply(worksheets []*model.Worksheet) error {
+	// Construct the workflow without skipping orphans so that they can be found
+	wf := newWorkflow()
+	wf.WorkflowOptions = o.WorkflowOptions
+	wf.SkipOrphanSamples = false
+	wf.constructWorkflow(worksheets)
+
+	orphans := wf.orphanSamples()
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	if o.SkipOrphanSamples {
+		return fmt.Errorf("not creating %d sample(s) that aren't used by any process: %s", len(orphans), strings.Join(orphans, ", "))
+	}
+
+	return fmt.Errorf("%d sample(s) aren't used by any process and will only be created: %s", len(orphans), strings.Join(orphans, ", "))
+}
+
+// orphanSamples returns the names of the samples whose Create Samples process doesn't send them
+// into any other process.
+func (w *Workflow) orphanSamples() []string {
+	var names []string
+	for _, wp := range w.root {
+		if len(wp.To) == 0 {
+			names = append(names, wp.Samples[0].Name)
+		}
+	}
+
+	return names
+}
+
+// removeOrphanSamples removes the Create Samples processes for samples that aren't sent into any
+// other process, so they aren't created.
+func (w *Workflow) removeOrphanSamples() {
+	var root []*WorkflowProcess
+	for _, wp := range w.root {
+		if len(wp.To) != 0 {
+			root = append(root, wp)
+		}
+	}
+
+	w.root = root
+}
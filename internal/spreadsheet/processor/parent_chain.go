@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"fmt"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// passThroughKey is the key in uniqueProcessInstances of the process a sample passes through on a
+// worksheet that doesn't list it.
+func passThroughKey(worksheetName, sampleName string) string {
+	return fmt.Sprintf("pass-through\x00%s\x00%s", worksheetName, sampleName)
+}
+
+// createPassThroughProcesses creates a process for each worksheet in a sample's parent chain that doesn't
+// list the sample. The process has no attributes, the sample only passes through it. Its sample's Parent
+// is the worksheet before it in the chain, blank for the first worksheet, and it is wired up by
+// wirePassThroughProcesses.
+func (w *Workflow) createPassThroughProcesses(worksheets []*model.Worksheet) {
+	byName := make(map[string]*model.Worksheet)
+	instances := make(map[*model.Worksheet]int)
+	for _, worksheet := range worksheets {
+		byName[worksheet.Name] = worksheet
+	}
+	for _, wp := range w.uniqueProcessInstances {
+		if wp.Instance > instances[wp.Worksheet] {
+			instances[wp.Worksheet] = wp.Instance
+		}
+	}
+
+	for _, worksheet := range worksheets {
+		for _, sample := range worksheet.Samples {
+			if len(sample.ParentChain) == 0 {
+				continue
+			}
+
+			// Walk back along the chain from the parent until a worksheet lists the sample
+			chain := append(append([]string{}, sample.ParentChain...), sample.Parent)
+			for i := len(chain) - 1; i >= 0; i-- {
+				through := byName[chain[i]]
+				if through == nil || w.isSamplesSheet(through) || lastStep(sample.Name, through) != nil {
+					break
+				}
+
+				from := ""
+				if i > 0 && chain[i-1] != w.SamplesSheet {
+					from = chain[i-1]
+				}
+
+				key := passThroughKey(through.Name, sample.Name)
+				if wp, ok := w.uniqueProcessInstances[key]; ok {
+					if wp.Samples[0].Parent != from {
+						console.Warningf("Worksheet %s row %d has sample %s going into %s from %s, an earlier parent chain has it come from %s and that is used\n",
+							worksheet.Source(), sample.Row, sample.Name, through.Name, chainStepName(from), chainStepName(wp.Samples[0].Parent))
+					}
+					break
+				}
+
+				passThrough := model.NewSample(sample.Name, 0)
+				passThrough.Parent = from
+
+				instances[through]++
+				wp := newWorkflowProcess()
+				wp.SampleName = sample.Name
+				wp.Key = key
+				wp.Instance = instances[through]
+				wp.Worksheet = through
+				wp.Samples = append(wp.Samples, passThrough)
+				w.uniqueProcessInstances[key] = wp
+				w.passThroughs = append(w.passThroughs, wp)
+			}
+		}
+	}
+}
+
+// chainStepName returns the name of a worksheet in a parent chain for messages. A blank worksheet is
+// where the sample was created.
+func chainStepName(worksheetName string) string {
+	if worksheetName == "" {
+		return "Create Samples"
+	}
+
+	return worksheetName
+}
+
+// wirePassThroughProcesses wires each pass through process to the process its sample comes from.
+func (w *Workflow) wirePassThroughProcesses(worksheets []*model.Worksheet) {
+	for _, wp := range w.passThroughs {
+		sample := wp.Samples[0]
+
+		var parentProcess *WorkflowProcess
+		if sample.Parent == "" {
+			parentProcess = w.findMatchingCreateSampleProcess(sample.Name)
+		} else {
+			parentProcess = w.findMatchingEntry(sample.Name, sample.Parent, worksheets)
+		}
+
+		if parentProcess == nil {
+			// Should never happen
+			fmt.Printf("Bug: Can't find the process %s goes into %s from\n", sample.Name, wp.Worksheet.Name)
+			continue
+		}
+
+		w.wireProcessesTogetherFromTo(parentProcess, wp)
+		w.edges = append(w.edges, &WorkflowEdge{From: parentProcess, SampleName: sample.Name, To: wp})
+	}
+}
@@ -0,0 +1,29 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/materials-commons/mcetl/internal/dates"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// processDate returns when a process from the worksheet was performed, taken from the worksheet's date
+// column. The bool is false if the worksheet has no date column or the process has no date.
+func processDate(worksheet *model.Worksheet, attrs []*model.Attribute) (time.Time, bool) {
+	if worksheet == nil || worksheet.DateAttr == "" {
+		return time.Time{}, false
+	}
+
+	attr := findAttrByName(attrs, worksheet.DateAttr)
+	if attr == nil || attr.Value == nil {
+		return time.Time{}, false
+	}
+
+	t, err := dates.Parse(fmt.Sprint(attr.Value["value"]))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
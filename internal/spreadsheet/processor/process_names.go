@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// nameProcesses sets the name each process is created with on the server. A worksheet that results
+// in a single process names it after the worksheet. When a worksheet results in several processes they
+// are numbered (Heat Treatment #1, Heat Treatment #2, ...) so they can be told apart on the server. If
+// ProcessNameAttrs is set then the process attribute values that differ between the processes are
+// included instead, eg Heat Treatment (400c/300s), and only processes whose names would still be the
+// same are numbered.
+func (w *Workflow) nameProcesses() {
+	byWorksheet := make(map[*model.Worksheet][]*WorkflowProcess)
+	for _, wp := range w.uniqueProcessInstances {
+		byWorksheet[wp.Worksheet] = append(byWorksheet[wp.Worksheet], wp)
+	}
+
+	for worksheet, processes := range byWorksheet {
+		if len(processes) == 1 {
+			processes[0].processName = worksheet.Name
+			continue
+		}
+
+		sort.Slice(processes, func(i, j int) bool { return processes[i].Instance < processes[j].Instance })
+
+		var varying []string
+		if w.ProcessNameAttrs {
+			varying = w.varyingProcessAttrs(processes)
+		}
+
+		names := make([]string, len(processes))
+		count := make(map[string]int)
+		for i, wp := range processes {
+			names[i] = worksheet.Name
+			if label := processAttrsLabel(wp.Samples[0].ProcessAttrs, varying); label != "" {
+				names[i] = fmt.Sprintf("%s (%s)", worksheet.Name, label)
+			}
+			count[names[i]]++
+		}
+
+		number := make(map[string]int)
+		for i, wp := range processes {
+			wp.processName = names[i]
+			if count[names[i]] > 1 {
+				number[names[i]]++
+				wp.processName = fmt.Sprintf("%s #%d", names[i], number[names[i]])
+			}
+		}
+	}
+}
+
+// processesInWorksheet returns the processes that were created from the worksheet in instance order.
+func (w *Workflow) processesInWorksheet(worksheet *model.Worksheet) []*WorkflowProcess {
+	var processes []*WorkflowProcess
+	for _, wp := range w.uniqueProcessInstances {
+		if wp.Worksheet == worksheet {
+			processes = append(processes, wp)
+		}
+	}
+
+	sort.Slice(processes, func(i, j int) bool { return processes[i].Instance < processes[j].Instance })
+	return processes
+}
+
+// varyingProcessAttrs returns the names of the process attributes whose values are not the same in all
+// of the processes, in the order the attributes first appear.
+func (w *Workflow) varyingProcessAttrs(processes []*WorkflowProcess) []string {
+	var names []string
+	values := make(map[string]map[string]bool)
+	for _, wp := range processes {
+		for _, attr := range wp.Samples[0].ProcessAttrs {
+			if _, ok := values[attr.Name]; !ok {
+				names = append(names, attr.Name)
+				values[attr.Name] = make(map[string]bool)
+			}
+		}
+	}
+
+	var varying []string
+	for _, name := range names {
+		for _, wp := range processes {
+			key := ""
+			if attr := findAttrByName(wp.Samples[0].ProcessAttrs, name); attr != nil {
+				key = w.attrKey(attr)
+			}
+			values[name][key] = true
+		}
+
+		if len(values[name]) > 1 {
+			varying = append(varying, name)
+		}
+	}
+
+	return varying
+}
+
+// processAttrsLabel formats the values of the named attributes as value+unit separated by slashes.
+func processAttrsLabel(attrs []*model.Attribute, names []string) string {
+	var parts []string
+	for _, name := range names {
+		attr := findAttrByName(attrs, name)
+		if attr == nil || attr.Value == nil {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%v%s", attr.Value["value"], attr.Unit))
+	}
+
+	return strings.Join(parts, "/")
+}
+
+func findAttrByName(attrs []*model.Attribute, name string) *model.Attribute {
+	for _, attr := range attrs {
+		if attr.Name == name {
+			return attr
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package processor
+
+import "github.com/materials-commons/mcetl/internal/spreadsheet/model"
+
+// previousStep returns the sample's row for the step before the sample's step on the worksheet, or nil
+// when it is the sample's first step or the worksheet has no steps. The step before is the input to a
+// step, rather than the sample's parent.
+func previousStep(sample *model.Sample, worksheet *model.Worksheet) *model.Sample {
+	var previous *model.Sample
+	for _, s := range worksheet.Samples {
+		if s.Name == sample.Name && s.Step < sample.Step && (previous == nil || s.Step > previous.Step) {
+			previous = s
+		}
+	}
+
+	return previous
+}
+
+// lastStep returns the sample's row for its last step on the worksheet, which is where the sample goes
+// on from. Without steps it is the sample's first row. It returns nil if the sample isn't on the worksheet.
+func lastStep(sampleName string, worksheet *model.Worksheet) *model.Sample {
+	var last *model.Sample
+	for _, s := range worksheet.Samples {
+		if s.Name == sampleName && (last == nil || s.Step > last.Step) {
+			last = s
+		}
+	}
+
+	return last
+}
@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/materials-commons/mcetl/internal/console"
+)
+
+// DefaultProgressInterval is how often the load report is saved while a load runs.
+const DefaultProgressInterval = 30 * time.Second
+
+// saveProgress writes the load report to ProgressPath, so that a load that is killed or crashes part way
+// through leaves a record of what it created. Unless force is true it is only written when ProgressInterval
+// has passed since it was last written. The report is written to a temporary file that replaces the last
+// one, so a crash while it is being written doesn't lose it.
+func (c *Creater) saveProgress(force bool) {
+	if c.ProgressPath == "" || c.report == nil {
+		return
+	}
+
+	c.progressMu.Lock()
+	defer c.progressMu.Unlock()
+
+	c.mu.Lock()
+	if !force && (c.ProgressInterval <= 0 || time.Since(c.progressSaved) < c.ProgressInterval) {
+		c.mu.Unlock()
+		return
+	}
+	c.progressSaved = time.Now()
+	contents, err := json.MarshalIndent(c.report, "", "  ")
+	c.mu.Unlock()
+
+	if err == nil {
+		err = writeFileAtomically(c.ProgressPath, contents)
+	}
+
+	if err != nil && !c.progressFailed {
+		console.Warning("unable to save the progress of the load:", err)
+		c.progressFailed = true
+	}
+}
+
+// saveProgressOnPanic saves the load report when the load panics, then carries on panicking. It must be
+// deferred.
+func (c *Creater) saveProgressOnPanic() {
+	if r := recover(); r != nil {
+		if c.report != nil {
+			c.mu.Lock()
+			c.report.Error = fmt.Sprintf("panic: %v", r)
+			c.mu.Unlock()
+		}
+		c.saveProgress(true)
+		panic(r)
+	}
+}
+
+// writeFileAtomically writes the contents to a temporary file that then replaces path, so a crash while
+// it is being written doesn't lose the last contents of path.
+func writeFileAtomically(path string, contents []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, contents, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,109 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule is a daily window of time, in local time, when API calls are allowed. Facilities that share
+// a production server use it so that very large loads only run off-peak. The window can wrap around
+// midnight, eg 22:00-06:00.
+type Schedule struct {
+	// Start and End of the window as the time since midnight
+	Start time.Duration
+	End   time.Duration
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// ParseSchedule parses a window of the form HH:MM-HH:MM.
+func ParseSchedule(spec string) (*Schedule, error) {
+	parts := strings.Split(spec, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid schedule '%s', must be of the form HH:MM-HH:MM", spec)
+	}
+
+	start, err := parseTimeOfDay(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule '%s': %s", spec, err)
+	}
+
+	end, err := parseTimeOfDay(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule '%s': %s", spec, err)
+	}
+
+	if start == end {
+		return nil, fmt.Errorf("invalid schedule '%s', start and end are the same", spec)
+	}
+
+	return &Schedule{Start: start, End: end, now: time.Now, sleep: time.Sleep}, nil
+}
+
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a time of day (HH:MM)", value)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (s *Schedule) String() string {
+	return fmt.Sprintf("%s-%s", formatTimeOfDay(s.Start), formatTimeOfDay(s.End))
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+}
+
+// isOpen returns true if t falls within the window.
+func (s *Schedule) isOpen(t time.Time) bool {
+	tod := timeOfDay(t)
+	if s.Start < s.End {
+		return tod >= s.Start && tod < s.End
+	}
+
+	// The window wraps around midnight
+	return tod >= s.Start || tod < s.End
+}
+
+// IsOpen returns true if the window is open now. A nil schedule is always open.
+func (s *Schedule) IsOpen() bool {
+	return s == nil || s.isOpen(s.now())
+}
+
+// untilOpen returns how long it is from t until the window next opens.
+func (s *Schedule) untilOpen(t time.Time) time.Duration {
+	wait := s.Start - timeOfDay(t)
+	if wait < 0 {
+		wait += 24 * time.Hour
+	}
+
+	return wait
+}
+
+func timeOfDay(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+}
+
+// WaitUntilOpen blocks until the window is open. It returns immediately for a nil schedule, or if the
+// window is already open.
+func (s *Schedule) WaitUntilOpen() {
+	if s == nil {
+		return
+	}
+
+	now := s.now()
+	if s.isOpen(now) {
+		return
+	}
+
+	wait := s.untilOpen(now)
+	fmt.Printf("Outside of load window %s, pausing until %s (%s)\n", s, formatTimeOfDay(s.Start), wait)
+	s.sleep(wait)
+	fmt.Println("Load window open, resuming")
+}
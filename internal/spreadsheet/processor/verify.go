@@ -0,0 +1,72 @@
+package processor
+
+/*
+ * verify reads processes back from the server while a load is running and checks them against what was
+ * created, so that a server that is silently losing or mangling data is caught early rather than after a
+ * load that takes hours. With VerifyEvery set to N every Nth process created is read back once its samples
+ * have been added to it, and its name, type and output samples compared to the ones sent. A process that
+ * doesn't match stops the load.
+ *
+ * The measurements are added at the end of the load, so they aren't verified.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/mcapix"
+)
+
+// verifyProcess reads the process created for the step back from the server and checks that it matches
+// what was created.
+func (c *Creater) verifyProcess(wp *WorkflowProcess) error {
+	c.AddCount("verifyProcess")
+	p, err := c.client.GetProcess(c.ProjectID, wp.Process.ID)
+	if err == mcapix.ErrAuth {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("unable to read back process %s (%s) to verify it: %s", wp.Name(), wp.Process.ID, err)
+	}
+
+	var problems []string
+	if p.Name != wp.ProcessName() {
+		problems = append(problems, fmt.Sprintf("its name is '%s' rather than '%s'", p.Name, wp.ProcessName()))
+	}
+
+	if p.ProcessType != wp.Worksheet.ProcessType() {
+		problems = append(problems, fmt.Sprintf("its type is '%s' rather than '%s'", p.ProcessType, wp.Worksheet.ProcessType()))
+	}
+
+	for _, expected := range wp.Out {
+		actual := findSampleByID(expected.ID, p.OutputSamples)
+		switch {
+		case actual == nil:
+			problems = append(problems, fmt.Sprintf("output sample '%s' (%s) is missing", expected.Name, expected.ID))
+		case actual.Name != expected.Name:
+			problems = append(problems, fmt.Sprintf("output sample %s is named '%s' rather than '%s'", expected.ID, actual.Name, expected.Name))
+		case actual.PropertySetID != expected.PropertySetID:
+			problems = append(problems, fmt.Sprintf("output sample '%s' has property set %s rather than %s",
+				expected.Name, actual.PropertySetID, expected.PropertySetID))
+		}
+	}
+
+	if len(problems) != 0 {
+		return fmt.Errorf("process %s (%s) on the server doesn't match what was created: %s",
+			wp.Name(), wp.Process.ID, strings.Join(problems, ", "))
+	}
+
+	c.verified++
+	return nil
+}
+
+// findSampleByID returns the sample with the id, or nil if there isn't one.
+func findSampleByID(id string, samples []*mcapi.Sample) *mcapi.Sample {
+	for _, s := range samples {
+		if s.ID == id {
+			return s
+		}
+	}
+
+	return nil
+}
@@ -42,9 +42,14 @@ package processor
 import (
 	"crypto/sha256"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
-	"github.com/materials-commons/gomcapi"
+	mcapi "github.com/materials-commons/gomcapi"
+	"github.com/materials-commons/mcetl/internal/console"
 	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/units"
 )
 
 // Workflow describes the entire workflow for the set of worksheets being processed
@@ -53,6 +58,10 @@ type Workflow struct {
 	// will be a Create Samples entry for creating each of the samples.
 	root []*WorkflowProcess
 
+	// existing are the processes already on the server that samples in the worksheets come from. Like
+	// the Create Samples processes they start workflows. See existing_processes.go.
+	existing []*WorkflowProcess
+
 	// existingSamples is used to track each of the unique sample instances that need to be created.
 	existingSamples map[string]*model.Sample
 
@@ -62,8 +71,46 @@ type Workflow struct {
 	// that contains the create samples.
 	uniqueProcessInstances map[string]*WorkflowProcess
 
+	// edges records each sample that is sent from one process into another. There is an edge for
+	// each sample row that is wired up, so the same two processes can be connected by several edges.
+	edges []*WorkflowEdge
+
+	// passThroughs are the processes that samples pass through on the worksheets in their parent chains
+	// that don't list them, they are also in uniqueProcessInstances. See parent_chain.go.
+	passThroughs []*WorkflowProcess
+
+	WorkflowOptions
+}
+
+// WorkflowOptions control how the worksheets are turned into a workflow. They are embedded
+// in each of the processors that construct a workflow.
+type WorkflowOptions struct {
 	// Is column 2 treated as a pointer to the parent worksheet?
 	HasParent bool
+
+	// NormalizeUnits converts attribute values with convertible units to a common base unit
+	// before computing process keys, so that 1 h and 60 min are treated as the same process.
+	NormalizeUnits bool
+
+	// CreateProcessName is the name given to the Create Samples processes. If blank the server
+	// default is used.
+	CreateProcessName string
+
+	// SamplesSheet is the name of a worksheet that describes how the samples were created rather than
+	// a process. Its attribute columns (eg creation date, supplier) become the setup attributes of
+	// each sample's Create Samples process.
+	SamplesSheet string
+
+	// ProcessNameAttrs includes the process attribute values that differ between the processes from
+	// a worksheet in the names of the processes created on the server. See nameProcesses.
+	ProcessNameAttrs bool
+
+	// SkipOrphanSamples doesn't create samples that aren't sent into any process, see orphans.go
+	SkipOrphanSamples bool
+
+	// CreationOrder is the order the samples and processes are created in, DepthFirst, BreadthFirst
+	// or Leveled. Blank is DepthFirst. See creation_order.go.
+	CreationOrder string
 }
 
 // WorkflowProcess is a unique process step. Each process step contains all the samples associated with that
@@ -76,6 +123,10 @@ type WorkflowProcess struct {
 	// Unique Process Key
 	Key string
 
+	// Instance is the number of this process within its worksheet. The first unique process
+	// found in a worksheet is instance 1, the next is instance 2, and so on.
+	Instance int
+
 	// Sample that this process was computed for
 	SampleName string
 
@@ -92,6 +143,20 @@ type WorkflowProcess struct {
 	// when the workflow is turned into actual server side entities.
 	Process *mcapi.Process
 
+	// CreateAttrs are the setup attributes for a Create Samples process. They are set from the
+	// samples sheet row for the sample. For other processes this is nil.
+	CreateAttrs []*model.Attribute
+
+	// ExistingProcessID is set when this is a process that is already on the server (see
+	// existing_processes.go). It has no worksheet and isn't created.
+	ExistingProcessID string
+
+	// createName overrides the name of a Create Samples process
+	createName string
+
+	// processName is the name the process is created with on the server (see nameProcesses)
+	processName string
+
 	// Workflow processes that send samples into this process. Essentially forward links for a linked list.
 	To []*WorkflowProcess
 
@@ -99,10 +164,55 @@ type WorkflowProcess struct {
 	From []*WorkflowProcess
 }
 
+// WorkflowEdge is a sample moving from one process into another process.
+type WorkflowEdge struct {
+	From       *WorkflowProcess
+	SampleName string
+	To         *WorkflowProcess
+}
+
 func newWorkflowProcess() *WorkflowProcess {
 	return &WorkflowProcess{}
 }
 
+// Name returns a name that identifies the process in the workflow. Create Sample processes are
+// named "Create Samples" unless a create process name was given, other processes are named after
+// their worksheet and instance number.
+func (wp *WorkflowProcess) Name() string {
+	if wp.ExistingProcessID != "" {
+		return ExistingProcessPrefix + wp.ExistingProcessID
+	}
+
+	if wp.Worksheet == nil {
+		if wp.createName != "" {
+			return wp.createName
+		}
+		return "Create Samples"
+	}
+
+	return fmt.Sprintf("%s #%d", wp.Worksheet.Name, wp.Instance)
+}
+
+// ProcessName returns the name to create the process with on the server. This differs from Name() in
+// that it is only numbered when the worksheet results in several processes, and can include attribute values.
+func (wp *WorkflowProcess) ProcessName() string {
+	if wp.processName == "" && wp.Worksheet != nil {
+		return wp.Worksheet.Name
+	}
+
+	return wp.processName
+}
+
+// sourceFile returns the spreadsheet the process was loaded from. Create Samples processes don't
+// come from a worksheet so they have no source file.
+func (wp *WorkflowProcess) sourceFile() string {
+	if wp.Worksheet == nil {
+		return ""
+	}
+
+	return wp.Worksheet.File
+}
+
 func newWorkflow() *Workflow {
 	return &Workflow{
 		existingSamples:        make(map[string]*model.Sample),
@@ -110,6 +220,28 @@ func newWorkflow() *Workflow {
 	}
 }
 
+// getInputSamples goes to the parent workflow processes and constructs the list
+// of samples that are input into the workflow process. Only the samples on the
+// process's rows are input, a parent such as an existing process can output others.
+func (wp *WorkflowProcess) getInputSamples() []*mcapi.Sample {
+	names := make(map[string]bool)
+	for _, sample := range wp.Samples {
+		names[sample.Name] = true
+	}
+
+	var samples []*mcapi.Sample
+	// A WorkflowProcess contains a pointer to its parent workflow processes, this allows
+	// it to retrieve all samples from the parent workflow process steps.
+	for _, parentWorkflow := range uniqueProcesses(wp.From) {
+		for _, sample := range parentWorkflow.Out {
+			if names[sample.Name] {
+				samples = append(samples, sample)
+			}
+		}
+	}
+	return samples
+}
+
 // constructWorkflow creates the workflow as described in the module following the 3 outlined steps.
 func (w *Workflow) constructWorkflow(worksheets []*model.Worksheet) {
 	// 1. Top level processes are all create sample processes
@@ -117,33 +249,64 @@ func (w *Workflow) constructWorkflow(worksheets []*model.Worksheet) {
 
 	// 2. Create a map containing all the unique processes
 	w.createUniqueProcessesMap(worksheets)
+	w.createPassThroughProcesses(worksheets)
+	w.nameProcesses()
 
 	// 3. Connect processes by going through the worksheet and looking at the parent attribute.
 	//    The parent will point to a sample on a worksheet, which means, for our purposes,
 	//    that is the process that is sending that sample instance into this process.
 	w.wireupWorkflow(worksheets)
+
+	// 4. Optionally drop the samples that weren't wired into any process
+	if w.SkipOrphanSamples {
+		w.removeOrphanSamples()
+	}
 }
 
 // createSampleProcesses goes through all the worksheets and identifies all the
 // samples that need to be created. It then adds them to the root field in the workflow.
 func (w *Workflow) createSampleProcesses(worksheets []*model.Worksheet) {
-	// Build up a list of unique samples that need to be created
+	// Build up a list of unique samples that need to be created. The list is kept in the order the samples
+	// are first seen in the worksheets so that the workflow is the same each time it is constructed.
+	var sampleNames []string
+	createAttrs := make(map[string][]*model.Attribute)
 	for _, worksheet := range worksheets {
 		for _, sample := range worksheet.Samples {
 			if _, ok := w.existingSamples[sample.Name]; !ok {
 				w.existingSamples[sample.Name] = sample
+				sampleNames = append(sampleNames, sample.Name)
+			}
+
+			if w.isSamplesSheet(worksheet) {
+				// All the attribute columns in the samples sheet describe how the sample was created
+				createAttrs[sample.Name] = append(createAttrs[sample.Name], sample.ProcessAttrs...)
+				createAttrs[sample.Name] = append(createAttrs[sample.Name], sample.Attributes...)
 			}
 		}
 	}
 
-	// Now add all those as top level nodes in the root. These are all "out" samples.
-	for sampleName := range w.existingSamples {
+	// Now add all those as top level nodes in the root. These are all "out" samples. Samples that
+	// come from processes already on the server don't need to be created.
+	fromExisting := w.samplesFromExistingProcesses(worksheets)
+	for _, sampleName := range sampleNames {
+		if fromExisting[sampleName] {
+			continue
+		}
+
 		node := newWorkflowProcess()
 		node.Samples = append(node.Samples, w.existingSamples[sampleName])
+		node.CreateAttrs = createAttrs[sampleName]
+		node.createName = w.CreateProcessName
 		w.root = append(w.root, node)
 	}
 }
 
+// isSamplesSheet returns true if the worksheet is the samples sheet. The samples sheet only
+// contributes to the Create Samples processes, no processes are created for it.
+func (w *Workflow) isSamplesSheet(worksheet *model.Worksheet) bool {
+	return w.SamplesSheet != "" && worksheet.Name == w.SamplesSheet
+}
+
 // createUniqueProcessesMap goes through the worksheet and identifies all the unique process
 // instances that need to be created. For example, in a worksheet a process will be created
 // whenever the process attributes in that worksheet are uniquely specified. So a particular
@@ -151,15 +314,22 @@ func (w *Workflow) createSampleProcesses(worksheets []*model.Worksheet) {
 // be of the same "type".
 func (w *Workflow) createUniqueProcessesMap(worksheets []*model.Worksheet) {
 	for _, worksheet := range worksheets {
+		if w.isSamplesSheet(worksheet) {
+			continue
+		}
+
+		instance := 0
 		for _, sample := range worksheet.Samples {
 			// Create a unique key for this process. This key is constructed based on the worksheet
 			// name and the process attributes. This allows us to track all the unique process instances.
-			key := w.makeSampleInstanceKey(sample, worksheet.Name)
+			key := w.makeSampleInstanceKey(sample, worksheet)
 			if wp, ok := w.uniqueProcessInstances[key]; !ok {
 				// There is no instance for this process so create it and insert it into uniqueProcessInstances
+				instance++
 				wp := newWorkflowProcess()
 				wp.SampleName = sample.Name
 				wp.Key = key
+				wp.Instance = instance
 				wp.Worksheet = worksheet
 				wp.Samples = append(wp.Samples, sample)
 				w.uniqueProcessInstances[key] = wp
@@ -193,19 +363,30 @@ func (w *Workflow) wireupWorkflow(worksheets []*model.Worksheet) {
 	var parentProcess *WorkflowProcess
 
 	for _, worksheet := range worksheets {
+		if w.isSamplesSheet(worksheet) {
+			continue
+		}
+
 		for _, sample := range worksheet.Samples {
 
 			// First get the process from the worksheet that we are sending the sample to
-			uniqueProcessFromWorksheet := w.findProcessFromSampleInWorksheet(sample, worksheet.Name)
+			uniqueProcessFromWorksheet := w.findProcessFromSampleInWorksheet(sample, worksheet)
 			if uniqueProcessFromWorksheet == nil {
 				// If this happens then we have a bug in the code for creating all the unique process instances
 				// because this means we've found a process that isn't in that map.
-				fmt.Printf("Bug: Can't find matching process to wire up %s %#v\n", worksheet.Name, sample)
+				console.Errorf("Bug: Can't find matching process to wire up %s %#v\n", worksheet.Source(), sample)
 				continue
 			}
 
-			// If Parent is blank then the input sample is from the original list of created samples
-			if sample.Parent == "" {
+			// If Parent is blank then the input sample is from the original list of created samples. The
+			// samples sheet describes the created samples, so a Parent pointing at it is the same as blank.
+			if previous := previousStep(sample, worksheet); previous != nil {
+				// A sample's later steps on a worksheet take it from the step before, see process_steps.go
+				parentProcess = w.findProcessFromSampleInWorksheet(previous, worksheet)
+			} else if id, ok := ExistingProcessID(sample.Parent); ok {
+				// The sample comes from a process that is already on the server
+				parentProcess = w.findExistingProcess(id)
+			} else if sample.Parent == "" || sample.Parent == w.SamplesSheet {
 				// Find the create sample process that is going to feed the sample into this process.
 				parentProcess = w.findMatchingCreateSampleProcess(sample.Name)
 			} else {
@@ -216,13 +397,20 @@ func (w *Workflow) wireupWorkflow(worksheets []*model.Worksheet) {
 
 			if parentProcess == nil {
 				// Should never happen
-				fmt.Println("Bug: Can't find matching create sample process for ", sample.Name)
+				console.Error("Bug: Can't find matching create sample process for ", sample.Name)
 				continue
 			}
 
 			w.wireProcessesTogetherFromTo(parentProcess, uniqueProcessFromWorksheet)
+			w.edges = append(w.edges, &WorkflowEdge{
+				From:       parentProcess,
+				SampleName: sample.Name,
+				To:         uniqueProcessFromWorksheet,
+			})
 		}
 	}
+
+	w.wirePassThroughProcesses(worksheets)
 }
 
 // wireProcessesTogetherFromTo wires the processes together point correctly setting up the links
@@ -233,10 +421,10 @@ func (w *Workflow) wireProcessesTogetherFromTo(fromProcess, toProcess *WorkflowP
 }
 
 // findProcessFromSampleInWorksheet creates the unique name to look up a process process in uniqueProcessInstances.
-func (w *Workflow) findProcessFromSampleInWorksheet(sample *model.Sample, worksheetName string) *WorkflowProcess {
-	key := w.makeSampleInstanceKey(sample, worksheetName)
+func (w *Workflow) findProcessFromSampleInWorksheet(sample *model.Sample, worksheet *model.Worksheet) *WorkflowProcess {
+	key := w.makeSampleInstanceKey(sample, worksheet)
 	if instance, ok := w.uniqueProcessInstances[key]; !ok {
-		fmt.Printf("Warning: Can't find matching process to wire up %s %#v\n", worksheetName, sample)
+		console.Warningf("Can't find matching process to wire up %s %#v\n", worksheet.Name, sample)
 		return nil
 	} else {
 		return instance
@@ -260,25 +448,28 @@ func (w *Workflow) findMatchingCreateSampleProcess(sampleName string) *WorkflowP
 }
 
 // findMatchingEntry finds the workflow process that matches the given sample in a worksheet. It first goes
-// through all the worksheets finding the worksheet (by name) then it goes through the samples in that worksheet
-// and for each sample that matches the sampleName it creates the unique key to look up the process in the
-// uniqueProcessInstances map. This should always find a match.
+// through all the worksheets finding the worksheet (by name) then it finds the sample's row in that worksheet,
+// the row for its last step when the worksheet has steps, and creates the unique key to look up the process
+// in the uniqueProcessInstances map. A sample that the worksheet doesn't list can pass through a process
+// on it from a parent chain. This should always find a match.
 func (w *Workflow) findMatchingEntry(sampleName, worksheetName string, worksheets []*model.Worksheet) *WorkflowProcess {
 	for _, worksheet := range worksheets {
 		if worksheet.Name == worksheetName {
-			for _, sample := range worksheet.Samples {
-				if sample.Name == sampleName {
-					key := w.makeSampleInstanceKey(sample, worksheetName)
-					if instance, ok := w.uniqueProcessInstances[key]; !ok {
-						return nil
-					} else {
-						return instance
-					}
+			if sample := lastStep(sampleName, worksheet); sample != nil {
+				key := w.makeSampleInstanceKey(sample, worksheet)
+				if instance, ok := w.uniqueProcessInstances[key]; !ok {
+					return nil
+				} else {
+					return instance
 				}
 			}
 		}
 	}
 
+	if wp, ok := w.uniqueProcessInstances[passThroughKey(worksheetName, sampleName)]; ok {
+		return wp
+	}
+
 	return nil
 }
 
@@ -286,19 +477,71 @@ func (w *Workflow) findMatchingEntry(sampleName, worksheetName string, worksheet
 // is used to store the unique processes. A key is constructed from the sample name and all its
 // process attributes. We then run sha256 on it and get the hex key to create the unique key for
 // that combination.
-func (w *Workflow) makeSampleInstanceKey(sample *model.Sample, starting string) string {
-	key := starting
-	for _, attr := range sample.ProcessAttrs {
-		key = fmt.Sprintf("%s%s%#v", key, attr.Unit, attr.Value)
+//
+// The attributes are sorted by name before they are added to the key so that reordering the columns
+// in a worksheet doesn't change the key. Keys created before attributes were sorted (and named) in
+// the key are different, so processes identified by the old keys need to be identified again by
+// reprocessing the worksheets.
+//
+// A worksheet can have a parent column without HasParent when it was given one by an index sheet. The
+// step of a sample with a step is part of the key, keys without steps are unchanged.
+func (w *Workflow) makeSampleInstanceKey(sample *model.Sample, worksheet *model.Worksheet) string {
+	key := worksheet.Name + w.attrsKey(sample.ProcessAttrs)
+
+	if !w.HasParent && !worksheet.HasParent {
+		key = key + w.attrsKey(sample.Attributes)
 	}
 
-	if !w.HasParent {
-		for _, attr := range sample.Attributes {
-			key = fmt.Sprintf("%s%s%#v", key, attr.Unit, attr.Value)
-		}
+	// Steps of the same process with the same attributes are still separate processes
+	if sample.Step != 0 {
+		key = fmt.Sprintf("%s\x00step=%d", key, sample.Step)
 	}
 
 	key = fmt.Sprintf("%s%s", sample.Name, key)
 
 	return fmt.Sprintf("%x", sha256.Sum256([]byte(key)))
 }
+
+// attrsKey returns the part of a process key for a list of attributes. Each attribute's part
+// includes its name, and the parts are sorted so that the key doesn't depend on column order.
+func (w *Workflow) attrsKey(attrs []*model.Attribute) string {
+	var parts []string
+	for _, attr := range attrs {
+		parts = append(parts, fmt.Sprintf("%s=%s", attr.Name, w.attrKey(attr)))
+	}
+
+	sort.Strings(parts)
+	return strings.Join(parts, "\x00")
+}
+
+// attrKey returns the part of a process key for a single attribute. When NormalizeUnits is set
+// numeric values with a known unit are converted to the base unit of that unit's dimension so
+// that equivalent values written in different units produce the same key.
+func (w *Workflow) attrKey(attr *model.Attribute) string {
+	if w.NormalizeUnits {
+		if value, ok := numericValue(attr.Value["value"]); ok {
+			if normalized, unit, ok := units.Normalize(value, attr.Unit); ok {
+				// Format with fewer digits than a float64 holds so that rounding noise from
+				// the conversion (eg 673.1500000000001) doesn't change the key.
+				return fmt.Sprintf("%s%s", unit, strconv.FormatFloat(normalized, 'g', 12, 64))
+			}
+		}
+	}
+
+	return fmt.Sprintf("%s%#v", attr.Unit, attr.Value)
+}
+
+// numericValue returns the value as a float64 if it is one of the numeric types the cell
+// converter creates.
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
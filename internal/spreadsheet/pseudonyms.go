@@ -0,0 +1,130 @@
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// Pseudonyms replaces sample names with pseudonyms so that internal specimen identifiers aren't put on a
+// shared server. The pseudonyms are the prefix followed by a number, eg S-0001, assigned in the order the
+// samples first appear. The mapping from sample name to pseudonym is kept in a CSV file (sample,pseudonym).
+// An existing mapping file is read first and its pseudonyms reused, so a sample keeps its pseudonym
+// across loads as long as the same mapping file is given.
+type Pseudonyms struct {
+	// Path is the mapping file
+	Path string
+
+	// Prefix starts each new pseudonym
+	Prefix string
+
+	pseudonyms map[string]string
+	used       map[string]bool
+	order      []string
+}
+
+// LoadPseudonyms reads the mapping file at path if it exists. If it doesn't exist the mapping starts
+// empty and the file is created by Apply.
+func LoadPseudonyms(path, prefix string) (*Pseudonyms, error) {
+	p := &Pseudonyms{
+		Path:       path,
+		Prefix:     prefix,
+		pseudonyms: make(map[string]string),
+		used:       make(map[string]bool),
+	}
+
+	f, err := os.Open(path)
+	switch {
+	case os.IsNotExist(err):
+		return p, nil
+	case err != nil:
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read pseudonyms file %s: %s", path, err)
+		}
+
+		if line == 1 && record[0] == "sample" && record[1] == "pseudonym" {
+			continue
+		}
+
+		if _, ok := p.pseudonyms[record[0]]; ok {
+			return nil, fmt.Errorf("pseudonyms file %s line %d: sample '%s' is listed more than once", path, line, record[0])
+		}
+
+		if p.used[record[1]] {
+			return nil, fmt.Errorf("pseudonyms file %s line %d: pseudonym '%s' is used more than once", path, line, record[1])
+		}
+
+		p.add(record[0], record[1])
+	}
+
+	return p, nil
+}
+
+// Apply implements the Processor interface. It replaces the sample names in the worksheets with their
+// pseudonyms, giving new samples the next unused pseudonym, and writes the mapping file.
+func (p *Pseudonyms) Apply(worksheets []*model.Worksheet) error {
+	for _, worksheet := range worksheets {
+		for _, sample := range worksheet.Samples {
+			sample.Name = p.pseudonym(sample.Name)
+		}
+	}
+
+	return p.save()
+}
+
+// pseudonym returns the pseudonym for the sample, assigning it one if it doesn't have one yet.
+func (p *Pseudonyms) pseudonym(name string) string {
+	if pseudonym, ok := p.pseudonyms[name]; ok {
+		return pseudonym
+	}
+
+	for n := len(p.order) + 1; ; n++ {
+		pseudonym := fmt.Sprintf("%s%04d", p.Prefix, n)
+		if !p.used[pseudonym] {
+			p.add(name, pseudonym)
+			return pseudonym
+		}
+	}
+}
+
+func (p *Pseudonyms) add(name, pseudonym string) {
+	p.pseudonyms[name] = pseudonym
+	p.used[pseudonym] = true
+	p.order = append(p.order, name)
+}
+
+// save writes the mapping file. It is only readable by the user as it reveals the sample names.
+func (p *Pseudonyms) save() error {
+	f, err := os.OpenFile(p.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"sample", "pseudonym"}); err != nil {
+		return err
+	}
+
+	for _, name := range p.order {
+		if err := w.Write([]string{name, p.pseudonyms[name]}); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
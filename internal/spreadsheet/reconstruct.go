@@ -0,0 +1,433 @@
+package spreadsheet
+
+/*
+ * reconstruct rebuilds a workbook from a bundle (see processor/bundler.go), so that what a load wrote to
+ * the bundle can be reviewed, or loaded again, as a spreadsheet. Only bundles are read, a workflow created
+ * through the API can't be rebuilt. The workbook has a worksheet for each kind of process, named after
+ * it, with the layout mcetl loads with --has-parent:
+ *
+ *   |sample|parent|p:Temperature(C)|s:Hardness(HV)|file-in:|
+ *
+ * The parent column names the worksheet of the process that the sample came from, is blank for samples
+ * that come straight from their Create Samples process, and is mc:process/<id> for samples continued from
+ * a process already on the server. A measurement made more than once, eg a hardness measured three times,
+ * is written as repeated columns with the same header.
+ *
+ * If the samples were created with attributes, or some samples aren't used by any process, they are
+ * written to a Samples worksheet that is loaded with --samples-sheet.
+ *
+ * Values that were loaded as objects, such as compositions, are written as their JSON text.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/materials-commons/mcetl/internal/dates"
+	"github.com/materials-commons/mcetl/internal/mcapix"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+)
+
+// maxSheetNameLength is the longest worksheet name Excel allows
+const maxSheetNameLength = 31
+
+// invalidSheetNameCharacters are the characters Excel doesn't allow in a worksheet name
+const invalidSheetNameCharacters = `[]:*?/\`
+
+// Reconstruction describes the workbook written by Reconstruct and how to load it.
+type Reconstruction struct {
+	// Worksheets are the names of the worksheets written, in order
+	Worksheets []string
+
+	// SamplesSheet is the name of the worksheet the samples were written to, or blank if they
+	// didn't need one
+	SamplesSheet string
+
+	// CreateProcessName and ExperimentName are what the bundle was loaded with
+	CreateProcessName string
+	ExperimentName    string
+
+	// LinkFilesToSamples is true if the bundle's files were also linked to their samples
+	LinkFilesToSamples bool
+}
+
+// reconstructedSheet is a worksheet being rebuilt from the processes of one kind.
+type reconstructedSheet struct {
+	name string
+
+	// processHeaders and sampleHeaders are the attribute headers in the order first seen, with the
+	// number of columns each needs
+	processHeaders []string
+	sampleHeaders  []string
+	columns        map[string]int
+
+	fileIns  int
+	fileOuts int
+	rows     []*reconstructedRow
+}
+
+type reconstructedRow struct {
+	sample   string
+	parent   string
+	values   map[string][]interface{}
+	fileIns  []string
+	fileOuts []string
+}
+
+// Reconstruct writes the workflow in the bundle to a workbook at path.
+func Reconstruct(bundle *processor.Bundle, path string) (*Reconstruction, error) {
+	var (
+		sheets    []*reconstructedSheet
+		usedNames = make(map[string]bool)
+		byKind    = make(map[string]*reconstructedSheet)
+
+		// producedBy maps a property set to the worksheet of the process that produced it
+		producedBy = make(map[string]string)
+	)
+
+	reconstruction := &Reconstruction{}
+	if bundle.Experiment != nil {
+		reconstruction.ExperimentName = bundle.Experiment.Name
+	}
+
+	samplesSheet := samplesSheetFor(bundle)
+	if samplesSheet != nil {
+		samplesSheet.name = uniqueSheetName("Samples", usedNames)
+		reconstruction.SamplesSheet = samplesSheet.name
+	}
+
+	for _, bp := range bundle.Processes {
+		kind := bp.SourceFile + "\x00" + bp.ProcessType
+		sheet, ok := byKind[kind]
+		if !ok {
+			sheet = &reconstructedSheet{name: uniqueSheetName(bp.ProcessType, usedNames), columns: make(map[string]int)}
+			byKind[kind] = sheet
+			sheets = append(sheets, sheet)
+		}
+
+		sheet.addProcess(bp, producedBy)
+		for _, ref := range bp.OutputSamples {
+			if len(ref.Files) != 0 {
+				reconstruction.LinkFilesToSamples = true
+			}
+		}
+	}
+
+	for _, s := range bundle.Samples {
+		if s.ProcessName != "" {
+			reconstruction.CreateProcessName = s.ProcessName
+		}
+
+		if samplesSheet != nil {
+			samplesSheet.addSample(s)
+		}
+	}
+
+	if samplesSheet != nil {
+		sheets = append([]*reconstructedSheet{samplesSheet}, sheets...)
+	}
+
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("the bundle has no samples or processes")
+	}
+
+	f := excelize.NewFile()
+	for i, sheet := range sheets {
+		if i == 0 {
+			f.SetSheetName("Sheet1", sheet.name)
+		} else {
+			f.NewSheet(sheet.name)
+		}
+
+		sheet.write(f, sheet != samplesSheet)
+		reconstruction.Worksheets = append(reconstruction.Worksheets, sheet.name)
+	}
+
+	if err := f.SaveAs(path); err != nil {
+		return nil, err
+	}
+
+	return reconstruction, nil
+}
+
+// samplesSheetFor returns the sheet for the samples if they need one, that is if any were created
+// with attributes or aren't the input to a process. It returns nil otherwise.
+func samplesSheetFor(bundle *processor.Bundle) *reconstructedSheet {
+	used := make(map[string]bool)
+	for _, bp := range bundle.Processes {
+		for _, ref := range bp.InputSamples {
+			used[ref.SampleID] = true
+		}
+	}
+
+	for _, s := range bundle.Samples {
+		if len(s.Setup) != 0 || !used[s.ID] {
+			return &reconstructedSheet{columns: make(map[string]int)}
+		}
+	}
+
+	return nil
+}
+
+// addSample adds a row for a sample to the samples sheet, with the attributes it was created with.
+func (s *reconstructedSheet) addSample(bs *processor.BundleSample) {
+	row := &reconstructedRow{sample: bs.Name, values: make(map[string][]interface{})}
+	for _, setup := range bs.Setup {
+		for _, p := range setup.Properties {
+			s.addValue(row, &s.processHeaders, attributeHeader("p", p.Name, p.Unit), p.Value)
+		}
+	}
+
+	s.rows = append(s.rows, row)
+}
+
+// addProcess adds a row to the sheet for each sample the process outputs.
+func (s *reconstructedSheet) addProcess(bp *processor.BundleProcess, producedBy map[string]string) {
+	measurements := make(map[string][]mcapix.SampleProperty)
+	for _, m := range bp.Measurements {
+		measurements[m.PropertySetID] = m.Attributes
+	}
+
+	dateName := performedAttribute(bp)
+	directions := make(map[string]string)
+	for _, file := range bp.Files {
+		directions[file.Path] = file.Direction
+	}
+
+	var (
+		firstRow *reconstructedRow
+		linked   bool
+	)
+	for i, out := range bp.OutputSamples {
+		row := &reconstructedRow{sample: out.Name, values: make(map[string][]interface{})}
+		if i < len(bp.InputSamples) {
+			in := bp.InputSamples[i]
+			if in.ExistingProcessID != "" {
+				row.parent = processor.ExistingProcessPrefix + in.ExistingProcessID
+			} else {
+				row.parent = producedBy[in.PropertySetID]
+			}
+		}
+		producedBy[out.PropertySetID] = s.name
+
+		for _, setup := range bp.Setup {
+			for _, p := range setup.Properties {
+				keyword := "p"
+				if p.Name == dateName {
+					keyword = "date"
+				}
+				s.addValue(row, &s.processHeaders, attributeHeader(keyword, p.Name, p.Unit), p.Value)
+			}
+		}
+
+		// The measurements aren't kept in column order, so they are written in name order
+		attrs := measurements[out.PropertySetID]
+		sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name < attrs[j].Name })
+		for _, attr := range attrs {
+			for _, m := range attr.Measurements {
+				s.addValue(row, &s.sampleHeaders, attributeHeader("s", attr.Name, m.Unit), m.Value)
+			}
+		}
+
+		for _, path := range out.Files {
+			row.addFile(path, directions[path])
+			linked = true
+		}
+
+		if firstRow == nil {
+			firstRow = row
+		}
+		s.rows = append(s.rows, row)
+	}
+
+	// Unless the files were linked to the samples the bundle doesn't record which row each file came
+	// from, so they go on the process's first row
+	if firstRow != nil && !linked {
+		seen := make(map[string]bool)
+		for _, file := range bp.Files {
+			if !seen[file.Path] {
+				seen[file.Path] = true
+				firstRow.addFile(file.Path, file.Direction)
+			}
+		}
+	}
+}
+
+func (r *reconstructedRow) addFile(path, direction string) {
+	if direction == model.FileDirectionOut {
+		r.fileOuts = append(r.fileOuts, path)
+	} else {
+		r.fileIns = append(r.fileIns, path)
+	}
+}
+
+// addValue adds a value for the header to the row, adding a column for the header if the row
+// needs more than the sheet has.
+func (s *reconstructedSheet) addValue(row *reconstructedRow, headers *[]string, header string, value interface{}) {
+	row.values[header] = append(row.values[header], value)
+	if _, ok := s.columns[header]; !ok {
+		*headers = append(*headers, header)
+	}
+
+	if len(row.values[header]) > s.columns[header] {
+		s.columns[header] = len(row.values[header])
+	}
+}
+
+// write writes the sheet to the workbook.
+func (s *reconstructedSheet) write(f *excelize.File, hasParent bool) {
+	headers := []string{"sample"}
+	if hasParent {
+		headers = append(headers, "parent")
+	}
+
+	firstAttributeColumn := len(headers) + 1
+	for _, header := range append(append([]string{}, s.processHeaders...), s.sampleHeaders...) {
+		for i := 0; i < s.columns[header]; i++ {
+			headers = append(headers, header)
+		}
+	}
+
+	for _, row := range s.rows {
+		if len(row.fileIns) > s.fileIns {
+			s.fileIns = len(row.fileIns)
+		}
+		if len(row.fileOuts) > s.fileOuts {
+			s.fileOuts = len(row.fileOuts)
+		}
+	}
+
+	for i := 0; i < s.fileIns; i++ {
+		headers = append(headers, "file-in:")
+	}
+	for i := 0; i < s.fileOuts; i++ {
+		headers = append(headers, "file-out:")
+	}
+
+	for i, header := range headers {
+		f.SetCellValue(s.name, cellAxis(i+1, 1), header)
+	}
+
+	for r, row := range s.rows {
+		rowIndex := r + 2
+		f.SetCellValue(s.name, cellAxis(1, rowIndex), row.sample)
+		if hasParent && row.parent != "" {
+			f.SetCellValue(s.name, cellAxis(2, rowIndex), row.parent)
+		}
+
+		// A header repeated over several columns takes the row's values in turn
+		taken := make(map[string]int)
+		column := firstAttributeColumn
+		for _, header := range headers[firstAttributeColumn-1:] {
+			switch header {
+			case "file-in:", "file-out:":
+				continue
+			}
+
+			if values := row.values[header]; taken[header] < len(values) {
+				if value := reconstructedCellValue(values[taken[header]]); value != nil {
+					f.SetCellValue(s.name, cellAxis(column, rowIndex), value)
+				}
+			}
+			taken[header]++
+			column++
+		}
+
+		for i, path := range row.fileIns {
+			f.SetCellValue(s.name, cellAxis(column+i, rowIndex), path)
+		}
+		for i, path := range row.fileOuts {
+			f.SetCellValue(s.name, cellAxis(column+s.fileIns+i, rowIndex), path)
+		}
+	}
+}
+
+// performedAttribute returns the name of the process attribute the process's date came from, or
+// blank if it wasn't given a date.
+func performedAttribute(bp *processor.BundleProcess) string {
+	if bp.Performed == "" {
+		return ""
+	}
+
+	performed, err := time.Parse(time.RFC3339, bp.Performed)
+	if err != nil {
+		return ""
+	}
+
+	for _, setup := range bp.Setup {
+		for _, p := range setup.Properties {
+			if value, ok := p.Value.(string); ok {
+				if date, err := dates.Parse(value); err == nil && date.Equal(performed) {
+					return p.Name
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// reconstructedCellValue returns the value to write to a cell. Objects and lists are written as
+// their JSON text, and nil is not written.
+func reconstructedCellValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string, float64, bool:
+		return v
+	default:
+		text, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(text)
+	}
+}
+
+func attributeHeader(keyword, name, unit string) string {
+	if unit == "" {
+		return fmt.Sprintf("%s:%s", keyword, name)
+	}
+
+	return fmt.Sprintf("%s:%s(%s)", keyword, name, unit)
+}
+
+func cellAxis(column, row int) string {
+	return fmt.Sprintf("%s%d", model.ColumnName(column), row)
+}
+
+// uniqueSheetName returns name, or name with a number added if a worksheet already has that name.
+// Names are shortened to the length Excel allows, and the characters Excel doesn't allow are replaced.
+func uniqueSheetName(name string, used map[string]bool) string {
+	name = strings.Map(func(c rune) rune {
+		if strings.ContainsRune(invalidSheetNameCharacters, c) {
+			return '-'
+		}
+		return c
+	}, name)
+	if name == "" {
+		name = "Sheet"
+	}
+
+	candidate := truncateSheetName(name, "")
+	for i := 2; used[candidate]; i++ {
+		candidate = truncateSheetName(name, fmt.Sprintf(" %d", i))
+	}
+
+	used[candidate] = true
+	return candidate
+}
+
+func truncateSheetName(name, suffix string) string {
+	runes := []rune(name)
+	if max := maxSheetNameLength - len(suffix); len(runes) > max {
+		runes = runes[:max]
+	}
+
+	return string(runes) + suffix
+}
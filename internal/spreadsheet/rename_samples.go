@@ -0,0 +1,15 @@
+package spreadsheet
+
+import "github.com/materials-commons/mcetl/internal/spreadsheet/model"
+
+// RenameSamples renames the samples in the worksheets, on every row they are on. renames maps the
+// current names to the new names.
+func RenameSamples(worksheets []*model.Worksheet, renames map[string]string) {
+	for _, worksheet := range worksheets {
+		for _, sample := range worksheet.Samples {
+			if name, ok := renames[sample.Name]; ok {
+				sample.Name = name
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package spreadsheet
+
+/*
+ * rounding rounds the decimal values in a column to the precision they were measured to, rather than storing
+ * the digits of noise that instrument exports carry, eg 12.340000000000002. The rule is given in the schema,
+ * either as significant figures or as decimal places:
+ *
+ *   columns:
+ *     - name: Hardness
+ *       significant_figures: 3
+ *     - name: Thickness
+ *       decimal_places: 2
+ *
+ * Numbers in arrays, objects and compositions are rounded too. Whole numbers are never changed.
+ */
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// validateRounding checks the column's rounding rule. Only one of significant figures and decimal
+// places can be given.
+func (c *ColumnSchema) validateRounding() error {
+	switch {
+	case c.SignificantFigures != 0 && c.DecimalPlaces != nil:
+		return fmt.Errorf("column '%s' has both significant_figures and decimal_places", c.Name)
+	case c.SignificantFigures < 0:
+		return fmt.Errorf("column '%s' significant_figures must be at least 1", c.Name)
+	case c.DecimalPlaces != nil && *c.DecimalPlaces < 0:
+		return fmt.Errorf("column '%s' decimal_places can't be negative", c.Name)
+	}
+
+	return nil
+}
+
+// rounds returns true if the column rule rounds the values.
+func (c *ColumnSchema) rounds() bool {
+	return c != nil && (c.SignificantFigures != 0 || c.DecimalPlaces != nil)
+}
+
+// round returns the value with its decimal numbers rounded by the column rule.
+func (c *ColumnSchema) round(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		return c.roundFloat(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = c.round(v[i])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = c.round(v[key])
+		}
+	}
+
+	return value
+}
+
+// roundFloat rounds a decimal number. It is rounded by formatting it, which rounds to the nearest
+// decimal digit rather than leaving binary noise, eg 0.1 + 0.2 to 1 decimal place is 0.3.
+func (c *ColumnSchema) roundFloat(value float64) float64 {
+	var formatted string
+	if c.DecimalPlaces != nil {
+		formatted = strconv.FormatFloat(value, 'f', *c.DecimalPlaces, 64)
+	} else {
+		formatted = strconv.FormatFloat(value, 'g', c.SignificantFigures, 64)
+	}
+
+	rounded, err := strconv.ParseFloat(formatted, 64)
+	if err != nil {
+		return value
+	}
+
+	return rounded
+}
@@ -4,10 +4,11 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/console"
 	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
-	"github.com/pkg/errors"
 )
 
 // rowProcessor handles processing of each row of a worksheet
@@ -25,27 +26,87 @@ type rowProcessor struct {
 	// converter is used to convert sample or process attribute cells that
 	// aren't blank into their relevant type (float, object, int, etc...)
 	converter *cellConverter
+
+	// schema contains the optional column level rules, it may be nil
+	schema *Schema
+
+	// crosstab is true when the worksheet is laid out as a cross-tab of samples vs condition
+	// levels. conditionLevels maps each condition level column to its header (see crosstab.go).
+	crosstab        bool
+	conditionLevels map[int]conditionLevel
+
+	// When fillParentDown is true a blank parent cell means the same parent as the previous row,
+	// rather than a created sample. previousParent is the last non blank parent seen.
+	fillParentDown bool
+	previousParent string
+
+	// When convertCellUnits is true a value with its own unit, eg "350 K" in a Temperature(C) column, is
+	// converted to the column's unit. Otherwise the value keeps its own unit. See cell_units.go.
+	convertCellUnits bool
+
+	// attrFilter selects the attribute columns to load, nil loads them all
+	attrFilter *attributeFilter
+
+	// columnTypes overrides the column type given by the header keyword for some columns, it may be nil
+	columnTypes map[int]ColumnAttributeType
+
+	// When fileDirectionByPosition is true file columns without file-in: or file-out: are inputs if they
+	// come before the process attribute columns and outputs if they come after them. See file_direction.go.
+	fileDirectionByPosition bool
+
+	// maxCellSize is the most characters a data cell can have, 0 means no limit. longCells is what to do
+	// with a cell over the limit. See cell_guard.go.
+	maxCellSize int
+	longCells   string
+
+	// sheetNames are the names of all the worksheets being loaded, see parent_chain.go
+	sheetNames map[string]bool
+
+	// constants are the process attributes from the worksheet's const: rows, see constants_row.go
+	constants []*model.Attribute
+
+	// patternGroups are the columns from combined pattern headers, see pattern_headers.go
+	patternGroups []*patternGroup
+
+	// lookups are the lookup worksheets by name, and lookupColumns the lookup worksheet of each
+	// lookup: column. See lookup_sheet.go.
+	lookups       map[string]*lookupTable
+	lookupColumns map[int]*lookupTable
+
+	// declaredTypes are the types the attribute headers declare for their columns, and typeErrors the cells
+	// that aren't of their column's type. See declared_types.go.
+	declaredTypes map[int]string
+	typeErrors    *multierror.Error
+
+	// warnDeclaredTypes warns about each header that declares a type, see declared_types.go
+	warnDeclaredTypes bool
 }
 
-func newRowProcessor(worksheetName string, hasParent bool, index int) *rowProcessor {
+func newRowProcessor(worksheetName, file string, hasParent bool, index int) *rowProcessor {
 	return &rowProcessor{
 		worksheet: &model.Worksheet{
 			Name:  worksheetName,
+			File:  file,
 			Index: index,
 		},
-		HasParent:  hasParent,
-		converter:  newCellConverter(),
-		columnType: make(map[int]ColumnAttributeType),
+		HasParent:       hasParent,
+		converter:       newCellConverter(),
+		columnType:      make(map[int]ColumnAttributeType),
+		conditionLevels: make(map[int]conditionLevel),
+		lookupColumns:   make(map[int]*lookupTable),
+		declaredTypes:   make(map[int]string),
 	}
 }
 
 // processHeaderRow processes the first row in the spreadsheet. This row is the header row and contains
 // the names of all the process, sample and file attributes. The type of an attribute is determined
-// by looking at its keyword prefix.
-func (r *rowProcessor) processHeaderRow(row *excelize.Rows) {
+// by looking at its keyword prefix. Pattern headers are expanded first, see pattern_headers.go.
+func (r *rowProcessor) processHeaderRow(row []string) error {
+	row = r.expandPatternHeaders(row)
 	column := 0
-	for _, colCell := range row.Columns() {
+	for _, colCell := range row {
 		colCell = strings.TrimSpace(colCell)
+		r.worksheet.Headers = append(r.worksheet.Headers, colCell)
 		column++
 		// Check for columns to skip. Column 1 is sample name and column 2
 		// could be the parent column. Always skip column 1, and optionally
@@ -66,12 +127,51 @@ func (r *rowProcessor) processHeaderRow(row *excelize.Rows) {
 			continue
 		}
 
+		if r.crosstab && isConditionLevelHeader(colCell) {
+			level := parseConditionLevelHeader(colCell)
+			if !r.attrFilter.keep(level.Name) {
+				r.columnType[column] = IgnoreAttributeColumn
+				continue
+			}
+			r.conditionLevels[column] = level
+			r.columnType[column] = ConditionLevelColumn
+			if findAttrByName(r.worksheet.ProcessAttrs, level.Name) == nil {
+				r.worksheet.AddProcessAttr(model.NewAttribute(level.Name, level.Unit, column))
+			}
+			continue
+		}
+
 		// If you add a new type of keyword then don't forget to modify processSampleRow() case statement to handle
 		// that keyword.
 
-		switch columnAttributeTypeFromKeyword(colCell) {
+		columnType := columnAttributeTypeFromKeyword(colCell)
+		if t, ok := r.columnTypes[column]; ok {
+			columnType = t
+		}
+
+		if columnType == ProcessAttributeColumn || columnType == SampleAttributeColumn {
+			var declaredType string
+			if colCell, declaredType = splitDeclaredType(colCell); declaredType != "" && declaredType != autoType {
+				r.declaredTypes[column] = declaredType
+				if r.warnDeclaredTypes {
+					r.warnDeclaredType(colCell, declaredType, column)
+				}
+			}
+
+			if name, _ := cell2NameAndUnit(colCell); !r.attrFilter.keep(name) {
+				columnType = IgnoreAttributeColumn
+			}
+		}
+
+		switch columnType {
 		case ProcessAttributeColumn:
 			name, unit := cell2NameAndUnit(colCell)
+			if isDateColumn(colCell, name) {
+				if name == "" {
+					name = "Date"
+				}
+				r.worksheet.DateAttr = name
+			}
 			attr := model.NewAttribute(name, unit, column)
 			r.columnType[column] = ProcessAttributeColumn
 			r.worksheet.AddProcessAttr(attr)
@@ -81,15 +181,34 @@ func (r *rowProcessor) processHeaderRow(row *excelize.Rows) {
 			r.columnType[column] = SampleAttributeColumn
 			r.worksheet.AddSampleAttr(attr)
 		case FileAttributeColumn:
-			fileHeader := createFileHeader(colCell, column)
+			fileHeader, err := createFileHeader(colCell, column)
+			if err != nil {
+				return fmt.Errorf("worksheet %s column %s: %s", r.worksheet.Source(), model.ColumnName(column), err)
+			}
+			fileHeader.Direction = fileDirectionFromKeyword(colCell)
 			r.worksheet.AddFileHeader(fileHeader)
 			r.columnType[column] = FileAttributeColumn
+		case LookupAttributeColumn:
+			if err := r.processLookupHeader(colCell, column); err != nil {
+				return err
+			}
+			r.columnType[column] = LookupAttributeColumn
+		case CohortColumn:
+			r.columnType[column] = CohortColumn
+		case StepColumn:
+			r.columnType[column] = StepColumn
+		case SampleIDColumn:
+			r.columnType[column] = SampleIDColumn
 		case IgnoreAttributeColumn:
 			r.columnType[column] = IgnoreAttributeColumn
 		default:
-			fmt.Printf("Warning: Worksheet %s heading column %d with value '%s' has unknown keyword to identify its type\n", r.worksheet.Name, column, colCell)
+			console.Warningf("Worksheet %s heading column %d with value '%s' has unknown keyword to identify its type\n", r.worksheet.Source(), column, colCell)
 		}
 	}
+
+	r.setFileDirections()
+	r.summarizeColumns()
+	return nil
 }
 
 // processSampleRow processes a row that has a sample on it. This row has the same format as above
@@ -100,14 +219,24 @@ func (r *rowProcessor) processHeaderRow(row *excelize.Rows) {
 //   cell: {edge: 1, angle: 2}, becomes the string; {value: {edge: 1, angle: 2}}
 // The reason for the conversion is that these cell values will be stored in the database a JSON objects
 // with a top level value key.
-func (r *rowProcessor) processSampleRow(row *excelize.Rows, rowIndex int) error {
+func (r *rowProcessor) processSampleRow(row []string, rowIndex int) error {
 	column := 0
 	var currentSample *model.Sample = nil
 
-	for _, colCell := range row.Columns() {
+	// Condition levels marked for the sample in a cross-tab worksheet
+	var markedLevels []*model.Attribute
+
+	for _, colCell := range row {
 		colCell = strings.TrimSpace(colCell)
 		column++
 
+		var err error
+		if r.isLoadedColumn(column) {
+			if colCell, err = r.guardCell(colCell, rowIndex, column); err != nil {
+				return err
+			}
+		}
+
 		// Go through each column and capture its value. Column 1 is special and column
 		// 2 may be special. If HasParent flag is true then column two is treated as a special column.
 		// Empty cell handling is column specific. If column 1 cell is blank then we
@@ -157,12 +286,16 @@ func (r *rowProcessor) processSampleRow(row *excelize.Rows, rowIndex int) error
 				// worksheet.SampleAttrs) so that we know which attribute we are looking at for this cell.
 				// Ignore cells that are blank.
 				attr := findAttr(r.worksheet.SampleAttrs, column)
-				sampleAttr := model.NewAttribute(attr.Name, attr.Unit, attr.Column)
+				columnSchema := r.schema.findColumn(r.worksheet.Name, attr.Name)
+				if columnSchema.treatAsBlank(colCell) {
+					continue
+				}
+				cell, unit := r.cellValueAndUnit(colCell, attr.Unit, rowIndex, column)
+				sampleAttr := model.NewAttribute(attr.Name, unit, attr.Column)
+				sampleAttr.Source = r.cellRef(rowIndex, column)
 
-				if val, err := r.converter.cellToJSONMap(colCell); err != nil {
-					errDesc := fmt.Sprintf("Error converting cell in worksheet %s: row: %d, column: %d with value %s",
-						r.worksheet.Name, rowIndex, column, colCell)
-					return errors.Wrapf(err, errDesc)
+				if val, err := r.convertAttributeCell(cell, columnSchema, rowIndex, column); err != nil {
+					return err
 				} else {
 					sampleAttr.Value = val
 				}
@@ -173,12 +306,23 @@ func (r *rowProcessor) processSampleRow(row *excelize.Rows, rowIndex int) error
 				// This column is a process attribute. As above look up the header so we know the attribute
 				// associated with this cell. Ignore cells that are blank.
 				attr := findAttr(r.worksheet.ProcessAttrs, column)
-				processAttr := model.NewAttribute(attr.Name, attr.Unit, attr.Column)
-
-				if val, err := r.converter.cellToJSONMap(colCell); err != nil {
-					errDesc := fmt.Sprintf("Error converting cell in worksheet %s: row: %d, column: %d with value '%s'",
-						r.worksheet.Name, rowIndex, column, colCell)
-					return errors.Wrapf(err, errDesc)
+				columnSchema := r.schema.findColumn(r.worksheet.Name, attr.Name)
+				if columnSchema.treatAsBlank(colCell) {
+					continue
+				}
+				cell, unit := r.cellValueAndUnit(colCell, attr.Unit, rowIndex, column)
+				processAttr := model.NewAttribute(attr.Name, unit, attr.Column)
+
+				if attr.Name == r.worksheet.DateAttr {
+					// Store dates in one format so the same date is the same process however it was entered
+					date, err := r.converter.dates.ParseDate(cell)
+					if err != nil {
+						return cellErrorf(r.worksheet, rowIndex, column, "Invalid date in worksheet %s row %d column %s: %s",
+							r.worksheet.Source(), rowIndex, model.ColumnName(column), err)
+					}
+					processAttr.Value = map[string]interface{}{"value": date.Format(processDateLayout(date))}
+				} else if val, err := r.convertAttributeCell(cell, columnSchema, rowIndex, column); err != nil {
+					return err
 				} else {
 					processAttr.Value = val
 				}
@@ -187,7 +331,53 @@ func (r *rowProcessor) processSampleRow(row *excelize.Rows, rowIndex int) error
 
 			case colType == FileAttributeColumn:
 				fileHeader := findFileHeader(r.worksheet.FileHeaders, column)
-				currentSample.AddFile(cell2Filepath(colCell, fileHeader), column)
+				direction := model.FileDirectionIn
+				if fileHeader != nil {
+					direction = fileHeader.Direction
+				}
+				currentSample.AddFile(cell2Filepath(colCell, fileHeader), direction, column)
+
+			case colType == ConditionLevelColumn:
+				// This column is a condition level in a cross-tab worksheet. An x marks that the sample was
+				// processed at the level in the header, any other value is used in place of the level.
+				level := r.conditionLevels[column]
+				if r.schema.findColumn(r.worksheet.Name, level.Name).treatAsBlank(colCell) {
+					continue
+				}
+
+				value := colCell
+				if isParticipationMark(colCell) {
+					value = level.Level
+				}
+
+				processAttr := model.NewAttribute(level.Name, level.Unit, column)
+				if val, err := r.convertCell(value, rowIndex, column); err != nil {
+					return err
+				} else {
+					processAttr.Value = val
+				}
+
+				markedLevels = append(markedLevels, processAttr)
+
+			case colType == LookupAttributeColumn:
+				// This column refers to an entry on a lookup worksheet, whose attributes are added to the process
+				if err := r.addLookupAttributes(currentSample, colCell, rowIndex, column); err != nil {
+					return err
+				}
+
+			case colType == CohortColumn:
+				// The cohort isn't an attribute, it is the experiment the sample is loaded into
+				currentSample.Cohort = colCell
+
+			case colType == StepColumn:
+				// The step orders the processes the sample goes through on this worksheet
+				if currentSample.Step, err = parseStep(colCell); err != nil {
+					return cellErrorf(r.worksheet, rowIndex, column, "worksheet %s row %d column %s: %s", r.worksheet.Source(), rowIndex, model.ColumnName(column), err)
+				}
+
+			case colType == SampleIDColumn:
+				// The sample is already on the server, it isn't an attribute
+				currentSample.MCID = colCell
 
 			case colType == IgnoreAttributeColumn:
 				// Ignore all values in this column
@@ -202,9 +392,112 @@ func (r *rowProcessor) processSampleRow(row *excelize.Rows, rowIndex int) error
 		}
 	}
 
+	if currentSample != nil {
+		r.addConstants(currentSample)
+		r.combinePatternAttributes(currentSample)
+		r.addDerivedAttributes(currentSample, rowIndex)
+	}
+
+	if currentSample != nil && r.HasParent && r.fillParentDown {
+		if currentSample.Parent == "" {
+			currentSample.Parent = r.previousParent
+		} else {
+			r.previousParent = currentSample.Parent
+		}
+	}
+
+	if currentSample != nil && r.HasParent {
+		// The parent cell can be a chain of the worksheets the sample went through, see parent_chain.go
+		currentSample.Parent, currentSample.ParentChain = splitParentChain(currentSample.Parent, r.sheetNames)
+	}
+
+	// Expand a cross-tab row into the long format. Additional samples are created when a sample was
+	// marked at more than one level of the same condition.
+	if currentSample != nil {
+		for _, sample := range expandConditionLevels(currentSample, markedLevels) {
+			r.worksheet.AddSample(sample)
+		}
+	}
+
 	return nil
 }
 
+// convertCell converts a sample or process attribute cell into its JSON map representation. If the
+// value that will be stored differs from the value displayed in the cell (for example because the
+// cell has more significant digits than can be stored) then a warning is printed so the user knows
+// that precision will be lost.
+func (r *rowProcessor) convertCell(colCell string, rowIndex, column int) (map[string]interface{}, error) {
+	var (
+		val map[string]interface{}
+		err error
+	)
+	if declaredType, ok := r.declaredTypes[column]; ok {
+		val = r.convertDeclaredCell(colCell, declaredType, rowIndex, column)
+	} else if val, err = r.converter.cellToJSONMap(colCell); err != nil {
+		return nil, cellErrorf(r.worksheet, rowIndex, column, "Error converting cell in worksheet %s row %d column %s with value '%s': %s",
+			r.worksheet.Source(), rowIndex, model.ColumnName(column), colCell, err)
+	}
+
+	if r.converter.losesPrecision(colCell, val["value"]) {
+		cellWarningf(r.worksheet, rowIndex, column, "Worksheet %s row %d column %s value '%s' will be stored as %v, precision will be lost\n",
+			r.worksheet.Source(), rowIndex, model.ColumnName(column), colCell, val["value"])
+	}
+
+	return val, nil
+}
+
+// convertAttributeCell converts a sample or process attribute cell, parsing it as a composition if
+// the column's schema says it is one, and rounding it if the column's schema has a rounding rule. A cell
+// in the column's value map is replaced by the value it maps to.
+func (r *rowProcessor) convertAttributeCell(cell string, columnSchema *ColumnSchema, rowIndex, column int) (map[string]interface{}, error) {
+	if value, ok := columnSchema.mapValue(cell); ok {
+		return map[string]interface{}{"value": value}, nil
+	}
+
+	if !columnSchema.isComposition() {
+		if !columnSchema.rounds() {
+			return r.convertCell(cell, rowIndex, column)
+		}
+
+		// The digits past a float64's precision are rounded off anyway, so there is no precision warning
+		var (
+			val map[string]interface{}
+			err error
+		)
+		if declaredType, ok := r.declaredTypes[column]; ok {
+			val = r.convertDeclaredCell(cell, declaredType, rowIndex, column)
+		} else if val, err = r.converter.cellToJSONMap(cell); err != nil {
+			return nil, cellErrorf(r.worksheet, rowIndex, column, "Error converting cell in worksheet %s row %d column %s with value '%s': %s",
+				r.worksheet.Source(), rowIndex, model.ColumnName(column), cell, err)
+		}
+
+		return map[string]interface{}{"value": columnSchema.round(val["value"])}, nil
+	}
+
+	composition, err := parseComposition(cell)
+	if err != nil {
+		return nil, cellErrorf(r.worksheet, rowIndex, column, "Error converting cell in worksheet %s row %d column %s: %s",
+			r.worksheet.Source(), rowIndex, model.ColumnName(column), err)
+	}
+
+	return map[string]interface{}{"value": columnSchema.round(composition)}, nil
+}
+
+// processDateLayout returns the layout a process date is stored in. The time is left off dates
+// that don't have one.
+func processDateLayout(date time.Time) string {
+	if date.Hour() == 0 && date.Minute() == 0 && date.Second() == 0 {
+		return "2006-01-02"
+	}
+
+	return "2006-01-02 15:04:05"
+}
+
+// cellRef returns the location of a cell in the worksheet being processed.
+func (r *rowProcessor) cellRef(rowIndex, column int) *model.CellRef {
+	return &model.CellRef{File: r.worksheet.File, Sheet: r.worksheet.Name, Row: rowIndex, Column: column}
+}
+
 // findAttr will look up the attribute in the given list of attributes. These attributes were built
 // during the header processing. Each attribute has a column it is associated with and we can use that
 // to find the given attribute in the header.
@@ -218,6 +511,17 @@ func findAttr(attributes []*model.Attribute, column int) *model.Attribute {
 	return nil
 }
 
+// findAttrByName will look up the attribute by name in the given list of attributes.
+func findAttrByName(attributes []*model.Attribute, name string) *model.Attribute {
+	for _, attr := range attributes {
+		if attr.Name == name {
+			return attr
+		}
+	}
+
+	return nil
+}
+
 // findFileHeader will look up the file header in the given list of file headers. The file headers
 // were built during the header processing stage. Each file header has a column associated with it
 // and this method matches on the column to find the given file header.
@@ -293,8 +597,10 @@ func cell2NameAndUnit(cell string) (name, unit string) {
 }
 
 // createFileHeader parses the cell for a file header. The format of a cell
-// is keyword:description:path, keyword:path or keyword:.
-func createFileHeader(cell string, column int) *model.FileHeader {
+// is keyword:description:path, keyword:path or keyword:. A header that has
+// a blank description or path between or after its colons, or too many
+// colons, is an error rather than a header with an empty path.
+func createFileHeader(cell string, column int) (*model.FileHeader, error) {
 	// Example of parsing:
 	//
 	// fullCell := "file:abc:path/"
@@ -316,12 +622,27 @@ func createFileHeader(cell string, column int) *model.FileHeader {
 	if firstColon != secondColon {
 		// if firstColon != secondColon then there is a description and a path
 		// ie, the format is:  FILE:My description:directory-path/to/file/in/cell/in/materials-commons
-		return model.NewFileHeader(cell[firstColon+1:secondColon], strings.TrimSpace(cell[secondColon+1:]), column)
+		description, path := cell[firstColon+1:secondColon], strings.TrimSpace(cell[secondColon+1:])
+		switch {
+		case strings.Contains(description, ":"):
+			return nil, fileHeaderError(cell, "it has more than two colons")
+		case strings.TrimSpace(description) == "":
+			return nil, fileHeaderError(cell, "the description between the colons is blank")
+		case path == "":
+			return nil, fileHeaderError(cell, "there is no path after the last colon")
+		}
+		return model.NewFileHeader(description, path, column), nil
 	}
 
 	// If we are here then firstColon == secondColon, which means the format is:
 	// FILE:directory-path/to/file/in/cell/in/materials-commons
-	return model.NewFileHeader("", strings.TrimSpace(cell[firstColon+1:]), column)
+	return model.NewFileHeader("", strings.TrimSpace(cell[firstColon+1:]), column), nil
+}
+
+// fileHeaderError returns the error for a malformed file header, showing the forms a file header can take.
+func fileHeaderError(cell, problem string) error {
+	return fmt.Errorf("file header '%s' is malformed, %s. A file header is written keyword:, keyword:path or "+
+		"keyword:description:path, eg file:, file:SEM/images or file:Micrographs:SEM/images", cell, problem)
 }
 
 // cell2Filepath converts a given cell into a file path. It does this by first checking
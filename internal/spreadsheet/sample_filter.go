@@ -0,0 +1,109 @@
+package spreadsheet
+
+/*
+ * sample_filter loads only some of the samples in the spreadsheets, for quick corrections to a few samples
+ * without loading a whole campaign again. The samples are given with --samples, or one per line in the
+ * file given with --samples-file:
+ *
+ *   mcetl load -f campaign.xlsx --has-parent --samples S1,S2,S17 ...
+ *
+ * Every row of a listed sample is kept, on every worksheet, so the sample goes through the same processes
+ * it would in a full load and its parent worksheets still send it into them. The rows of the other samples
+ * are left out. A worksheet left without rows is only kept if a kept row names it as a parent, or it is the
+ * samples sheet. Samples that aren't in any worksheet are warned about.
+ */
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// ReadSamplesFile reads the sample names in the file, one per line. Blank lines and lines starting with #
+// are skipped.
+func ReadSamplesFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		names = append(names, name)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("samples file %s has no sample names", path)
+	}
+
+	return names, nil
+}
+
+// filterSamples returns the worksheets with only the rows of the samples in l.Samples.
+func (l *Loader) filterSamples(worksheets []*model.Worksheet) ([]*model.Worksheet, error) {
+	wanted := make(map[string]bool)
+	for _, name := range l.Samples {
+		wanted[strings.TrimSpace(name)] = true
+	}
+
+	found := make(map[string]bool)
+	parents := make(map[string]bool)
+	filtered := make([]*model.Worksheet, 0, len(worksheets))
+	for _, worksheet := range worksheets {
+		var samples []*model.Sample
+		for _, sample := range worksheet.Samples {
+			if !wanted[sample.Name] {
+				continue
+			}
+
+			samples = append(samples, sample)
+			found[sample.Name] = true
+			parents[sample.Parent] = true
+			for _, name := range sample.ParentChain {
+				parents[name] = true
+			}
+		}
+
+		filteredWorksheet := *worksheet
+		filteredWorksheet.Samples = samples
+		filtered = append(filtered, &filteredWorksheet)
+	}
+
+	var missing []string
+	for _, name := range l.Samples {
+		if name = strings.TrimSpace(name); !found[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(found) == 0 {
+		return nil, fmt.Errorf("none of the samples to load (%s) are in the spreadsheet(s)", strings.Join(missing, ", "))
+	}
+
+	if len(missing) != 0 {
+		console.Warningf("samples %s aren't in the spreadsheet(s), they won't be loaded\n", strings.Join(missing, ", "))
+	}
+
+	var kept []*model.Worksheet
+	for _, worksheet := range filtered {
+		if len(worksheet.Samples) != 0 || parents[worksheet.Name] || l.isSamplesOrMasterSheet(worksheet.Name) {
+			kept = append(kept, worksheet)
+		}
+	}
+
+	return kept, nil
+}
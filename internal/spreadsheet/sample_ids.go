@@ -0,0 +1,54 @@
+package spreadsheet
+
+/*
+ * sample_ids links the samples on the worksheets to samples already on the server, for loading the processes
+ * and measurements of specimens that were registered earlier. The ID of the server's sample is given in a
+ * column with the mcid: keyword:
+ *
+ *   |sample |mcid:Sample ID |p:Temperature(C) |s:Hardness(HV) |
+ *   |S1     |5e3f0a2b...    |400              |210            |
+ *
+ * S1 isn't created, the server's sample goes into the processes on the worksheets and the measurements on
+ * its rows are added to it. A sample is the same server sample on every worksheet, so its ID only needs to
+ * be given on one of its rows. Samples without an ID are created as usual.
+ */
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// SampleIDs returns the IDs of the server samples given for the samples on the worksheets, by sample
+// name. It is an error for a sample to be given two IDs, or for two samples to be given the same ID.
+func SampleIDs(worksheets []*model.Worksheet) (map[string]string, error) {
+	var savedErrs *multierror.Error
+
+	ids := make(map[string]string)
+	names := make(map[string]string)
+	givenAt := make(map[string]string)
+	for _, worksheet := range worksheets {
+		for _, sample := range worksheet.Samples {
+			if sample.MCID == "" {
+				continue
+			}
+
+			at := fmt.Sprintf("worksheet %s row %d", worksheet.Source(), sample.Row)
+			switch id, ok := ids[sample.Name]; {
+			case ok && id != sample.MCID:
+				savedErrs = multierror.Append(savedErrs, fmt.Errorf("sample %s has mcid %s on %s and mcid %s on %s",
+					sample.Name, id, givenAt[sample.Name], sample.MCID, at))
+			case !ok && names[sample.MCID] != "":
+				savedErrs = multierror.Append(savedErrs, fmt.Errorf("samples %s and %s on %s have the same mcid %s",
+					names[sample.MCID], sample.Name, at, sample.MCID))
+			case !ok:
+				ids[sample.Name] = sample.MCID
+				names[sample.MCID] = sample.Name
+				givenAt[sample.Name] = at
+			}
+		}
+	}
+
+	return ids, savedErrs.ErrorOrNil()
+}
@@ -0,0 +1,191 @@
+package spreadsheet
+
+/*
+ * schema contains the optional column level configuration for a spreadsheet. The schema is
+ * loaded from a YAML file and lets the user describe how the values in particular columns
+ * should be interpreted. For example some instruments export 0 for "not measured", the
+ * schema can mark those columns so that a 0 is treated as a blank cell:
+ *
+ *   columns:
+ *     - name: Hardness
+ *       zero_is_blank: true
+ *     - name: Porosity
+ *       worksheet: CT Scan
+ *       zero_is_blank: true
+ *     - name: Composition
+ *       composition: true
+ *     - name: Thickness
+ *       decimal_places: 2
+ *     - name: Inspection
+ *       value_map: {pass: true, fail: false}
+ *
+ * Column names are matched against the attribute name (without the keyword or unit) case
+ * insensitively. If worksheet is given then the rule only applies to that worksheet.
+ *
+ * The schema also declares the values that the placeholders in pattern headers expand to, see
+ * pattern_headers.go:
+ *
+ *   patterns:
+ *     - name: element
+ *       values: [Fe, Ni, Cr, Mo]
+ *       combine: true
+ *
+ * and the attributes that are computed from the other attributes of a row, see derived_attributes.go:
+ *
+ *   derived:
+ *     - name: Density
+ *       unit: g/cm^3
+ *       expression: Mass / Volume
+ */
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type Schema struct {
+	Columns  []*ColumnSchema  `yaml:"columns"`
+	Patterns []*PatternSchema `yaml:"patterns"`
+	Derived  []*DerivedSchema `yaml:"derived"`
+}
+
+// ColumnSchema is the configuration for a single attribute column.
+type ColumnSchema struct {
+	// Name of the attribute the rule applies to
+	Name string `yaml:"name"`
+
+	// Worksheet the rule applies to, if blank the rule applies to all worksheets
+	Worksheet string `yaml:"worksheet"`
+
+	// When true a cell containing 0 is treated as a blank cell
+	ZeroIsBlank bool `yaml:"zero_is_blank"`
+
+	// When true the cells are compositions, eg "Fe-20Cr-5Al", that are stored as a map of element
+	// to amount (see composition.go)
+	Composition bool `yaml:"composition"`
+
+	// SignificantFigures or DecimalPlaces round the decimal values in the column (see rounding.go)
+	SignificantFigures int  `yaml:"significant_figures"`
+	DecimalPlaces      *int `yaml:"decimal_places"`
+
+	// ValueMap replaces categorical values, eg pass and fail, with the values they stand for (see value_map.go)
+	ValueMap map[string]interface{} `yaml:"value_map"`
+}
+
+// PatternSchema declares the values a placeholder in a pattern header expands to.
+type PatternSchema struct {
+	// Name of the placeholder, eg element for the header s:EDS_{element}(at%)
+	Name string `yaml:"name"`
+
+	// Values the placeholder is replaced with, one column for each value
+	Values []string `yaml:"values"`
+
+	// When true the columns are combined into a single attribute whose value is an object
+	// keyed by the values, rather than being loaded as one attribute per value
+	Combine bool `yaml:"combine"`
+}
+
+// LoadSchema reads and parses the schema in the given YAML file.
+func LoadSchema(path string) (*Schema, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schema Schema
+	if err := yaml.UnmarshalStrict(contents, &schema); err != nil {
+		return nil, fmt.Errorf("unable to parse schema file %s: %s", path, err)
+	}
+
+	for i, column := range schema.Columns {
+		if strings.TrimSpace(column.Name) == "" {
+			return nil, fmt.Errorf("schema file %s: column entry %d has no name", path, i+1)
+		}
+
+		if err := column.validateRounding(); err != nil {
+			return nil, fmt.Errorf("schema file %s: %s", path, err)
+		}
+
+		if err := column.validateValueMap(); err != nil {
+			return nil, fmt.Errorf("schema file %s: %s", path, err)
+		}
+	}
+
+	for i, pattern := range schema.Patterns {
+		switch {
+		case strings.TrimSpace(pattern.Name) == "":
+			return nil, fmt.Errorf("schema file %s: pattern entry %d has no name", path, i+1)
+		case len(pattern.Values) == 0:
+			return nil, fmt.Errorf("schema file %s: pattern '%s' has no values", path, pattern.Name)
+		}
+	}
+
+	for i, derived := range schema.Derived {
+		if err := derived.validateDerived(); err != nil {
+			return nil, fmt.Errorf("schema file %s: derived entry %d: %s", path, i+1, err)
+		}
+	}
+
+	return &schema, nil
+}
+
+// findColumn returns the column rule for the given attribute in the given worksheet. A rule
+// that names the worksheet takes precedence over a rule that applies to all worksheets. It
+// returns nil if there is no rule for the attribute. A nil schema has no rules.
+func (s *Schema) findColumn(worksheetName, attrName string) *ColumnSchema {
+	if s == nil {
+		return nil
+	}
+
+	var found *ColumnSchema
+	for _, column := range s.Columns {
+		if !strings.EqualFold(strings.TrimSpace(column.Name), attrName) {
+			continue
+		}
+
+		switch {
+		case strings.EqualFold(strings.TrimSpace(column.Worksheet), worksheetName):
+			return column
+		case column.Worksheet == "" && found == nil:
+			found = column
+		}
+	}
+
+	return found
+}
+
+// findPattern returns the declared pattern with the given name, or nil if there isn't one. A nil
+// schema has no patterns.
+func (s *Schema) findPattern(name string) *PatternSchema {
+	if s == nil {
+		return nil
+	}
+
+	for _, pattern := range s.Patterns {
+		if strings.EqualFold(strings.TrimSpace(pattern.Name), name) {
+			return pattern
+		}
+	}
+
+	return nil
+}
+
+// treatAsBlank returns true if the column rule says that the value in cell should be treated
+// as a blank cell.
+func (c *ColumnSchema) treatAsBlank(cell string) bool {
+	if c == nil || !c.ZeroIsBlank {
+		return false
+	}
+
+	value, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+	return err == nil && value == 0
+}
+
+// isComposition returns true if the column rule says the cells are compositions.
+func (c *ColumnSchema) isComposition() bool {
+	return c != nil && c.Composition
+}
@@ -0,0 +1,138 @@
+package spreadsheet
+
+/*
+ * starter_workbook writes an empty workbook laid out the way mcetl loads it, so a new user starts from
+ * headers that load rather than guessing at the format. There is a worksheet for each process, in the
+ * order given, with the columns mcetl loads with --has-parent:
+ *
+ *   |sample|parent|p:Temperature(C)|s:Measurement(unit)|file:|
+ *
+ * There is a placeholder column for the process attributes and one for the sample attributes, to rename
+ * or delete. Each header cell has a comment saying what goes in the column.
+ *
+ * A worksheet name can be at most 31 characters and can't contain any of []:*?/\ so a process whose name
+ * can't be used is given a worksheet with a shortened name, and an index sheet (see index_sheet.go) says
+ * which template the worksheet's processes are created from.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/materials-commons/mcetl/internal/console"
+)
+
+// StarterProcess is a worksheet of a starter workbook.
+type StarterProcess struct {
+	// Name is the name of the worksheet, and of the process template its processes are created from
+	Name string
+}
+
+// starterColumn is a column of a worksheet in a starter workbook, with the comment on its header.
+type starterColumn struct {
+	header  string
+	comment string
+}
+
+// The author of the comments on the headers of a starter workbook
+const starterCommentAuthor = "mcetl"
+
+// StarterWorkbook describes the workbook written by WriteStarterWorkbook and how to load it.
+type StarterWorkbook struct {
+	// Worksheets are the names of the process worksheets written, in order
+	Worksheets []string
+
+	// IndexSheet is the name of the index sheet written, or blank if there isn't one. There is one when a
+	// worksheet couldn't be named after its process, so the workbook has to be loaded with --index-sheet.
+	IndexSheet string
+}
+
+// WriteStarterWorkbook writes a starter workbook with a worksheet for each of the processes to path.
+// A process whose name can't be a worksheet name, because it is too long or has a character Excel
+// doesn't allow, is warned about and its template is given in an index sheet.
+func WriteStarterWorkbook(path string, processes []StarterProcess) (*StarterWorkbook, error) {
+	if len(processes) == 0 {
+		return nil, fmt.Errorf("no processes to write worksheets for")
+	}
+
+	var (
+		workbook  StarterWorkbook
+		usedNames = make(map[string]bool)
+		renamed   bool
+	)
+
+	for _, process := range processes {
+		name := uniqueSheetName(process.Name, usedNames)
+		if name != process.Name {
+			console.Warningf("process '%s' can't be a worksheet name, its worksheet is named '%s' and its template given in an index sheet\n",
+				process.Name, name)
+			renamed = true
+		}
+		workbook.Worksheets = append(workbook.Worksheets, name)
+	}
+
+	f := excelize.NewFile()
+	if renamed {
+		workbook.IndexSheet = uniqueSheetName("Index", usedNames)
+		f.SetSheetName("Sheet1", workbook.IndexSheet)
+		f.SetCellValue(workbook.IndexSheet, cellAxis(1, 1), indexSheetColumn)
+		f.SetCellValue(workbook.IndexSheet, cellAxis(2, 1), indexTemplateColumn)
+		for i, process := range processes {
+			f.SetCellValue(workbook.IndexSheet, cellAxis(1, i+2), workbook.Worksheets[i])
+			f.SetCellValue(workbook.IndexSheet, cellAxis(2, i+2), process.Name)
+		}
+	}
+
+	for i, process := range processes {
+		name := workbook.Worksheets[i]
+		if i == 0 && !renamed {
+			f.SetSheetName("Sheet1", name)
+		} else {
+			f.NewSheet(name)
+		}
+
+		for column, c := range starterColumns(process, workbook.Worksheets[:i]) {
+			axis := cellAxis(column+1, 1)
+			f.SetCellValue(name, axis, c.header)
+
+			comment, err := json.Marshal(map[string]string{"author": starterCommentAuthor, "text": c.comment})
+			if err != nil {
+				return nil, err
+			}
+
+			if err := f.AddComment(name, axis, string(comment)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := f.SaveAs(path); err != nil {
+		return nil, err
+	}
+
+	return &workbook, nil
+}
+
+// starterColumns returns the columns of the worksheet for the process. previous are the worksheets
+// before it, which its samples can come from.
+func starterColumns(process StarterProcess, previous []string) []starterColumn {
+	parentComment := "The worksheet the sample comes from. Leave it blank for a sample that starts here."
+	if len(previous) != 0 {
+		parentComment = fmt.Sprintf("The worksheet the sample comes from, eg %s. Leave it blank for a sample that starts here.",
+			previous[len(previous)-1])
+	}
+
+	columns := []starterColumn{
+		{"sample", "The name of the sample, one row for each sample that goes through the process."},
+		{"parent", parentComment},
+	}
+
+	return append(columns,
+		starterColumn{"p:Attribute(unit)",
+			"A setting of the process, such as p:Temperature(C). Rename this column, and add one for each setting."},
+		starterColumn{"s:Measurement(unit)",
+			"A measurement of the sample after the process, such as s:Hardness(HV). Rename or delete this column, and add one for each measurement."},
+		starterColumn{"file:",
+			"The path in the project of a file, such as an image, that belongs to the process. Delete this column if there are none."})
+}
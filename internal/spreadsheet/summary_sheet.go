@@ -0,0 +1,160 @@
+package spreadsheet
+
+/*
+ * summary_sheet writes a copy of each loaded workbook with an extra MC Summary worksheet that lists, for every
+ * row that was loaded, the IDs of the sample and process that were created on the server for it:
+ *
+ *   |Worksheet |Row |Sample |Sample ID |Process        |Process ID |Experiment ID |Project ID |Status  |
+ *   |Heat      |2   |S1     |4f1...    |Heat Treatment |9ab...     |c70...        |p12...     |created |
+ *
+ * so the workbook itself is a record of what exists on the server. The copies are written to the directory
+ * given with --summary-dir, the workbooks that were loaded are never changed. Workbooks with the same name
+ * from different directories have a number added to the names of their copies. The MC Summary worksheet is
+ * skipped when a copy is loaded again. Workbooks loaded from a zip don't get a summary.
+ */
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/processor"
+)
+
+// SummarySheetName is the name of the worksheet the summary is written to.
+const SummarySheetName = "MC Summary"
+
+// summaryHeaders are the headers of the summary worksheet's columns.
+var summaryHeaders = []string{"Worksheet", "Row", "Sample", "Sample ID", "Process", "Process ID", "Experiment ID", "Project ID", "Status"}
+
+// summaryStep is a step in a load report and the report it is in.
+type summaryStep struct {
+	step   *processor.StepReport
+	report *processor.LoadReport
+}
+
+// WriteSummaryWorkbooks writes a copy of each workbook the worksheets were loaded from into dir, with a
+// summary worksheet listing what was created for each row. reports are the reports of the loads of the
+// worksheets, there is more than one when the worksheets were loaded into an experiment per cohort.
+func WriteSummaryWorkbooks(dir string, worksheets []*model.Worksheet, reports []*processor.LoadReport) error {
+	// Index the steps so each row can find the sample and process that were created for it
+	samples := make(map[string]summaryStep)
+	processes := make(map[string]summaryStep)
+	for _, report := range reports {
+		for _, step := range report.Steps {
+			switch step.Type {
+			case "sample":
+				samples[step.Samples[0]] = summaryStep{step: step, report: report}
+			case "process":
+				for _, row := range step.Rows {
+					processes[summaryRowKey(step.File, step.Worksheet, row)] = summaryStep{step: step, report: report}
+				}
+			}
+		}
+	}
+
+	var files []string
+	byFile := make(map[string][]*model.Worksheet)
+	for _, worksheet := range worksheets {
+		if _, ok := byFile[worksheet.File]; !ok {
+			files = append(files, worksheet.File)
+		}
+		byFile[worksheet.File] = append(byFile[worksheet.File], worksheet)
+	}
+
+	usedPaths := make(map[string]bool)
+	for _, file := range files {
+		if info, err := os.Stat(file); err != nil || info.IsDir() || delimiterForFile(file) != 0 {
+			console.Warningf("%s isn't a workbook file, no summary has been written for it\n", file)
+			continue
+		}
+
+		path := uniqueSummaryPath(dir, file, usedPaths)
+		if err := writeSummaryWorkbook(file, path, byFile[file], samples, processes); err != nil {
+			return fmt.Errorf("unable to write summary of %s to %s: %s", file, path, err)
+		}
+		fmt.Printf("Wrote %s with a summary of what was loaded from %s\n", path, file)
+	}
+
+	return nil
+}
+
+// uniqueSummaryPath returns the path in dir to write the copy of the workbook at file to. Workbooks in
+// different directories can have the same name, so a number is added to the name of a copy when an earlier
+// copy has the name. Names are compared ignoring case, as they are on some file systems.
+func uniqueSummaryPath(dir, file string, used map[string]bool) string {
+	base := filepath.Base(file)
+	ext := filepath.Ext(base)
+	name := base
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%s %d%s", strings.TrimSuffix(base, ext), i, ext)
+	}
+
+	used[strings.ToLower(name)] = true
+	return filepath.Join(dir, name)
+}
+
+// writeSummaryWorkbook copies the workbook at file to path, adding the summary of its worksheets.
+func writeSummaryWorkbook(file, path string, worksheets []*model.Worksheet, samples, processes map[string]summaryStep) error {
+	if sameFile(file, path) {
+		return fmt.Errorf("the copy would replace the workbook, use a different --summary-dir")
+	}
+
+	xlsx, err := excelize.OpenFile(file)
+	if err != nil {
+		return err
+	}
+
+	if xlsx.GetSheetIndex(SummarySheetName) != 0 {
+		// A copy that already has a summary is being loaded again
+		xlsx.DeleteSheet(SummarySheetName)
+	}
+	xlsx.NewSheet(SummarySheetName)
+
+	for i, header := range summaryHeaders {
+		xlsx.SetCellValue(SummarySheetName, cellAxis(i+1, 1), header)
+	}
+
+	rowIndex := 1
+	for _, worksheet := range worksheets {
+		for _, sample := range worksheet.Samples {
+			rowIndex++
+			values := []interface{}{worksheet.Name, sample.Row, sample.Name}
+
+			if created, ok := samples[sample.Name]; ok {
+				values = append(values, created.step.ID)
+			} else {
+				values = append(values, "")
+			}
+
+			if created, ok := processes[summaryRowKey(worksheet.File, worksheet.Name, sample.Row)]; ok {
+				values = append(values, created.step.Name, created.step.ID, created.report.ExperimentID,
+					created.report.ProjectID, created.step.Status)
+			} else {
+				values = append(values, "", "", "", "", processor.StepNotAttempted)
+			}
+
+			for column, value := range values {
+				xlsx.SetCellValue(SummarySheetName, cellAxis(column+1, rowIndex), value)
+			}
+		}
+	}
+
+	return xlsx.SaveAs(path)
+}
+
+// summaryRowKey identifies a row of a worksheet in a workbook.
+func summaryRowKey(file, worksheet string, row int) string {
+	return fmt.Sprintf("%s\x00%s\x00%d", file, worksheet, row)
+}
+
+// sameFile returns true if the paths are the same file.
+func sameFile(path1, path2 string) bool {
+	info1, err1 := os.Stat(path1)
+	info2, err2 := os.Stat(path2)
+	return err1 == nil && err2 == nil && os.SameFile(info1, info2)
+}
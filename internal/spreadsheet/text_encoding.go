@@ -0,0 +1,155 @@
+package spreadsheet
+
+/*
+ * text_encoding decodes CSV and TSV files that aren't UTF-8. Instruments often write their exports in the
+ * encoding of the PC they run on, such as Windows-1252, or as UTF-16, and reading those as UTF-8 turns
+ * characters such as the µ in Size(µm) into garbage, changing the attribute names. The encoding is given
+ * with --encoding:
+ *
+ *   auto          (the default) A byte order mark gives the encoding. Without one a file that is valid UTF-8
+ *                 is UTF-8, a file with a zero byte in every other position is UTF-16, and any other file is
+ *                 read as Windows-1252 (Latin-1 with punctuation in place of its unused control codes),
+ *                 with a warning.
+ *
+ *   utf-8         It is an error for the file not to be UTF-8.
+ *
+ *   utf-16le, utf-16be, latin-1, windows-1252
+ *
+ * A byte order mark at the start of the file is removed whatever the encoding. Workbooks don't need an
+ * encoding, their text is always Unicode.
+ */
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/materials-commons/mcetl/internal/console"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// The encodings of CSV and TSV files, see above.
+const (
+	EncodingAuto        = "auto"
+	EncodingUTF8        = "utf-8"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingLatin1      = "latin-1"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// The byte order marks of UTF-8 and UTF-16
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+// ValidEncoding returns an error if name isn't one of the encodings.
+func ValidEncoding(name string) error {
+	switch name {
+	case EncodingAuto, EncodingUTF8, EncodingUTF16LE, EncodingUTF16BE, EncodingLatin1, EncodingWindows1252:
+		return nil
+	default:
+		return fmt.Errorf("unknown encoding '%s', use '%s', '%s', '%s', '%s', '%s' or '%s'", name, EncodingAuto,
+			EncodingUTF8, EncodingUTF16LE, EncodingUTF16BE, EncodingLatin1, EncodingWindows1252)
+	}
+}
+
+// decodeText returns the contents of the file as UTF-8, decoding them from the encoding, which is detected
+// when it is EncodingAuto or blank.
+func decodeText(contents []byte, file, encodingName string) ([]byte, error) {
+	if encodingName == "" || encodingName == EncodingAuto {
+		encodingName = detectEncoding(contents)
+		if encodingName == EncodingWindows1252 {
+			console.Warningf("%s isn't UTF-8, it has been read as %s, use --encoding if its text looks wrong\n", file, encodingName)
+		}
+	}
+
+	var decoder *encoding.Decoder
+	switch encodingName {
+	case EncodingUTF8:
+		contents = bytes.TrimPrefix(contents, utf8BOM)
+		if !utf8.Valid(contents) {
+			return nil, fmt.Errorf("%s line %d isn't valid UTF-8, give the file's --encoding", file, invalidUTF8Line(contents))
+		}
+		return contents, nil
+	case EncodingUTF16LE:
+		decoder = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder()
+		contents = bytes.TrimPrefix(contents, utf16LEBOM)
+	case EncodingUTF16BE:
+		decoder = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder()
+		contents = bytes.TrimPrefix(contents, utf16BEBOM)
+	case EncodingLatin1:
+		decoder = charmap.ISO8859_1.NewDecoder()
+	case EncodingWindows1252:
+		decoder = charmap.Windows1252.NewDecoder()
+	default:
+		return nil, ValidEncoding(encodingName)
+	}
+
+	decoded, err := decoder.Bytes(contents)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode %s as %s: %s", file, encodingName, err)
+	}
+
+	return decoded, nil
+}
+
+// detectEncoding returns the encoding of the contents of a file, see above.
+func detectEncoding(contents []byte) string {
+	switch {
+	case bytes.HasPrefix(contents, utf8BOM):
+		return EncodingUTF8
+	case bytes.HasPrefix(contents, utf16LEBOM):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(contents, utf16BEBOM):
+		return EncodingUTF16BE
+	}
+
+	// Mostly ASCII text in UTF-16 has a zero byte in every other position, which UTF-8 text never has
+	sample := contents
+	if len(sample) > 4096 {
+		sample = sample[:4096]
+	}
+	var evenZeros, oddZeros int
+	for i, b := range sample {
+		switch {
+		case b != 0:
+		case i%2 == 0:
+			evenZeros++
+		default:
+			oddZeros++
+		}
+	}
+
+	switch half := len(sample) / 2; {
+	case half != 0 && oddZeros > half*3/4 && evenZeros == 0:
+		return EncodingUTF16LE
+	case half != 0 && evenZeros > half*3/4 && oddZeros == 0:
+		return EncodingUTF16BE
+	case utf8.Valid(contents):
+		return EncodingUTF8
+	default:
+		return EncodingWindows1252
+	}
+}
+
+// invalidUTF8Line returns the line the first invalid UTF-8 in the contents is on.
+func invalidUTF8Line(contents []byte) int {
+	line := 1
+	for len(contents) != 0 {
+		r, size := utf8.DecodeRune(contents)
+		if r == utf8.RuneError && size == 1 {
+			break
+		}
+		if r == '\n' {
+			line++
+		}
+		contents = contents[size:]
+	}
+
+	return line
+}
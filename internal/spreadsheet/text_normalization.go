@@ -0,0 +1,163 @@
+package spreadsheet
+
+/*
+ * text_normalization cleans up the text of the cells before the headers are matched against the keywords
+ * and the cells are read. Text copied from web pages, papers and other programs often contains characters
+ * that look the same as the ones typed on a keyboard but aren't, so a header such as p：Temperature (with a
+ * full width colon) or Grain Size (with a no-break space) isn't recognized, or loads as a different
+ * attribute to the one typed, without anything looking wrong. The normalizations are:
+ *
+ *   whitespace  (the default) Unicode spaces, such as the no-break space, become ordinary spaces, zero
+ *               width characters are removed, and leading and trailing whitespace is trimmed.
+ *
+ *   unicode     As whitespace, and also smart quotes become straight quotes, the dash and minus sign
+ *               variants become '-', and the text is put in Unicode NFKC form, which turns full width and
+ *               other compatibility variants into their ordinary forms. NFKC also rewrites data that is
+ *               meant as written, such as ² to 2 and ㎏ to kg, so it has to be asked for.
+ *
+ *   none        The cells are read as they are.
+ *
+ * The cells that are changed are reported. Worksheet names are not normalized, so neither are the parent
+ * cells that refer to them.
+ */
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// The text normalizations, see above.
+const (
+	TextNormalizationNone       = "none"
+	TextNormalizationWhitespace = "whitespace"
+	TextNormalizationUnicode    = "unicode"
+)
+
+// maxNormalizedExamples is the number of changed data cells that are shown for each worksheet
+const maxNormalizedExamples = 3
+
+// ValidTextNormalization returns an error if mode isn't one of the text normalizations.
+func ValidTextNormalization(mode string) error {
+	switch mode {
+	case TextNormalizationNone, TextNormalizationWhitespace, TextNormalizationUnicode:
+		return nil
+	default:
+		return fmt.Errorf("unknown text normalization '%s', use '%s', '%s' or '%s'", mode,
+			TextNormalizationWhitespace, TextNormalizationUnicode, TextNormalizationNone)
+	}
+}
+
+// punctuationReplacer replaces the look-alike punctuation that word processors substitute when typing.
+// The em dash is left alone, it isn't used in place of a hyphen or minus sign.
+var punctuationReplacer = strings.NewReplacer(
+	// Single and double smart quotes
+	"\u2018", "'", "\u2019", "'", "\u201a", "'", "\u201b", "'",
+	"\u201c", `"`, "\u201d", `"`, "\u201e", `"`, "\u201f", `"`,
+
+	// Hyphen, non-breaking hyphen, figure dash, en dash and minus sign
+	"\u2010", "-", "\u2011", "-", "\u2012", "-", "\u2013", "-", "\u2212", "-",
+)
+
+// normalizeText returns the text normalized as described above.
+func normalizeText(text, mode string) string {
+	if mode == TextNormalizationNone {
+		return text
+	}
+
+	if mode == TextNormalizationUnicode {
+		text = norm.NFKC.String(punctuationReplacer.Replace(text))
+	}
+
+	text = strings.Map(func(c rune) rune {
+		switch {
+		case c == '\u200b' || c == '\u200c' || c == '\u200d' || c == '\u2060' || c == '\ufeff':
+			// Zero width spaces and joiners, and the byte order mark
+			return -1
+		case c != '\t' && c != '\n' && c != '\r' && (unicode.IsSpace(c) || unicode.Is(unicode.Zs, c)):
+			return ' '
+		default:
+			return c
+		}
+	}, text)
+
+	return strings.TrimSpace(text)
+}
+
+// normalizedCell is a cell whose text was changed by normalization. row and column start at 1.
+type normalizedCell struct {
+	row, column int
+	before      string
+	after       string
+}
+
+// normalizeRows returns the rows with their cells normalized, and the cells that were changed. The
+// parent column, if there is one, isn't normalized.
+func normalizeRows(rows [][]string, mode string, hasParent bool) ([][]string, []normalizedCell) {
+	if mode == TextNormalizationNone {
+		return rows, nil
+	}
+
+	var changed []normalizedCell
+	normalized := make([][]string, len(rows))
+	for i, row := range rows {
+		normalized[i] = make([]string, len(row))
+		for j, cell := range row {
+			normalized[i][j] = cell
+			if hasParent && j == 1 {
+				continue
+			}
+
+			// Only the changes that matter once the cell has been trimmed, as it will be, are reported
+			if after := normalizeText(cell, mode); after != strings.TrimSpace(cell) {
+				normalized[i][j] = after
+				changed = append(changed, normalizedCell{row: i + 1, column: j + 1, before: cell, after: after})
+			}
+		}
+	}
+
+	return normalized, changed
+}
+
+// reportNormalizedCells prints the header cells that were normalized, and how many data cells were
+// with a few examples. headerRow is the index of the header row. The text before normalization is
+// quoted with its non ASCII characters escaped, which shows the characters that look the same.
+func reportNormalizedCells(worksheet *model.Worksheet, changed []normalizedCell, headerRow int) {
+	var data []normalizedCell
+	for _, cell := range changed {
+		if cell.row <= headerRow {
+			// The preamble before the header isn't loaded
+			continue
+		}
+
+		if cell.row != headerRow+1 {
+			data = append(data, cell)
+			continue
+		}
+
+		fmt.Printf("Worksheet %s header %s%d %+q was normalized to '%s'\n",
+			worksheet.Source(), model.ColumnName(cell.column), cell.row, cell.before, cell.after)
+	}
+
+	if len(data) == 0 {
+		return
+	}
+
+	fmt.Printf("Worksheet %s has %d cell(s) whose text was normalized, eg", worksheet.Source(), len(data))
+	for i, cell := range data {
+		if i == maxNormalizedExamples {
+			break
+		}
+
+		separator := ","
+		if i == 0 {
+			separator = ""
+		}
+		fmt.Printf("%s %s%d %+q to '%s'", separator, model.ColumnName(cell.column), cell.row, cell.before, cell.after)
+	}
+	fmt.Println()
+}
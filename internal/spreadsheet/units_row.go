@@ -0,0 +1,76 @@
+package spreadsheet
+
+/*
+ * units_row reads the units of the attributes from the row under the header, for templates that put them
+ * there rather than in the header:
+ *
+ *   |sample |p:Temperature |p:Time |s:Hardness |
+ *   |       |C             |h      |HV         |
+ *   |S1     |400           |2      |102        |
+ *
+ * With --units-row this is loaded as if the header were |sample|p:Temperature(C)|p:Time(h)|s:Hardness(HV)|
+ * and the units row is never read as a sample. A unit can also be written in parenthesis or brackets, eg
+ * (C) or [C]. A column whose header already gives a unit must have the same unit, or none, in the units
+ * row. Units under the sample, parent and file columns are ignored.
+ */
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// mergeUnitsRow returns the header row with the units from the units row added to its attribute headers.
+// unitsRowIndex is the row number of the units row in the worksheet.
+func mergeUnitsRow(worksheet *model.Worksheet, header, units []string, unitsRowIndex int, hasParent bool) ([]string, error) {
+	merged := append([]string{}, header...)
+	for i, cell := range units {
+		column := i + 1
+		unit := unitFromUnitsCell(cell)
+		if unit == "" || column == 1 || (column == 2 && hasParent) {
+			continue
+		}
+
+		if i >= len(header) || strings.TrimSpace(header[i]) == "" {
+			cellWarningf(worksheet, unitsRowIndex, column, "Worksheet %s row %d column %s has unit '%s' but the column has no header, it has been ignored\n",
+				worksheet.Source(), unitsRowIndex, model.ColumnName(column), unit)
+			continue
+		}
+
+		headerCell := strings.TrimSpace(header[i])
+		if columnAttributeTypeFromKeyword(headerCell) == FileAttributeColumn {
+			cellWarningf(worksheet, unitsRowIndex, column, "Worksheet %s row %d column %s has unit '%s' but is a file column, it has been ignored\n",
+				worksheet.Source(), unitsRowIndex, model.ColumnName(column), unit)
+			continue
+		}
+
+		// The unit goes before the type the header declares, if it declares one, see declared_types.go
+		headerCell, declaredType := splitDeclaredType(headerCell)
+		if _, headerUnit := cell2NameAndUnit(headerCell); headerUnit != "" {
+			if headerUnit != unit {
+				return nil, cellErrorf(worksheet, unitsRowIndex, column, "worksheet %s row %d column %s has unit '%s' but the header %s gives the unit '%s'",
+					worksheet.Source(), unitsRowIndex, model.ColumnName(column), unit, headerCell, headerUnit)
+			}
+			continue
+		}
+
+		merged[i] = fmt.Sprintf("%s(%s)", headerCell, unit)
+		if declaredType != "" {
+			merged[i] += ":" + declaredType
+		}
+	}
+
+	return merged, nil
+}
+
+// unitFromUnitsCell returns the unit in a cell of the units row, without any parenthesis or brackets
+// around it.
+func unitFromUnitsCell(cell string) string {
+	unit := strings.TrimSpace(cell)
+	if len(unit) >= 2 && ((unit[0] == '(' && unit[len(unit)-1] == ')') || (unit[0] == '[' && unit[len(unit)-1] == ']')) {
+		unit = strings.TrimSpace(unit[1 : len(unit)-1])
+	}
+
+	return unit
+}
@@ -0,0 +1,288 @@
+package spreadsheet
+
+/*
+ * validator_plugins runs validators, programs that check the loaded worksheets against rules of a lab's own,
+ * such as every SEM row must reference a .tif file, so a facility can ship its rules without changing mcetl.
+ * check runs each program given with --validator, which can be repeated. A validator is the path of any
+ * executable, run without arguments, or a JSON array of the program and its arguments, eg
+ * '["python3", "sem rules.py"]'. It is run as it is given rather than through a shell, so neither is split
+ * on spaces. The program is given the worksheets as JSON on its stdin:
+ *
+ *   {"version": 1, "worksheets": [{"name": "SEM", "file": "campaign.xlsx", "process_type": "SEM",
+ *     "header_row": 1, "headers": ["sample", "parent", "p:Voltage(kV)", "file:Image"],
+ *     "samples": [{"name": "S1", "parent": "Polish", "row": 2,
+ *       "process_attributes": [{"name": "Voltage", "unit": "kV", "value": 20, "cell": "C2"}],
+ *       "attributes": [],
+ *       "files": [{"path": "sem/S1.png", "column": 4, "direction": "out"}]}]}]}
+ *
+ * and writes what it finds to its stdout, one JSON object per line:
+ *
+ *   {"severity": "error", "worksheet": "SEM", "row": 2, "column": 4, "message": "S1 has no .tif image"}
+ *
+ * severity is "error", the default, or "warning". worksheet, row and column are optional, column is 1
+ * based. A validator that exits with an error without reporting one has failed, and what it wrote to its
+ * stderr is reported. A validator that runs longer than the timeout (--validator-timeout) is stopped and has
+ * failed.
+ */
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/materials-commons/mcetl/internal/console"
+	"github.com/materials-commons/mcetl/internal/spreadsheet/model"
+)
+
+// ValidatorProtocolVersion is the version of the JSON given to validators, see above.
+const ValidatorProtocolVersion = 1
+
+// DefaultValidatorTimeout is how long a validator can run before it is stopped.
+const DefaultValidatorTimeout = time.Minute
+
+// The severities of a validator's findings
+const (
+	ValidatorError   = "error"
+	ValidatorWarning = "warning"
+)
+
+type validatorInput struct {
+	Version    int                   `json:"version"`
+	Worksheets []*validatorWorksheet `json:"worksheets"`
+}
+
+type validatorWorksheet struct {
+	Name        string             `json:"name"`
+	File        string             `json:"file,omitempty"`
+	ProcessType string             `json:"process_type"`
+	HeaderRow   int                `json:"header_row"`
+	Headers     []string           `json:"headers"`
+	Samples     []*validatorSample `json:"samples"`
+}
+
+type validatorSample struct {
+	Name              string                `json:"name"`
+	Parent            string                `json:"parent,omitempty"`
+	Row               int                   `json:"row"`
+	Cohort            string                `json:"cohort,omitempty"`
+	ProcessAttributes []*validatorAttribute `json:"process_attributes"`
+	Attributes        []*validatorAttribute `json:"attributes"`
+	Files             []*validatorFile      `json:"files"`
+}
+
+type validatorFile struct {
+	Path      string `json:"path"`
+	Column    int    `json:"column"`
+	Direction string `json:"direction,omitempty"`
+}
+
+type validatorAttribute struct {
+	Name  string      `json:"name"`
+	Unit  string      `json:"unit,omitempty"`
+	Value interface{} `json:"value"`
+	Cell  string      `json:"cell,omitempty"`
+}
+
+// ValidatorFinding is a problem a validator found.
+type ValidatorFinding struct {
+	Severity  string `json:"severity"`
+	Worksheet string `json:"worksheet"`
+	Row       int    `json:"row"`
+	Column    int    `json:"column"`
+	Message   string `json:"message"`
+}
+
+// ParseValidator returns the program and arguments of a validator as it is given to --validator, see above.
+func ParseValidator(validator string) ([]string, error) {
+	validator = strings.TrimSpace(validator)
+	if !strings.HasPrefix(validator, "[") {
+		if validator == "" {
+			return nil, fmt.Errorf("validator is blank")
+		}
+		return []string{validator}, nil
+	}
+
+	var argv []string
+	if err := json.Unmarshal([]byte(validator), &argv); err != nil {
+		return nil, fmt.Errorf("validator %s isn't a JSON array of the program and its arguments: %s", validator, err)
+	}
+	if len(argv) == 0 || argv[0] == "" {
+		return nil, fmt.Errorf("validator %s has no program", validator)
+	}
+
+	return argv, nil
+}
+
+// RunValidators runs each of the validators, each a program and its arguments, against the worksheets,
+// stopping any that run longer than timeout. The warnings they find are printed and the errors are returned.
+func RunValidators(validators [][]string, timeout time.Duration, worksheets []*model.Worksheet) error {
+	input, err := json.Marshal(newValidatorInput(worksheets))
+	if err != nil {
+		return err
+	}
+
+	var errs *multierror.Error
+	for _, argv := range validators {
+		name := strings.Join(argv, " ")
+		findings, err := runValidator(name, argv, timeout, input)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+			continue
+		}
+
+		for _, finding := range findings {
+			if finding.Severity == ValidatorWarning {
+				console.Warningf("validator %s: %s\n", name, finding)
+			} else {
+				errs = multierror.Append(errs, fmt.Errorf("validator %s: %s", name, finding))
+			}
+		}
+	}
+
+	return errs.ErrorOrNil()
+}
+
+// runValidator runs a validator, giving it input, and returns its findings. name is how the validator is
+// described in errors.
+func runValidator(name string, argv []string, timeout time.Duration, input []byte) ([]*ValidatorFinding, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("validator %s didn't finish within %s, it has been stopped", name, timeout)
+	}
+	if _, ok := runErr.(*exec.ExitError); runErr != nil && !ok {
+		return nil, fmt.Errorf("unable to run validator %s: %s", name, runErr)
+	}
+
+	var (
+		findings []*ValidatorFinding
+		hasError bool
+	)
+	scanner := bufio.NewScanner(&stdout)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var finding ValidatorFinding
+		if err := json.Unmarshal([]byte(line), &finding); err != nil || finding.Message == "" {
+			return nil, fmt.Errorf("validator %s wrote '%s', which isn't a finding", name, line)
+		}
+
+		switch finding.Severity {
+		case "":
+			finding.Severity = ValidatorError
+		case ValidatorError, ValidatorWarning:
+		default:
+			return nil, fmt.Errorf("validator %s wrote a finding with unknown severity '%s', use '%s' or '%s'",
+				name, finding.Severity, ValidatorError, ValidatorWarning)
+		}
+
+		hasError = hasError || finding.Severity == ValidatorError
+		findings = append(findings, &finding)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the findings of validator %s: %s", name, err)
+	}
+
+	if runErr != nil && !hasError {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = runErr.Error()
+		}
+		return nil, fmt.Errorf("validator %s failed: %s", name, message)
+	}
+
+	return findings, nil
+}
+
+// String describes the finding with where it is in the spreadsheet.
+func (f *ValidatorFinding) String() string {
+	var where []string
+	if f.Worksheet != "" {
+		where = append(where, "worksheet "+f.Worksheet)
+	}
+	if f.Row != 0 {
+		where = append(where, fmt.Sprintf("row %d", f.Row))
+	}
+	if f.Column != 0 {
+		where = append(where, "column "+model.ColumnName(f.Column))
+	}
+
+	if len(where) == 0 {
+		return f.Message
+	}
+
+	return strings.Join(where, " ") + ": " + f.Message
+}
+
+// newValidatorInput returns the worksheets as they are given to validators.
+func newValidatorInput(worksheets []*model.Worksheet) *validatorInput {
+	input := &validatorInput{Version: ValidatorProtocolVersion, Worksheets: []*validatorWorksheet{}}
+	for _, worksheet := range worksheets {
+		vw := &validatorWorksheet{
+			Name:        worksheet.Name,
+			File:        worksheet.File,
+			ProcessType: worksheet.ProcessType(),
+			HeaderRow:   worksheet.HeaderRow,
+			Headers:     worksheet.Headers,
+			Samples:     []*validatorSample{},
+		}
+
+		for _, sample := range worksheet.Samples {
+			vs := &validatorSample{
+				Name:              sample.Name,
+				Parent:            sample.Parent,
+				Row:               sample.Row,
+				Cohort:            sample.Cohort,
+				ProcessAttributes: validatorAttributes(sample.ProcessAttrs, sample.Row),
+				Attributes:        validatorAttributes(sample.Attributes, sample.Row),
+				Files:             []*validatorFile{},
+			}
+			for _, file := range sample.Files {
+				vs.Files = append(vs.Files, &validatorFile{Path: file.Path, Column: file.Column, Direction: file.Direction})
+			}
+			vw.Samples = append(vw.Samples, vs)
+		}
+
+		input.Worksheets = append(input.Worksheets, vw)
+	}
+
+	return input
+}
+
+// validatorAttributes returns the attributes of a sample on row as they are given to validators.
+func validatorAttributes(attrs []*model.Attribute, row int) []*validatorAttribute {
+	vattrs := []*validatorAttribute{}
+	for _, attr := range attrs {
+		va := &validatorAttribute{Name: attr.Name, Unit: attr.Unit}
+		if attr.Value != nil {
+			va.Value = attr.Value["value"]
+		}
+		switch {
+		case attr.Source != nil:
+			va.Cell = attr.Source.Cell()
+		case attr.Column != 0:
+			va.Cell = (&model.CellRef{Row: row, Column: attr.Column}).Cell()
+		}
+		vattrs = append(vattrs, va)
+	}
+
+	return vattrs
+}
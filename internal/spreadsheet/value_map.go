@@ -0,0 +1,62 @@
+package spreadsheet
+
+/*
+ * value_map replaces the categorical values instruments write, such as pass/fail or low/med/high, with the
+ * values they stand for, so that the same result is stored the same way whatever the instrument called it.
+ * The map for a column is given in the schema:
+ *
+ *   columns:
+ *     - name: Inspection
+ *       value_map:
+ *         pass: true
+ *         fail: false
+ *     - name: Grade
+ *       value_map: {low: 1, med: 2, high: 3}
+ *
+ * Cells are matched against the map case insensitively, ignoring leading and trailing spaces. Cells that
+ * aren't in the map are converted as they would be without it. Keys that YAML reads as something other
+ * than a string, such as yes, no, on and off, must be quoted.
+ */
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validateValueMap checks that the column's value map only maps to strings, numbers and booleans, and
+// that no two of its keys only differ by case.
+func (c *ColumnSchema) validateValueMap() error {
+	seen := make(map[string]string)
+	for key, value := range c.ValueMap {
+		switch value.(type) {
+		case string, int, float64, bool:
+		default:
+			return fmt.Errorf("column '%s' value_map maps '%s' to %v, it can only map to a string, number or boolean", c.Name, key, value)
+		}
+
+		lower := strings.ToLower(strings.TrimSpace(key))
+		if other, ok := seen[lower]; ok {
+			return fmt.Errorf("column '%s' value_map has both '%s' and '%s'", c.Name, other, key)
+		}
+		seen[lower] = key
+	}
+
+	return nil
+}
+
+// mapValue returns the value the column's value map gives for the cell. It returns false if the column
+// has no value map or the cell isn't in it.
+func (c *ColumnSchema) mapValue(cell string) (interface{}, bool) {
+	if c == nil || len(c.ValueMap) == 0 {
+		return nil, false
+	}
+
+	cell = strings.TrimSpace(cell)
+	for key, value := range c.ValueMap {
+		if strings.EqualFold(strings.TrimSpace(key), cell) {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
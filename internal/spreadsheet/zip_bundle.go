@@ -0,0 +1,156 @@
+package spreadsheet
+
+/*
+ * zip_bundle loads the workbooks in a zip file, so a campaign made up of several workbooks can be
+ * shared and loaded as a single file, eg -f campaign.zip. The workbooks are read from the zip in
+ * memory and loaded as if each had been given with -f.
+ *
+ * If the zip contains a manifest.txt at its top level then it lists the workbooks to load, one per
+ * line, in the order they are loaded:
+ *
+ *   # Workbooks are loaded in this order
+ *   casting.xlsx
+ *   heat-treatment/ht.xlsx
+ *
+ * Blank lines and lines starting with # are ignored. Workbooks in the zip that aren't in the manifest
+ * aren't loaded. Without a manifest every workbook in the zip is loaded, in name order. CSV and TSV files in
+ * the zip are loaded as well as workbooks, see delimited_file.go.
+ */
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/360EntSecGroup-Skylar/excelize"
+	"github.com/materials-commons/mcetl/internal/console"
+)
+
+const zipManifestName = "manifest.txt"
+
+// isZipFile returns true if the path is a zip of workbooks rather than a workbook.
+func isZipFile(p string) bool {
+	return strings.EqualFold(filepath.Ext(p), ".zip")
+}
+
+// zippedWorkbooks returns the workbooks in the zip file at zipPath, in the order they are loaded.
+// Each workbook is named by the zip file and its path in the zip, eg campaign.zip/casting.xlsx.
+func zippedWorkbooks(zipPath string) ([]workbook, error) {
+	r, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open zip file %s: %s", zipPath, err)
+	}
+	defer r.Close()
+
+	var (
+		manifest *zip.File
+		names    []string
+		entries  = make(map[string]*zip.File)
+	)
+
+	for _, f := range r.File {
+		switch {
+		case f.FileInfo().IsDir() || isZipClutter(f.Name):
+			continue
+		case f.Name == zipManifestName:
+			manifest = f
+		case strings.EqualFold(path.Ext(f.Name), ".xlsx") || delimiterForFile(f.Name) != 0:
+			entries[f.Name] = f
+			names = append(names, f.Name)
+		}
+	}
+
+	if manifest != nil {
+		if names, err = readZipManifest(zipPath, manifest, entries); err != nil {
+			return nil, err
+		}
+	} else {
+		sort.Strings(names)
+	}
+
+	var workbooks []workbook
+	for _, name := range names {
+		contents, err := readZipEntry(entries[name])
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s from zip file %s: %s", name, zipPath, err)
+		}
+
+		workbooks = append(workbooks, workbook{
+			name:      filepath.Join(zipPath, name),
+			open:      func() (*excelize.File, error) { return excelize.OpenReader(bytes.NewReader(contents)) },
+			delimiter: delimiterForFile(name),
+			openText:  func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(contents)), nil },
+		})
+	}
+
+	if len(workbooks) == 0 {
+		return nil, fmt.Errorf("zip file %s doesn't contain any workbooks", zipPath)
+	}
+
+	return workbooks, nil
+}
+
+// readZipManifest returns the workbooks listed in the manifest. Every workbook listed must be in the zip.
+// The workbooks in the zip that aren't listed are reported, as leaving one out is usually a mistake.
+func readZipManifest(zipPath string, manifest *zip.File, entries map[string]*zip.File) ([]string, error) {
+	contents, err := readZipEntry(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s from zip file %s: %s", zipManifestName, zipPath, err)
+	}
+
+	var names []string
+	listed := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for line := 1; scanner.Scan(); line++ {
+		name := strings.TrimSpace(scanner.Text())
+		switch {
+		case name == "" || strings.HasPrefix(name, "#"):
+			continue
+		case entries[name] == nil:
+			return nil, fmt.Errorf("zip file %s %s line %d: %s isn't a workbook in the zip", zipPath, zipManifestName, line, name)
+		case listed[name]:
+			return nil, fmt.Errorf("zip file %s %s line %d: %s is listed more than once", zipPath, zipManifestName, line, name)
+		}
+
+		listed[name] = true
+		names = append(names, name)
+	}
+
+	var unlisted []string
+	for name := range entries {
+		if !listed[name] {
+			unlisted = append(unlisted, name)
+		}
+	}
+	sort.Strings(unlisted)
+
+	for _, name := range unlisted {
+		console.Warningf("%s in zip file %s isn't listed in its %s, it won't be loaded\n", name, zipPath, zipManifestName)
+	}
+
+	return names, nil
+}
+
+// isZipClutter returns true for the files that archivers and Excel leave in zips, such as the
+// __MACOSX resource forks and ~$ lock files, which look like workbooks but aren't.
+func isZipClutter(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(base, "~$") || strings.HasPrefix(base, "._")
+}
+
+func readZipEntry(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return ioutil.ReadAll(rc)
+}
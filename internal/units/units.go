@@ -0,0 +1,169 @@
+package units
+
+/*
+ * units provides conversions between the common units found in experimental spreadsheets. Every
+ * unit belongs to a dimension (time, temperature, length, ...) and each dimension has a base unit.
+ * Values are converted by first converting to the base unit and then to the target unit.
+ *
+ * Unit symbols are matched case sensitively, as their case is part of the unit: "ms" is milliseconds and
+ * "Ms" megaseconds, "mK" millikelvin and "MK" megakelvin. The spellings in common use are listed, such as
+ * "c" for Celsius. Units written as words, such as "hours" or "Celsius", are matched case insensitively.
+ */
+
+import (
+	"strings"
+)
+
+type unitDef struct {
+	// dimension the unit measures, only units with the same dimension can be converted
+	dimension string
+
+	// A value in this unit is converted to the base unit as value*scale + offset
+	scale  float64
+	offset float64
+}
+
+// baseUnits is the unit each dimension is normalized to
+var baseUnits = map[string]string{
+	"time":        "s",
+	"temperature": "K",
+	"length":      "m",
+	"mass":        "kg",
+	"pressure":    "Pa",
+	"force":       "N",
+	"energy":      "J",
+}
+
+// knownUnits are the unit symbols, matched case sensitively.
+var knownUnits = map[string]unitDef{
+	// time
+	"s":   {dimension: "time", scale: 1},
+	"ms":  {dimension: "time", scale: 1e-3},
+	"min": {dimension: "time", scale: 60},
+	"h":   {dimension: "time", scale: 3600},
+	"hr":  {dimension: "time", scale: 3600},
+	"hrs": {dimension: "time", scale: 3600},
+	"d":   {dimension: "time", scale: 86400},
+
+	// temperature, the lower case spellings are common in headers such as Temperature(c)
+	"K":    {dimension: "temperature", scale: 1},
+	"k":    {dimension: "temperature", scale: 1},
+	"C":    {dimension: "temperature", scale: 1, offset: 273.15},
+	"c":    {dimension: "temperature", scale: 1, offset: 273.15},
+	"°C":   {dimension: "temperature", scale: 1, offset: 273.15},
+	"degC": {dimension: "temperature", scale: 1, offset: 273.15},
+	"F":    {dimension: "temperature", scale: 5.0 / 9.0, offset: 273.15 - 32*5.0/9.0},
+	"f":    {dimension: "temperature", scale: 5.0 / 9.0, offset: 273.15 - 32*5.0/9.0},
+	"°F":   {dimension: "temperature", scale: 5.0 / 9.0, offset: 273.15 - 32*5.0/9.0},
+	"degF": {dimension: "temperature", scale: 5.0 / 9.0, offset: 273.15 - 32*5.0/9.0},
+
+	// length
+	"km": {dimension: "length", scale: 1e3},
+	"m":  {dimension: "length", scale: 1},
+	"cm": {dimension: "length", scale: 1e-2},
+	"mm": {dimension: "length", scale: 1e-3},
+	"um": {dimension: "length", scale: 1e-6},
+	"µm": {dimension: "length", scale: 1e-6},
+	"μm": {dimension: "length", scale: 1e-6}, // Greek mu, which the micro sign normalizes to
+	"nm": {dimension: "length", scale: 1e-9},
+	"Å":  {dimension: "length", scale: 1e-10},
+	"in": {dimension: "length", scale: 0.0254},
+	"ft": {dimension: "length", scale: 0.3048},
+
+	// mass
+	"kg": {dimension: "mass", scale: 1},
+	"g":  {dimension: "mass", scale: 1e-3},
+	"mg": {dimension: "mass", scale: 1e-6},
+	"ug": {dimension: "mass", scale: 1e-9},
+	"µg": {dimension: "mass", scale: 1e-9},
+	"μg": {dimension: "mass", scale: 1e-9},
+	"lb": {dimension: "mass", scale: 0.45359237},
+
+	// pressure and stress
+	"Pa":   {dimension: "pressure", scale: 1},
+	"kPa":  {dimension: "pressure", scale: 1e3},
+	"MPa":  {dimension: "pressure", scale: 1e6},
+	"GPa":  {dimension: "pressure", scale: 1e9},
+	"bar":  {dimension: "pressure", scale: 1e5},
+	"mbar": {dimension: "pressure", scale: 1e2},
+	"atm":  {dimension: "pressure", scale: 101325},
+	"psi":  {dimension: "pressure", scale: 6894.757293168},
+	"ksi":  {dimension: "pressure", scale: 6894757.293168},
+	"Torr": {dimension: "pressure", scale: 101325.0 / 760.0},
+	"torr": {dimension: "pressure", scale: 101325.0 / 760.0},
+
+	// force
+	"N":  {dimension: "force", scale: 1},
+	"kN": {dimension: "force", scale: 1e3},
+
+	// energy
+	"J":  {dimension: "energy", scale: 1},
+	"kJ": {dimension: "energy", scale: 1e3},
+	"eV": {dimension: "energy", scale: 1.602176634e-19},
+}
+
+// unitWords are the units written as words, by their lower case spelling.
+var unitWords = map[string]string{
+	"sec":        "s",
+	"secs":       "s",
+	"second":     "s",
+	"seconds":    "s",
+	"mins":       "min",
+	"minute":     "min",
+	"minutes":    "min",
+	"hour":       "h",
+	"hours":      "h",
+	"day":        "d",
+	"days":       "d",
+	"kelvin":     "K",
+	"celsius":    "C",
+	"fahrenheit": "F",
+	"micron":     "um",
+	"microns":    "um",
+	"angstrom":   "Å",
+	"inch":       "in",
+}
+
+func lookup(unit string) (unitDef, bool) {
+	unit = strings.TrimSpace(unit)
+	if def, ok := knownUnits[unit]; ok {
+		return def, true
+	}
+
+	def, ok := knownUnits[unitWords[strings.ToLower(unit)]]
+	return def, ok
+}
+
+// IsKnown returns true if unit is one of the units that can be converted.
+func IsKnown(unit string) bool {
+	_, ok := lookup(unit)
+	return ok
+}
+
+// Normalize converts value in unit to the base unit for the unit's dimension, for example
+// 60 min becomes 3600 s, and 400 C becomes 673.15 K. It returns false if the unit isn't known.
+func Normalize(value float64, unit string) (float64, string, bool) {
+	def, ok := lookup(unit)
+	if !ok {
+		return value, unit, false
+	}
+
+	return value*def.scale + def.offset, baseUnits[def.dimension], true
+}
+
+// Convert converts value from one unit to another. It returns false if either unit isn't known
+// or the units measure different dimensions.
+func Convert(value float64, from, to string) (float64, bool) {
+	fromDef, ok := lookup(from)
+	if !ok {
+		return value, false
+	}
+
+	toDef, ok := lookup(to)
+	if !ok || toDef.dimension != fromDef.dimension {
+		return value, false
+	}
+
+	base := value*fromDef.scale + fromDef.offset
+	return (base - toDef.offset) / toDef.scale, true
+}